@@ -2,7 +2,6 @@ package main
 
 import (
 	"context"
-	"fmt"
 	"github.com/go-chi/chi/middleware"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/cors"
@@ -10,30 +9,60 @@ import (
 	"log"
 	"mis_kursach_backend/configs"
 	"mis_kursach_backend/internal/db"
+	"mis_kursach_backend/internal/models"
+	"mis_kursach_backend/internal/services"
 	"net/http"
+	"time"
 )
 
 func main() {
 	// Инициализация конфига
 	configs.InitConfig()
 	config := configs.NewConfig()
+	// Все суммы Money хранятся и считаются в базовой валюте отеля
+	models.DefaultCurrency = config.HotelConfig.BaseCurrency
+	// Ключи шифрования паспортных данных гостей
+	passports, err := services.NewPassportCipher(config.CryptoConfig)
+	if err != nil {
+		log.Fatalf("Invalid passport encryption config: %v", err)
+	}
+	db.Passports = passports
+	// Письма гостям с кодами входа в кабинет и менеджерам о просроченных жалобах
+	db.Mail = services.NewMailer(config.SMTPConfig)
+	db.ManagerEmail = config.HotelConfig.ManagerEmail
+	// Сканы документов гостей хранятся на локальном диске
+	attachments, err := services.NewLocalStorage(config.StorageConfig.AttachmentsDir)
+	if err != nil {
+		log.Fatalf("Unable to open attachment storage: %v", err)
+	}
+	db.Attachments = attachments
 
 	// Подключение к БД
-	dsn := fmt.Sprintf("user=%s password=%s host=%s port=%s dbname=%s sslmode=disable",
-		config.DBConfig.Username, config.DBConfig.Password, config.DBConfig.Host, config.DBConfig.Port,
-		config.DBConfig.Name)
-	dbpool, err := pgxpool.New(context.Background(), dsn)
+	dbpool, err := pgxpool.New(context.Background(), config.DBConfig.DSN())
 	if err != nil {
 		log.Fatalf("Unable to connect to database: %v", err)
 	}
 	// После завершения работы программы закрываем соединение с БД
 	defer dbpool.Close()
+	// Суммы в БД уже записаны в базовой валюте, сменить её в конфигурации нельзя
+	if err = db.EnsureBaseCurrency(dbpool, models.DefaultCurrency); err != nil {
+		log.Fatalf("Base currency check failed: %v", err)
+	}
+	// Ночной аудит закрывает прошедшие бизнес-дни, после чего составляется список уборки на новый день
+	go runDaily(config.HotelConfig.NightAuditTime, func() {
+		db.RunPendingNightAudits(dbpool)
+		db.RunHousekeepingTasks(dbpool)
+	})
+	// Баллы лояльности с истёкшим сроком сгорают раз в сутки
+	go runDaily(config.HotelConfig.NightAuditTime, func() { db.RunLoyaltyExpiry(dbpool) })
+	// Просроченные по SLA жалобы передаются менеджерам
+	go runEvery(services.ComplaintEscalationInterval, func() { db.RunComplaintEscalation(dbpool) })
 	r := chi.NewRouter()
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"http://localhost:3000"},
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
 		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-		ExposedHeaders:   []string{"Link"},
+		ExposedHeaders:   []string{"Link", "Content-Disposition", "X-Skipped-Notices"},
 		AllowCredentials: true,
 		MaxAge:           300,
 	}))
@@ -45,3 +74,29 @@ func main() {
 		log.Fatalf("Unable to start server: %v", err)
 	}
 }
+
+// runDaily вызывает job каждый день в указанное время (ЧЧ:ММ, локальное время сервера)
+func runDaily(at string, job func()) {
+	clock, err := time.Parse("15:04", at)
+	if err != nil {
+		log.Printf("Invalid daily job time %q, job disabled: %v", at, err)
+		return
+	}
+	for {
+		now := time.Now()
+		next := time.Date(now.Year(), now.Month(), now.Day(), clock.Hour(), clock.Minute(), 0, 0, now.Location())
+		if !next.After(now) {
+			next = next.AddDate(0, 0, 1)
+		}
+		time.Sleep(time.Until(next))
+		job()
+	}
+}
+
+// runEvery вызывает job с указанным интервалом, первый раз — сразу после запуска
+func runEvery(interval time.Duration, job func()) {
+	for {
+		job()
+		time.Sleep(interval)
+	}
+}
@@ -0,0 +1,33 @@
+// Команда перешифровывает номера паспортов гостей активным ключом из PASSPORT_KEYS.
+// Порядок ротации: добавить новый ключ первым в PASSPORT_KEYS, оставив старый,
+// перезапустить сервер, выполнить команду, после чего старый ключ можно удалить.
+package main
+
+import (
+	"context"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"log"
+	"mis_kursach_backend/configs"
+	"mis_kursach_backend/internal/db"
+	"mis_kursach_backend/internal/services"
+)
+
+func main() {
+	configs.InitConfig()
+	config := configs.NewConfig()
+	passports, err := services.NewPassportCipher(config.CryptoConfig)
+	if err != nil {
+		log.Fatalf("Invalid passport encryption config: %v", err)
+	}
+	db.Passports = passports
+
+	dbpool, err := pgxpool.New(context.Background(), config.DBConfig.DSN())
+	if err != nil {
+		log.Fatalf("Unable to connect to database: %v", err)
+	}
+	defer dbpool.Close()
+
+	if _, err := db.RotatePassportKeys(dbpool); err != nil {
+		log.Fatalf("Passport key rotation stopped: %v", err)
+	}
+}
@@ -7,8 +7,12 @@ import (
 )
 
 type Config struct {
-	DBConfig  DBConfig
-	JWTConfig JWTConfig
+	DBConfig      DBConfig
+	JWTConfig     JWTConfig
+	HotelConfig   HotelConfig
+	CryptoConfig  CryptoConfig
+	SMTPConfig    SMTPConfig
+	StorageConfig StorageConfig
 }
 
 func NewConfig() *Config {
@@ -23,7 +27,33 @@ func NewConfig() *Config {
 		JWTConfig: JWTConfig{
 			Secret: os.Getenv("JWT_SECRET"),
 		},
+		HotelConfig: HotelConfig{
+			BaseCurrency:   getEnvDefault("HOTEL_BASE_CURRENCY", "RUB"),
+			NightAuditTime: getEnvDefault("HOTEL_NIGHT_AUDIT_TIME", "03:00"),
+			ManagerEmail:   os.Getenv("HOTEL_MANAGER_EMAIL"),
+		},
+		CryptoConfig: CryptoConfig{
+			PassportKeys:     os.Getenv("PASSPORT_KEYS"),
+			PassportIndexKey: os.Getenv("PASSPORT_INDEX_KEY"),
+		},
+		SMTPConfig: SMTPConfig{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     getEnvDefault("SMTP_PORT", "587"),
+			Username: os.Getenv("SMTP_USER"),
+			Password: os.Getenv("SMTP_PASS"),
+			From:     os.Getenv("SMTP_FROM"),
+		},
+		StorageConfig: StorageConfig{
+			AttachmentsDir: getEnvDefault("ATTACHMENTS_DIR", "attachments"),
+		},
+	}
+}
+
+func getEnvDefault(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
 	}
+	return fallback
 }
 
 func InitConfig() {
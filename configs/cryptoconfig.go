@@ -0,0 +1,9 @@
+package configs
+
+// CryptoConfig holds the keys used to encrypt personal data
+type CryptoConfig struct {
+	// PassportKeys — ключи шифрования паспортов вида "id:base64,id:base64", первый ключ активный
+	PassportKeys string
+	// PassportIndexKey — ключ HMAC для поиска по точному номеру паспорта
+	PassportIndexKey string
+}
@@ -1,5 +1,7 @@
 package configs
 
+import "fmt"
+
 type DBConfig struct {
 	Username string
 	Password string
@@ -7,3 +9,8 @@ type DBConfig struct {
 	Port     string
 	Name     string
 }
+
+func (c DBConfig) DSN() string {
+	return fmt.Sprintf("user=%s password=%s host=%s port=%s dbname=%s sslmode=disable",
+		c.Username, c.Password, c.Host, c.Port, c.Name)
+}
@@ -0,0 +1,9 @@
+package configs
+
+type HotelConfig struct {
+	BaseCurrency string
+	// NightAuditTime — время ежедневного закрытия дня в формате ЧЧ:ММ
+	NightAuditTime string
+	// ManagerEmail — адрес, на который приходят уведомления о просроченных жалобах
+	ManagerEmail string
+}
@@ -0,0 +1,11 @@
+package configs
+
+// SMTPConfig holds the mail server used to send guests their access codes
+type SMTPConfig struct {
+	// Host — пустой адрес отключает отправку писем
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
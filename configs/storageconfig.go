@@ -0,0 +1,7 @@
+package configs
+
+// StorageConfig holds where uploaded guest documents are kept
+type StorageConfig struct {
+	// AttachmentsDir — каталог для файлов вложений на локальном диске
+	AttachmentsDir string
+}
@@ -0,0 +1,149 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"io"
+	"log"
+	"mis_kursach_backend/internal/models"
+	"mis_kursach_backend/internal/services"
+	"time"
+)
+
+// Attachments хранит файлы вложений; задаётся при запуске из конфигурации
+var Attachments services.Storage
+
+// ErrAttachmentNotFound возвращается, если вложения нет
+var ErrAttachmentNotFound = errors.New("attachment not found")
+
+const attachmentColumns = `id, guest_id, booking_id, kind, file_name, content_type, size_bytes, sha256,
+	storage_key, uploaded_by, uploaded_at`
+
+// CreateAttachment сохраняет файл в хранилище и записывает его метаданные
+func CreateAttachment(dbpool *pgxpool.Pool, a models.Attachment, data []byte, userID *int) (int, error) {
+	key, err := services.NewStorageKey()
+	if err != nil {
+		return 0, err
+	}
+	sum := sha256.Sum256(data)
+	if err = Attachments.Put(key, bytes.NewReader(data)); err != nil {
+		return 0, err
+	}
+	ctx := context.Background()
+	tx, err := dbpool.Begin(ctx)
+	if err != nil {
+		Attachments.Delete(key)
+		return 0, fmt.Errorf("error beginning transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var id int
+	err = tx.QueryRow(ctx,
+		`INSERT INTO ATTACHMENTS (guest_id, booking_id, kind, file_name, content_type, size_bytes, sha256,
+			storage_key, uploaded_by, uploaded_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id`,
+		a.GuestID, a.BookingID, a.Kind, a.FileName, a.ContentType, len(data), hex.EncodeToString(sum[:]),
+		key, userID, time.Now()).Scan(&id)
+	if err == nil {
+		err = writeAudit(ctx, tx, "attachment", id, "upload", map[string]any{
+			"guest_id":   a.GuestID,
+			"booking_id": a.BookingID,
+			"kind":       a.Kind,
+			"file_name":  a.FileName,
+		}, userID)
+	}
+	if err == nil {
+		err = tx.Commit(ctx)
+	}
+	if err != nil {
+		// без записи в базе файл никому не виден, поэтому сразу удаляем его
+		if delErr := Attachments.Delete(key); delErr != nil {
+			log.Printf("Error deleting orphaned attachment file %s: %v", key, delErr)
+		}
+		log.Printf("error saving attachment: %v", err)
+		return 0, fmt.Errorf("error saving attachment: %v", err)
+	}
+	return id, nil
+}
+
+// GetAttachments возвращает вложения гостя и/или бронирования только тех видов, что доступны роли
+func GetAttachments(dbpool *pgxpool.Pool, guestID, bookingID *int, kinds []string) ([]models.Attachment, error) {
+	var attachments []models.Attachment
+	err := pgxscan.Select(context.Background(), dbpool, &attachments,
+		`SELECT `+attachmentColumns+` FROM ATTACHMENTS
+		WHERE ($1::int IS NULL OR guest_id = $1)
+			AND ($2::int IS NULL OR booking_id = $2)
+			AND kind = ANY($3)
+		ORDER BY uploaded_at DESC, id DESC`, guestID, bookingID, kinds)
+	if err != nil {
+		return nil, fmt.Errorf("error getting attachments: %v", err)
+	}
+	return attachments, nil
+}
+
+func GetAttachment(dbpool *pgxpool.Pool, id int) (models.Attachment, error) {
+	var a models.Attachment
+	err := pgxscan.Get(context.Background(), dbpool, &a,
+		`SELECT `+attachmentColumns+` FROM ATTACHMENTS WHERE id = $1`, id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return a, ErrAttachmentNotFound
+	}
+	if err != nil {
+		return a, fmt.Errorf("error getting attachment: %v", err)
+	}
+	return a, nil
+}
+
+// OpenAttachment открывает файл на чтение; каждое скачивание записывается в журнал
+func OpenAttachment(dbpool *pgxpool.Pool, a models.Attachment, userID *int) (io.ReadCloser, error) {
+	err := writeAudit(context.Background(), dbpool, "attachment", a.ID, "download", map[string]any{"kind": a.Kind}, userID)
+	if err != nil {
+		return nil, err
+	}
+	return Attachments.Open(a.StorageKey)
+}
+
+func DeleteAttachment(dbpool *pgxpool.Pool, id int, userID *int) error {
+	ctx := context.Background()
+	tx, err := dbpool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("error beginning transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var key, kind, fileName string
+	err = tx.QueryRow(ctx,
+		`DELETE FROM ATTACHMENTS WHERE id = $1 RETURNING storage_key, kind, file_name`, id).Scan(&key, &kind, &fileName)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrAttachmentNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("error deleting attachment: %v", err)
+	}
+	err = writeAudit(ctx, tx, "attachment", id, "delete", map[string]any{"kind": kind, "file_name": fileName}, userID)
+	if err != nil {
+		return err
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("error committing attachment deletion: %v", err)
+	}
+	deleteAttachmentFiles([]string{key})
+	return nil
+}
+
+// deleteAttachmentFiles удаляет файлы после того, как их записи удалены из базы.
+// Ошибка только пишется в лог: без записи в базе файл уже недоступен.
+func deleteAttachmentFiles(keys []string) {
+	for _, key := range keys {
+		if err := Attachments.Delete(key); err != nil {
+			log.Printf("Error deleting attachment file %s: %v", key, err)
+		}
+	}
+}
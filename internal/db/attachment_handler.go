@@ -0,0 +1,203 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"io"
+	"log"
+	"mis_kursach_backend/internal/models"
+	"mis_kursach_backend/internal/services"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// optionalIntParam читает необязательный числовой параметр запроса
+func optionalIntParam(r *http.Request, name string) (*int, error) {
+	s := r.URL.Query().Get(name)
+	if s == "" {
+		s = r.FormValue(name)
+	}
+	if s == "" {
+		return nil, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v <= 0 {
+		return nil, fmt.Errorf("invalid %s", name)
+	}
+	return &v, nil
+}
+
+// UploadAttachment принимает multipart-форму: file, kind и guest_id и/или booking_id
+func (p *PsHandler) UploadAttachment(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	// запас на поля формы сверх самого файла
+	r.Body = http.MaxBytesReader(w, r.Body, services.AttachmentMaxSize+1<<20)
+	if err := r.ParseMultipartForm(services.AttachmentMaxSize); err != nil {
+		http.Error(w, `{"error": "file is too large or the form is invalid"}`, http.StatusRequestEntityTooLarge)
+		log.Printf("Error parsing attachment upload: %v", err)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	kind := r.FormValue("kind")
+	if !services.IsAttachmentKind(kind) {
+		http.Error(w, `{"error": "kind must be passport_scan, registration_card or other"}`, http.StatusBadRequest)
+		return
+	}
+	if !services.CanAccessAttachment(roleFromRequest(r), kind) {
+		http.Error(w, `{"error": "forbidden"}`, http.StatusForbidden)
+		return
+	}
+	guestID, err := optionalIntParam(r, "guest_id")
+	if err != nil {
+		http.Error(w, `{"error": "invalid guest_id"}`, http.StatusBadRequest)
+		return
+	}
+	bookingID, err := optionalIntParam(r, "booking_id")
+	if err != nil {
+		http.Error(w, `{"error": "invalid booking_id"}`, http.StatusBadRequest)
+		return
+	}
+	if guestID == nil && bookingID == nil {
+		http.Error(w, `{"error": "guest_id or booking_id is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, `{"error": "file is required"}`, http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, services.AttachmentMaxSize+1))
+	if err != nil {
+		http.Error(w, `{"error": "failed to read file"}`, http.StatusBadRequest)
+		log.Printf("Error reading attachment: %v", err)
+		return
+	}
+	if len(data) == 0 {
+		http.Error(w, `{"error": "file is empty"}`, http.StatusBadRequest)
+		return
+	}
+	if len(data) > services.AttachmentMaxSize {
+		http.Error(w, `{"error": "file is too large"}`, http.StatusRequestEntityTooLarge)
+		return
+	}
+	contentType, ok := services.SniffAttachment(data)
+	if !ok {
+		http.Error(w, `{"error": "only JPEG, PNG, WebP and PDF files are accepted"}`, http.StatusUnsupportedMediaType)
+		return
+	}
+
+	id, err := CreateAttachment(p.dbpool, models.Attachment{
+		GuestID:     guestID,
+		BookingID:   bookingID,
+		Kind:        kind,
+		FileName:    services.SanitizeFileName(header.Filename),
+		ContentType: contentType,
+	}, data, userIDFromRequest(r))
+	if err != nil {
+		http.Error(w, `{"error": "failed to save attachment"}`, http.StatusBadRequest)
+		log.Printf("Error saving attachment: %v", err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]any{"message": "success", "id": id})
+}
+
+func (p *PsHandler) GetAttachments(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	guestID, err := optionalIntParam(r, "guest_id")
+	if err != nil {
+		http.Error(w, `{"error": "invalid guest_id"}`, http.StatusBadRequest)
+		return
+	}
+	bookingID, err := optionalIntParam(r, "booking_id")
+	if err != nil {
+		http.Error(w, `{"error": "invalid booking_id"}`, http.StatusBadRequest)
+		return
+	}
+	if guestID == nil && bookingID == nil {
+		http.Error(w, `{"error": "guest_id or booking_id is required"}`, http.StatusBadRequest)
+		return
+	}
+	attachments, err := GetAttachments(p.dbpool, guestID, bookingID, services.AccessibleAttachmentKinds(roleFromRequest(r)))
+	if err != nil {
+		http.Error(w, `{"error": "failed to get attachments"}`, http.StatusInternalServerError)
+		log.Printf("Error getting attachments: %v", err)
+		return
+	}
+	if attachments == nil {
+		attachments = []models.Attachment{}
+	}
+	if err := json.NewEncoder(w).Encode(attachments); err != nil {
+		http.Error(w, `{"error": "failed to encode response"}`, http.StatusInternalServerError)
+		log.Printf("Error encoding attachments: %v", err)
+	}
+}
+
+func (p *PsHandler) DownloadAttachment(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		http.Error(w, `{"error": "invalid id"}`, http.StatusBadRequest)
+		return
+	}
+	a, err := GetAttachment(p.dbpool, id)
+	// вложение, недоступное роли, выглядит так же, как несуществующее
+	if errors.Is(err, ErrAttachmentNotFound) || (err == nil && !services.CanAccessAttachment(roleFromRequest(r), a.Kind)) {
+		w.Header().Set("Content-Type", "application/json")
+		http.Error(w, `{"error": "attachment not found"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		http.Error(w, `{"error": "failed to get attachment"}`, http.StatusInternalServerError)
+		log.Printf("Error getting attachment: %v", err)
+		return
+	}
+	content, err := OpenAttachment(p.dbpool, a, userIDFromRequest(r))
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		http.Error(w, `{"error": "failed to open attachment"}`, http.StatusInternalServerError)
+		log.Printf("Error opening attachment %d: %v", id, err)
+		return
+	}
+	defer content.Close()
+	w.Header().Set("Content-Type", a.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(a.SizeBytes, 10))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"; filename*=UTF-8''%s`,
+		a.FileName, url.PathEscape(a.FileName)))
+	if _, err := io.Copy(w, content); err != nil {
+		log.Printf("Error sending attachment %d: %v", id, err)
+	}
+}
+
+func (p *PsHandler) DeleteAttachment(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if !services.CanDeleteAttachment(roleFromRequest(r)) {
+		http.Error(w, `{"error": "forbidden"}`, http.StatusForbidden)
+		return
+	}
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, `{"error": "invalid id"}`, http.StatusBadRequest)
+		return
+	}
+	err = DeleteAttachment(p.dbpool, id, userIDFromRequest(r))
+	if errors.Is(err, ErrAttachmentNotFound) {
+		http.Error(w, `{"error": "attachment not found"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error": "failed to delete attachment"}`, http.StatusInternalServerError)
+		log.Printf("Error deleting attachment: %v", err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "success"})
+}
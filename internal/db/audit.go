@@ -0,0 +1,45 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"mis_kursach_backend/internal/models"
+)
+
+// execer — общий интерфейс пула и транзакции, чтобы запись в журнал шла в той же транзакции, что и действие
+type execer interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+// writeAudit записывает действие в журнал; details сериализуется в JSON
+func writeAudit(ctx context.Context, q execer, entity string, entityID int, action string, details any, userID *int) error {
+	data, err := json.Marshal(details)
+	if err != nil {
+		return fmt.Errorf("error encoding audit details: %v", err)
+	}
+	_, err = q.Exec(ctx,
+		`INSERT INTO AUDIT_LOG (entity, entity_id, action, details, user_id) VALUES ($1, $2, $3, $4, $5)`,
+		entity, entityID, action, data, userID)
+	if err != nil {
+		return fmt.Errorf("error writing audit log: %v", err)
+	}
+	return nil
+}
+
+// GetAuditLog возвращает журнал по сущности; entityID = 0 — по всем записям сущности
+func GetAuditLog(dbpool *pgxpool.Pool, entity string, entityID int) ([]models.AuditEntry, error) {
+	var entries []models.AuditEntry
+	err := pgxscan.Select(context.Background(), dbpool, &entries,
+		`SELECT id, entity, entity_id, action, details, user_id, created_at
+		FROM AUDIT_LOG
+		WHERE entity = $1 AND ($2 = 0 OR entity_id = $2)
+		ORDER BY created_at DESC, id DESC`, entity, entityID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting audit log: %v", err)
+	}
+	return entries, nil
+}
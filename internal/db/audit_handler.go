@@ -0,0 +1,40 @@
+package db
+
+import (
+	"encoding/json"
+	"log"
+	"mis_kursach_backend/internal/models"
+	"net/http"
+	"strconv"
+)
+
+func (p *PsHandler) GetAuditLog(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	entity := r.URL.Query().Get("entity")
+	if entity == "" {
+		http.Error(w, `{"error": "entity is required"}`, http.StatusBadRequest)
+		return
+	}
+	entityID := 0
+	if s := r.URL.Query().Get("entity_id"); s != "" {
+		var err error
+		entityID, err = strconv.Atoi(s)
+		if err != nil {
+			http.Error(w, `{"error": "invalid entity_id"}`, http.StatusBadRequest)
+			return
+		}
+	}
+	entries, err := GetAuditLog(p.dbpool, entity, entityID)
+	if err != nil {
+		http.Error(w, `{"error": "failed to get audit log"}`, http.StatusInternalServerError)
+		log.Printf("Error getting audit log: %v", err)
+		return
+	}
+	if entries == nil {
+		entries = []models.AuditEntry{}
+	}
+	if err := json.NewEncoder(w).Encode(entries); err != nil {
+		http.Error(w, `{"error": "failed to encode response"}`, http.StatusInternalServerError)
+		log.Printf("Error encoding audit log: %v", err)
+	}
+}
@@ -0,0 +1,134 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"log"
+	"mis_kursach_backend/internal/models"
+	"time"
+)
+
+// ErrGuestBlacklisted возвращается, если гость в чёрном списке с запретом бронирования
+var ErrGuestBlacklisted = errors.New("guest is blacklisted")
+
+const blacklistColumns = `bl.id, bl.guest_id, g.name AS guest_name, bl.reason, bl.severity,
+	bl.created_by, bl.created_at, bl.expires_at, bl.lifted_at, bl.lifted_by`
+
+// запись действует, пока не снята и не истекла
+const blacklistActive = `bl.lifted_at IS NULL AND (bl.expires_at IS NULL OR bl.expires_at >= CURRENT_DATE)`
+
+func GetBlacklist(dbpool *pgxpool.Pool, activeOnly bool) ([]models.BlacklistEntry, error) {
+	var entries []models.BlacklistEntry
+	err := pgxscan.Select(context.Background(), dbpool, &entries,
+		`SELECT `+blacklistColumns+`
+		FROM GUEST_BLACKLIST bl
+		JOIN GUESTS g ON g.id = bl.guest_id
+		WHERE NOT $1 OR (`+blacklistActive+`)
+		ORDER BY bl.created_at DESC, bl.id DESC`, activeOnly)
+	if err != nil {
+		return nil, fmt.Errorf("error getting blacklist: %v", err)
+	}
+	return entries, nil
+}
+
+func AddToBlacklist(dbpool *pgxpool.Pool, in models.CreateBlacklistInput, userID *int) (int, error) {
+	var expiresAt *time.Time
+	if in.ExpiresAt != nil && *in.ExpiresAt != "" {
+		t, err := time.Parse("2006-01-02", *in.ExpiresAt)
+		if err != nil {
+			return 0, fmt.Errorf("couldn't parse expires_at: %v", err)
+		}
+		expiresAt = &t
+	}
+	ctx := context.Background()
+	tx, err := dbpool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("error beginning transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var id int
+	err = tx.QueryRow(ctx,
+		`INSERT INTO GUEST_BLACKLIST (guest_id, reason, severity, created_by, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`,
+		in.GuestID, in.Reason, in.Severity, userID, time.Now(), expiresAt).Scan(&id)
+	if err != nil {
+		log.Printf("error inserting blacklist entry: %v", err)
+		return 0, fmt.Errorf("error inserting blacklist entry: %v", err)
+	}
+	err = writeAudit(ctx, tx, "guest", in.GuestID, "blacklist", map[string]any{
+		"entry_id": id,
+		"reason":   in.Reason,
+		"severity": in.Severity,
+	}, userID)
+	if err != nil {
+		return 0, err
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("error committing blacklist entry: %v", err)
+	}
+	return id, nil
+}
+
+// LiftBlacklistEntry снимает запись досрочно; сама запись остаётся в истории
+func LiftBlacklistEntry(dbpool *pgxpool.Pool, id int, userID *int) error {
+	ctx := context.Background()
+	tx, err := dbpool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("error beginning transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var guestID int
+	err = tx.QueryRow(ctx,
+		`UPDATE GUEST_BLACKLIST SET lifted_at = $1, lifted_by = $2
+		WHERE id = $3 AND lifted_at IS NULL
+		RETURNING guest_id`, time.Now(), userID, id).Scan(&guestID)
+	if err != nil {
+		return fmt.Errorf("blacklist entry %d not found or already lifted: %v", id, err)
+	}
+	err = writeAudit(ctx, tx, "guest", guestID, "blacklist_lift", map[string]any{"entry_id": id}, userID)
+	if err != nil {
+		return err
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("error committing blacklist lift: %v", err)
+	}
+	return nil
+}
+
+// CheckBlacklist ищет действующие записи о гостях с тем же паспортом или телефоном.
+// Телефон сравнивается в формате E.164.
+func CheckBlacklist(dbpool *pgxpool.Pool, passport, phone string) ([]models.BlacklistMatch, error) {
+	var passportHash string
+	if passport != "" {
+		passportHash = Passports.BlindIndex(passport)
+	}
+	var matches []models.BlacklistMatch
+	err := pgxscan.Select(context.Background(), dbpool, &matches,
+		`SELECT `+blacklistColumns+`,
+				($1 <> '' AND (g.passport_hash = $1 OR g.passport_no = $2)) AS passport_match,
+				($3 <> '' AND g.phone_number = $3) AS phone_match
+		FROM GUEST_BLACKLIST bl
+		JOIN GUESTS g ON g.id = bl.guest_id
+		WHERE `+blacklistActive+`
+			AND (($1 <> '' AND (g.passport_hash = $1 OR g.passport_no = $2)) OR ($3 <> '' AND g.phone_number = $3))
+		ORDER BY bl.severity = 'block' DESC, bl.created_at DESC`, passportHash, passport, phone)
+	if err != nil {
+		return nil, fmt.Errorf("error checking blacklist: %v", err)
+	}
+	return matches, nil
+}
+
+// blocksBooking сообщает, есть ли среди совпадений запрет бронирования
+func blocksBooking(matches []models.BlacklistMatch) bool {
+	for _, m := range matches {
+		if m.Severity == models.BlacklistBlock {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,109 @@
+package db
+
+import (
+	"encoding/json"
+	"github.com/go-chi/chi/v5"
+	"log"
+	"mis_kursach_backend/internal/models"
+	"mis_kursach_backend/internal/services"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+func (p *PsHandler) GetBlacklist(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	activeOnly := r.URL.Query().Get("active") == "true"
+	entries, err := GetBlacklist(p.dbpool, activeOnly)
+	if err != nil {
+		http.Error(w, `{"error": "failed to get blacklist"}`, http.StatusInternalServerError)
+		log.Printf("Error getting blacklist: %v", err)
+		return
+	}
+	if entries == nil {
+		entries = []models.BlacklistEntry{}
+	}
+	if err := json.NewEncoder(w).Encode(entries); err != nil {
+		http.Error(w, `{"error": "failed to encode response"}`, http.StatusInternalServerError)
+		log.Printf("Error encoding blacklist: %v", err)
+	}
+}
+
+func (p *PsHandler) AddToBlacklist(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var in models.CreateBlacklistInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, `{"error": "invalid request body"}`, http.StatusBadRequest)
+		log.Printf("Error decoding blacklist input: %v", err)
+		return
+	}
+	defer r.Body.Close()
+	in.Reason = strings.TrimSpace(in.Reason)
+	errs := models.FieldErrors{}
+	if in.GuestID <= 0 {
+		errs["guest_id"] = "guest_id is required"
+	}
+	if in.Reason == "" {
+		errs["reason"] = "reason is required"
+	}
+	if in.Severity != models.BlacklistBlock && in.Severity != models.BlacklistWarn {
+		errs["severity"] = "severity must be block or warn"
+	}
+	if len(errs) > 0 {
+		writeFieldErrors(w, errs)
+		return
+	}
+	id, err := AddToBlacklist(p.dbpool, in, userIDFromRequest(r))
+	if err != nil {
+		http.Error(w, `{"error": "failed to add guest to blacklist"}`, http.StatusBadRequest)
+		log.Printf("Error adding to blacklist: %v", err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]any{"message": "success", "id": id})
+}
+
+func (p *PsHandler) LiftBlacklistEntry(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, `{"error": "invalid id"}`, http.StatusBadRequest)
+		return
+	}
+	if err := LiftBlacklistEntry(p.dbpool, id, userIDFromRequest(r)); err != nil {
+		http.Error(w, `{"error": "failed to lift blacklist entry"}`, http.StatusNotFound)
+		log.Printf("Error lifting blacklist entry: %v", err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "success"})
+}
+
+// CheckBlacklist проверяет гостя до оформления брони, по паспорту и/или телефону
+func (p *PsHandler) CheckBlacklist(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	passport := services.NormalizePassport(r.URL.Query().Get("passport"))
+	phone := r.URL.Query().Get("phone")
+	if phone != "" {
+		normalized, ok := services.NormalizePhoneE164(phone)
+		if !ok {
+			http.Error(w, `{"error": "invalid phone"}`, http.StatusBadRequest)
+			return
+		}
+		phone = normalized
+	}
+	if passport == "" && phone == "" {
+		http.Error(w, `{"error": "passport or phone is required"}`, http.StatusBadRequest)
+		return
+	}
+	matches, err := CheckBlacklist(p.dbpool, passport, phone)
+	if err != nil {
+		http.Error(w, `{"error": "failed to check blacklist"}`, http.StatusInternalServerError)
+		log.Printf("Error checking blacklist: %v", err)
+		return
+	}
+	if matches == nil {
+		matches = []models.BlacklistMatch{}
+	}
+	json.NewEncoder(w).Encode(map[string]any{"blocked": blocksBooking(matches), "matches": matches})
+}
@@ -0,0 +1,428 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"log"
+	"mis_kursach_backend/internal/models"
+	"mis_kursach_backend/internal/services"
+	"time"
+)
+
+// ErrCreditLimit возвращается, если начисление превысит кредитный лимит организации
+var ErrCreditLimit = errors.New("company credit limit exceeded")
+
+// ErrBookingInvoiced возвращается при удалении бронирования, которое уже вошло в счёт организации
+var ErrBookingInvoiced = errors.New("booking is on a company invoice")
+
+const companyColumns = `id, name, tax_id, email, phone_number, address,
+	credit_limit, payment_terms_days, active, created_at`
+
+func GetCompanies(dbpool *pgxpool.Pool) ([]models.Company, error) {
+	var companies []models.Company
+	err := pgxscan.Select(context.Background(), dbpool, &companies,
+		`SELECT `+companyColumns+` FROM COMPANIES ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("error getting companies: %v", err)
+	}
+	return companies, nil
+}
+
+func getCompany(dbpool *pgxpool.Pool, id int) (models.Company, error) {
+	var company models.Company
+	err := pgxscan.Get(context.Background(), dbpool, &company,
+		`SELECT `+companyColumns+` FROM COMPANIES WHERE id = $1`, id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return company, fmt.Errorf("company with ID %d not found", id)
+		}
+		return company, fmt.Errorf("error getting company: %v", err)
+	}
+	return company, nil
+}
+
+func GetCompanyAccount(dbpool *pgxpool.Pool, id int) (models.CompanyAccount, error) {
+	var account models.CompanyAccount
+	var err error
+	account.Company, err = getCompany(dbpool, id)
+	if err != nil {
+		return account, err
+	}
+	err = pgxscan.Select(context.Background(), dbpool, &account.Rates,
+		`SELECT cr.company_id, cr.category_code, rc.name AS category_name, cr.price
+		FROM COMPANY_RATES cr
+		JOIN ROOM_CATEGORIES rc ON rc.code = cr.category_code
+		WHERE cr.company_id = $1
+		ORDER BY cr.category_code`, id)
+	if err != nil {
+		return account, fmt.Errorf("error getting company rates: %v", err)
+	}
+	account.Outstanding, err = companyOutstanding(context.Background(), dbpool, id)
+	if err != nil {
+		return account, err
+	}
+	account.AvailableCredit = account.CreditLimit.Sub(account.Outstanding)
+	return account, nil
+}
+
+func CreateCompany(dbpool *pgxpool.Pool, c models.CompanyInput) (int, error) {
+	var id int
+	terms := 30
+	if c.PaymentTermsDays != nil {
+		terms = *c.PaymentTermsDays
+	}
+	active := true
+	if c.Active != nil {
+		active = *c.Active
+	}
+	err := pgxscan.Get(context.Background(), dbpool, &id,
+		`INSERT INTO COMPANIES (name, tax_id, email, phone_number, address, credit_limit, payment_terms_days, active)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`,
+		c.Name, c.TaxID, c.Email, c.PhoneNumber, c.Address, c.CreditLimit, terms, active)
+	if err != nil {
+		return 0, fmt.Errorf("error inserting company: %v", err)
+	}
+	return id, nil
+}
+
+func UpdateCompany(dbpool *pgxpool.Pool, id int, c models.CompanyInput) error {
+	result, err := dbpool.Exec(context.Background(),
+		`UPDATE COMPANIES
+		SET name = $1, tax_id = $2, email = $3, phone_number = $4, address = $5, credit_limit = $6,
+			payment_terms_days = COALESCE($7, payment_terms_days), active = COALESCE($8, active)
+		WHERE id = $9`,
+		c.Name, c.TaxID, c.Email, c.PhoneNumber, c.Address, c.CreditLimit, c.PaymentTermsDays, c.Active, id)
+	if err != nil {
+		return fmt.Errorf("error updating company: %v", err)
+	}
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("company with ID %d not found", id)
+	}
+	return nil
+}
+
+// SetCompanyRate задаёт договорную цену за ночь для категории номеров
+func SetCompanyRate(dbpool *pgxpool.Pool, rate models.CompanyRate) error {
+	_, err := dbpool.Exec(context.Background(),
+		`INSERT INTO COMPANY_RATES (company_id, category_code, price)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (company_id, category_code) DO UPDATE SET price = EXCLUDED.price`,
+		rate.CompanyID, rate.CategoryCode, rate.Price)
+	if err != nil {
+		log.Printf("error setting company rate: %v", err)
+		return fmt.Errorf("error setting company rate: %v", err)
+	}
+	return nil
+}
+
+// getContractRate возвращает договорную цену организации для категории, если она задана
+func getContractRate(dbpool *pgxpool.Pool, companyID, categoryCode int) (*models.Money, error) {
+	var price models.Money
+	err := dbpool.QueryRow(context.Background(),
+		`SELECT price FROM COMPANY_RATES WHERE company_id = $1 AND category_code = $2`,
+		companyID, categoryCode).Scan(&price)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error getting contract rate: %v", err)
+	}
+	return &price, nil
+}
+
+// uninvoicedStays — проживания организации, ещё не вошедшие ни в один счёт.
+// Если until задан, берутся только выехавшие не позже этой даты.
+func uninvoicedStays(ctx context.Context, q pgxscan.Querier, companyID int, until *time.Time) ([]models.CompanyInvoiceLine, error) {
+	var stays []models.CompanyInvoiceLine
+	err := pgxscan.Select(ctx, q, &stays,
+		`SELECT
+				b.id AS booking_id,
+				MIN(g.name) AS guest_name,
+				MIN(gib.room) AS room,
+				b.start_date,
+				b.end_date,
+				b.check_out,
+				b.total_sum + COALESCE(fc.extras_sum, 0) - COALESCE(p.paid_sum, 0) - COALESCE(k.credit_sum, 0) AS amount
+			FROM BOOKINGS b
+			LEFT JOIN (
+				SELECT booking_id, SUM(amount) AS extras_sum
+				FROM folio_charges
+				WHERE voided_at IS NULL
+				GROUP BY booking_id
+			) fc ON fc.booking_id = b.id
+			LEFT JOIN (
+				SELECT booking_id, SUM(amount) AS paid_sum
+				FROM payments
+				WHERE status_code = 2
+				GROUP BY booking_id
+			) p ON p.booking_id = b.id
+			LEFT JOIN (
+				SELECT booking_id, SUM(amount) AS credit_sum
+				FROM complaint_compensations
+				WHERE kind <> 'refund'
+				GROUP BY booking_id
+			) k ON k.booking_id = b.id
+			JOIN GUESTS_IN_BOOKINGS gib ON gib.booking_id = b.id
+			JOIN GUESTS g ON g.id = gib.guest_id
+			WHERE b.company_id = $1
+				AND NOT EXISTS (SELECT 1 FROM COMPANY_INVOICE_LINES l WHERE l.booking_id = b.id)
+				AND ($2::date IS NULL OR (b.check_out IS NOT NULL AND b.check_out::date <= $2))
+			GROUP BY b.id, fc.extras_sum, p.paid_sum, k.credit_sum
+			ORDER BY b.start_date, b.id`, companyID, until)
+	if err != nil {
+		return nil, fmt.Errorf("error getting company stays: %v", err)
+	}
+	return stays, nil
+}
+
+// companyOutstanding — долг организации: неоплаченные счета и проживания, по которым счёт ещё не выставлен
+func companyOutstanding(ctx context.Context, q pgxscan.Querier, companyID int) (models.Money, error) {
+	var outstanding models.Money
+	err := pgxscan.Get(ctx, q, &outstanding,
+		`SELECT COALESCE(SUM(amount - paid_amount), 0) FROM COMPANY_INVOICES WHERE company_id = $1`,
+		companyID)
+	if err != nil {
+		return outstanding, fmt.Errorf("error getting company invoices total: %v", err)
+	}
+	stays, err := uninvoicedStays(ctx, q, companyID, nil)
+	if err != nil {
+		return outstanding, err
+	}
+	for _, s := range stays {
+		outstanding = outstanding.Add(s.Amount)
+	}
+	return outstanding, nil
+}
+
+// ensureCreditAvailable проверяет, что новое начисление не выведет организацию за кредитный лимит.
+// Строка организации блокируется до конца транзакции, чтобы параллельные начисления проверялись по очереди
+func ensureCreditAvailable(ctx context.Context, tx pgx.Tx, companyID int, amount models.Money) error {
+	var company models.Company
+	err := pgxscan.Get(ctx, tx, &company,
+		`SELECT `+companyColumns+` FROM COMPANIES WHERE id = $1 FOR UPDATE`, companyID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("company with ID %d not found", companyID)
+		}
+		return fmt.Errorf("error locking company: %v", err)
+	}
+	if !company.Active {
+		return fmt.Errorf("company %s is not active", company.Name)
+	}
+	outstanding, err := companyOutstanding(ctx, tx, companyID)
+	if err != nil {
+		return err
+	}
+	if outstanding.Add(amount).Cmp(company.CreditLimit) > 0 {
+		return fmt.Errorf("%w: limit %s, outstanding %s, charge %s",
+			ErrCreditLimit, company.CreditLimit, outstanding, amount)
+	}
+	return nil
+}
+
+// bookingCompanyID возвращает организацию, которой выставляется счёт за бронирование
+func bookingCompanyID(dbpool *pgxpool.Pool, bookingID int) (*int, error) {
+	var companyID *int
+	err := dbpool.QueryRow(context.Background(),
+		`SELECT company_id FROM BOOKINGS WHERE id = $1`, bookingID).Scan(&companyID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting booking company: %v", err)
+	}
+	return companyID, nil
+}
+
+// CreateCompanyInvoice выставляет сводный счёт за месяц: в него входят все выехавшие
+// до конца месяца проживания организации, не попавшие в прошлые счета
+func CreateCompanyInvoice(dbpool *pgxpool.Pool, in models.CreateCompanyInvoiceInput, userID *int) (models.CompanyInvoice, error) {
+	var invoice models.CompanyInvoice
+	periodStart, err := time.Parse("2006-01", in.Month)
+	if err != nil {
+		return invoice, fmt.Errorf("couldn't parse month: %v", err)
+	}
+	periodEnd := periodStart.AddDate(0, 1, -1)
+	company, err := getCompany(dbpool, in.CompanyID)
+	if err != nil {
+		return invoice, err
+	}
+	stays, err := uninvoicedStays(context.Background(), dbpool, company.ID, &periodEnd)
+	if err != nil {
+		return invoice, err
+	}
+	if len(stays) == 0 {
+		return invoice, fmt.Errorf("no stays to invoice for company %d in %s", company.ID, in.Month)
+	}
+	issueDate := dateOnly(time.Now())
+	invoice = models.CompanyInvoice{
+		CompanyID:   company.ID,
+		CompanyName: company.Name,
+		PeriodStart: periodStart,
+		PeriodEnd:   periodEnd,
+		IssueDate:   issueDate,
+		DueDate:     issueDate.AddDate(0, 0, company.PaymentTermsDays),
+		Status:      models.CompanyInvoiceIssued,
+		Lines:       stays,
+	}
+	for _, s := range stays {
+		invoice.Amount = invoice.Amount.Add(s.Amount)
+	}
+
+	ctx := context.Background()
+	tx, err := dbpool.Begin(ctx)
+	if err != nil {
+		return invoice, fmt.Errorf("error beginning transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+	err = tx.QueryRow(ctx,
+		`INSERT INTO COMPANY_INVOICES (company_id, period_start, period_end, issue_date, due_date, amount, status, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`,
+		invoice.CompanyID, invoice.PeriodStart, invoice.PeriodEnd, invoice.IssueDate, invoice.DueDate,
+		invoice.Amount, invoice.Status, userID).Scan(&invoice.ID)
+	if err != nil {
+		log.Printf("error inserting company invoice: %v", err)
+		return invoice, fmt.Errorf("error inserting company invoice: %v", err)
+	}
+	for _, s := range stays {
+		_, err = tx.Exec(ctx,
+			`INSERT INTO COMPANY_INVOICE_LINES (invoice_id, booking_id, amount) VALUES ($1, $2, $3)`,
+			invoice.ID, s.BookingID, s.Amount)
+		if err != nil {
+			log.Printf("error inserting company invoice line: %v", err)
+			return invoice, fmt.Errorf("error inserting company invoice line: %v", err)
+		}
+	}
+	// долг по каждому проживанию переходит с гостя на организацию
+	for _, s := range stays {
+		if !s.Amount.IsPositive() {
+			continue
+		}
+		bookingID := s.BookingID
+		_, err = PostTransaction(ctx, tx, models.LedgerTransaction{
+			Kind:      models.LedgerKindCompanyInvoice,
+			BookingID: &bookingID,
+			Memo:      fmt.Sprintf("Счёт организации %d за %s", invoice.ID, in.Month),
+			CreatedBy: userID,
+			Entries:   services.CompanyInvoiceEntries(s.Amount),
+		})
+		if err != nil {
+			return invoice, err
+		}
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return invoice, fmt.Errorf("error committing company invoice: %v", err)
+	}
+	return invoice, nil
+}
+
+func GetCompanyInvoices(dbpool *pgxpool.Pool, companyID int) ([]models.CompanyInvoice, error) {
+	var invoices []models.CompanyInvoice
+	err := pgxscan.Select(context.Background(), dbpool, &invoices,
+		`SELECT ci.id, ci.company_id, c.name AS company_name, ci.period_start, ci.period_end,
+				ci.issue_date, ci.due_date, ci.amount, ci.paid_amount, ci.status
+		FROM COMPANY_INVOICES ci
+		JOIN COMPANIES c ON c.id = ci.company_id
+		WHERE $1 = 0 OR ci.company_id = $1
+		ORDER BY ci.issue_date DESC, ci.id DESC`, companyID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting company invoices: %v", err)
+	}
+	return invoices, nil
+}
+
+func GetCompanyInvoice(dbpool *pgxpool.Pool, id int) (models.CompanyInvoice, error) {
+	var invoice models.CompanyInvoice
+	err := pgxscan.Get(context.Background(), dbpool, &invoice,
+		`SELECT ci.id, ci.company_id, c.name AS company_name, ci.period_start, ci.period_end,
+				ci.issue_date, ci.due_date, ci.amount, ci.paid_amount, ci.status
+		FROM COMPANY_INVOICES ci
+		JOIN COMPANIES c ON c.id = ci.company_id
+		WHERE ci.id = $1`, id)
+	if err != nil {
+		return invoice, fmt.Errorf("error getting company invoice: %v", err)
+	}
+	err = pgxscan.Select(context.Background(), dbpool, &invoice.Lines,
+		`SELECT l.booking_id, MIN(g.name) AS guest_name, MIN(gib.room) AS room,
+				b.start_date, b.end_date, b.check_out, l.amount
+		FROM COMPANY_INVOICE_LINES l
+		JOIN BOOKINGS b ON b.id = l.booking_id
+		JOIN GUESTS_IN_BOOKINGS gib ON gib.booking_id = b.id
+		JOIN GUESTS g ON g.id = gib.guest_id
+		WHERE l.invoice_id = $1
+		GROUP BY l.booking_id, b.start_date, b.end_date, b.check_out, l.amount
+		ORDER BY b.start_date, l.booking_id`, id)
+	if err != nil {
+		return invoice, fmt.Errorf("error getting company invoice lines: %v", err)
+	}
+	return invoice, nil
+}
+
+// PayCompanyInvoice принимает оплату по счёту организации, в том числе частичную
+func PayCompanyInvoice(dbpool *pgxpool.Pool, in models.PayCompanyInvoiceInput, userID *int) error {
+	var account string
+	err := dbpool.QueryRow(context.Background(),
+		`SELECT ledger_account FROM PAYMENT_METHODS WHERE code = $1`, in.MethodCode).Scan(&account)
+	if err != nil {
+		return fmt.Errorf("error getting payment method: %v", err)
+	}
+
+	ctx := context.Background()
+	tx, err := dbpool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("error beginning transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+	var companyID int
+	err = tx.QueryRow(ctx,
+		`UPDATE COMPANY_INVOICES
+		SET paid_amount = paid_amount + $1,
+			status = CASE WHEN paid_amount + $1 >= amount THEN $3 ELSE status END
+		WHERE id = $2 AND paid_amount + $1 <= amount
+		RETURNING company_id`,
+		in.Amount, in.InvoiceID, models.CompanyInvoicePaid).Scan(&companyID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("invoice with ID %d not found or payment exceeds the amount due", in.InvoiceID)
+		}
+		log.Printf("error updating company invoice: %v", err)
+		return fmt.Errorf("error updating company invoice: %v", err)
+	}
+	_, err = tx.Exec(ctx,
+		`INSERT INTO COMPANY_INVOICE_PAYMENTS (invoice_id, amount, method_code, paid_at, created_by)
+		VALUES ($1, $2, $3, $4, $5)`,
+		in.InvoiceID, in.Amount, in.MethodCode, time.Now(), userID)
+	if err != nil {
+		log.Printf("error inserting company invoice payment: %v", err)
+		return fmt.Errorf("error inserting company invoice payment: %v", err)
+	}
+	_, err = PostTransaction(ctx, tx, models.LedgerTransaction{
+		Kind:      models.LedgerKindCompanyPayment,
+		Memo:      fmt.Sprintf("Оплата счёта организации %d", in.InvoiceID),
+		CreatedBy: userID,
+		Entries:   services.CompanyPaymentEntries(account, in.Amount),
+	})
+	if err != nil {
+		return err
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("error committing company invoice payment: %v", err)
+	}
+	return nil
+}
+
+// GetCompanyAging — дебиторская задолженность организаций по срокам просрочки на дату asOf
+func GetCompanyAging(dbpool *pgxpool.Pool, asOf time.Time) ([]models.CompanyAging, error) {
+	var invoices []models.OutstandingInvoice
+	err := pgxscan.Select(context.Background(), dbpool, &invoices,
+		`SELECT ci.company_id, c.name AS company_name, ci.due_date, ci.amount - ci.paid_amount AS outstanding
+		FROM COMPANY_INVOICES ci
+		JOIN COMPANIES c ON c.id = ci.company_id
+		WHERE ci.status = $1 AND ci.issue_date <= $2
+		ORDER BY c.name, ci.company_id, ci.due_date`, models.CompanyInvoiceIssued, asOf)
+	if err != nil {
+		return nil, fmt.Errorf("error getting outstanding company invoices: %v", err)
+	}
+	return services.AgeInvoices(invoices, asOf), nil
+}
@@ -0,0 +1,258 @@
+package db
+
+import (
+	"encoding/json"
+	"github.com/go-chi/chi/v5"
+	"log"
+	"mis_kursach_backend/internal/models"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+func validateCompany(c models.CompanyInput) string {
+	if c.Name == "" || c.TaxID == "" {
+		return "name and tax_id are required"
+	}
+	if c.CreditLimit.IsNegative() {
+		return "credit limit cannot be negative"
+	}
+	if c.PaymentTermsDays != nil && *c.PaymentTermsDays < 0 {
+		return "payment terms cannot be negative"
+	}
+	return ""
+}
+
+func (p *PsHandler) GetCompanies(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	companies, err := GetCompanies(p.dbpool)
+	if err != nil {
+		http.Error(w, `{"error": "failed to get companies"}`, http.StatusInternalServerError)
+		log.Printf("Error getting companies: %v", err)
+		return
+	}
+	if companies == nil {
+		companies = []models.Company{}
+	}
+	if err := json.NewEncoder(w).Encode(companies); err != nil {
+		http.Error(w, `{"error": "failed to encode response"}`, http.StatusInternalServerError)
+		log.Printf("Error encoding companies: %v", err)
+	}
+}
+
+func (p *PsHandler) GetCompanyByID(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, `{"error": "invalid id"}`, http.StatusBadRequest)
+		return
+	}
+	account, err := GetCompanyAccount(p.dbpool, id)
+	if err != nil {
+		http.Error(w, `{"error": "company not found"}`, http.StatusNotFound)
+		log.Printf("Error getting company: %v", err)
+		return
+	}
+	if account.Rates == nil {
+		account.Rates = []models.CompanyRate{}
+	}
+	if err := json.NewEncoder(w).Encode(account); err != nil {
+		http.Error(w, `{"error": "failed to encode response"}`, http.StatusInternalServerError)
+		log.Printf("Error encoding company: %v", err)
+	}
+}
+
+func (p *PsHandler) CreateCompany(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var c models.CompanyInput
+	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+		http.Error(w, `{"error": "invalid request body"}`, http.StatusBadRequest)
+		log.Printf("Error decoding company input: %v", err)
+		return
+	}
+	defer r.Body.Close()
+	if msg := validateCompany(c); msg != "" {
+		http.Error(w, `{"error": "`+msg+`"}`, http.StatusBadRequest)
+		return
+	}
+	id, err := CreateCompany(p.dbpool, c)
+	if err != nil {
+		http.Error(w, `{"error": "failed to create company"}`, http.StatusBadRequest)
+		log.Printf("Error creating company: %v", err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Company created successfully", "id": strconv.Itoa(id)})
+}
+
+func (p *PsHandler) UpdateCompany(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, `{"error": "invalid id"}`, http.StatusBadRequest)
+		return
+	}
+	var c models.CompanyInput
+	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+		http.Error(w, `{"error": "invalid request body"}`, http.StatusBadRequest)
+		log.Printf("Error decoding company input: %v", err)
+		return
+	}
+	defer r.Body.Close()
+	if msg := validateCompany(c); msg != "" {
+		http.Error(w, `{"error": "`+msg+`"}`, http.StatusBadRequest)
+		return
+	}
+	err = UpdateCompany(p.dbpool, id, c)
+	if err != nil {
+		http.Error(w, `{"error": "failed to update company"}`, http.StatusBadRequest)
+		log.Printf("Error updating company: %v", err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	response := make(map[string]string)
+	response["message"] = "success"
+	json.NewEncoder(w).Encode(response)
+}
+
+func (p *PsHandler) SetCompanyRate(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var rate models.CompanyRate
+	if err := json.NewDecoder(r.Body).Decode(&rate); err != nil {
+		http.Error(w, `{"error": "invalid request body"}`, http.StatusBadRequest)
+		log.Printf("Error decoding company rate: %v", err)
+		return
+	}
+	defer r.Body.Close()
+	if !rate.Price.IsPositive() {
+		http.Error(w, `{"error": "price must be positive"}`, http.StatusBadRequest)
+		return
+	}
+	err := SetCompanyRate(p.dbpool, rate)
+	if err != nil {
+		http.Error(w, `{"error": "failed to set company rate"}`, http.StatusBadRequest)
+		log.Printf("Error setting company rate: %v", err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	response := make(map[string]string)
+	response["message"] = "success"
+	json.NewEncoder(w).Encode(response)
+}
+
+func (p *PsHandler) CreateCompanyInvoice(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var in models.CreateCompanyInvoiceInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, `{"error": "invalid request body"}`, http.StatusBadRequest)
+		log.Printf("Error decoding company invoice input: %v", err)
+		return
+	}
+	defer r.Body.Close()
+	invoice, err := CreateCompanyInvoice(p.dbpool, in, userIDFromRequest(r))
+	if err != nil {
+		http.Error(w, `{"error": "failed to create company invoice"}`, http.StatusBadRequest)
+		log.Printf("Error creating company invoice: %v", err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(invoice); err != nil {
+		log.Printf("Error encoding company invoice: %v", err)
+	}
+}
+
+func (p *PsHandler) GetCompanyInvoices(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	companyID := 0
+	if s := r.URL.Query().Get("company_id"); s != "" {
+		var err error
+		companyID, err = strconv.Atoi(s)
+		if err != nil {
+			http.Error(w, `{"error": "invalid company_id"}`, http.StatusBadRequest)
+			return
+		}
+	}
+	invoices, err := GetCompanyInvoices(p.dbpool, companyID)
+	if err != nil {
+		http.Error(w, `{"error": "failed to get company invoices"}`, http.StatusInternalServerError)
+		log.Printf("Error getting company invoices: %v", err)
+		return
+	}
+	if invoices == nil {
+		invoices = []models.CompanyInvoice{}
+	}
+	if err := json.NewEncoder(w).Encode(invoices); err != nil {
+		http.Error(w, `{"error": "failed to encode response"}`, http.StatusInternalServerError)
+		log.Printf("Error encoding company invoices: %v", err)
+	}
+}
+
+func (p *PsHandler) GetCompanyInvoice(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, `{"error": "invalid id"}`, http.StatusBadRequest)
+		return
+	}
+	invoice, err := GetCompanyInvoice(p.dbpool, id)
+	if err != nil {
+		http.Error(w, `{"error": "invoice not found"}`, http.StatusNotFound)
+		log.Printf("Error getting company invoice: %v", err)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(invoice); err != nil {
+		http.Error(w, `{"error": "failed to encode response"}`, http.StatusInternalServerError)
+		log.Printf("Error encoding company invoice: %v", err)
+	}
+}
+
+func (p *PsHandler) PayCompanyInvoice(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var in models.PayCompanyInvoiceInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, `{"error": "invalid request body"}`, http.StatusBadRequest)
+		log.Printf("Error decoding company invoice payment: %v", err)
+		return
+	}
+	defer r.Body.Close()
+	if !in.Amount.IsPositive() {
+		http.Error(w, `{"error": "amount must be positive"}`, http.StatusBadRequest)
+		return
+	}
+	err := PayCompanyInvoice(p.dbpool, in, userIDFromRequest(r))
+	if err != nil {
+		http.Error(w, `{"error": "failed to pay company invoice"}`, http.StatusBadRequest)
+		log.Printf("Error paying company invoice: %v", err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	response := make(map[string]string)
+	response["message"] = "success"
+	json.NewEncoder(w).Encode(response)
+}
+
+func (p *PsHandler) GetCompanyAging(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	asOf := dateOnly(time.Now())
+	if s := r.URL.Query().Get("as_of"); s != "" {
+		var err error
+		asOf, err = time.Parse("2006-01-02", s)
+		if err != nil {
+			http.Error(w, `{"error": "invalid as_of date"}`, http.StatusBadRequest)
+			return
+		}
+	}
+	aging, err := GetCompanyAging(p.dbpool, asOf)
+	if err != nil {
+		http.Error(w, `{"error": "failed to get company aging"}`, http.StatusInternalServerError)
+		log.Printf("Error getting company aging: %v", err)
+		return
+	}
+	if aging == nil {
+		aging = []models.CompanyAging{}
+	}
+	if err := json.NewEncoder(w).Encode(aging); err != nil {
+		http.Error(w, `{"error": "failed to encode response"}`, http.StatusInternalServerError)
+		log.Printf("Error encoding company aging: %v", err)
+	}
+}
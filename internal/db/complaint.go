@@ -0,0 +1,342 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"log"
+	"mis_kursach_backend/internal/models"
+	"mis_kursach_backend/internal/services"
+	"strings"
+	"time"
+)
+
+// ErrInvalidComplaintTransition возвращается при недопустимой смене статуса жалобы
+var ErrInvalidComplaintTransition = errors.New("invalid complaint status transition")
+
+// ErrUnknownComplaintCategory возвращается, если категории жалобы нет в справочнике
+var ErrUnknownComplaintCategory = errors.New("unknown complaint category")
+
+// ErrComplaintBookingMismatch возвращается, если номер или гость жалобы не из указанного бронирования
+var ErrComplaintBookingMismatch = errors.New("complaint room or guest does not belong to the booking")
+
+func GetComplaintCategories(dbpool *pgxpool.Pool) ([]models.ComplaintCategory, error) {
+	var categories []models.ComplaintCategory
+	err := pgxscan.Select(context.Background(), dbpool, &categories,
+		`SELECT code, name, sla_hours FROM COMPLAINT_CATEGORIES ORDER BY sla_hours, code`)
+	if err != nil {
+		return nil, fmt.Errorf("error getting complaint categories: %v", err)
+	}
+	return categories, nil
+}
+
+// complaintSLAHours возвращает срок SLA категории в часах
+func complaintSLAHours(ctx context.Context, q pgxscan.Querier, category string) (int, error) {
+	var hours int
+	err := pgxscan.Get(ctx, q, &hours, `SELECT sla_hours FROM COMPLAINT_CATEGORIES WHERE code = $1`, category)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, ErrUnknownComplaintCategory
+	}
+	if err != nil {
+		return 0, fmt.Errorf("error getting complaint category: %v", err)
+	}
+	return hours, nil
+}
+
+// ChangeComplaintStatus переводит жалобу в новый статус по правилам переходов.
+// Взятая в работу жалоба без исполнителя назначается на того, кто её взял;
+// возвращённой в работу после решения срок SLA отсчитывается заново.
+func ChangeComplaintStatus(dbpool *pgxpool.Pool, id, statusCode int, userID *int) error {
+	ctx := context.Background()
+	tx, err := dbpool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("error beginning transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err = changeComplaintStatus(ctx, tx, id, statusCode, userID); err != nil {
+		return err
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("error committing complaint status: %v", err)
+	}
+	return nil
+}
+
+func changeComplaintStatus(ctx context.Context, tx pgx.Tx, id, statusCode int, userID *int) error {
+	var current models.ComplaintResponse
+	err := pgxscan.Get(ctx, tx, &current,
+		`SELECT c.id, c.status_code, cs.name AS status, c.category_code AS category, c.priority, c.assigned_to
+		FROM COMPLAINTS c
+		JOIN COMPLAINT_STATUSES cs ON cs.status_code = c.status_code
+		WHERE c.id = $1 AND c.deleted_at IS NULL FOR UPDATE OF c`, id)
+	if err != nil {
+		return fmt.Errorf("complaint with ID %d not found: %v", id, err)
+	}
+	if !services.CanTransitionComplaint(current.StatusCode, statusCode) {
+		return ErrInvalidComplaintTransition
+	}
+	var statusName string
+	err = tx.QueryRow(ctx, `SELECT name FROM COMPLAINT_STATUSES WHERE status_code = $1`, statusCode).Scan(&statusName)
+	if err != nil {
+		return fmt.Errorf("error getting complaint status: %v", err)
+	}
+	now := time.Now()
+	assignee := current.AssignedTo
+	if statusCode == models.ComplaintStatusInProgress && assignee == nil {
+		assignee = userID
+	}
+	var resolvedAt *time.Time
+	if statusCode == models.ComplaintStatusResolved {
+		resolvedAt = &now
+	}
+	_, err = tx.Exec(ctx,
+		`UPDATE COMPLAINTS SET status_code = $1, assigned_to = $2, resolved_at = $3 WHERE id = $4`,
+		statusCode, assignee, resolvedAt, id)
+	if err != nil {
+		log.Printf("error updating complaint: %v", err)
+		return fmt.Errorf("error updating complaint: %v", err)
+	}
+	changes := map[string]models.FieldChange{
+		"status": {From: current.Status, To: statusName},
+	}
+	if assignee != current.AssignedTo {
+		changes["assigned_to"] = models.FieldChange{From: current.AssignedTo, To: assignee}
+	}
+	if current.StatusCode == models.ComplaintStatusResolved {
+		hours, err := complaintSLAHours(ctx, tx, current.Category)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx,
+			`UPDATE COMPLAINTS SET sla_due_at = $1, escalated_at = NULL, escalation_notified_at = NULL WHERE id = $2`,
+			services.ComplaintSLADue(now, hours, current.Priority), id)
+		if err != nil {
+			return fmt.Errorf("error updating complaint deadline: %v", err)
+		}
+	}
+	err = addComplaintActivity(ctx, tx, id, models.ComplaintActivityStatus, nil, changes, userID)
+	if err != nil {
+		return err
+	}
+	return writeAudit(ctx, tx, "complaint", id, "status", map[string]any{
+		"from": current.StatusCode,
+		"to":   statusCode,
+	}, userID)
+}
+
+// AssignComplaint назначает исполнителя жалобы; пустой UserID снимает назначение
+func AssignComplaint(dbpool *pgxpool.Pool, in models.AssignComplaintInput, userID *int) error {
+	ctx := context.Background()
+	tx, err := dbpool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("error beginning transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var previous *int
+	err = tx.QueryRow(ctx,
+		`SELECT assigned_to FROM COMPLAINTS WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`, in.ComplaintID).Scan(&previous)
+	if err != nil {
+		return fmt.Errorf("complaint with ID %d not found: %v", in.ComplaintID, err)
+	}
+	_, err = tx.Exec(ctx, `UPDATE COMPLAINTS SET assigned_to = $1 WHERE id = $2`, in.UserID, in.ComplaintID)
+	if err != nil {
+		log.Printf("error assigning complaint: %v", err)
+		return fmt.Errorf("error assigning complaint: %v", err)
+	}
+	err = addComplaintActivity(ctx, tx, in.ComplaintID, models.ComplaintActivityAssignment, nil,
+		map[string]models.FieldChange{"assigned_to": {From: previous, To: in.UserID}}, userID)
+	if err != nil {
+		return err
+	}
+	err = writeAudit(ctx, tx, "complaint", in.ComplaintID, "assign", map[string]any{
+		"from": previous,
+		"to":   in.UserID,
+	}, userID)
+	if err != nil {
+		return err
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("error committing complaint assignment: %v", err)
+	}
+	return nil
+}
+
+// EscalateOverdueComplaints отмечает нерешённые жалобы с истёкшим сроком SLA как переданные менеджерам.
+// Каждая жалоба эскалируется один раз; отметка видна в списке жалоб и на панели показателей.
+func EscalateOverdueComplaints(dbpool *pgxpool.Pool, now time.Time) ([]models.ComplaintEscalation, error) {
+	ctx := context.Background()
+	tx, err := dbpool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error beginning transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var escalated []models.ComplaintEscalation
+	err = pgxscan.Select(ctx, tx, &escalated,
+		`UPDATE COMPLAINTS SET escalated_at = $1
+		WHERE status_code <> $2 AND sla_due_at < $1 AND escalated_at IS NULL AND deleted_at IS NULL
+		RETURNING id, sla_due_at, assigned_to`, now, models.ComplaintStatusResolved)
+	if err != nil {
+		return nil, fmt.Errorf("error escalating complaints: %v", err)
+	}
+	for _, e := range escalated {
+		err = addComplaintActivity(ctx, tx, e.ID, models.ComplaintActivityEscalation, nil,
+			map[string]models.FieldChange{"escalated_at": {From: nil, To: now}}, nil)
+		if err != nil {
+			return nil, err
+		}
+		err = writeAudit(ctx, tx, "complaint", e.ID, "escalate", map[string]any{
+			"sla_due_at":  e.SLADueAt,
+			"assigned_to": e.AssignedTo,
+		}, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("error committing complaint escalation: %v", err)
+	}
+	return escalated, nil
+}
+
+// ManagerEmail — адрес менеджеров для уведомлений об эскалации; задаётся при запуске из конфигурации
+var ManagerEmail string
+
+// RunComplaintEscalation эскалирует просроченные жалобы и одним письмом сообщает менеджерам обо всех,
+// о которых они ещё не знают. Отметка об уведомлении ставится только после отправки письма,
+// так что без адреса или при ошибке почты жалобы ждут следующей проверки.
+func RunComplaintEscalation(dbpool *pgxpool.Pool) {
+	escalated, err := EscalateOverdueComplaints(dbpool, time.Now())
+	if err != nil {
+		log.Printf("Error escalating overdue complaints: %v", err)
+		return
+	}
+	for _, e := range escalated {
+		log.Printf("Complaint %d is overdue since %s, escalated to managers", e.ID, e.SLADueAt.Format(time.RFC3339))
+	}
+	pending, err := pendingEscalations(dbpool)
+	if err != nil {
+		log.Printf("Error getting pending escalations: %v", err)
+		return
+	}
+	if len(pending) == 0 {
+		return
+	}
+	if ManagerEmail == "" {
+		log.Printf("HOTEL_MANAGER_EMAIL is not set, %d escalated complaints are waiting to be mailed", len(pending))
+		return
+	}
+	var body strings.Builder
+	body.WriteString("Истёк срок SLA по жалобам:\n\n")
+	ids := make([]int, len(pending))
+	for i, e := range pending {
+		ids[i] = e.ID
+		assignee := "не назначена"
+		if e.AssignedTo != nil {
+			assignee = fmt.Sprintf("сотрудник #%d", *e.AssignedTo)
+		}
+		fmt.Fprintf(&body, "Жалоба %d: срок истёк %s, ответственный: %s\n",
+			e.ID, e.SLADueAt.Format("02.01.2006 15:04"), assignee)
+	}
+	subject := fmt.Sprintf("Просрочено жалоб: %d", len(pending))
+	if err = Mail.Send(ManagerEmail, subject, body.String()); err != nil {
+		log.Printf("Error mailing complaint escalation: %v", err)
+		return
+	}
+	_, err = dbpool.Exec(context.Background(),
+		`UPDATE COMPLAINTS SET escalation_notified_at = $1 WHERE id = ANY($2) AND escalation_notified_at IS NULL`,
+		time.Now(), ids)
+	if err != nil {
+		log.Printf("Error marking complaint escalations as notified: %v", err)
+	}
+}
+
+// pendingEscalations возвращает эскалированные нерешённые жалобы, о которых менеджерам ещё не написали
+func pendingEscalations(dbpool *pgxpool.Pool) ([]models.ComplaintEscalation, error) {
+	var pending []models.ComplaintEscalation
+	err := pgxscan.Select(context.Background(), dbpool, &pending,
+		`SELECT id, sla_due_at, assigned_to FROM COMPLAINTS
+		WHERE escalated_at IS NOT NULL AND escalation_notified_at IS NULL
+			AND status_code <> $1 AND deleted_at IS NULL
+		ORDER BY sla_due_at, id`, models.ComplaintStatusResolved)
+	if err != nil {
+		return nil, fmt.Errorf("error getting pending escalations: %v", err)
+	}
+	return pending, nil
+}
+
+// GetEscalatedComplaints — очередь менеджера: эскалированные и ещё не решённые жалобы, самые просроченные первыми
+func GetEscalatedComplaints(dbpool *pgxpool.Pool) ([]models.ComplaintResponse, error) {
+	var complaints []models.ComplaintResponse
+	err := pgxscan.Select(context.Background(), dbpool, &complaints,
+		complaintSelect+`
+				WHERE C.DELETED_AT IS NULL AND C.ESCALATED_AT IS NOT NULL AND C.STATUS_CODE <> $1
+				ORDER BY C.SLA_DUE_AT, C.ID`, models.ComplaintStatusResolved)
+	if err != nil {
+		return nil, fmt.Errorf("error getting escalated complaints: %v", err)
+	}
+	return complaints, nil
+}
+
+// addComplaintActivity дописывает запись в журнал жалобы; записи журнала не меняются и не удаляются
+func addComplaintActivity(ctx context.Context, q execer, complaintID int, kind string, body *string,
+	changes map[string]models.FieldChange, userID *int) error {
+	if changes == nil {
+		changes = map[string]models.FieldChange{}
+	}
+	data, err := json.Marshal(changes)
+	if err != nil {
+		return fmt.Errorf("error encoding complaint changes: %v", err)
+	}
+	_, err = q.Exec(ctx,
+		`INSERT INTO COMPLAINT_ACTIVITY (complaint_id, kind, body, changes, user_id) VALUES ($1, $2, $3, $4, $5)`,
+		complaintID, kind, body, data, userID)
+	if err != nil {
+		return fmt.Errorf("error writing complaint activity: %v", err)
+	}
+	return nil
+}
+
+// GetComplaintActivity возвращает журнал жалобы от первой записи к последней
+func GetComplaintActivity(dbpool *pgxpool.Pool, complaintID int) ([]models.ComplaintActivity, error) {
+	var activity []models.ComplaintActivity
+	err := pgxscan.Select(context.Background(), dbpool, &activity,
+		`SELECT a.id, a.complaint_id, a.kind, a.body, a.changes, a.user_id, u.username, a.created_at, a.redacted_at
+		FROM COMPLAINT_ACTIVITY a
+		LEFT JOIN USERS u ON u.id = a.user_id
+		WHERE a.complaint_id = $1
+		ORDER BY a.created_at, a.id`, complaintID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting complaint activity: %v", err)
+	}
+	return activity, nil
+}
+
+// AddComplaintComment добавляет комментарий сотрудника в журнал жалобы
+func AddComplaintComment(dbpool *pgxpool.Pool, in models.ComplaintCommentInput, userID *int) (int, error) {
+	var id int
+	err := dbpool.QueryRow(context.Background(),
+		`INSERT INTO COMPLAINT_ACTIVITY (complaint_id, kind, body, user_id)
+		SELECT id, $2, $3, $4 FROM COMPLAINTS WHERE id = $1 AND deleted_at IS NULL
+		RETURNING id`, in.ComplaintID, models.ComplaintActivityComment, in.Body, userID).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, fmt.Errorf("complaint with ID %d not found", in.ComplaintID)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("error adding complaint comment: %v", err)
+	}
+	return id, nil
+}
+
+func equalStringPtr(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -0,0 +1,103 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"mis_kursach_backend/internal/models"
+	"mis_kursach_backend/internal/services"
+	"time"
+)
+
+// complaintsInRange — жалобы, поданные в [$1, $2], с номером из жалобы или из её бронирования.
+// У жалоб, решённых до учёта времени решения, RESOLVED_AT пуст: они считаются решёнными, но не входят в медианы.
+const complaintsInRange = `SELECT
+				c.id, c.category_code, c.status_code, c.issue_date, c.resolved_at,
+				COALESCE(c.room,
+					(SELECT MIN(gib.room) FROM GUESTS_IN_BOOKINGS gib WHERE gib.booking_id = c.booking_id)) AS room
+			FROM COMPLAINTS c
+			WHERE c.issue_date::DATE BETWEEN $1 AND $2 AND c.deleted_at IS NULL`
+
+// GetComplaintAnalytics считает динамику и разбивки жалоб за период [from, to].
+// Занятые номеро-ночи считаются так же, как в ночном аудите: номер заселён и ночь входит в даты бронирования.
+func GetComplaintAnalytics(dbpool *pgxpool.Pool, from, to time.Time) (models.ComplaintAnalytics, error) {
+	a := models.ComplaintAnalytics{From: from, To: to}
+	ctx := context.Background()
+	err := dbpool.QueryRow(ctx,
+		`WITH rc AS (`+complaintsInRange+`)
+		SELECT
+			COUNT(*),
+			COUNT(*) FILTER (WHERE status_code = $3),
+			PERCENTILE_CONT(0.5) WITHIN GROUP (ORDER BY EXTRACT(EPOCH FROM resolved_at - issue_date) / 3600)
+				FILTER (WHERE resolved_at IS NOT NULL)
+		FROM rc`, from, to, models.ComplaintStatusResolved).Scan(&a.Complaints, &a.Resolved, &a.MedianResolutionHours)
+	if err != nil {
+		return a, fmt.Errorf("error getting complaint totals: %v", err)
+	}
+	err = pgxscan.Select(ctx, dbpool, &a.Weekly,
+		`WITH days AS (
+			SELECT d::DATE AS day FROM GENERATE_SERIES($1::DATE, $2::DATE, INTERVAL '1 day') d
+		), occupied AS (
+			SELECT days.day, COUNT(DISTINCT gib.room) AS rooms
+			FROM days
+			LEFT JOIN BOOKINGS b ON b.check_in::DATE <= days.day
+				AND (b.check_out IS NULL OR b.check_out::DATE > days.day)
+				AND b.start_date <= days.day AND b.end_date > days.day
+			LEFT JOIN GUESTS_IN_BOOKINGS gib ON gib.booking_id = b.id
+			GROUP BY days.day
+		), weeks AS (
+			SELECT DATE_TRUNC('week', day)::DATE AS week_start, SUM(rooms)::INT AS room_nights
+			FROM occupied
+			GROUP BY 1
+		), rc AS (`+complaintsInRange+`)
+		SELECT
+			w.week_start,
+			w.room_nights,
+			(SELECT COUNT(*) FROM rc WHERE DATE_TRUNC('week', rc.issue_date)::DATE = w.week_start) AS complaints
+		FROM weeks w
+		ORDER BY w.week_start`, from, to)
+	if err != nil {
+		return a, fmt.Errorf("error getting weekly complaint rate: %v", err)
+	}
+	for i := range a.Weekly {
+		a.Weekly[i].PerHundredRoomNights = services.ComplaintRate(a.Weekly[i].Complaints, a.Weekly[i].RoomNights)
+	}
+	err = pgxscan.Select(ctx, dbpool, &a.ByCategory,
+		`WITH rc AS (`+complaintsInRange+`)
+		SELECT
+			cc.code AS category,
+			cc.name AS category_name,
+			COUNT(rc.id) AS complaints,
+			COUNT(rc.id) FILTER (WHERE rc.status_code = $3) AS resolved,
+			PERCENTILE_CONT(0.5) WITHIN GROUP (ORDER BY EXTRACT(EPOCH FROM rc.resolved_at - rc.issue_date) / 3600)
+				FILTER (WHERE rc.resolved_at IS NOT NULL) AS median_resolution_hours
+		FROM COMPLAINT_CATEGORIES cc
+		LEFT JOIN rc ON rc.category_code = cc.code
+		GROUP BY cc.code, cc.name
+		ORDER BY complaints DESC, cc.code`, from, to, models.ComplaintStatusResolved)
+	if err != nil {
+		return a, fmt.Errorf("error getting complaints by category: %v", err)
+	}
+	err = pgxscan.Select(ctx, dbpool, &a.ByRoom,
+		`WITH rc AS (`+complaintsInRange+`)
+		SELECT room, COUNT(*) AS complaints, ARRAY_AGG(DISTINCT category_code) AS categories
+		FROM rc
+		WHERE room IS NOT NULL
+		GROUP BY room
+		ORDER BY complaints DESC, room`, from, to)
+	if err != nil {
+		return a, fmt.Errorf("error getting complaints by room: %v", err)
+	}
+	if a.Weekly == nil {
+		a.Weekly = []models.ComplaintWeek{}
+	}
+	if a.ByCategory == nil {
+		a.ByCategory = []models.ComplaintCategoryStats{}
+	}
+	if a.ByRoom == nil {
+		a.ByRoom = []models.ComplaintRoomStats{}
+	}
+	a.RepeatedRooms = services.RepeatedRooms(a.ByRoom)
+	return a, nil
+}
@@ -0,0 +1,208 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"mis_kursach_backend/internal/models"
+	"mis_kursach_backend/internal/services"
+	"time"
+)
+
+// ErrCompensationExceedsBalance возвращается, если скидка больше долга по бронированию — тогда нужен возврат
+var ErrCompensationExceedsBalance = errors.New("compensation exceeds folio balance")
+
+// ErrComplaintCompensated возвращается при удалении жалобы, по которой уже выдана компенсация
+var ErrComplaintCompensated = errors.New("complaint has compensations")
+
+// ErrBookingCompensated возвращается при удалении бронирования, по которому выданы компенсации:
+// они должны остаться в отчёте о стоимости жалоб
+var ErrBookingCompensated = errors.New("booking has complaint compensations")
+
+// ErrCompensationExceedsPaid возвращается, если к возврату больше, чем гость заплатил
+var ErrCompensationExceedsPaid = errors.New("refund exceeds paid amount")
+
+// CompensateComplaint выдаёт компенсацию по жалобе: скидку или бесплатную ночь на фолио бронирования
+// либо возврат денег. Сумма записывается в жалобу и её журнал, а в главную книгу — расходом на компенсации.
+func CompensateComplaint(dbpool *pgxpool.Pool, in models.CompensateComplaintInput, userID *int) (models.ComplaintCompensation, error) {
+	comp := models.ComplaintCompensation{ComplaintID: in.ComplaintID, Kind: in.Kind, MethodCode: in.MethodCode, Commentary: in.Commentary}
+	ctx := context.Background()
+	tx, err := dbpool.Begin(ctx)
+	if err != nil {
+		return comp, fmt.Errorf("error beginning transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var complaintBooking *int
+	err = tx.QueryRow(ctx, `SELECT booking_id FROM COMPLAINTS WHERE id = $1 AND deleted_at IS NULL`, in.ComplaintID).Scan(&complaintBooking)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return comp, fmt.Errorf("complaint with ID %d not found", in.ComplaintID)
+		}
+		return comp, fmt.Errorf("error getting complaint: %v", err)
+	}
+	comp.BookingID = complaintBooking
+	if complaintBooking == nil {
+		comp.BookingID = in.BookingID
+	} else if in.BookingID != nil && *in.BookingID != *complaintBooking {
+		return comp, ErrComplaintBookingMismatch
+	}
+	if comp.BookingID == nil {
+		return comp, fmt.Errorf("compensation requires a booking")
+	}
+	bookingID := *comp.BookingID
+
+	// блокировка бронирования не даёт двум компенсациям одновременно пройти проверку одного и того же остатка
+	var startDate, endDate time.Time
+	err = tx.QueryRow(ctx,
+		`SELECT start_date, end_date FROM BOOKINGS WHERE id = $1 FOR UPDATE`, bookingID).Scan(&startDate, &endDate)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return comp, fmt.Errorf("booking with ID %d not found", bookingID)
+		}
+		return comp, fmt.Errorf("error getting booking: %v", err)
+	}
+
+	var account string
+	switch in.Kind {
+	case models.CompensationFolioCredit, models.CompensationFreeNight:
+		folio, err := getFolio(ctx, tx, bookingID)
+		if err != nil {
+			return comp, err
+		}
+		if in.Kind == models.CompensationFreeNight {
+			nights := in.Nights
+			if nights == 0 {
+				nights = 1
+			}
+			bookingNights := daysBetween(startDate, endDate)
+			if nights > bookingNights {
+				return comp, fmt.Errorf("booking %d has only %d nights", bookingID, bookingNights)
+			}
+			comp.Nights = &nights
+			comp.Amount = services.NightsShare(folio.RoomTotal, bookingNights, nights)
+		} else {
+			comp.Amount = *in.Amount
+		}
+		if comp.Amount.Cmp(folio.Balance) > 0 {
+			return comp, ErrCompensationExceedsBalance
+		}
+	case models.CompensationRefund:
+		comp.Amount = *in.Amount
+		var isVoucher, isLoyalty bool
+		err = tx.QueryRow(ctx,
+			`SELECT ledger_account, is_voucher, is_loyalty FROM PAYMENT_METHODS WHERE code = $1`,
+			*in.MethodCode).Scan(&account, &isVoucher, &isLoyalty)
+		if err != nil {
+			return comp, fmt.Errorf("payment method %d not found: %v", *in.MethodCode, err)
+		}
+		if isVoucher || isLoyalty {
+			return comp, fmt.Errorf("refunds are paid out in money, not vouchers or points")
+		}
+		// вернуть можно не больше, чем заплачено, за вычетом уже выданных возвратов
+		var refundable models.Money
+		err = tx.QueryRow(ctx,
+			`SELECT
+				COALESCE((SELECT SUM(amount) FROM PAYMENTS WHERE booking_id = $1 AND status_code = $2), 0)
+				- COALESCE((SELECT SUM(amount) FROM COMPLAINT_COMPENSATIONS WHERE booking_id = $1 AND kind = $3), 0)`,
+			bookingID, models.PaymentStatusPaid, models.CompensationRefund).Scan(&refundable)
+		if err != nil {
+			return comp, fmt.Errorf("error getting refundable amount: %v", err)
+		}
+		if comp.Amount.Cmp(refundable) > 0 {
+			return comp, ErrCompensationExceedsPaid
+		}
+	}
+
+	var previous models.Money
+	err = tx.QueryRow(ctx,
+		`SELECT COALESCE(SUM(amount), 0) FROM COMPLAINT_COMPENSATIONS WHERE complaint_id = $1`, in.ComplaintID).Scan(&previous)
+	if err != nil {
+		return comp, fmt.Errorf("error getting complaint compensations: %v", err)
+	}
+	err = tx.QueryRow(ctx,
+		`INSERT INTO COMPLAINT_COMPENSATIONS
+			(complaint_id, booking_id, kind, amount, nights, method_code, commentary, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created_at`,
+		comp.ComplaintID, comp.BookingID, comp.Kind, comp.Amount, comp.Nights, comp.MethodCode, comp.Commentary, userID).
+		Scan(&comp.ID, &comp.CreatedAt)
+	if err != nil {
+		return comp, fmt.Errorf("error inserting complaint compensation: %v", err)
+	}
+	comp.CreatedBy = userID
+	err = addComplaintActivity(ctx, tx, in.ComplaintID, models.ComplaintActivityCompensation, in.Commentary,
+		map[string]models.FieldChange{"compensation_total": {From: previous, To: previous.Add(comp.Amount)}}, userID)
+	if err != nil {
+		return comp, err
+	}
+	err = writeAudit(ctx, tx, "complaint", in.ComplaintID, "compensate", comp, userID)
+	if err != nil {
+		return comp, err
+	}
+	entries := services.CompensationCreditEntries(comp.Amount)
+	if comp.Kind == models.CompensationRefund {
+		entries = services.CompensationRefundEntries(account, comp.Amount)
+	}
+	_, err = PostTransaction(ctx, tx, models.LedgerTransaction{
+		Kind:      models.LedgerKindCompensation,
+		BookingID: comp.BookingID,
+		Memo:      fmt.Sprintf("Компенсация по жалобе %d", comp.ComplaintID),
+		CreatedBy: userID,
+		Entries:   entries,
+	})
+	if err != nil {
+		return comp, err
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return comp, fmt.Errorf("error committing complaint compensation: %v", err)
+	}
+	return comp, nil
+}
+
+func GetComplaintCompensations(dbpool *pgxpool.Pool, complaintID int) ([]models.ComplaintCompensation, error) {
+	var compensations []models.ComplaintCompensation
+	err := pgxscan.Select(context.Background(), dbpool, &compensations,
+		`SELECT id, complaint_id, booking_id, kind, amount, nights, method_code, commentary, created_at, created_by
+		FROM COMPLAINT_COMPENSATIONS
+		WHERE complaint_id = $1
+		ORDER BY created_at, id`, complaintID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting complaint compensations: %v", err)
+	}
+	return compensations, nil
+}
+
+// GetComplaintCostReport — стоимость жалоб по категориям: компенсации, выданные за период [from, to],
+// и число жалоб, поданных за тот же период
+func GetComplaintCostReport(dbpool *pgxpool.Pool, from, to time.Time) (models.ComplaintCostReport, error) {
+	report := models.ComplaintCostReport{From: from, To: to}
+	err := pgxscan.Select(context.Background(), dbpool, &report.Rows,
+		`SELECT
+				cc.code AS category,
+				cc.name AS category_name,
+				(SELECT COUNT(*) FROM COMPLAINTS c
+					WHERE c.category_code = cc.code AND c.issue_date::DATE BETWEEN $1 AND $2
+						AND c.deleted_at IS NULL) AS complaints,
+				COUNT(k.id) AS compensations,
+				COALESCE(SUM(k.amount) FILTER (WHERE k.kind = 'folio_credit'), 0) AS folio_credits,
+				COALESCE(SUM(k.amount) FILTER (WHERE k.kind = 'free_night'), 0) AS free_nights,
+				COALESCE(SUM(k.amount) FILTER (WHERE k.kind = 'refund'), 0) AS refunds
+			FROM COMPLAINT_CATEGORIES cc
+			LEFT JOIN COMPLAINTS c ON c.category_code = cc.code
+			LEFT JOIN COMPLAINT_COMPENSATIONS k ON k.complaint_id = c.id AND k.created_at::DATE BETWEEN $1 AND $2
+			GROUP BY cc.code, cc.name
+			ORDER BY cc.code`, from, to)
+	if err != nil {
+		return report, fmt.Errorf("error getting complaint cost report: %v", err)
+	}
+	for i := range report.Rows {
+		row := &report.Rows[i]
+		row.Total = row.FolioCredits.Add(row.FreeNights).Add(row.Refunds)
+		report.Total = report.Total.Add(row.Total)
+	}
+	return report, nil
+}
@@ -0,0 +1,177 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"mis_kursach_backend/internal/models"
+	"mis_kursach_backend/internal/services"
+	"net/http"
+	"strings"
+	"time"
+)
+
+func (p *PsHandler) GetComplaintCategories(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	categories, err := GetComplaintCategories(p.dbpool)
+	if err != nil {
+		http.Error(w, `{"error": "failed to get complaint categories"}`, http.StatusInternalServerError)
+		log.Printf("Error getting complaint categories: %v", err)
+		return
+	}
+	if categories == nil {
+		categories = []models.ComplaintCategory{}
+	}
+	if err := json.NewEncoder(w).Encode(categories); err != nil {
+		http.Error(w, `{"error": "failed to encode response"}`, http.StatusInternalServerError)
+		log.Printf("Error encoding complaint categories: %v", err)
+	}
+}
+
+// AssignComplaint — менеджер назначает любого исполнителя, остальные сотрудники могут только взять жалобу себе
+func (p *PsHandler) AssignComplaint(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var in models.AssignComplaintInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, `{"error": "invalid request body"}`, http.StatusBadRequest)
+		log.Printf("Error decoding complaint assignment: %v", err)
+		return
+	}
+	defer r.Body.Close()
+	if in.ComplaintID <= 0 {
+		http.Error(w, `{"error": "complaint_id is required"}`, http.StatusBadRequest)
+		return
+	}
+	userID := userIDFromRequest(r)
+	role := roleFromRequest(r)
+	if role != models.RoleAdmin && role != models.RoleManager {
+		if in.UserID == nil || userID == nil || *in.UserID != *userID {
+			http.Error(w, `{"error": "only a manager can assign complaints to other users"}`, http.StatusForbidden)
+			return
+		}
+	}
+	if err := AssignComplaint(p.dbpool, in, userID); err != nil {
+		http.Error(w, `{"error": "failed to assign complaint"}`, http.StatusBadRequest)
+		log.Printf("Error assigning complaint: %v", err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "success"})
+}
+
+func (p *PsHandler) AddComplaintComment(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var in models.ComplaintCommentInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, `{"error": "invalid request body"}`, http.StatusBadRequest)
+		log.Printf("Error decoding complaint comment: %v", err)
+		return
+	}
+	defer r.Body.Close()
+	in.Body = strings.TrimSpace(in.Body)
+	if in.ComplaintID <= 0 || in.Body == "" {
+		http.Error(w, `{"error": "complaint_id and body are required"}`, http.StatusBadRequest)
+		return
+	}
+	id, err := AddComplaintComment(p.dbpool, in, userIDFromRequest(r))
+	if err != nil {
+		http.Error(w, `{"error": "failed to add comment"}`, http.StatusBadRequest)
+		log.Printf("Error adding complaint comment: %v", err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]any{"message": "success", "id": id})
+}
+
+func (p *PsHandler) CompensateComplaint(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var in models.CompensateComplaintInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, `{"error": "invalid request body"}`, http.StatusBadRequest)
+		log.Printf("Error decoding complaint compensation: %v", err)
+		return
+	}
+	defer r.Body.Close()
+	if errs := services.ValidateCompensation(in); errs != nil {
+		writeFieldErrors(w, errs)
+		return
+	}
+	comp, err := CompensateComplaint(p.dbpool, in, userIDFromRequest(r))
+	switch {
+	case errors.Is(err, ErrCompensationExceedsBalance):
+		http.Error(w, `{"error": "compensation exceeds the folio balance, use a refund instead"}`, http.StatusConflict)
+		return
+	case errors.Is(err, ErrCompensationExceedsPaid):
+		http.Error(w, `{"error": "refund exceeds the amount paid for the booking"}`, http.StatusConflict)
+		return
+	case errors.Is(err, ErrComplaintBookingMismatch):
+		http.Error(w, `{"error": "complaint belongs to another booking"}`, http.StatusBadRequest)
+		return
+	case err != nil && comp.ID != 0:
+		// компенсация записана, не удалась только проводка
+		http.Error(w, `{"error": "compensation recorded but ledger posting failed"}`, http.StatusInternalServerError)
+		log.Printf("Error posting complaint compensation %d: %v", comp.ID, err)
+		return
+	case err != nil:
+		http.Error(w, `{"error": "failed to compensate complaint"}`, http.StatusBadRequest)
+		log.Printf("Error compensating complaint: %v", err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(comp)
+}
+
+func (p *PsHandler) GetComplaintCostReport(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	from, err := time.Parse("2006-01-02", r.URL.Query().Get("from"))
+	if err != nil {
+		http.Error(w, `{"error": "invalid from date"}`, http.StatusBadRequest)
+		return
+	}
+	to, err := time.Parse("2006-01-02", r.URL.Query().Get("to"))
+	if err != nil {
+		http.Error(w, `{"error": "invalid to date"}`, http.StatusBadRequest)
+		return
+	}
+	report, err := GetComplaintCostReport(p.dbpool, from, to)
+	if err != nil {
+		http.Error(w, `{"error": "failed to get complaint cost report"}`, http.StatusInternalServerError)
+		log.Printf("Error getting complaint cost report: %v", err)
+		return
+	}
+	if report.Rows == nil {
+		report.Rows = []models.ComplaintCostRow{}
+	}
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		http.Error(w, `{"error": "failed to encode response"}`, http.StatusInternalServerError)
+		log.Printf("Error encoding complaint cost report: %v", err)
+	}
+}
+
+func (p *PsHandler) GetComplaintAnalytics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	from, err := time.Parse("2006-01-02", r.URL.Query().Get("from"))
+	if err != nil {
+		http.Error(w, `{"error": "invalid from date"}`, http.StatusBadRequest)
+		return
+	}
+	to, err := time.Parse("2006-01-02", r.URL.Query().Get("to"))
+	if err != nil {
+		http.Error(w, `{"error": "invalid to date"}`, http.StatusBadRequest)
+		return
+	}
+	if to.Before(from) || to.Sub(from) > services.ComplaintAnalyticsMaxDays*24*time.Hour {
+		http.Error(w, `{"error": "to must not be before from and the range must not exceed two years"}`, http.StatusBadRequest)
+		return
+	}
+	analytics, err := GetComplaintAnalytics(p.dbpool, from, to)
+	if err != nil {
+		http.Error(w, `{"error": "failed to get complaint analytics"}`, http.StatusInternalServerError)
+		log.Printf("Error getting complaint analytics: %v", err)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(analytics); err != nil {
+		http.Error(w, `{"error": "failed to encode response"}`, http.StatusInternalServerError)
+		log.Printf("Error encoding complaint analytics: %v", err)
+	}
+}
@@ -0,0 +1,95 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"log"
+	"mis_kursach_backend/internal/models"
+	"time"
+)
+
+// EnsureBaseCurrency сверяет базовую валюту из конфигурации с валютой, в которой записаны суммы в БД.
+// В пустой базе валюта закрепляется при первом запуске; база, где уже есть бронирования, тарифы
+// или платежи, велась в рублях и закрепляется за RUB. Сменить валюту на базе с данными нельзя.
+func EnsureBaseCurrency(dbpool *pgxpool.Pool, currency string) error {
+	ctx := context.Background()
+	_, err := dbpool.Exec(ctx,
+		`INSERT INTO BASE_CURRENCY (currency)
+		SELECT CASE
+			WHEN EXISTS (SELECT 1 FROM BOOKINGS) OR EXISTS (SELECT 1 FROM TARIFFS) OR EXISTS (SELECT 1 FROM PAYMENTS)
+				THEN 'RUB'
+			ELSE $1::char(3)
+		END
+		ON CONFLICT (id) DO NOTHING`, currency)
+	if err != nil {
+		return fmt.Errorf("error saving base currency: %v", err)
+	}
+	var stored string
+	err = dbpool.QueryRow(ctx, `SELECT currency FROM BASE_CURRENCY`).Scan(&stored)
+	if err != nil {
+		return fmt.Errorf("error getting base currency: %v", err)
+	}
+	if stored != currency {
+		return fmt.Errorf("database amounts are in %s, but HOTEL_BASE_CURRENCY is %s", stored, currency)
+	}
+	return nil
+}
+
+func GetExchangeRates(dbpool *pgxpool.Pool, currency string) ([]models.ExchangeRate, error) {
+	var rates []models.ExchangeRate
+	err := pgxscan.Select(context.Background(), dbpool, &rates,
+		`SELECT id, currency, rate, effective_date, created_by, created_at
+		FROM EXCHANGE_RATES
+		WHERE $1 = '' OR currency = $1
+		ORDER BY currency, effective_date DESC`, currency)
+	if err != nil {
+		return nil, fmt.Errorf("error getting exchange rates: %v", err)
+	}
+	return rates, nil
+}
+
+// SetExchangeRate добавляет курс на дату; повторная установка на ту же дату перезаписывает курс
+func SetExchangeRate(dbpool *pgxpool.Pool, rate models.ExchangeRateInput, userID *int) error {
+	effectiveDate, err := time.Parse("2006-01-02", rate.EffectiveDate)
+	if err != nil {
+		return fmt.Errorf("couldn't parse effective_date: %v", err)
+	}
+	if err = ensureDateOpen(dbpool, effectiveDate); err != nil {
+		return err
+	}
+	_, err = dbpool.Exec(context.Background(),
+		`INSERT INTO EXCHANGE_RATES (currency, rate, effective_date, created_by, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (currency, effective_date)
+		DO UPDATE SET rate = EXCLUDED.rate, created_by = EXCLUDED.created_by, created_at = EXCLUDED.created_at`,
+		rate.Currency, rate.Rate, effectiveDate, userID, time.Now())
+	if err != nil {
+		log.Printf("error inserting exchange rate: %v", err)
+		return fmt.Errorf("error inserting exchange rate: %v", err)
+	}
+	return nil
+}
+
+// GetEffectiveRate возвращает последний курс валюты, действующий на указанную дату
+func GetEffectiveRate(dbpool *pgxpool.Pool, currency string, date time.Time) (models.Decimal, error) {
+	var rate models.Decimal
+	if currency == models.DefaultCurrency {
+		return models.DecimalFromInt(1), nil
+	}
+	err := dbpool.QueryRow(context.Background(),
+		`SELECT rate FROM EXCHANGE_RATES
+		WHERE currency = $1 AND effective_date <= $2
+		ORDER BY effective_date DESC
+		LIMIT 1`, currency, date).Scan(&rate)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return rate, fmt.Errorf("no exchange rate for %s on %s", currency, date.Format("2006-01-02"))
+		}
+		return rate, fmt.Errorf("error getting exchange rate: %v", err)
+	}
+	return rate, nil
+}
@@ -0,0 +1,68 @@
+package db
+
+import (
+	"encoding/json"
+	"log"
+	"mis_kursach_backend/internal/models"
+	"mis_kursach_backend/internal/services"
+	"net/http"
+)
+
+func (p *PsHandler) GetExchangeRates(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	currency := r.URL.Query().Get("currency")
+	if currency != "" {
+		var err error
+		currency, err = services.NormalizeCurrency(currency)
+		if err != nil {
+			http.Error(w, `{"error": "invalid currency"}`, http.StatusBadRequest)
+			return
+		}
+	}
+	rates, err := GetExchangeRates(p.dbpool, currency)
+	if err != nil {
+		http.Error(w, `{"error": "failed to get exchange rates"}`, http.StatusInternalServerError)
+		log.Printf("Error getting exchange rates: %v", err)
+		return
+	}
+	if rates == nil {
+		rates = []models.ExchangeRate{}
+	}
+	if err := json.NewEncoder(w).Encode(rates); err != nil {
+		http.Error(w, `{"error": "failed to encode response"}`, http.StatusInternalServerError)
+		log.Printf("Error encoding exchange rates: %v", err)
+	}
+}
+
+func (p *PsHandler) SetExchangeRate(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var rate models.ExchangeRateInput
+	if err := json.NewDecoder(r.Body).Decode(&rate); err != nil {
+		http.Error(w, `{"error": "invalid request body"}`, http.StatusBadRequest)
+		log.Printf("Error decoding exchange rate: %v", err)
+		return
+	}
+	defer r.Body.Close()
+	currency, err := services.NormalizeCurrency(rate.Currency)
+	if err != nil {
+		http.Error(w, `{"error": "invalid currency"}`, http.StatusBadRequest)
+		return
+	}
+	if currency == models.DefaultCurrency {
+		http.Error(w, `{"error": "rate for base currency is always 1"}`, http.StatusBadRequest)
+		return
+	}
+	if rate.Rate.Sign() <= 0 {
+		http.Error(w, `{"error": "rate must be positive"}`, http.StatusBadRequest)
+		return
+	}
+	rate.Currency = currency
+	err = SetExchangeRate(p.dbpool, rate, userIDFromRequest(r))
+	if err != nil {
+		http.Error(w, `{"error": "failed to set exchange rate"}`, http.StatusBadRequest)
+		log.Printf("Error setting exchange rate: %v", err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Exchange rate saved successfully"})
+}
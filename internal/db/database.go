@@ -13,11 +13,20 @@ import (
 	"time"
 )
 
+// ErrBookingPaymentMethod возвращается при создании бронирования с оплатой сертификатом или баллами:
+// ими оплачивают уже созданное бронирование через CreatePayment
+var ErrBookingPaymentMethod = errors.New("vouchers and loyalty points cannot pay for a new booking")
+
+// ErrBookingPaid возвращается при удалении бронирования с полученными деньгами:
+// сначала платежи нужно вернуть, чтобы возврат прошёл по кассе и эквайрингу
+var ErrBookingPaid = errors.New("booking has paid payments")
+
 func GetAllBookings(dbpool *pgxpool.Pool) ([]*models.BookingResponse, error) {
 	var bookings []*models.BookingResponse
 	err := pgxscan.Select(context.Background(), dbpool, &bookings,
 		`SELECT DISTINCT
 				b.id AS "id", 
+				b.reference,
 				b.start_date, 
 				b.end_date, 
 				b.check_in, 
@@ -25,8 +34,12 @@ func GetAllBookings(dbpool *pgxpool.Pool) ([]*models.BookingResponse, error) {
 				b.baby_bed, 
 				b.booking_sum, 
 				b.total_sum, 
+				b.tax_sum, 
 				bs.name AS "booking_status", 
 				d.amount AS "discount_amount",
+				COALESCE(fc.extras_sum, 0) AS "extras_sum",
+				`+bookingBalanceSQL+` AS "balance",
+				b.company_id,
 				gib.room,
 				g.name AS "guest_name"
 			FROM 
@@ -35,6 +48,12 @@ func GetAllBookings(dbpool *pgxpool.Pool) ([]*models.BookingResponse, error) {
 				booking_statuses bs ON bs.status_code = b.status_code
 			LEFT JOIN 
 				discounts d ON d.id = b.discount_id
+			LEFT JOIN (
+				SELECT booking_id, SUM(amount) AS extras_sum
+				FROM folio_charges
+				WHERE voided_at IS NULL
+				GROUP BY booking_id
+			) fc ON fc.booking_id = b.id
 			JOIN guests_in_bookings gib on gib.booking_id = b.id
 			JOIN GUESTS G ON G.id = gib.guest_id`)
 	if err != nil {
@@ -47,6 +66,7 @@ func GetBookingByID(dbpool *pgxpool.Pool, id int) (models.BookingResponse, error
 	var booking models.BookingResponse
 	err := pgxscan.Get(context.Background(), dbpool, &booking, `SELECT DISTINCT
 				b.id AS "id", 
+				b.reference,
 				b.start_date, 
 				b.end_date, 
 				b.check_in, 
@@ -54,8 +74,12 @@ func GetBookingByID(dbpool *pgxpool.Pool, id int) (models.BookingResponse, error
 				b.baby_bed, 
 				b.booking_sum, 
 				b.total_sum, 
+				b.tax_sum, 
 				bs.name AS "booking_status", 
 				d.amount AS "discount_amount",
+				COALESCE(fc.extras_sum, 0) AS "extras_sum",
+				`+bookingBalanceSQL+` AS "balance",
+				b.company_id,
 				gib.room,
 				g.name as "guest_name"
 			FROM 
@@ -64,6 +88,12 @@ func GetBookingByID(dbpool *pgxpool.Pool, id int) (models.BookingResponse, error
 				booking_statuses bs ON bs.status_code = b.status_code
 			LEFT JOIN 
 				discounts d ON d.id = b.discount_id
+			LEFT JOIN (
+				SELECT booking_id, SUM(amount) AS extras_sum
+				FROM folio_charges
+				WHERE voided_at IS NULL
+				GROUP BY booking_id
+			) fc ON fc.booking_id = b.id
 			JOIN guests_in_bookings gib on gib.booking_id = b.id
 			JOIN GUESTS G ON GIB.GUEST_ID = G.ID
 			WHERE b.id = $1`, id)
@@ -73,103 +103,332 @@ func GetBookingByID(dbpool *pgxpool.Pool, id int) (models.BookingResponse, error
 	return booking, nil
 }
 
-func CreateBooking(dbpool *pgxpool.Pool, b models.CreateBookingInput) error {
-
+// QuoteBooking рассчитывает стоимость бронирования без сохранения: тариф категории, скидка за длительность и налоги
+func QuoteBooking(dbpool *pgxpool.Pool, b models.CreateBookingInput) (models.BookingQuote, error) {
+	var quote models.BookingQuote
 	var tariffs []models.Tariff
 	var discounts []models.Discount
-	var guests []models.Guest
-	var discountAmount float64
-	var discountID int
-	var guestID int
-	var bookingID int
 
 	// Парсинг времени
 	startDate, err := time.Parse("2006-01-02", b.StartDate)
 	if err != nil {
-		return fmt.Errorf("couldn't parse start_date: %v", err)
+		return quote, fmt.Errorf("couldn't parse start_date: %v", err)
 	}
 	endDate, err := time.Parse("2006-01-02", b.EndDate)
 	if err != nil {
-		return fmt.Errorf("couldn't parse end_date: %v", err)
+		return quote, fmt.Errorf("couldn't parse end_date: %v", err)
 	}
 
 	// Подсчёт ночей
 	nights := int(endDate.Sub(startDate).Hours() / 24)
 	if nights <= 0 {
-		return fmt.Errorf("nights is zero or lower than zero")
+		return quote, fmt.Errorf("nights is zero or lower than zero")
+	}
+	if b.Adults <= 0 {
+		b.Adults = 1
+	}
+	if b.Children < 0 {
+		return quote, fmt.Errorf("children cannot be negative")
 	}
 	err = pgxscan.Select(context.Background(), dbpool, &tariffs, `SELECT * FROM TARIFFS`)
 	if err != nil {
-		return fmt.Errorf("error fetching tariffs from db: %v", err)
+		return quote, fmt.Errorf("error fetching tariffs from db: %v", err)
 	}
 
 	err = pgxscan.Select(context.Background(), dbpool, &discounts, `SELECT * FROM DISCOUNTS`)
 	if err != nil {
-		return fmt.Errorf("error fetching discounts from db: %v", err)
+		return quote, fmt.Errorf("error fetching discounts from db: %v", err)
 	}
 
-	err = pgxscan.Select(context.Background(), dbpool, &guests, `SELECT * FROM GUESTS G WHERE G.PASSPORT_NO = $1`, b.GuestPassportNumber)
-	if len(guests) == 0 {
-		// если гостя нет, то добавляем его в таблицу и сразу вытаскиваем айди
-		err = pgxscan.Get(context.Background(), dbpool, &guestID,
-			`INSERT INTO GUESTS(name, phone_number, passport_no) VALUES ($1, $2, $3) RETURNING ID`, b.GuestName, b.GuestPhoneNumber, b.GuestPassportNumber)
+	rules, err := getActiveTaxRules(dbpool, startDate)
+	if err != nil {
+		return quote, err
+	}
+
+	var basePrice models.Money
+	for _, tariff := range tariffs {
+		if tariff.CategoryCode == b.CategoryCode {
+			basePrice = tariff.BasePrice
+			break
+		}
+	}
+	// договорная цена организации заменяет базовый тариф категории
+	if b.CompanyID != nil {
+		rate, err := getContractRate(dbpool, *b.CompanyID, b.CategoryCode)
 		if err != nil {
-			return fmt.Errorf("error inserting guest: %v", err)
+			return quote, err
+		}
+		if rate != nil {
+			basePrice = *rate
 		}
 	}
-	// ретривим айди гостя
-	err = pgxscan.Get(context.Background(), dbpool, &guestID, `SELECT G.ID FROM GUESTS G WHERE G.PASSPORT_NO = $1`, b.GuestPassportNumber)
+	if basePrice.IsZero() {
+		return quote, fmt.Errorf("error fetching basePrice")
+	}
+	discount := services.DiscountForNights(discounts, nights)
+	// скидка уровня лояльности добавляется к скидке за длительность; договорная цена организации её не получает
+	var tier models.LoyaltyTier
+	if b.CompanyID == nil && b.GuestPassportNumber != "" {
+		tier, err = loyaltyTierForPassport(dbpool, b.GuestPassportNumber)
+		if err != nil {
+			return quote, err
+		}
+		discount = services.WithLoyaltyDiscount(discount, tier)
+	}
+	quote, err = services.QuoteBooking(basePrice, discount, rules,
+		services.TaxContext{Nights: nights, Adults: b.Adults, Children: b.Children})
+	if err != nil {
+		return quote, fmt.Errorf("error pricing booking: %v", err)
+	}
+	quote.LoyaltyTier = tier.Code
+	quote.LoyaltyPercent = tier.DiscountPercent
+	return quote, nil
+}
+
+// CreateBooking проверяет гостя по чёрному списку и создаёт бронирование.
+// Запрет обходится только с override; обоснование записывается в журнал.
+// Предупреждения и сработавшие записи возвращаются вызывающему.
+func CreateBooking(dbpool *pgxpool.Pool, b models.CreateBookingInput, override *models.BlacklistOverride) ([]models.BlacklistMatch, error) {
+	matches, err := CheckBlacklist(dbpool, b.GuestPassportNumber, b.GuestPhoneNumber)
 	if err != nil {
-		return fmt.Errorf("error fetching guest: %v", err)
+		return nil, err
 	}
-	if nights >= 3 && nights < 7 {
-		discountID = 1
-	} else if nights >= 7 && nights < 14 {
-		discountID = 2
-	} else if nights >= 14 {
-		discountID = 3
+	blocked := blocksBooking(matches)
+	if blocked && override == nil {
+		return matches, ErrGuestBlacklisted
+	}
+	var overridden []int
+	if blocked {
+		for _, m := range matches {
+			overridden = append(overridden, m.ID)
+		}
 	} else {
-		discountID = 4
+		override = nil
 	}
-	var basePrice float64
-	for _, tariff := range tariffs {
-		if tariff.CategoryCode == b.CategoryCode {
-			basePrice = tariff.BasePrice
-			break
+	_, err = createBooking(dbpool, b, override, overridden)
+	return matches, err
+}
+
+// createBooking заводит гостя, бронирование и платёж одной транзакцией; возвращает ID созданного бронирования.
+// Если передан override, обход чёрного списка записывается в журнал в той же транзакции:
+// без записи в журнале бронирование не создаётся.
+func createBooking(dbpool *pgxpool.Pool, b models.CreateBookingInput, override *models.BlacklistOverride, overridden []int) (int, error) {
+
+	var guests []models.Guest
+	var guestID int
+	var bookingID int
+
+	quote, err := QuoteBooking(dbpool, b)
+	if err != nil {
+		return 0, err
+	}
+	var available bool
+	err = dbpool.QueryRow(context.Background(),
+		`SELECT EXISTS(SELECT 1 FROM ROOMS r
+			JOIN ROOM_CATEGORIES rc ON rc.code = r.category_code
+			WHERE r.number = $1 AND r.active AND rc.active)`, b.RoomNumber).Scan(&available)
+	if err != nil {
+		return 0, fmt.Errorf("error checking room: %v", err)
+	}
+	if !available {
+		return 0, ErrRoomUnavailable
+	}
+	if b.CompanyID == nil {
+		// сертификату нужен код, а баллам — остаток у гостя, поэтому ими оплачивают уже созданное бронирование
+		isVoucher, isLoyalty, err := paymentMethodKind(context.Background(), dbpool, b.MethodCode)
+		if err != nil {
+			return 0, err
+		}
+		if isVoucher || isLoyalty {
+			return 0, ErrBookingPaymentMethod
 		}
 	}
-	if basePrice == 0 {
-		return fmt.Errorf("error fetching basePrice")
+
+	ctx := context.Background()
+	tx, err := dbpool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("error beginning transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+	if b.CompanyID != nil {
+		err = ensureCreditAvailable(ctx, tx, *b.CompanyID, quote.TotalSum)
+		if err != nil {
+			return 0, err
+		}
 	}
-	bookingSum := basePrice * float64(nights)
-	totalSum := bookingSum * ((100 - discountAmount) / 100)
-	err = pgxscan.Get(context.Background(), dbpool, &bookingID, `
+
+	// гость ищется по слепому индексу паспорта, номер в базе зашифрован
+	passportHash := Passports.BlindIndex(b.GuestPassportNumber)
+	err = pgxscan.Select(ctx, tx, &guests,
+		`SELECT `+guestColumns+` FROM GUESTS G WHERE G.PASSPORT_HASH = $1 OR G.PASSPORT_NO = $2 ORDER BY G.ID LIMIT 1`,
+		passportHash, b.GuestPassportNumber)
+	if err != nil {
+		return 0, fmt.Errorf("error fetching guest: %v", err)
+	}
+	if len(guests) == 0 {
+		// если гостя нет, то добавляем его в таблицу и сразу вытаскиваем айди
+		enc, hash, err := sealPassport(b.GuestPassportNumber)
+		if err != nil {
+			return 0, err
+		}
+		err = pgxscan.Get(ctx, tx, &guestID,
+			`INSERT INTO GUESTS(name, phone_number, email, passport_enc, passport_hash, document_type, nationality) VALUES ($1, $2, NULLIF($3, ''), $4, $5, $6, $7) RETURNING ID`,
+			b.GuestName, b.GuestPhoneNumber, b.GuestEmail, enc, hash, b.GuestDocumentType, b.GuestNationality)
+		if err != nil {
+			return 0, fmt.Errorf("error inserting guest: %v", err)
+		}
+	} else {
+		guestID = guests[0].ID
+		// почта нужна гостю для входа в личный кабинет; уже указанную не перезаписываем
+		if b.GuestEmail != "" && guests[0].Email == nil {
+			_, err = tx.Exec(ctx, `UPDATE GUESTS SET email = $1 WHERE id = $2`, b.GuestEmail, guestID)
+			if err != nil {
+				return 0, fmt.Errorf("error updating guest email: %v", err)
+			}
+		}
+	}
+	reference, err := services.GenerateBookingReference()
+	if err != nil {
+		return 0, err
+	}
+	err = pgxscan.Get(ctx, tx, &bookingID, `
 					INSERT INTO BOOKINGS(
 					status_code,
 					start_date, end_date,
 					check_in, check_out,
 					baby_bed, booking_sum,
-					discount_id, total_sum) VALUES (1, $1, $2, $3, $4, $5, $6, $7, $8) RETURNING ID`,
-		b.StartDate, b.EndDate, b.CheckIn, b.CheckOut, b.BabyBed, bookingSum, discountID, totalSum)
+					discount_id, total_sum,
+					adults, children, tax_sum,
+					company_id, reference) VALUES (1, $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13) RETURNING ID`,
+		b.StartDate, b.EndDate, b.CheckIn, b.CheckOut, b.BabyBed, quote.BookingSum, quote.DiscountID, quote.TotalSum,
+		quote.Adults, quote.Children, quote.TaxSum, b.CompanyID, reference)
 	if err != nil {
-		return fmt.Errorf("error inserting booking: %v", err)
+		return 0, fmt.Errorf("error inserting booking: %v", err)
 	}
-	_, err = dbpool.Exec(context.Background(),
+	err = insertTaxLines(ctx, tx, bookingID, nil, quote.Taxes)
+	if err != nil {
+		return 0, err
+	}
+	// проживание проводится в книгу по ночам при ночном аудите
+	_, err = tx.Exec(ctx,
 		`INSERT INTO GUESTS_IN_BOOKINGS (GUEST_ID, BOOKING_ID, ROOM)
 			VALUES ($1, $2, $3)`, guestID, bookingID, b.RoomNumber)
-	err = CreatePayment(dbpool, b, totalSum, bookingID)
 	if err != nil {
-		return fmt.Errorf("error inserting payment: %v", err)
+		return 0, fmt.Errorf("error inserting guest in booking: %v", err)
+	}
+	// проживание организации войдёт в её ежемесячный счёт, платёж гостя не нужен
+	if b.CompanyID == nil {
+		err = insertPayment(ctx, dbpool, tx, models.CreatePaymentInput{
+			BookingID:  bookingID,
+			Amount:     quote.TotalSum,
+			MethodCode: b.MethodCode,
+		})
+		if err != nil {
+			return 0, fmt.Errorf("error inserting payment: %v", err)
+		}
+	}
+	if override != nil {
+		err = writeAudit(ctx, tx, "booking", bookingID, "blacklist_override", map[string]any{
+			"justification": override.Justification,
+			"entries":       overridden,
+		}, override.UserID)
+		if err != nil {
+			return 0, err
+		}
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("error committing booking: %v", err)
 	}
-	return err
+	return bookingID, nil
 }
 
 func DeleteBooking(dbpool *pgxpool.Pool, bookingID int) error {
-	tx, err := dbpool.Begin(context.Background())
+	// строка счёта организации ссылается на бронирование, а долг уже перенесён на организацию
+	var invoiced bool
+	err := dbpool.QueryRow(context.Background(),
+		`SELECT EXISTS(SELECT 1 FROM COMPANY_INVOICE_LINES WHERE booking_id = $1)`, bookingID).Scan(&invoiced)
+	if err != nil {
+		return fmt.Errorf("error checking company invoices: %v", err)
+	}
+	if invoiced {
+		return ErrBookingInvoiced
+	}
+	var firstPosting *time.Time
+	err = dbpool.QueryRow(context.Background(),
+		`SELECT MIN(business_date) FROM LEDGER_TRANSACTIONS WHERE booking_id = $1`, bookingID).Scan(&firstPosting)
+	if err != nil {
+		return fmt.Errorf("error getting booking postings: %v", err)
+	}
+	// бронирование с проводками в закрытых днях удалять нельзя
+	if firstPosting != nil {
+		if err = ensureDateOpen(dbpool, *firstPosting); err != nil {
+			return err
+		}
+	}
+	ctx := context.Background()
+	tx, err := dbpool.Begin(ctx)
 	if err != nil {
 		log.Printf("Error beginning transaction: %v", err)
 		return fmt.Errorf("error beginning transaction: %v", err)
 	}
+	// сертификаты, баллы и сторно фиксируются только вместе с удалением бронирования
+	defer tx.Rollback(ctx)
+
+	var compensated bool
+	err = tx.QueryRow(ctx,
+		`SELECT EXISTS(SELECT 1 FROM COMPLAINT_COMPENSATIONS WHERE booking_id = $1)`, bookingID).Scan(&compensated)
+	if err != nil {
+		return fmt.Errorf("error checking complaint compensations: %v", err)
+	}
+	if compensated {
+		return ErrBookingCompensated
+	}
+	// сертификаты и баллы восстанавливаются ниже, а деньги — только через возврат
+	var paid bool
+	err = tx.QueryRow(ctx,
+		`SELECT EXISTS(
+			SELECT 1 FROM PAYMENTS p
+			JOIN PAYMENT_METHODS pm ON pm.code = p.method_code
+			WHERE p.booking_id = $1 AND p.status_code = $2 AND NOT pm.is_voucher AND NOT pm.is_loyalty)`,
+		bookingID, models.PaymentStatusPaid).Scan(&paid)
+	if err != nil {
+		return fmt.Errorf("error checking booking payments: %v", err)
+	}
+	if paid {
+		return ErrBookingPaid
+	}
+
+	var voucherPayments []int
+	err = pgxscan.Select(ctx, tx, &voucherPayments,
+		`SELECT payment_id FROM VOUCHER_REDEMPTIONS WHERE booking_id = $1 AND reversed_at IS NULL`, bookingID)
+	if err != nil {
+		return fmt.Errorf("error getting voucher redemptions: %v", err)
+	}
+	for _, paymentID := range voucherPayments {
+		if _, err = restoreVoucherRedemption(ctx, tx, paymentID); err != nil {
+			return err
+		}
+	}
+	var loyaltyPayments []int
+	err = pgxscan.Select(ctx, tx, &loyaltyPayments,
+		`SELECT payment_id FROM LOYALTY_TRANSACTIONS WHERE booking_id = $1 AND kind = 'redeem' AND reversed_at IS NULL`, bookingID)
+	if err != nil {
+		return fmt.Errorf("error getting loyalty redemptions: %v", err)
+	}
+	for _, paymentID := range loyaltyPayments {
+		if _, err = restoreLoyaltyRedemption(ctx, tx, paymentID); err != nil {
+			return err
+		}
+	}
+	// Главная книга не очищается: остаток по бронированию закрываем сторнирующей проводкой
+	err = reverseNet(ctx, tx, "booking_id", bookingID, models.LedgerTransaction{
+		Kind:      models.LedgerKindReversal,
+		BookingID: &bookingID,
+		Memo:      fmt.Sprintf("Удаление бронирования %d", bookingID),
+	})
+	if err != nil {
+		return err
+	}
 
 	_, err = tx.Exec(context.Background(), `DELETE FROM PAYMENTS WHERE BOOKING_ID = $1`, bookingID)
 	if err != nil {
@@ -183,6 +442,86 @@ func DeleteBooking(dbpool *pgxpool.Pool, bookingID int) error {
 		return fmt.Errorf("error deleting payment while deleting booking: %v", err)
 	}
 
+	_, err = tx.Exec(context.Background(), `DELETE FROM TAX_LINES WHERE BOOKING_ID = $1`, bookingID)
+	if err != nil {
+		log.Printf("Error deleting tax lines, rolling back: %v", err)
+		if rbErr := tx.Rollback(context.Background()); rbErr != nil {
+			log.Printf("Error rolling back transaction: %v", rbErr)
+			return fmt.Errorf("error rolling back transaction: %v", rbErr)
+		}
+		return fmt.Errorf("error deleting tax lines while deleting booking: %v", err)
+	}
+
+	_, err = tx.Exec(context.Background(), `DELETE FROM FOLIO_CHARGES WHERE BOOKING_ID = $1`, bookingID)
+	if err != nil {
+		log.Printf("Error deleting folio charges, rolling back: %v", err)
+		if rbErr := tx.Rollback(context.Background()); rbErr != nil {
+			log.Printf("Error rolling back transaction: %v", rbErr)
+			return fmt.Errorf("error rolling back transaction: %v", rbErr)
+		}
+		log.Printf("Error deleting folio charges while deleting booking: %v", err)
+		return fmt.Errorf("error deleting folio charges while deleting booking: %v", err)
+	}
+
+	_, err = tx.Exec(context.Background(), `DELETE FROM MIGRATION_NOTICES WHERE BOOKING_ID = $1`, bookingID)
+	if err != nil {
+		log.Printf("Error deleting migration notices, rolling back: %v", err)
+		if rbErr := tx.Rollback(context.Background()); rbErr != nil {
+			log.Printf("Error rolling back transaction: %v", rbErr)
+			return fmt.Errorf("error rolling back transaction: %v", rbErr)
+		}
+		return fmt.Errorf("error deleting migration notices while deleting booking: %v", err)
+	}
+
+	// сканы документов гостя остаются в его профиле, вложения только бронирования удаляются
+	_, err = tx.Exec(context.Background(), `UPDATE ATTACHMENTS SET BOOKING_ID = NULL WHERE BOOKING_ID = $1 AND GUEST_ID IS NOT NULL`, bookingID)
+	var attachmentKeys []string
+	if err == nil {
+		err = pgxscan.Select(context.Background(), tx, &attachmentKeys,
+			`DELETE FROM ATTACHMENTS WHERE BOOKING_ID = $1 RETURNING STORAGE_KEY`, bookingID)
+	}
+	if err != nil {
+		log.Printf("Error deleting attachments, rolling back: %v", err)
+		if rbErr := tx.Rollback(context.Background()); rbErr != nil {
+			log.Printf("Error rolling back transaction: %v", rbErr)
+			return fmt.Errorf("error rolling back transaction: %v", rbErr)
+		}
+		return fmt.Errorf("error deleting attachments while deleting booking: %v", err)
+	}
+
+	_, err = tx.Exec(context.Background(), `DELETE FROM GUEST_ACCESS_CODES WHERE BOOKING_ID = $1`, bookingID)
+	if err != nil {
+		log.Printf("Error deleting guest access codes, rolling back: %v", err)
+		if rbErr := tx.Rollback(context.Background()); rbErr != nil {
+			log.Printf("Error rolling back transaction: %v", rbErr)
+			return fmt.Errorf("error rolling back transaction: %v", rbErr)
+		}
+		return fmt.Errorf("error deleting guest access codes while deleting booking: %v", err)
+	}
+
+	// задания на уборку остаются в истории номера
+	_, err = tx.Exec(context.Background(), `UPDATE HOUSEKEEPING_TASKS SET BOOKING_ID = NULL WHERE BOOKING_ID = $1`, bookingID)
+	if err != nil {
+		log.Printf("Error detaching housekeeping tasks, rolling back: %v", err)
+		if rbErr := tx.Rollback(context.Background()); rbErr != nil {
+			log.Printf("Error rolling back transaction: %v", rbErr)
+			return fmt.Errorf("error rolling back transaction: %v", rbErr)
+		}
+		return fmt.Errorf("error detaching housekeeping tasks while deleting booking: %v", err)
+	}
+
+	// жалобы и их журнал остаются: номер и гость переносятся из бронирования в саму жалобу
+	_, err = tx.Exec(context.Background(),
+		`UPDATE COMPLAINTS SET
+			ROOM = COALESCE(ROOM, (SELECT MIN(ROOM) FROM GUESTS_IN_BOOKINGS WHERE BOOKING_ID = $1)),
+			GUEST_ID = COALESCE(GUEST_ID, (SELECT MIN(GUEST_ID) FROM GUESTS_IN_BOOKINGS WHERE BOOKING_ID = $1)),
+			BOOKING_ID = NULL
+		WHERE BOOKING_ID = $1`, bookingID)
+	if err != nil {
+		log.Printf("Error detaching complaints, rolling back: %v", err)
+		return fmt.Errorf("error detaching complaints while deleting booking: %v", err)
+	}
+
 	_, err = tx.Exec(context.Background(), `DELETE FROM GUESTS_IN_BOOKINGS WHERE BOOKING_ID = $1`, bookingID)
 	if err != nil {
 		log.Printf("Error deleting guest in booking, rolling back: %v", err)
@@ -193,17 +532,6 @@ func DeleteBooking(dbpool *pgxpool.Pool, bookingID int) error {
 		log.Printf("Error deleting guest in booking while deleting booking: %v", err)
 		return fmt.Errorf("error deleting guest in booking while deleting booking: %v", err)
 	}
-	_, err = tx.Exec(context.Background(), `DELETE FROM COMPLAINTS WHERE BOOKING_ID = $1`, bookingID)
-	if err != nil {
-		log.Printf("Error deleting complaint, rolling back: %v", err)
-		err := tx.Rollback(context.Background())
-		if err != nil {
-			log.Printf("Error rolling back transaction: %v", err)
-			return fmt.Errorf("error rolling back transaction: %v", err)
-		}
-		log.Printf("Error deleting complaint while deleting booking: %v", err)
-		return fmt.Errorf("error deleting complaint while deleting booking: %v", err)
-	}
 	_, err = tx.Exec(context.Background(), `DELETE FROM BOOKINGS WHERE ID = $1`, bookingID)
 	if err != nil {
 		log.Printf("Error deleting booking, rolling back: %v", err)
@@ -220,108 +548,281 @@ func DeleteBooking(dbpool *pgxpool.Pool, bookingID int) error {
 		log.Printf("Error committing transaction: %v", err)
 		return fmt.Errorf("error commiting transaction while deleting booking: %v", err)
 	}
+	deleteAttachmentFiles(attachmentKeys)
 	return nil
 }
 
-func GetAllComplaints(dbpool *pgxpool.Pool) ([]models.ComplaintResponse, error) {
-	var complaints []models.ComplaintResponse
-	err := pgxscan.Select(context.Background(), dbpool, &complaints,
-		`SELECT DISTINCT
+// complaintSelect — общая выборка жалоб; номер берётся из жалобы, а если не указан — из бронирования
+const complaintSelect = `SELECT
 					C.ID,
 					C.REASON,
 					C.COMMENTARY,
 					C.ISSUE_DATE,
 					C.BOOKING_ID,
 					CS.NAME AS STATUS,
-					GIB.ROOM
+					C.STATUS_CODE,
+					COALESCE(C.ROOM,
+						(SELECT MIN(GIB.ROOM) FROM GUESTS_IN_BOOKINGS GIB WHERE GIB.BOOKING_ID = C.BOOKING_ID)) AS ROOM,
+					C.GUEST_ID,
+					G.NAME AS GUEST_NAME,
+					C.CATEGORY_CODE AS CATEGORY,
+					CC.NAME AS CATEGORY_NAME,
+					C.PRIORITY,
+					C.ASSIGNED_TO,
+					U.USERNAME AS ASSIGNEE_NAME,
+					C.SLA_DUE_AT,
+					C.RESOLVED_AT,
+					C.ESCALATED_AT,
+					COALESCE((SELECT SUM(K.AMOUNT) FROM COMPLAINT_COMPENSATIONS K WHERE K.COMPLAINT_ID = C.ID), 0)
+						AS COMPENSATION_TOTAL,
+					(C.STATUS_CODE <> 3 AND C.SLA_DUE_AT < NOW()) AS OVERDUE
 				FROM
 					COMPLAINTS C
 					JOIN COMPLAINT_STATUSES CS ON C.STATUS_CODE = CS.STATUS_CODE
-					JOIN BOOKINGS B ON C.BOOKING_ID = B.ID
-					JOIN GUESTS_IN_BOOKINGS GIB ON B.ID = GIB.BOOKING_ID`)
+					JOIN COMPLAINT_CATEGORIES CC ON C.CATEGORY_CODE = CC.CODE
+					LEFT JOIN USERS U ON C.ASSIGNED_TO = U.ID
+					LEFT JOIN GUESTS G ON C.GUEST_ID = G.ID`
+
+// GetAllComplaints возвращает все жалобы; непустой category оставляет только жалобы этой категории
+func GetAllComplaints(dbpool *pgxpool.Pool, category string) ([]models.ComplaintResponse, error) {
+	if category != "" {
+		if _, err := complaintSLAHours(context.Background(), dbpool, category); err != nil {
+			return nil, err
+		}
+	}
+	var complaints []models.ComplaintResponse
+	err := pgxscan.Select(context.Background(), dbpool, &complaints,
+		complaintSelect+`
+				WHERE C.DELETED_AT IS NULL AND ($1 = '' OR C.CATEGORY_CODE = $1)
+				ORDER BY C.ISSUE_DATE DESC, C.ID DESC`, category)
 	if err != nil {
 		return nil, fmt.Errorf("error getting all complaints: %v", err)
 	}
 	return complaints, nil
 }
 
-func GetComplaintByID(dbpool *pgxpool.Pool, id int) (models.ComplaintResponse, error) {
-	var complaint models.ComplaintResponse
-	err := pgxscan.Get(context.Background(), dbpool, &complaint, `SELECT DISTINCT
-					C.ID,
-					C.REASON,
-					C.COMMENTARY,
-					C.ISSUE_DATE,
-					C.BOOKING_ID,
-					CS.NAME AS STATUS,
-					GIB.ROOM
-				FROM
-					COMPLAINTS C
-					JOIN COMPLAINT_STATUSES CS ON C.STATUS_CODE = CS.STATUS_CODE
-					JOIN BOOKINGS B ON C.BOOKING_ID = B.ID
-					JOIN GUESTS_IN_BOOKINGS GIB ON B.ID = GIB.BOOKING_ID
-				WHERE c.id=$1`, id)
+// GetComplaintByID возвращает жалобу вместе с журналом работы по ней
+func GetComplaintByID(dbpool *pgxpool.Pool, id int) (models.ComplaintDetails, error) {
+	var complaint models.ComplaintDetails
+	err := pgxscan.Get(context.Background(), dbpool, &complaint.ComplaintResponse,
+		complaintSelect+`
+				WHERE C.ID = $1 AND C.DELETED_AT IS NULL`, id)
 	if err != nil {
 		return complaint, fmt.Errorf("error getting complaint: %v", err)
 	}
+	complaint.Activity, err = GetComplaintActivity(dbpool, id)
+	if err != nil {
+		return complaint, err
+	}
+	if complaint.Activity == nil {
+		complaint.Activity = []models.ComplaintActivity{}
+	}
+	complaint.Compensations, err = GetComplaintCompensations(dbpool, id)
+	if err != nil {
+		return complaint, err
+	}
+	if complaint.Compensations == nil {
+		complaint.Compensations = []models.ComplaintCompensation{}
+	}
 	return complaint, nil
 }
 
-func CreateComplaint(dbpool *pgxpool.Pool, complaint models.CreateComplaintInput) error {
-	_, err := dbpool.Exec(context.Background(),
-		`INSERT INTO COMPLAINTS(reason, commentary, issue_date, booking_id, status_code) VALUES ($1, $2, $3, $4, $5)`,
-		complaint.Reason, complaint.Commentary, time.Now(), complaint.BookingID, 2)
+// CreateComplaint заводит жалобу и открывает её журнал; userID — сотрудник, nil — жалоба подана гостем
+func CreateComplaint(dbpool *pgxpool.Pool, complaint models.CreateComplaintInput, userID *int) error {
+	if complaint.Category == "" {
+		complaint.Category = models.ComplaintCategoryOther
+	}
+	if complaint.Priority == "" {
+		complaint.Priority = models.ComplaintPriorityNormal
+	}
+	// срок решения считается от времени подачи по категории и приоритету
+	slaHours, err := complaintSLAHours(context.Background(), dbpool, complaint.Category)
+	if err != nil {
+		return err
+	}
+	// номер и гость, указанные вместе с бронированием, должны быть из этого бронирования
+	if complaint.BookingID != nil && (complaint.Room != nil || complaint.GuestID != nil) {
+		var matches bool
+		err = dbpool.QueryRow(context.Background(),
+			`SELECT EXISTS(SELECT 1 FROM GUESTS_IN_BOOKINGS
+				WHERE booking_id = $1 AND ($2::INT IS NULL OR room = $2) AND ($3::INT IS NULL OR guest_id = $3))`,
+			complaint.BookingID, complaint.Room, complaint.GuestID).Scan(&matches)
+		if err != nil {
+			return fmt.Errorf("error checking complaint booking: %v", err)
+		}
+		if !matches {
+			return ErrComplaintBookingMismatch
+		}
+	}
+	ctx := context.Background()
+	tx, err := dbpool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("error beginning transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	issued := time.Now()
+	var id int
+	err = tx.QueryRow(ctx,
+		`INSERT INTO COMPLAINTS(reason, commentary, issue_date, booking_id, room, guest_id, status_code,
+			category_code, priority, assigned_to, sla_due_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING id`,
+		complaint.Reason, complaint.Commentary, issued, complaint.BookingID, complaint.Room, complaint.GuestID,
+		models.ComplaintStatusOpen,
+		complaint.Category, complaint.Priority, complaint.AssignedTo,
+		services.ComplaintSLADue(issued, slaHours, complaint.Priority)).Scan(&id)
 	if err != nil {
 		return fmt.Errorf("error inserting complaint: %v", err)
 	}
+	err = addComplaintActivity(ctx, tx, id, models.ComplaintActivityCreated, nil, nil, userID)
+	if err != nil {
+		return err
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("error committing complaint: %v", err)
+	}
 	return nil
 }
 
-func DeleteComplaint(dbpool *pgxpool.Pool, complaintID int) error {
-	_, err := dbpool.Exec(context.Background(), `DELETE FROM COMPLAINTS WHERE ID = $1`, complaintID)
+// DeleteComplaint скрывает жалобу: она помечается удалённой, а журнал работы по ней сохраняется
+func DeleteComplaint(dbpool *pgxpool.Pool, complaintID int, userID *int) error {
+	ctx := context.Background()
+	var compensated bool
+	err := dbpool.QueryRow(ctx,
+		`SELECT EXISTS(SELECT 1 FROM COMPLAINT_COMPENSATIONS WHERE complaint_id = $1)`, complaintID).Scan(&compensated)
+	if err != nil {
+		return fmt.Errorf("error checking complaint compensations: %v", err)
+	}
+	if compensated {
+		return ErrComplaintCompensated
+	}
+	tx, err := dbpool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("error beginning transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	result, err := tx.Exec(ctx,
+		`UPDATE COMPLAINTS SET deleted_at = NOW(), deleted_by = $2 WHERE id = $1 AND deleted_at IS NULL`,
+		complaintID, userID)
 	if err != nil {
 		return fmt.Errorf("error deleting complaint: %v", err)
 	}
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("complaint with ID %d not found", complaintID)
+	}
+	if err = addComplaintActivity(ctx, tx, complaintID, models.ComplaintActivityDeleted, nil, nil, userID); err != nil {
+		return err
+	}
+	if err = writeAudit(ctx, tx, "complaint", complaintID, "delete", nil, userID); err != nil {
+		return err
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("error committing complaint deletion: %v", err)
+	}
 	return nil
 }
 
-func UpdateComplaint(dbpool *pgxpool.Pool, c models.UpdateComplaintRequest) error {
+// UpdateComplaint меняет текст, категорию и приоритет жалобы; смена статуса проходит по правилам переходов.
+// Дата подачи не меняется. Изменённые поля со старыми и новыми значениями записываются в журнал жалобы.
+func UpdateComplaint(dbpool *pgxpool.Pool, c models.UpdateComplaintRequest, userID *int) error {
+	ctx := context.Background()
 	var statusCode int
-	err := dbpool.QueryRow(context.Background(), `SELECT status_code FROM COMPLAINT_STATUSES WHERE name = $1`, c.Status).Scan(&statusCode)
+	err := dbpool.QueryRow(ctx, `SELECT status_code FROM COMPLAINT_STATUSES WHERE name = $1`, c.Status).Scan(&statusCode)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return fmt.Errorf("статус %s не найден", c.Status)
 		}
 		return fmt.Errorf("ошибка при поиске status_code: %v", err)
 	}
-
-	query := `
-		UPDATE COMPLAINTS 
-		SET reason = $1, commentary = $2, issue_date = $3, status_code = $4
-		WHERE id = $5`
-	result, err := dbpool.Exec(context.Background(), query, c.Reason, c.Commentary, c.IssueDate, statusCode, c.ID)
+	tx, err := dbpool.Begin(ctx)
 	if err != nil {
-		log.Printf("Error updating complaint: %v", err)
-		return fmt.Errorf("error updating complaint: %v", err)
+		return fmt.Errorf("error beginning transaction: %v", err)
 	}
+	defer tx.Rollback(ctx)
 
-	if result.RowsAffected() == 0 {
+	var current models.ComplaintResponse
+	err = pgxscan.Get(ctx, tx, &current,
+		`SELECT id, reason, commentary, status_code, category_code AS category, priority, issue_date, sla_due_at
+		FROM COMPLAINTS WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`, c.ID)
+	if err != nil {
 		return fmt.Errorf("complaint with ID %d not found", c.ID)
 	}
+	if statusCode != current.StatusCode && !services.CanTransitionComplaint(current.StatusCode, statusCode) {
+		return ErrInvalidComplaintTransition
+	}
+	if c.Category == "" {
+		c.Category = current.Category
+	}
+	if c.Priority == "" {
+		c.Priority = current.Priority
+	}
+	// при смене категории или приоритета срок пересчитывается от даты подачи
+	slaDueAt := current.SLADueAt
+	if c.Category != current.Category || c.Priority != current.Priority {
+		slaHours, err := complaintSLAHours(ctx, tx, c.Category)
+		if err != nil {
+			return err
+		}
+		slaDueAt = services.ComplaintSLADue(current.IssueDate, slaHours, c.Priority)
+	}
 
+	changes := map[string]models.FieldChange{}
+	if c.Reason != current.Reason {
+		changes["reason"] = models.FieldChange{From: current.Reason, To: c.Reason}
+	}
+	if !equalStringPtr(c.Commentary, current.Commentary) {
+		changes["commentary"] = models.FieldChange{From: current.Commentary, To: c.Commentary}
+	}
+	if c.Category != current.Category {
+		changes["category"] = models.FieldChange{From: current.Category, To: c.Category}
+	}
+	if c.Priority != current.Priority {
+		changes["priority"] = models.FieldChange{From: current.Priority, To: c.Priority}
+	}
+	if !slaDueAt.Equal(current.SLADueAt) {
+		changes["sla_due_at"] = models.FieldChange{From: current.SLADueAt, To: slaDueAt}
+	}
+	if len(changes) > 0 {
+		_, err = tx.Exec(ctx,
+			`UPDATE COMPLAINTS
+			SET reason = $1, commentary = $2, category_code = $3, priority = $4, sla_due_at = $5
+			WHERE id = $6`,
+			c.Reason, c.Commentary, c.Category, c.Priority, slaDueAt, c.ID)
+		if err != nil {
+			log.Printf("Error updating complaint: %v", err)
+			return fmt.Errorf("error updating complaint: %v", err)
+		}
+		err = addComplaintActivity(ctx, tx, c.ID, models.ComplaintActivityEdit, nil, changes, userID)
+		if err != nil {
+			return err
+		}
+	}
+	if statusCode != current.StatusCode {
+		if err = changeComplaintStatus(ctx, tx, c.ID, statusCode, userID); err != nil {
+			return err
+		}
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("error committing complaint update: %v", err)
+	}
 	return nil
-
 }
 
 func GetAllPayments(dbpool *pgxpool.Pool) ([]models.PaymentResponse, error) {
 	var payments []models.PaymentResponse
 	err := pgxscan.Select(context.Background(), dbpool, &payments,
-		`select p.id, p.booking_id, p.amount, p.pay_date, pm.name as method_name, ps.name as status_name from payments p
+		`select p.id, p.booking_id, p.amount, p.original_amount, p.original_currency, p.exchange_rate,
+				p.pay_date, pm.name as method_name, ps.name as status_name from payments p
 				join payment_methods pm on p.method_code = pm.code
 				join payment_statuses ps on p.status_code = ps.status_code`)
 	if err != nil {
 		return nil, fmt.Errorf("error getting all payments: %v", err)
 	}
+	for i := range payments {
+		payments[i].OriginalAmount = payments[i].OriginalAmount.WithCurrency(payments[i].OriginalCurrency)
+	}
 	return payments, nil
 }
 
@@ -341,10 +842,61 @@ func GetPaymentByID(dbpool *pgxpool.Pool, id int) (models.Payment, error) {
 	return payment, nil
 }
 
-func CreatePayment(dbpool *pgxpool.Pool, b models.CreateBookingInput, amount float64, bookingID int) error {
-	_, err := dbpool.Exec(context.Background(),
-		`INSERT INTO Payments(booking_id, pay_date, amount, method_code, status_code) VALUES ($1, $2, $3, $4, $5)`,
-		bookingID, time.Now(), amount, b.MethodCode, 1)
+// paymentMethodKind сообщает, является ли способ оплаты сертификатом или баллами
+func paymentMethodKind(ctx context.Context, q pgxscan.Querier, methodCode int) (isVoucher, isLoyalty bool, err error) {
+	var kind struct {
+		IsVoucher bool
+		IsLoyalty bool
+	}
+	err = pgxscan.Get(ctx, q, &kind,
+		`SELECT is_voucher, is_loyalty FROM PAYMENT_METHODS WHERE code = $1`, methodCode)
+	if err != nil {
+		return false, false, fmt.Errorf("error getting payment method: %v", err)
+	}
+	return kind.IsVoucher, kind.IsLoyalty, nil
+}
+
+func CreatePayment(dbpool *pgxpool.Pool, p models.CreatePaymentInput) error {
+	isVoucher, isLoyalty, err := paymentMethodKind(context.Background(), dbpool, p.MethodCode)
+	if err != nil {
+		return err
+	}
+	if isVoucher {
+		return redeemVoucher(dbpool, p)
+	}
+	if isLoyalty {
+		return redeemLoyaltyPoints(dbpool, p)
+	}
+	return insertPayment(context.Background(), dbpool, dbpool, p)
+}
+
+// insertPayment заводит денежный платёж, ожидающий подтверждения; курс читается из dbpool, запись идёт через q
+func insertPayment(ctx context.Context, dbpool *pgxpool.Pool, q execer, p models.CreatePaymentInput) error {
+	payDate := time.Now()
+	currency := models.DefaultCurrency
+	if p.Currency != "" {
+		var err error
+		currency, err = services.NormalizeCurrency(p.Currency)
+		if err != nil {
+			return err
+		}
+	}
+	// Платёж в иностранной валюте пересчитываем в базовую по курсу на дату оплаты
+	rate, err := GetEffectiveRate(dbpool, currency, payDate)
+	if err != nil {
+		return err
+	}
+	original := models.NewCurrencyAmount(p.Amount, currency)
+	amount, err := services.ConvertToBase(original, rate)
+	if err != nil {
+		return fmt.Errorf("error converting payment amount: %v", err)
+	}
+	_, err = q.Exec(ctx,
+		`INSERT INTO Payments(
+				booking_id, pay_date, amount,
+				original_amount, original_currency, exchange_rate,
+				method_code, status_code) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		p.BookingID, payDate, amount, original, currency, rate, p.MethodCode, 1)
 	if err != nil {
 		log.Printf("error inserting payment: %v", err)
 		return fmt.Errorf("error inserting payment: %v", err)
@@ -353,10 +905,47 @@ func CreatePayment(dbpool *pgxpool.Pool, b models.CreateBookingInput, amount flo
 }
 
 func DeletePayment(dbpool *pgxpool.Pool, paymentID int) error {
-	_, err := dbpool.Exec(context.Background(), `DELETE FROM Payments WHERE id = $1`, paymentID)
+	var bookingID int
+	var payDate time.Time
+	err := dbpool.QueryRow(context.Background(), `SELECT booking_id, pay_date FROM Payments WHERE id = $1`, paymentID).Scan(&bookingID, &payDate)
+	if err != nil {
+		return fmt.Errorf("error getting payment: %v", err)
+	}
+	// платёж закрытого дня уже попал в отчёт аудита, его можно только вернуть
+	if err = ensureDateOpen(dbpool, payDate); err != nil {
+		return err
+	}
+	ctx := context.Background()
+	tx, err := dbpool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("error beginning transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+	_, err = restoreVoucherRedemption(ctx, tx, paymentID)
+	if err != nil {
+		return err
+	}
+	_, err = restoreLoyaltyRedemption(ctx, tx, paymentID)
+	if err != nil {
+		return err
+	}
+	// Проводки по платежу остаются в книге, поэтому сначала сторнируем их
+	err = reverseNet(ctx, tx, "payment_id", paymentID, models.LedgerTransaction{
+		Kind:      models.LedgerKindReversal,
+		BookingID: &bookingID,
+		PaymentID: &paymentID,
+		Memo:      fmt.Sprintf("Удаление платежа %d", paymentID),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `DELETE FROM Payments WHERE id = $1`, paymentID)
 	if err != nil {
 		return fmt.Errorf("error deleting payment: %v", err)
 	}
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("error committing payment deletion: %v", err)
+	}
 	return nil
 }
 
@@ -365,12 +954,16 @@ type GetAllRoomsResult struct {
 	CategoryName string `gorm:"column:category_name" json:"category_name"`
 	StateName    string `gorm:"column:state_name" json:"state_name"`
 	Capacity     int    `gorm:"column:capacity" json:"capacity"`
+	Active       bool   `gorm:"column:active" json:"active"`
+	// HousekeepingStatus — статус уборки: dirty, cleaning, clean или inspected
+	HousekeepingStatus string `gorm:"column:housekeeping_status" json:"housekeeping_status"`
 }
 
 func GetAllRooms(dbpool *pgxpool.Pool) ([]GetAllRoomsResult, error) {
 	var rooms []GetAllRoomsResult
 	err := pgxscan.Select(context.Background(), dbpool, &rooms,
-		`SELECT r.number, rc.name AS category_name, rs.name AS state_name, rc.capacity 
+		`SELECT r.number, rc.name AS category_name, rs.name AS state_name, rc.capacity, r.active AND rc.active AS active,
+			r.housekeeping_status
 		FROM rooms r
 		JOIN room_categories rc ON rc.code = r.category_code
 		JOIN room_states rs ON rs.state_code = r.state_code`)
@@ -386,7 +979,7 @@ func SetMetrics(dbpool *pgxpool.Pool) (models.SetMetricsResponse, error) {
 	ROUND(
 	(
 			SELECT
-				COUNT(G.PASSPORT_NO)
+				COUNT(G.ID)
 			FROM
 				BOOKINGS B
 				JOIN BOOKING_STATUSES BS ON BS.STATUS_CODE = B.STATUS_CODE
@@ -402,6 +995,8 @@ func SetMetrics(dbpool *pgxpool.Pool) (models.SetMetricsResponse, error) {
 			FROM
 				ROOMS R
 				JOIN ROOM_CATEGORIES RC ON R.CATEGORY_CODE = RC.CODE
+			WHERE
+				R.ACTIVE AND RC.ACTIVE
 		) * 1.0
 	, 0) AS OCCUPANCY_RATIO`).Scan(&metrics.Occupancy)
 
@@ -427,7 +1022,13 @@ func SetMetrics(dbpool *pgxpool.Pool) (models.SetMetricsResponse, error) {
 	}
 
 	err = dbpool.QueryRow(context.Background(),
-		`SELECT COUNT(ID) AS OPEN_COMPLAINTS FROM COMPLAINTS WHERE STATUS_CODE = 1 OR STATUS_CODE = 2`).Scan(&metrics.OpenComplaints)
+		`SELECT COUNT(ID) AS OPEN_COMPLAINTS FROM COMPLAINTS WHERE (STATUS_CODE = 1 OR STATUS_CODE = 2) AND DELETED_AT IS NULL`).Scan(&metrics.OpenComplaints)
+	if err != nil {
+		return metrics, fmt.Errorf("error setting metrics: %v", err)
+	}
+
+	err = dbpool.QueryRow(context.Background(),
+		`SELECT COUNT(ID) FROM COMPLAINTS WHERE ESCALATED_AT IS NOT NULL AND STATUS_CODE <> 3 AND DELETED_AT IS NULL`).Scan(&metrics.EscalatedComplaints)
 	if err != nil {
 		return metrics, fmt.Errorf("error setting metrics: %v", err)
 	}
@@ -441,6 +1042,9 @@ func SetMetrics(dbpool *pgxpool.Pool) (models.SetMetricsResponse, error) {
 					R.NUMBER
 				FROM
 					ROOMS R
+					JOIN ROOM_CATEGORIES RC ON R.CATEGORY_CODE = RC.CODE
+				WHERE
+					R.ACTIVE AND RC.ACTIVE
 				EXCEPT
 				SELECT
 					GIB.ROOM AS NUMBER
@@ -462,13 +1066,17 @@ func SetMetrics(dbpool *pgxpool.Pool) (models.SetMetricsResponse, error) {
 		return metrics, fmt.Errorf("error setting metrics: %v", err)
 	}
 
+	// Выручка считается по счетам выручки в главной книге, а не по суммам платежей
 	err = dbpool.QueryRow(context.Background(),
 		`SELECT
-				COALESCE(SUM(AMOUNT), 0) AS REVENUE_7DAYS
+				COALESCE(SUM(LE.CREDIT - LE.DEBIT), 0) AS REVENUE_7DAYS
 			FROM
-				PAYMENTS
+				LEDGER_ENTRIES LE
+				JOIN LEDGER_TRANSACTIONS LT ON LT.ID = LE.TRANSACTION_ID
+				JOIN ACCOUNTS A ON A.CODE = LE.ACCOUNT_CODE
 			WHERE
-				PAY_DATE BETWEEN NOW() - INTERVAL '7 DAYS' AND NOW()`).Scan(&metrics.Revenue7Days)
+				A.TYPE = 'revenue'
+			AND LT.POSTED_AT BETWEEN NOW() - INTERVAL '7 DAYS' AND NOW()`).Scan(&metrics.Revenue7Days)
 	if err != nil {
 		return metrics, fmt.Errorf("error setting metrics: %v", err)
 	}
@@ -478,9 +1086,12 @@ func SetMetrics(dbpool *pgxpool.Pool) (models.SetMetricsResponse, error) {
 	COALESCE(ROUND(
 		(
 			SELECT
-				SUM(AMOUNT)
+				SUM(LE.CREDIT - LE.DEBIT)
 			FROM
-				PAYMENTS P
+				LEDGER_ENTRIES LE
+				JOIN ACCOUNTS A ON A.CODE = LE.ACCOUNT_CODE
+			WHERE
+				A.TYPE = 'revenue'
 		) / (
 			SELECT
 				COUNT(*)
@@ -507,17 +1118,30 @@ func SetMetrics(dbpool *pgxpool.Pool) (models.SetMetricsResponse, error) {
 
 	err = dbpool.QueryRow(context.Background(),
 		`SELECT
-			COALESCE(SUM(AMOUNT), 0) AS REVPAC_7DAYS
+			COALESCE(SUM(R.REVENUE), 0) AS REVPAC_7DAYS
 		FROM
-			PAYMENTS P
-			JOIN BOOKINGS B ON P.BOOKING_ID = B.ID
-			JOIN GUESTS_IN_BOOKINGS GIB ON GIB.BOOKING_ID = P.BOOKING_ID
+			(
+				SELECT LT.BOOKING_ID, SUM(LE.CREDIT - LE.DEBIT) AS REVENUE
+				FROM LEDGER_ENTRIES LE
+				JOIN LEDGER_TRANSACTIONS LT ON LT.ID = LE.TRANSACTION_ID
+				JOIN ACCOUNTS A ON A.CODE = LE.ACCOUNT_CODE
+				WHERE A.TYPE = 'revenue'
+				GROUP BY LT.BOOKING_ID
+			) R
+			JOIN BOOKINGS B ON R.BOOKING_ID = B.ID
+			JOIN GUESTS_IN_BOOKINGS GIB ON GIB.BOOKING_ID = R.BOOKING_ID
 			JOIN GUESTS G ON G.ID = GIB.GUEST_ID
 		WHERE 
 		    START_DATE < NOW() + INTERVAL '7 DAYS' `).Scan(&metrics.RevPac)
 	if err != nil {
 		return metrics, fmt.Errorf("error setting metrics: %v", err)
 	}
+	// главная книга ведётся в базовой валюте
+	metrics.Currency = models.DefaultCurrency
+	metrics.MigrationDeadlines, err = GetMigrationDeadlines(dbpool)
+	if err != nil {
+		return metrics, fmt.Errorf("error setting metrics: %v", err)
+	}
 	return metrics, nil
 }
 
@@ -549,38 +1173,21 @@ func GetUser(dbpool *pgxpool.Pool, user *models.UserRequestBody) (*models.User,
 	return &u, nil
 }
 
-func DeleteUser(dbpool *pgxpool.Pool, usernameToDelete string) error {
-	_, err := dbpool.Exec(context.Background(), `DELETE FROM USERS WHERE USERNAME = $1`, usernameToDelete)
+func SetUserRole(dbpool *pgxpool.Pool, username, role string) error {
+	result, err := dbpool.Exec(context.Background(), `UPDATE USERS SET ROLE = $1 WHERE USERNAME = $2`, role, username)
 	if err != nil {
-		return fmt.Errorf("error deleting user: %v", err)
+		return fmt.Errorf("error setting user role: %v", err)
 	}
-	return nil
-}
-
-func CreateGuest(dbpool *pgxpool.Pool, guest models.Guest) error {
-	_, err := dbpool.Exec(context.Background(),
-		`INSERT INTO GUESTS (name, phone_number, passport_no) VALUES ($1, $2, $3)`,
-		guest.Name, guest.PhoneNumber, guest.PassportNo)
-	if err != nil {
-		return fmt.Errorf("error inserting guest: %v", err)
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("user %s not found", username)
 	}
 	return nil
 }
 
-func GetAllGuests(dbpool *pgxpool.Pool) ([]models.Guest, error) {
-	var guests []models.Guest
-	err := pgxscan.Select(context.Background(), dbpool, &guests, "SELECT * FROM GUESTS ORDER BY id")
-	if err != nil {
-		log.Printf("error getting all guests: %v", err)
-		return nil, fmt.Errorf("error getting all guests: %v", err)
-	}
-	return guests, nil
-}
-
-func DeleteGuest(dbpool *pgxpool.Pool, id int) error {
-	_, err := dbpool.Exec(context.Background(), `DELETE FROM GUESTS WHERE ID = $1`, id)
+func DeleteUser(dbpool *pgxpool.Pool, usernameToDelete string) error {
+	_, err := dbpool.Exec(context.Background(), `DELETE FROM USERS WHERE USERNAME = $1`, usernameToDelete)
 	if err != nil {
-		return fmt.Errorf("error deleting guest: %v", err)
+		return fmt.Errorf("error deleting user: %v", err)
 	}
 	return nil
 }
@@ -597,7 +1204,7 @@ func GetRoomCategories(dbpool *pgxpool.Pool) ([]models.RoomCategory, error) {
 
 func GetPaymentMethods(dbpool *pgxpool.Pool) ([]models.PaymentMethod, error) {
 	var paymentMethods []models.PaymentMethod
-	err := pgxscan.Select(context.Background(), dbpool, &paymentMethods, "SELECT * FROM Payment_Methods")
+	err := pgxscan.Select(context.Background(), dbpool, &paymentMethods, "SELECT code, name, is_voucher, is_loyalty FROM Payment_Methods ORDER BY code")
 	if err != nil {
 		log.Printf("error getting payment methods: %v", err)
 		return nil, fmt.Errorf("error getting payment methods: %v", err)
@@ -611,7 +1218,8 @@ func GetFreeRooms(dbpool *pgxpool.Pool, start string, end string, categoryCode i
 		`SELECT r.number
 		FROM 
 		    ROOMS r
-		WHERE r.number NOT IN (
+		    JOIN ROOM_CATEGORIES rc ON rc.code = r.category_code
+		WHERE r.active AND rc.active AND r.number NOT IN (
 			SELECT g.room
 			FROM BOOKINGS b
 			JOIN GUESTS_IN_BOOKINGS g ON g.booking_id = b.id
@@ -624,11 +1232,22 @@ func GetFreeRooms(dbpool *pgxpool.Pool, start string, end string, categoryCode i
 	return freeRooms, nil
 }
 
-func ConfirmBooking(dbpool *pgxpool.Pool, id int) error {
-	_, err := dbpool.Exec(context.Background(), `UPDATE BOOKINGS SET STATUS_CODE = 1 WHERE ID = $1`, id)
+// ConfirmBooking заселяет гостя. Номер должен быть убран или проверен;
+// при override заселение проходит и так, а пропуск проверки записывается в журнал аудита.
+// Вместе с ErrRoomNotReady возвращаются неготовые номера.
+func ConfirmBooking(dbpool *pgxpool.Pool, id int, override bool, userID *int) ([]int, error) {
+	notReady, err := roomsNotReady(context.Background(), dbpool, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(notReady) > 0 && !override {
+		return notReady, ErrRoomNotReady
+	}
+	// подтверждение бронирования означает заселение гостя
+	_, err = dbpool.Exec(context.Background(), `UPDATE BOOKINGS SET STATUS_CODE = 1, CHECK_IN = COALESCE(CHECK_IN, NOW()), NO_SHOW_CANDIDATE = FALSE WHERE ID = $1`, id)
 	if err != nil {
 		log.Printf("error updating booking: %v", err)
-		return fmt.Errorf("error updating booking: %v", err)
+		return nil, fmt.Errorf("error updating booking: %v", err)
 	}
 	_, err = dbpool.Exec(context.Background(), `UPDATE ROOMS R
         SET STATE_CODE = 2
@@ -637,25 +1256,116 @@ func ConfirmBooking(dbpool *pgxpool.Pool, id int) error {
         WHERE GIB.ROOM = R.NUMBER AND B.ID = $1`, id)
 	if err != nil {
 		log.Printf("error updating room: %v", err)
-		return fmt.Errorf("error updating room: %v", err)
+		return nil, fmt.Errorf("error updating room: %v", err)
 	}
-	return nil
+	if len(notReady) > 0 {
+		err = writeAudit(context.Background(), dbpool, "booking", id, "housekeeping_override", map[string]any{
+			"rooms": notReady,
+		}, userID)
+		if err != nil {
+			return notReady, err
+		}
+	}
+	return notReady, nil
 }
 
-func ResolveComplaint(dbpool *pgxpool.Pool, id int, statusCode int) error {
-	_, err := dbpool.Exec(context.Background(), `UPDATE COMPLAINTS SET STATUS_CODE = $1 WHERE ID = $2`, statusCode, id)
+func ConfirmPayment(dbpool *pgxpool.Pool, id int, userID *int) error {
+	ctx := context.Background()
+	tx, err := dbpool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("error beginning transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+	var bookingID int
+	var amount models.Money
+	err = tx.QueryRow(ctx,
+		`UPDATE PAYMENTS SET STATUS_CODE = 2 WHERE ID = $1 AND STATUS_CODE = 1 RETURNING BOOKING_ID, AMOUNT`,
+		id).Scan(&bookingID, &amount)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("payment with ID %d not found or already confirmed", id)
+		}
+		log.Printf("error updating payment: %v", err)
+		return fmt.Errorf("error updating payment: %v", err)
+	}
+	account, err := paymentLedgerAccount(ctx, tx, id)
 	if err != nil {
-		log.Printf("error updating complaint: %v", err)
-		return fmt.Errorf("error updating complaint: %v", err)
+		return err
+	}
+	_, err = PostTransaction(ctx, tx, models.LedgerTransaction{
+		Kind:      models.LedgerKindPayment,
+		BookingID: &bookingID,
+		PaymentID: &id,
+		Memo:      fmt.Sprintf("Оплата по бронированию %d", bookingID),
+		CreatedBy: userID,
+		Entries:   services.PaymentEntries(account, amount),
+	})
+	if err != nil {
+		return err
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("error committing payment: %v", err)
 	}
 	return nil
 }
 
-func ConfirmPayment(dbpool *pgxpool.Pool, id int) error {
-	_, err := dbpool.Exec(context.Background(), `UPDATE PAYMENTS SET STATUS_CODE = 2 WHERE ID = $1`, id)
+// RefundPayment возвращает гостю подтверждённый платёж
+func RefundPayment(dbpool *pgxpool.Pool, id int, userID *int) error {
+	ctx := context.Background()
+	tx, err := dbpool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("error beginning transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+	var bookingID int
+	var amount models.Money
+	err = tx.QueryRow(ctx,
+		`UPDATE PAYMENTS SET STATUS_CODE = 3 WHERE ID = $1 AND STATUS_CODE = 2 RETURNING BOOKING_ID, AMOUNT`,
+		id).Scan(&bookingID, &amount)
 	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("payment with ID %d not found or not paid", id)
+		}
 		log.Printf("error updating payment: %v", err)
 		return fmt.Errorf("error updating payment: %v", err)
 	}
+	// при возврате оплаты сертификатом сумма возвращается на сертификат, а не гостю
+	restored, err := restoreVoucherRedemption(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+	if restored {
+		err = reverseNet(ctx, tx, "payment_id", id, models.LedgerTransaction{
+			Kind:      models.LedgerKindRefund,
+			BookingID: &bookingID,
+			PaymentID: &id,
+			Memo:      fmt.Sprintf("Возврат на сертификат по бронированию %d", bookingID),
+			CreatedBy: userID,
+		})
+	} else {
+		// оплата баллами: баллы возвращаются гостю, обязательство восстанавливается обычной проводкой возврата
+		if _, err = restoreLoyaltyRedemption(ctx, tx, id); err != nil {
+			return err
+		}
+		var account string
+		account, err = paymentLedgerAccount(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		_, err = PostTransaction(ctx, tx, models.LedgerTransaction{
+			Kind:      models.LedgerKindRefund,
+			BookingID: &bookingID,
+			PaymentID: &id,
+			Memo:      fmt.Sprintf("Возврат по бронированию %d", bookingID),
+			CreatedBy: userID,
+			Entries:   services.RefundEntries(account, amount),
+		})
+	}
+	if err != nil {
+		return err
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("error committing refund: %v", err)
+	}
 	return nil
 }
@@ -0,0 +1,370 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"log"
+	"mis_kursach_backend/internal/models"
+	"mis_kursach_backend/internal/services"
+	"time"
+)
+
+// ErrFolioUnpaid возвращается при выезде гостя с непогашенным балансом
+var ErrFolioUnpaid = errors.New("folio has unpaid balance")
+
+func GetServices(dbpool *pgxpool.Pool, activeOnly bool) ([]models.Service, error) {
+	var catalog []models.Service
+	err := pgxscan.Select(context.Background(), dbpool, &catalog,
+		`SELECT id, code, name, price, tax_rate, active
+		FROM SERVICES
+		WHERE active OR NOT $1
+		ORDER BY id`, activeOnly)
+	if err != nil {
+		return nil, fmt.Errorf("error getting services: %v", err)
+	}
+	return catalog, nil
+}
+
+func CreateService(dbpool *pgxpool.Pool, s models.ServiceInput) (int, error) {
+	var id int
+	active := true
+	if s.Active != nil {
+		active = *s.Active
+	}
+	err := pgxscan.Get(context.Background(), dbpool, &id,
+		`INSERT INTO SERVICES (code, name, price, tax_rate, active) VALUES ($1, $2, $3, $4, $5) RETURNING ID`,
+		s.Code, s.Name, s.Price, s.TaxRate, active)
+	if err != nil {
+		return 0, fmt.Errorf("error inserting service: %v", err)
+	}
+	return id, nil
+}
+
+func UpdateService(dbpool *pgxpool.Pool, id int, s models.ServiceInput) error {
+	result, err := dbpool.Exec(context.Background(),
+		`UPDATE SERVICES
+		SET code = $1, name = $2, price = $3, tax_rate = $4, active = COALESCE($5, active)
+		WHERE id = $6`,
+		s.Code, s.Name, s.Price, s.TaxRate, s.Active, id)
+	if err != nil {
+		return fmt.Errorf("error updating service: %v", err)
+	}
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("service with ID %d not found", id)
+	}
+	return nil
+}
+
+func getFolioCharges(ctx context.Context, q pgxscan.Querier, bookingID int) ([]models.FolioCharge, error) {
+	var charges []models.FolioCharge
+	err := pgxscan.Select(ctx, q, &charges,
+		`SELECT
+				fc.id, fc.booking_id, fc.service_id, s.name AS service_name,
+				fc.quantity, fc.unit_price, fc.tax_rate, fc.amount, fc.tax_amount,
+				fc.commentary, fc.posted_at, fc.posted_by,
+				fc.voided_at, fc.voided_by, fc.void_reason
+			FROM FOLIO_CHARGES fc
+			JOIN SERVICES s ON s.id = fc.service_id
+			WHERE fc.booking_id = $1
+			ORDER BY fc.posted_at, fc.id`, bookingID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting folio charges: %v", err)
+	}
+	return charges, nil
+}
+
+// bookingBalanceSQL — долг по бронированию b: проживание и услуги минус оплаты, суммы в счетах организации
+// и компенсации по жалобам. Один расчёт на фолио, карточку бронирования и историю гостя.
+const bookingBalanceSQL = `(b.total_sum
+				+ COALESCE((SELECT SUM(amount) FROM FOLIO_CHARGES WHERE booking_id = b.id AND voided_at IS NULL), 0)
+				- COALESCE((SELECT SUM(amount) FROM PAYMENTS WHERE booking_id = b.id AND status_code = 2), 0)
+				- COALESCE((SELECT SUM(amount) FROM COMPANY_INVOICE_LINES WHERE booking_id = b.id), 0)
+				- COALESCE((SELECT SUM(amount) FROM COMPLAINT_COMPENSATIONS
+					WHERE booking_id = b.id AND kind <> '` + models.CompensationRefund + `'), 0))`
+
+func GetFolio(dbpool *pgxpool.Pool, bookingID int) (models.Folio, error) {
+	return getFolio(context.Background(), dbpool, bookingID)
+}
+
+// getFolio собирает фолио через переданное соединение, чтобы его можно было прочитать внутри транзакции
+func getFolio(ctx context.Context, q pgxscan.Querier, bookingID int) (models.Folio, error) {
+	folio := models.Folio{BookingID: bookingID}
+	err := pgxscan.Get(ctx, q, &folio.RoomTotal, `SELECT total_sum FROM BOOKINGS WHERE id = $1`, bookingID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return folio, fmt.Errorf("booking with ID %d not found", bookingID)
+		}
+		return folio, fmt.Errorf("error getting booking: %v", err)
+	}
+	folio.Charges, err = getFolioCharges(ctx, q, bookingID)
+	if err != nil {
+		return folio, err
+	}
+	err = pgxscan.Get(ctx, q, &folio.Paid,
+		`SELECT COALESCE(SUM(amount), 0) FROM PAYMENTS WHERE booking_id = $1 AND status_code = 2`, bookingID)
+	if err != nil {
+		return folio, fmt.Errorf("error getting paid amount: %v", err)
+	}
+	for _, c := range folio.Charges {
+		// аннулированные строки остаются в фолио, но не входят в сумму
+		if c.VoidedAt != nil {
+			continue
+		}
+		folio.ExtrasTotal = folio.ExtrasTotal.Add(c.Amount)
+	}
+	taxes, err := getBookingTaxes(ctx, q, bookingID)
+	if err != nil {
+		return folio, err
+	}
+	folio.TaxTotal = services.TaxTotal(taxes)
+	err = pgxscan.Get(ctx, q, &folio.BilledToCompany,
+		`SELECT COALESCE(SUM(amount), 0) FROM COMPANY_INVOICE_LINES WHERE booking_id = $1`, bookingID)
+	if err != nil {
+		return folio, fmt.Errorf("error getting company billed amount: %v", err)
+	}
+	err = pgxscan.Get(ctx, q, &folio.Compensations,
+		`SELECT COALESCE(SUM(amount), 0) FROM COMPLAINT_COMPENSATIONS WHERE booking_id = $1 AND kind <> $2`,
+		bookingID, models.CompensationRefund)
+	if err != nil {
+		return folio, fmt.Errorf("error getting complaint compensations: %v", err)
+	}
+	folio.Total = folio.RoomTotal.Add(folio.ExtrasTotal)
+	err = pgxscan.Get(ctx, q, &folio.Balance, `SELECT `+bookingBalanceSQL+` FROM BOOKINGS b WHERE b.id = $1`, bookingID)
+	if err != nil {
+		return folio, fmt.Errorf("error getting folio balance: %v", err)
+	}
+	return folio, nil
+}
+
+func PostCharge(dbpool *pgxpool.Pool, c models.PostChargeInput, userID *int) (int, error) {
+	var service models.Service
+	var chargeID int
+	if c.Quantity <= 0 {
+		return 0, fmt.Errorf("quantity must be positive")
+	}
+	err := pgxscan.Get(context.Background(), dbpool, &service,
+		`SELECT id, code, name, price, tax_rate, active FROM SERVICES WHERE id = $1`, c.ServiceID)
+	if err != nil {
+		return 0, fmt.Errorf("error fetching service: %v", err)
+	}
+	if !service.Active {
+		return 0, fmt.Errorf("service %s is not active", service.Code)
+	}
+	// Цена из каталога, если сотрудник не указал фактическую (например, счёт ресторана)
+	unitPrice := service.Price
+	if c.UnitPrice != nil {
+		unitPrice = *c.UnitPrice
+	}
+	if unitPrice.IsNegative() {
+		return 0, fmt.Errorf("unit price cannot be negative")
+	}
+	gross, err := unitPrice.Mul(int64(c.Quantity))
+	if err != nil {
+		return 0, fmt.Errorf("error pricing charge: %v", err)
+	}
+	rules, err := getActiveTaxRules(dbpool, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	var taxes []models.TaxLine
+	// НДС по ставке из каталога уже включён в цену услуги; если НДС на услуги задан
+	// налоговым правилом, считается только оно, иначе налог вошёл бы в строку дважды
+	if services.RulesIncludeVAT(rules, models.ChargeTypeService) {
+		service.TaxRate = models.Decimal{}
+	}
+	if !service.TaxRate.IsZero() {
+		vat, err := services.IncludedTax(gross, service.TaxRate)
+		if err != nil {
+			return 0, fmt.Errorf("error calculating VAT: %v", err)
+		}
+		taxes = append(taxes, models.TaxLine{
+			Name:       fmt.Sprintf("НДС %s%%", service.TaxRate),
+			ChargeType: models.ChargeTypeService,
+			Taxable:    gross,
+			Amount:     vat,
+			Inclusive:  true,
+		})
+	}
+	ruleTaxes, err := services.ApplyTaxes(rules, models.ChargeTypeService, gross,
+		services.TaxContext{Nights: 1, Adults: 1})
+	if err != nil {
+		return 0, err
+	}
+	taxes = append(taxes, ruleTaxes...)
+	// В сумму строки входят налоги, начисляемые сверх цены
+	amount := gross.Add(services.ExclusiveTaxTotal(taxes))
+	taxAmount := services.TaxTotal(taxes)
+	companyID, err := bookingCompanyID(dbpool, c.BookingID)
+	if err != nil {
+		return 0, err
+	}
+	ctx := context.Background()
+	tx, err := dbpool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("error beginning transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+	if companyID != nil {
+		err = ensureCreditAvailable(ctx, tx, *companyID, amount)
+		if err != nil {
+			return 0, err
+		}
+	}
+	err = pgxscan.Get(ctx, tx, &chargeID,
+		`INSERT INTO FOLIO_CHARGES (
+				booking_id, service_id, quantity, unit_price, tax_rate,
+				amount, tax_amount, commentary, posted_at, posted_by)
+			SELECT b.id, $2, $3, $4, $5, $6, $7, $8, $9, $10
+			FROM BOOKINGS b
+			WHERE b.id = $1 AND b.check_out IS NULL
+			RETURNING id`,
+		c.BookingID, service.ID, c.Quantity, unitPrice, service.TaxRate,
+		amount, taxAmount, c.Commentary, time.Now(), userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, fmt.Errorf("booking with ID %d not found or already checked out", c.BookingID)
+		}
+		log.Printf("error inserting folio charge: %v", err)
+		return 0, fmt.Errorf("error inserting folio charge: %v", err)
+	}
+	err = insertTaxLines(ctx, tx, c.BookingID, &chargeID, taxes)
+	if err != nil {
+		return 0, err
+	}
+	_, err = PostTransaction(ctx, tx, models.LedgerTransaction{
+		Kind:          models.LedgerKindExtrasCharge,
+		BookingID:     &c.BookingID,
+		FolioChargeID: &chargeID,
+		Memo:          fmt.Sprintf("%s x%d", service.Name, c.Quantity),
+		CreatedBy:     userID,
+		Entries:       services.ChargeEntries(models.AccountExtrasRevenue, gross, taxes),
+	})
+	if err != nil {
+		return 0, err
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("error committing folio charge: %v", err)
+	}
+	return chargeID, nil
+}
+
+func VoidCharge(dbpool *pgxpool.Pool, v models.VoidChargeInput, userID *int) error {
+	ctx := context.Background()
+	tx, err := dbpool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("error beginning transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+	var bookingID int
+	err = tx.QueryRow(ctx,
+		`UPDATE FOLIO_CHARGES
+		SET voided_at = $1, voided_by = $2, void_reason = $3
+		WHERE id = $4 AND voided_at IS NULL
+		RETURNING booking_id`,
+		time.Now(), userID, v.Reason, v.ID).Scan(&bookingID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("charge with ID %d not found or already voided", v.ID)
+		}
+		log.Printf("error voiding folio charge: %v", err)
+		return fmt.Errorf("error voiding folio charge: %v", err)
+	}
+	err = reverseNet(ctx, tx, "folio_charge_id", v.ID, models.LedgerTransaction{
+		Kind:          models.LedgerKindVoid,
+		BookingID:     &bookingID,
+		FolioChargeID: &v.ID,
+		Memo:          v.Reason,
+		CreatedBy:     userID,
+	})
+	if err != nil {
+		return err
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("error committing void: %v", err)
+	}
+	return nil
+}
+
+func GetInvoice(dbpool *pgxpool.Pool, bookingID int) (models.Invoice, error) {
+	invoice := models.Invoice{IssuedAt: time.Now()}
+	var err error
+	invoice.Booking, err = GetBookingByID(dbpool, bookingID)
+	if err != nil {
+		return invoice, err
+	}
+	invoice.Folio, err = GetFolio(dbpool, bookingID)
+	if err != nil {
+		return invoice, err
+	}
+	invoice.Taxes, err = GetBookingTaxes(dbpool, bookingID)
+	if err != nil {
+		return invoice, err
+	}
+	err = pgxscan.Select(context.Background(), dbpool, &invoice.Payments,
+		`select p.id, p.booking_id, p.amount, p.original_amount, p.original_currency, p.exchange_rate,
+				p.pay_date, pm.name as method_name, ps.name as status_name from payments p
+				join payment_methods pm on p.method_code = pm.code
+				join payment_statuses ps on p.status_code = ps.status_code
+				where p.booking_id = $1
+				order by p.pay_date`, bookingID)
+	if err != nil {
+		return invoice, fmt.Errorf("error getting booking payments: %v", err)
+	}
+	for i := range invoice.Payments {
+		invoice.Payments[i].OriginalAmount = invoice.Payments[i].OriginalAmount.WithCurrency(invoice.Payments[i].OriginalCurrency)
+	}
+	return invoice, nil
+}
+
+// CheckOutBooking фиксирует выезд и освобождает номер.
+// Если по фолио есть долг, выезд блокируется, пока не передан force.
+func CheckOutBooking(dbpool *pgxpool.Pool, id int, force bool) (models.Folio, error) {
+	folio, err := GetFolio(dbpool, id)
+	if err != nil {
+		return folio, err
+	}
+	companyID, err := bookingCompanyID(dbpool, id)
+	if err != nil {
+		return folio, err
+	}
+	// долг по бронированию организации закрывается её ежемесячным счётом
+	if folio.Balance.IsPositive() && !force && companyID == nil {
+		return folio, ErrFolioUnpaid
+	}
+	ctx := context.Background()
+	tx, err := dbpool.Begin(ctx)
+	if err != nil {
+		return folio, fmt.Errorf("error beginning transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+	result, err := tx.Exec(ctx,
+		`UPDATE BOOKINGS SET CHECK_OUT = $1 WHERE ID = $2 AND CHECK_OUT IS NULL`, time.Now(), id)
+	if err != nil {
+		log.Printf("error updating booking: %v", err)
+		return folio, fmt.Errorf("error updating booking: %v", err)
+	}
+	if result.RowsAffected() == 0 {
+		return folio, fmt.Errorf("booking with ID %d is already checked out", id)
+	}
+	// после выезда номер ждёт уборки
+	_, err = tx.Exec(ctx, `UPDATE ROOMS R
+        SET STATE_CODE = 1, HOUSEKEEPING_STATUS = 'dirty', HOUSEKEEPING_UPDATED_AT = NOW()
+        FROM GUESTS_IN_BOOKINGS GIB
+        WHERE GIB.ROOM = R.NUMBER AND GIB.BOOKING_ID = $1`, id)
+	if err != nil {
+		log.Printf("error updating room: %v", err)
+		return folio, fmt.Errorf("error updating room: %v", err)
+	}
+	// баллы начисляются в той же транзакции, чтобы выезд не прошёл без них
+	if _, err = earnLoyaltyPoints(ctx, tx, id); err != nil {
+		return folio, err
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return folio, fmt.Errorf("error committing check-out: %v", err)
+	}
+	return folio, nil
+}
@@ -0,0 +1,215 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"log"
+	"mis_kursach_backend/internal/models"
+	"net/http"
+	"strconv"
+)
+
+func (p *PsHandler) GetServices(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	activeOnly := r.URL.Query().Get("all") != "true"
+	catalog, err := GetServices(p.dbpool, activeOnly)
+	if err != nil {
+		http.Error(w, `{"error": "failed to get services"}`, http.StatusInternalServerError)
+		log.Printf("Error getting services: %v", err)
+		return
+	}
+	if catalog == nil {
+		catalog = []models.Service{}
+	}
+	if err := json.NewEncoder(w).Encode(catalog); err != nil {
+		http.Error(w, `{"error": "failed to encode response"}`, http.StatusInternalServerError)
+		log.Printf("Error encoding services: %v", err)
+	}
+}
+
+func (p *PsHandler) CreateService(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var s models.ServiceInput
+	if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+		http.Error(w, `{"error": "invalid request body"}`, http.StatusBadRequest)
+		log.Printf("Error decoding service input: %v", err)
+		return
+	}
+	defer r.Body.Close()
+	if s.Code == "" || s.Name == "" {
+		http.Error(w, `{"error": "code and name are required"}`, http.StatusBadRequest)
+		return
+	}
+	if s.Price.IsNegative() || s.TaxRate.Sign() < 0 {
+		http.Error(w, `{"error": "price and tax rate cannot be negative"}`, http.StatusBadRequest)
+		return
+	}
+	id, err := CreateService(p.dbpool, s)
+	if err != nil {
+		http.Error(w, `{"error": "failed to create service"}`, http.StatusBadRequest)
+		log.Printf("Error creating service: %v", err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Service created successfully", "id": strconv.Itoa(id)})
+}
+
+func (p *PsHandler) UpdateService(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, `{"error": "invalid id"}`, http.StatusBadRequest)
+		return
+	}
+	var s models.ServiceInput
+	if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+		http.Error(w, `{"error": "invalid request body"}`, http.StatusBadRequest)
+		log.Printf("Error decoding service input: %v", err)
+		return
+	}
+	defer r.Body.Close()
+	if s.Code == "" || s.Name == "" {
+		http.Error(w, `{"error": "code and name are required"}`, http.StatusBadRequest)
+		return
+	}
+	if s.Price.IsNegative() || s.TaxRate.Sign() < 0 {
+		http.Error(w, `{"error": "price and tax rate cannot be negative"}`, http.StatusBadRequest)
+		return
+	}
+	err = UpdateService(p.dbpool, id, s)
+	if err != nil {
+		http.Error(w, `{"error": "failed to update service"}`, http.StatusBadRequest)
+		log.Printf("Error updating service: %v", err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	response := make(map[string]string)
+	response["message"] = "success"
+	json.NewEncoder(w).Encode(response)
+}
+
+func (p *PsHandler) GetFolio(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, `{"error": "invalid id"}`, http.StatusBadRequest)
+		log.Printf("Error parsing booking id: %v", err)
+		return
+	}
+	folio, err := GetFolio(p.dbpool, id)
+	if err != nil {
+		http.Error(w, `{"error": "folio not found"}`, http.StatusNotFound)
+		log.Printf("Error getting folio: %v", err)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(folio); err != nil {
+		http.Error(w, `{"error": "failed to encode response"}`, http.StatusInternalServerError)
+		log.Printf("Error encoding folio: %v", err)
+	}
+}
+
+func (p *PsHandler) PostCharge(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var c models.PostChargeInput
+	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+		http.Error(w, `{"error": "invalid request body"}`, http.StatusBadRequest)
+		log.Printf("Error decoding charge input: %v", err)
+		return
+	}
+	defer r.Body.Close()
+	if c.Quantity == 0 {
+		c.Quantity = 1
+	}
+	id, err := PostCharge(p.dbpool, c, userIDFromRequest(r))
+	if errors.Is(err, ErrCreditLimit) {
+		http.Error(w, `{"error": "company credit limit exceeded"}`, http.StatusConflict)
+		log.Printf("Error posting charge: %v", err)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error": "failed to post charge"}`, http.StatusBadRequest)
+		log.Printf("Error posting charge: %v", err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Charge posted successfully", "id": strconv.Itoa(id)})
+}
+
+func (p *PsHandler) VoidCharge(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var v models.VoidChargeInput
+	if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
+		http.Error(w, `{"error": "invalid request body"}`, http.StatusBadRequest)
+		log.Printf("Error decoding void input: %v", err)
+		return
+	}
+	defer r.Body.Close()
+	if v.Reason == "" {
+		http.Error(w, `{"error": "reason cannot be empty"}`, http.StatusBadRequest)
+		return
+	}
+	err := VoidCharge(p.dbpool, v, userIDFromRequest(r))
+	if err != nil {
+		http.Error(w, `{"error": "failed to void charge"}`, http.StatusBadRequest)
+		log.Printf("Error voiding charge: %v", err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	response := make(map[string]string)
+	response["message"] = "success"
+	json.NewEncoder(w).Encode(response)
+}
+
+func (p *PsHandler) GetInvoice(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, `{"error": "invalid id"}`, http.StatusBadRequest)
+		log.Printf("Error parsing booking id: %v", err)
+		return
+	}
+	invoice, err := GetInvoice(p.dbpool, id)
+	if err != nil {
+		http.Error(w, `{"error": "invoice not found"}`, http.StatusNotFound)
+		log.Printf("Error getting invoice: %v", err)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(invoice); err != nil {
+		http.Error(w, `{"error": "failed to encode response"}`, http.StatusInternalServerError)
+		log.Printf("Error encoding invoice: %v", err)
+	}
+}
+
+func (p *PsHandler) CheckOutBooking(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		http.Error(w, `{"error": "invalid request body"}`, http.StatusBadRequest)
+		log.Printf("Error decoding request body: %v", err)
+		return
+	}
+	force := r.URL.Query().Get("force") == "true"
+	folio, err := CheckOutBooking(p.dbpool, id, force)
+	if errors.Is(err, ErrFolioUnpaid) {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":   "folio has unpaid balance",
+			"balance": folio.Balance,
+		})
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error": "failed to check out booking"}`, http.StatusBadRequest)
+		log.Printf("Error checking out booking: %v", err)
+		return
+	}
+	response := map[string]interface{}{"message": "success"}
+	if folio.Balance.IsPositive() {
+		// выезд проведён принудительно, долг остаётся на фолио
+		response["warning"] = "checked out with unpaid balance"
+		response["balance"] = folio.Balance
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,241 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"log"
+	"mis_kursach_backend/internal/models"
+	"mis_kursach_backend/internal/services"
+	"strings"
+)
+
+// ErrGuestHasBookings возвращается при удалении гостя, у которого есть бронирования
+var ErrGuestHasBookings = errors.New("guest has bookings")
+
+// ErrDuplicatePassport возвращается, если паспорт уже записан за другим гостем
+var ErrDuplicatePassport = errors.New("passport number belongs to another guest")
+
+// passport_no заполнен только у записей, ещё не зашифрованных командой ротации ключей
+const guestColumns = `id, name, phone_number, email, COALESCE(passport_no, '') AS passport_no, passport_enc,
+	document_type, nationality, erased_at,
+	visa_number, visa_expires_at, migration_card_no, entry_date, migration_card_expires_at`
+
+// likePrefix экранирует спецсимволы LIKE, чтобы ввод искался как обычный текст
+func likePrefix(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+	return s + "%"
+}
+
+// ensurePassportFree проверяет, что паспорт не записан за другим гостем (exceptID — сам редактируемый гость)
+func ensurePassportFree(dbpool *pgxpool.Pool, passportNo string, exceptID int) error {
+	var taken bool
+	err := dbpool.QueryRow(context.Background(),
+		`SELECT EXISTS(SELECT 1 FROM GUESTS WHERE (passport_hash = $1 OR passport_no = $2) AND id <> $3)`,
+		Passports.BlindIndex(passportNo), passportNo, exceptID).Scan(&taken)
+	if err != nil {
+		return fmt.Errorf("error checking passport: %v", err)
+	}
+	if taken {
+		return ErrDuplicatePassport
+	}
+	return nil
+}
+
+func CreateGuest(dbpool *pgxpool.Pool, guest models.GuestInput) (int, error) {
+	var id int
+	if err := ensurePassportFree(dbpool, guest.PassportNo, 0); err != nil {
+		return 0, err
+	}
+	enc, hash, err := sealPassport(guest.PassportNo)
+	if err != nil {
+		return 0, err
+	}
+	err = pgxscan.Get(context.Background(), dbpool, &id,
+		`INSERT INTO GUESTS (name, phone_number, passport_enc, passport_hash, document_type, nationality,
+			visa_number, visa_expires_at, migration_card_no, entry_date, migration_card_expires_at, email)
+		VALUES ($1, $2, $3, $4, $5, $6,
+			NULLIF($7, ''), NULLIF($8, '')::date, NULLIF($9, ''), NULLIF($10, '')::date, NULLIF($11, '')::date,
+			NULLIF($12, ''))
+		RETURNING id`,
+		guest.Name, guest.PhoneNumber, enc, hash, guest.DocumentType, guest.Nationality,
+		guest.VisaNumber, guest.VisaExpiresAt, guest.MigrationCardNo, guest.EntryDate, guest.MigrationCardExpiresAt,
+		guest.Email)
+	if err != nil {
+		return 0, fmt.Errorf("error inserting guest: %v", err)
+	}
+	return id, nil
+}
+
+func UpdateGuest(dbpool *pgxpool.Pool, id int, guest models.GuestInput) error {
+	if err := ensurePassportFree(dbpool, guest.PassportNo, id); err != nil {
+		return err
+	}
+	enc, hash, err := sealPassport(guest.PassportNo)
+	if err != nil {
+		return err
+	}
+	result, err := dbpool.Exec(context.Background(),
+		`UPDATE GUESTS
+		SET name = $1, phone_number = $2, passport_no = NULL, passport_enc = $3, passport_hash = $4,
+			document_type = $5, nationality = $6,
+			visa_number = NULLIF($8, ''), visa_expires_at = NULLIF($9, '')::date,
+			migration_card_no = NULLIF($10, ''), entry_date = NULLIF($11, '')::date,
+			migration_card_expires_at = NULLIF($12, '')::date, email = NULLIF($13, '')
+		WHERE id = $7 AND erased_at IS NULL`,
+		guest.Name, guest.PhoneNumber, enc, hash, guest.DocumentType, guest.Nationality, id,
+		guest.VisaNumber, guest.VisaExpiresAt, guest.MigrationCardNo, guest.EntryDate, guest.MigrationCardExpiresAt,
+		guest.Email)
+	if err != nil {
+		log.Printf("error updating guest: %v", err)
+		return fmt.Errorf("error updating guest: %v", err)
+	}
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("guest with ID %d not found or erased", id)
+	}
+	return nil
+}
+
+func revealGuests(guests []models.Guest) error {
+	for i := range guests {
+		if err := revealPassport(&guests[i].PassportNo, guests[i].PassportEnc); err != nil {
+			return fmt.Errorf("guest %d: %v", guests[i].ID, err)
+		}
+	}
+	return nil
+}
+
+// GetAllGuests возвращает страницу списка гостей с поиском по началу имени, части телефона
+// и точному номеру паспорта (номера зашифрованы, поэтому поиск по части номера невозможен)
+func GetAllGuests(dbpool *pgxpool.Pool, q models.GuestSearch) (models.GuestPage, error) {
+	page := models.GuestPage{Page: q.Page, PageSize: q.PageSize}
+	var name, phone, passport, passportHash string
+	if q.Name != "" {
+		name = likePrefix(q.Name)
+	}
+	if q.Phone != "" {
+		phone = "%" + services.NormalizePhone(q.Phone) + "%"
+	}
+	if q.Passport != "" {
+		passport = services.NormalizePassport(q.Passport)
+		passportHash = Passports.BlindIndex(passport)
+	}
+	const where = `
+		WHERE ($1 = '' OR name ILIKE $1)
+			AND ($2 = '' OR REGEXP_REPLACE(phone_number, '\D', '', 'g') LIKE $2)
+			AND ($3 = '' OR passport_hash = $4 OR passport_no = $3)`
+	err := dbpool.QueryRow(context.Background(),
+		`SELECT COUNT(*) FROM GUESTS`+where, name, phone, passport, passportHash).Scan(&page.Total)
+	if err != nil {
+		return page, fmt.Errorf("error counting guests: %v", err)
+	}
+	err = pgxscan.Select(context.Background(), dbpool, &page.Items,
+		`SELECT `+guestColumns+` FROM GUESTS`+where+`
+		ORDER BY name, id
+		LIMIT $5 OFFSET $6`,
+		name, phone, passport, passportHash, q.PageSize, (q.Page-1)*q.PageSize)
+	if err != nil {
+		log.Printf("error getting all guests: %v", err)
+		return page, fmt.Errorf("error getting all guests: %v", err)
+	}
+	return page, revealGuests(page.Items)
+}
+
+// GetGuestByID возвращает гостя вместе с историей его бронирований
+func GetGuestByID(dbpool *pgxpool.Pool, id int) (models.GuestProfile, error) {
+	var guest models.GuestProfile
+	err := pgxscan.Get(context.Background(), dbpool, &guest,
+		`SELECT `+guestColumns+` FROM GUESTS WHERE id = $1`, id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return guest, fmt.Errorf("guest with ID %d not found", id)
+		}
+		return guest, fmt.Errorf("error getting guest: %v", err)
+	}
+	if err = revealPassport(&guest.PassportNo, guest.PassportEnc); err != nil {
+		return guest, err
+	}
+	err = pgxscan.Select(context.Background(), dbpool, &guest.Bookings,
+		`SELECT
+				b.id AS "id",
+				b.reference,
+				b.start_date,
+				b.end_date,
+				b.check_in,
+				b.check_out,
+				b.baby_bed,
+				b.booking_sum,
+				b.total_sum,
+				b.tax_sum,
+				bs.name AS "booking_status",
+				d.amount AS "discount_amount",
+				COALESCE(fc.extras_sum, 0) AS "extras_sum",
+				`+bookingBalanceSQL+` AS "balance",
+				b.company_id,
+				gib.room,
+				g.name AS "guest_name"
+			FROM
+				bookings b
+			JOIN
+				booking_statuses bs ON bs.status_code = b.status_code
+			LEFT JOIN
+				discounts d ON d.id = b.discount_id
+			LEFT JOIN (
+				SELECT booking_id, SUM(amount) AS extras_sum
+				FROM folio_charges
+				WHERE voided_at IS NULL
+				GROUP BY booking_id
+			) fc ON fc.booking_id = b.id
+			JOIN guests_in_bookings gib ON gib.booking_id = b.id
+			JOIN GUESTS G ON G.id = gib.guest_id
+			WHERE gib.guest_id = $1
+			ORDER BY b.start_date DESC, b.id DESC`, id)
+	if err != nil {
+		return guest, fmt.Errorf("error getting guest bookings: %v", err)
+	}
+	return guest, nil
+}
+
+func DeleteGuest(dbpool *pgxpool.Pool, id int) error {
+	var hasBookings bool
+	err := dbpool.QueryRow(context.Background(),
+		`SELECT EXISTS(SELECT 1 FROM GUESTS_IN_BOOKINGS WHERE guest_id = $1)`, id).Scan(&hasBookings)
+	if err != nil {
+		return fmt.Errorf("error checking guest bookings: %v", err)
+	}
+	if hasBookings {
+		return ErrGuestHasBookings
+	}
+	ctx := context.Background()
+	tx, err := dbpool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("error beginning transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+	var attachmentKeys []string
+	err = pgxscan.Select(ctx, tx, &attachmentKeys,
+		`DELETE FROM ATTACHMENTS WHERE guest_id = $1 RETURNING storage_key`, id)
+	if err != nil {
+		return fmt.Errorf("error deleting guest attachments: %v", err)
+	}
+	// жалобы остаются, но больше не ссылаются на удалённого гостя
+	_, err = tx.Exec(ctx, `UPDATE COMPLAINTS SET guest_id = NULL WHERE guest_id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("error detaching guest complaints: %v", err)
+	}
+	result, err := tx.Exec(ctx, `DELETE FROM GUESTS WHERE ID = $1`, id)
+	if err != nil {
+		return fmt.Errorf("error deleting guest: %v", err)
+	}
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("guest with ID %d not found", id)
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("error committing guest deletion: %v", err)
+	}
+	deleteAttachmentFiles(attachmentKeys)
+	return nil
+}
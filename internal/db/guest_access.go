@@ -0,0 +1,293 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"log"
+	"mis_kursach_backend/internal/models"
+	"mis_kursach_backend/internal/services"
+	"time"
+)
+
+// ErrGuestAccessDenied возвращается, если номер бронирования, фамилия или код не подошли.
+// Причина не уточняется, чтобы по ответу нельзя было проверить существование бронирования.
+var ErrGuestAccessDenied = errors.New("booking reference or credentials do not match")
+
+// ErrGuestLoginThrottled возвращается, если по номеру бронирования или с адреса было слишком много неудачных попыток входа
+var ErrGuestLoginThrottled = errors.New("too many failed guest login attempts")
+
+// Mail отправляет письма гостям и менеджерам; задаётся при запуске из конфигурации
+var Mail services.Mailer
+
+// guestBookingID находит бронирование по номеру, который знает гость
+func guestBookingID(dbpool *pgxpool.Pool, reference string) (int, error) {
+	var id int
+	err := dbpool.QueryRow(context.Background(),
+		`SELECT id FROM BOOKINGS WHERE reference = $1`, services.NormalizeBookingReference(reference)).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, ErrGuestAccessDenied
+	}
+	if err != nil {
+		return 0, fmt.Errorf("error getting booking: %v", err)
+	}
+	return id, nil
+}
+
+// RequestGuestAccessCode отправляет новый код на почту гостей бронирования.
+// Если бронирования или почты нет, ничего не происходит: ответ гостю от этого не зависит.
+func RequestGuestAccessCode(dbpool *pgxpool.Pool, reference string) error {
+	bookingID, err := guestBookingID(dbpool, reference)
+	if errors.Is(err, ErrGuestAccessDenied) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var emails []string
+	err = pgxscan.Select(context.Background(), dbpool, &emails,
+		`SELECT DISTINCT g.email FROM GUESTS g
+		JOIN GUESTS_IN_BOOKINGS gib ON gib.guest_id = g.id
+		WHERE gib.booking_id = $1 AND g.email IS NOT NULL AND g.erased_at IS NULL`, bookingID)
+	if err != nil {
+		return fmt.Errorf("error getting guest emails: %v", err)
+	}
+	if len(emails) == 0 {
+		return nil
+	}
+	// не чаще одного письма в минуту на бронирование
+	var recent bool
+	err = dbpool.QueryRow(context.Background(),
+		`SELECT EXISTS(SELECT 1 FROM GUEST_ACCESS_CODES WHERE booking_id = $1 AND created_at > $2)`,
+		bookingID, time.Now().Add(-time.Minute)).Scan(&recent)
+	if err != nil {
+		return fmt.Errorf("error checking access codes: %v", err)
+	}
+	if recent {
+		return nil
+	}
+
+	code, err := services.GenerateAccessCode()
+	if err != nil {
+		return err
+	}
+	ref := services.NormalizeBookingReference(reference)
+	ctx := context.Background()
+	tx, err := dbpool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("error beginning transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+	// новый код отменяет все выданные ранее
+	_, err = tx.Exec(ctx,
+		`UPDATE GUEST_ACCESS_CODES SET used_at = $2 WHERE booking_id = $1 AND used_at IS NULL`, bookingID, time.Now())
+	if err != nil {
+		return fmt.Errorf("error revoking access codes: %v", err)
+	}
+	_, err = tx.Exec(ctx,
+		`INSERT INTO GUEST_ACCESS_CODES (booking_id, code_hash, created_at, expires_at) VALUES ($1, $2, $3, $4)`,
+		bookingID, services.HashAccessCode(ref, code), time.Now(), time.Now().Add(services.AccessCodeTTL*time.Minute))
+	if err != nil {
+		log.Printf("error inserting access code: %v", err)
+		return fmt.Errorf("error inserting access code: %v", err)
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("error committing access code: %v", err)
+	}
+	body := fmt.Sprintf("Код для просмотра бронирования %s: %s\nКод действует %d минут.", ref, code, services.AccessCodeTTL)
+	// ошибку отправки только логируем: иначе по ответу было бы видно, что номер бронирования существует
+	for _, email := range emails {
+		if err := Mail.Send(email, "Код доступа к бронированию", body); err != nil {
+			log.Printf("error sending access code for booking %d: %v", bookingID, err)
+		}
+	}
+	return nil
+}
+
+// GuestLogin проверяет номер бронирования вместе с фамилией гостя или кодом из письма
+// и возвращает ID бронирования. Код после успешного входа погашается.
+// Неудачные попытки считаются по номеру бронирования и по адресу клиента ip.
+func GuestLogin(dbpool *pgxpool.Pool, in models.GuestLoginInput, ip string) (int, error) {
+	reference := services.NormalizeBookingReference(in.Reference)
+	if err := checkGuestLoginAttempts(dbpool, reference, ip); err != nil {
+		return 0, err
+	}
+	method := "last_name"
+	bookingID, err := guestBookingID(dbpool, reference)
+	if err == nil {
+		if in.Code != "" {
+			method = "code"
+			err = redeemAccessCode(dbpool, bookingID, reference, in.Code)
+		} else {
+			err = matchGuestLastName(dbpool, bookingID, in.LastName)
+		}
+	}
+	if errors.Is(err, ErrGuestAccessDenied) {
+		if recordErr := recordGuestLoginFailure(dbpool, reference, ip); recordErr != nil {
+			return 0, recordErr
+		}
+		return 0, err
+	}
+	if err != nil {
+		return 0, err
+	}
+	// после успешного входа опечатки гостя больше не считаются
+	_, err = dbpool.Exec(context.Background(), `DELETE FROM GUEST_LOGIN_ATTEMPTS WHERE reference = $1`, reference)
+	if err != nil {
+		return 0, fmt.Errorf("error clearing guest login attempts: %v", err)
+	}
+	err = writeAudit(context.Background(), dbpool, "booking", bookingID, "guest_login", map[string]any{"method": method}, nil)
+	return bookingID, err
+}
+
+// checkGuestLoginAttempts отказывает во входе, пока не истекло окно после слишком многих неудачных попыток.
+// Счётчик по номеру ведётся и для несуществующих номеров, чтобы ответ не выдавал, есть ли бронирование.
+func checkGuestLoginAttempts(dbpool *pgxpool.Pool, reference, ip string) error {
+	var byReference, byIP int
+	err := dbpool.QueryRow(context.Background(),
+		`SELECT COUNT(*) FILTER (WHERE reference = $1), COUNT(*) FILTER (WHERE ip = $2)
+		FROM GUEST_LOGIN_ATTEMPTS
+		WHERE created_at > $3 AND (reference = $1 OR ip = $2)`,
+		reference, ip, time.Now().Add(-services.GuestLoginWindow*time.Minute)).Scan(&byReference, &byIP)
+	if err != nil {
+		return fmt.Errorf("error checking guest login attempts: %v", err)
+	}
+	if byReference >= services.GuestLoginMaxPerReference || byIP >= services.GuestLoginMaxPerIP {
+		return ErrGuestLoginThrottled
+	}
+	return nil
+}
+
+// recordGuestLoginFailure записывает неудачную попытку и заодно удаляет попытки, вышедшие из окна
+func recordGuestLoginFailure(dbpool *pgxpool.Pool, reference, ip string) error {
+	ctx := context.Background()
+	_, err := dbpool.Exec(ctx,
+		`INSERT INTO GUEST_LOGIN_ATTEMPTS (reference, ip, created_at) VALUES ($1, $2, $3)`, reference, ip, time.Now())
+	if err != nil {
+		return fmt.Errorf("error recording guest login attempt: %v", err)
+	}
+	_, err = dbpool.Exec(ctx,
+		`DELETE FROM GUEST_LOGIN_ATTEMPTS WHERE created_at < $1`, time.Now().Add(-services.GuestLoginWindow*time.Minute))
+	if err != nil {
+		return fmt.Errorf("error cleaning guest login attempts: %v", err)
+	}
+	return nil
+}
+
+func matchGuestLastName(dbpool *pgxpool.Pool, bookingID int, lastName string) error {
+	var names []string
+	err := pgxscan.Select(context.Background(), dbpool, &names,
+		`SELECT g.name FROM GUESTS g
+		JOIN GUESTS_IN_BOOKINGS gib ON gib.guest_id = g.id
+		WHERE gib.booking_id = $1 AND g.erased_at IS NULL`, bookingID)
+	if err != nil {
+		return fmt.Errorf("error getting booking guests: %v", err)
+	}
+	for _, name := range names {
+		if services.MatchesLastName(name, lastName) {
+			return nil
+		}
+	}
+	return ErrGuestAccessDenied
+}
+
+// redeemAccessCode сверяет код с последним выданным; неверные попытки считаются
+func redeemAccessCode(dbpool *pgxpool.Pool, bookingID int, reference, code string) error {
+	ctx := context.Background()
+	tx, err := dbpool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("error beginning transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var id, attempts int
+	var codeHash string
+	err = tx.QueryRow(ctx,
+		`SELECT id, code_hash, attempts FROM GUEST_ACCESS_CODES
+		WHERE booking_id = $1 AND used_at IS NULL AND expires_at > $2
+		ORDER BY created_at DESC LIMIT 1 FOR UPDATE`, bookingID, time.Now()).Scan(&id, &codeHash, &attempts)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrGuestAccessDenied
+	}
+	if err != nil {
+		return fmt.Errorf("error getting access code: %v", err)
+	}
+	if attempts >= services.AccessCodeMaxAttempts {
+		return ErrGuestAccessDenied
+	}
+	if services.HashAccessCode(reference, code) != codeHash {
+		_, err = tx.Exec(ctx, `UPDATE GUEST_ACCESS_CODES SET attempts = attempts + 1 WHERE id = $1`, id)
+		if err != nil {
+			return fmt.Errorf("error counting access code attempt: %v", err)
+		}
+		if err = tx.Commit(ctx); err != nil {
+			return fmt.Errorf("error committing access code attempt: %v", err)
+		}
+		return ErrGuestAccessDenied
+	}
+	_, err = tx.Exec(ctx, `UPDATE GUEST_ACCESS_CODES SET used_at = $1 WHERE id = $2`, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("error redeeming access code: %v", err)
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("error committing access code: %v", err)
+	}
+	return nil
+}
+
+// GetGuestBookingView возвращает то, что гостю можно видеть о своём бронировании:
+// без номера комнаты, данных других гостей и истории платежей
+func GetGuestBookingView(dbpool *pgxpool.Pool, bookingID int) (models.GuestBookingView, error) {
+	var view models.GuestBookingView
+	var companyID *int
+	err := dbpool.QueryRow(context.Background(),
+		`SELECT b.reference, b.start_date, b.end_date, b.check_in, b.check_out, bs.name,
+				COALESCE((
+					SELECT rc.name FROM GUESTS_IN_BOOKINGS gib
+					JOIN ROOMS r ON r.number = gib.room
+					JOIN ROOM_CATEGORIES rc ON rc.code = r.category_code
+					WHERE gib.booking_id = b.id
+					LIMIT 1
+				), ''),
+				b.total_sum, b.tax_sum,
+				COALESCE((SELECT SUM(amount) FROM FOLIO_CHARGES WHERE booking_id = b.id AND voided_at IS NULL), 0),
+				COALESCE((SELECT SUM(amount) FROM PAYMENTS WHERE booking_id = b.id AND status_code = $2), 0),
+				COALESCE((SELECT SUM(amount) FROM COMPLAINT_COMPENSATIONS WHERE booking_id = b.id AND kind <> $3), 0),
+				`+bookingBalanceSQL+`,
+				b.company_id
+		FROM BOOKINGS b
+		JOIN BOOKING_STATUSES bs ON bs.status_code = b.status_code
+		WHERE b.id = $1`, bookingID, models.PaymentStatusPaid, models.CompensationRefund).Scan(
+		&view.Reference, &view.StartDate, &view.EndDate, &view.CheckIn, &view.CheckOut, &view.BookingStatus,
+		&view.RoomCategory, &view.TotalSum, &view.TaxSum, &view.ExtrasSum, &view.PaidSum, &view.Compensations, &view.Balance, &companyID)
+	if err != nil {
+		return view, fmt.Errorf("error getting booking: %v", err)
+	}
+	switch {
+	case companyID != nil:
+		// проживание оплачивает организация по ежемесячному счёту
+		view.PaymentStatus = models.BalanceCompanyBilled
+	case !view.Balance.IsPositive():
+		view.PaymentStatus = models.BalancePaid
+	case view.PaidSum.IsPositive():
+		view.PaymentStatus = models.BalancePartiallyPaid
+	default:
+		view.PaymentStatus = models.BalanceUnpaid
+	}
+	err = pgxscan.Select(context.Background(), dbpool, &view.Complaints,
+		`SELECT c.id, c.reason, c.issue_date, cs.name AS status
+		FROM COMPLAINTS c
+		JOIN COMPLAINT_STATUSES cs ON cs.status_code = c.status_code
+		WHERE c.booking_id = $1 AND c.deleted_at IS NULL
+		ORDER BY c.issue_date, c.id`, bookingID)
+	if err != nil {
+		return view, fmt.Errorf("error getting booking complaints: %v", err)
+	}
+	if view.Complaints == nil {
+		view.Complaints = []models.GuestComplaint{}
+	}
+	return view, nil
+}
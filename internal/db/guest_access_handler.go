@@ -0,0 +1,161 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/jwtauth/v5"
+	"log"
+	"mis_kursach_backend/internal/models"
+	"mis_kursach_backend/internal/services"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// guestBookingFromRequest достаёт из токена гостя ID его бронирования
+func guestBookingFromRequest(r *http.Request) (int, bool) {
+	_, claims, err := jwtauth.FromContext(r.Context())
+	if err != nil || claims == nil {
+		return 0, false
+	}
+	id, ok := claims["booking_id"].(float64)
+	return int(id), ok
+}
+
+// clientIP — адрес клиента без порта, по нему ограничиваются попытки входа гостей
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func (p *PsHandler) GuestRequestAccessCode(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var in models.GuestAccessCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, `{"error": "invalid request body"}`, http.StatusBadRequest)
+		log.Printf("Error decoding access code request: %v", err)
+		return
+	}
+	defer r.Body.Close()
+	if strings.TrimSpace(in.Reference) == "" {
+		http.Error(w, `{"error": "reference is required"}`, http.StatusBadRequest)
+		return
+	}
+	if err := RequestGuestAccessCode(p.dbpool, in.Reference); err != nil {
+		http.Error(w, `{"error": "failed to send access code"}`, http.StatusInternalServerError)
+		log.Printf("Error sending guest access code: %v", err)
+		return
+	}
+	// ответ одинаковый, есть такое бронирование или нет
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "if the booking has an email on file, an access code has been sent",
+	})
+}
+
+func (p *PsHandler) GuestLogin(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var in models.GuestLoginInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, `{"error": "invalid request body"}`, http.StatusBadRequest)
+		log.Printf("Error decoding guest login: %v", err)
+		return
+	}
+	defer r.Body.Close()
+	in.LastName, in.Code = strings.TrimSpace(in.LastName), strings.TrimSpace(in.Code)
+	if strings.TrimSpace(in.Reference) == "" || (in.LastName == "" && in.Code == "") {
+		http.Error(w, `{"error": "reference and either last_name or code are required"}`, http.StatusBadRequest)
+		return
+	}
+	bookingID, err := GuestLogin(p.dbpool, in, clientIP(r))
+	if errors.Is(err, ErrGuestLoginThrottled) {
+		http.Error(w, `{"error": "too many failed attempts, try again later"}`, http.StatusTooManyRequests)
+		return
+	}
+	if errors.Is(err, ErrGuestAccessDenied) {
+		http.Error(w, `{"error": "booking reference or credentials do not match"}`, http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error": "failed to log in"}`, http.StatusInternalServerError)
+		log.Printf("Error logging in guest: %v", err)
+		return
+	}
+	claims := map[string]interface{}{
+		"booking_id": bookingID,
+		"exp":        time.Now().Add(services.GuestSessionTTL * time.Minute).Unix()}
+	_, tokenString, err := p.guestAuth.Encode(claims)
+	if err != nil {
+		http.Error(w, `{"error": "failed to generate token"}`, http.StatusInternalServerError)
+		return
+	}
+	view, err := GetGuestBookingView(p.dbpool, bookingID)
+	if err != nil {
+		http.Error(w, `{"error": "failed to get booking"}`, http.StatusInternalServerError)
+		log.Printf("Error getting guest booking view: %v", err)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]any{"token": tokenString, "booking": view})
+}
+
+func (p *PsHandler) GuestGetBooking(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	bookingID, ok := guestBookingFromRequest(r)
+	if !ok {
+		http.Error(w, `{"error": "invalid guest token"}`, http.StatusUnauthorized)
+		return
+	}
+	view, err := GetGuestBookingView(p.dbpool, bookingID)
+	if err != nil {
+		http.Error(w, `{"error": "booking not found"}`, http.StatusNotFound)
+		log.Printf("Error getting guest booking view: %v", err)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(view); err != nil {
+		http.Error(w, `{"error": "failed to encode response"}`, http.StatusInternalServerError)
+		log.Printf("Error encoding guest booking view: %v", err)
+	}
+}
+
+// GuestCreateComplaint — жалоба гостя на своё бронирование, заводится так же, как жалоба от сотрудника
+func (p *PsHandler) GuestCreateComplaint(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	bookingID, ok := guestBookingFromRequest(r)
+	if !ok {
+		http.Error(w, `{"error": "invalid guest token"}`, http.StatusUnauthorized)
+		return
+	}
+	var in models.GuestComplaintInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, `{"error": "invalid request body"}`, http.StatusBadRequest)
+		log.Printf("Error decoding guest complaint: %v", err)
+		return
+	}
+	defer r.Body.Close()
+	in.Reason = strings.TrimSpace(in.Reason)
+	if in.Reason == "" {
+		http.Error(w, `{"error": "reason is required"}`, http.StatusBadRequest)
+		return
+	}
+	err := CreateComplaint(p.dbpool, models.CreateComplaintInput{
+		Reason:     in.Reason,
+		Commentary: in.Commentary,
+		BookingID:  &bookingID,
+		Category:   in.Category,
+	}, nil)
+	if errors.Is(err, ErrUnknownComplaintCategory) {
+		http.Error(w, `{"error": "unknown complaint category"}`, http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error": "failed to create complaint"}`, http.StatusBadRequest)
+		log.Printf("Error creating guest complaint: %v", err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Complaint created successfully"})
+}
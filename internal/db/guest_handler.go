@@ -0,0 +1,319 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"log"
+	"mis_kursach_backend/internal/models"
+	"mis_kursach_backend/internal/services"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultGuestPageSize = 20
+	maxGuestPageSize     = 100
+)
+
+// bookingGuestFields — имена полей гостя в форме бронирования
+var bookingGuestFields = map[string]string{
+	"name":          "guest_name",
+	"phone_number":  "guest_phone_number",
+	"email":         "guest_email",
+	"passport_no":   "guest_passport_number",
+	"document_type": "guest_document_type",
+	"nationality":   "guest_nationality",
+}
+
+func renameFields(errs models.FieldErrors, names map[string]string) models.FieldErrors {
+	renamed := make(models.FieldErrors, len(errs))
+	for field, msg := range errs {
+		if name, ok := names[field]; ok {
+			field = name
+		}
+		renamed[field] = msg
+	}
+	return renamed
+}
+
+// fullPassportAccess — полные номера паспортов видят только администраторы и менеджеры
+func fullPassportAccess(r *http.Request) bool {
+	role := roleFromRequest(r)
+	return role == models.RoleAdmin || role == models.RoleManager
+}
+
+// writeFieldErrors отвечает 400 с причиной отказа по каждому полю
+func writeFieldErrors(w http.ResponseWriter, errs models.FieldErrors) {
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]any{"error": "validation failed", "fields": errs})
+}
+
+// queryInt читает положительное число из query-параметра; пустой параметр даёт def
+func queryInt(r *http.Request, name string, def int) (int, error) {
+	s := r.URL.Query().Get(name)
+	if s == "" {
+		return def, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v <= 0 {
+		return 0, errors.New("invalid " + name)
+	}
+	return v, nil
+}
+
+func (p *PsHandler) GetAllGuests(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	q := models.GuestSearch{
+		Name:     strings.TrimSpace(r.URL.Query().Get("name")),
+		Phone:    strings.TrimSpace(r.URL.Query().Get("phone")),
+		Passport: strings.TrimSpace(r.URL.Query().Get("passport")),
+	}
+	var err error
+	if q.Page, err = queryInt(r, "page", 1); err != nil {
+		http.Error(w, `{"error": "invalid page"}`, http.StatusBadRequest)
+		return
+	}
+	if q.PageSize, err = queryInt(r, "page_size", defaultGuestPageSize); err != nil {
+		http.Error(w, `{"error": "invalid page_size"}`, http.StatusBadRequest)
+		return
+	}
+	if q.PageSize > maxGuestPageSize {
+		q.PageSize = maxGuestPageSize
+	}
+	page, err := GetAllGuests(p.dbpool, q)
+	if err != nil {
+		http.Error(w, `{"error": "failed to get guests"}`, http.StatusInternalServerError)
+		log.Printf("Error getting guests: %v", err)
+		return
+	}
+	if page.Items == nil {
+		page.Items = []models.Guest{}
+	}
+	if !fullPassportAccess(r) {
+		for i := range page.Items {
+			page.Items[i].PassportNo = services.MaskPassport(page.Items[i].PassportNo)
+		}
+	}
+	if err := json.NewEncoder(w).Encode(page); err != nil {
+		http.Error(w, `{"error": "failed to encode response"}`, http.StatusInternalServerError)
+		log.Printf("Error encoding guests: %v", err)
+	}
+}
+
+func (p *PsHandler) GetGuestByID(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, `{"error": "invalid id"}`, http.StatusBadRequest)
+		return
+	}
+	guest, err := GetGuestByID(p.dbpool, id)
+	if err != nil {
+		http.Error(w, `{"error": "guest not found"}`, http.StatusNotFound)
+		log.Printf("Error getting guest: %v", err)
+		return
+	}
+	if guest.Bookings == nil {
+		guest.Bookings = []models.BookingResponse{}
+	}
+	if !fullPassportAccess(r) {
+		guest.PassportNo = services.MaskPassport(guest.PassportNo)
+	}
+	if err := json.NewEncoder(w).Encode(guest); err != nil {
+		http.Error(w, `{"error": "failed to encode response"}`, http.StatusInternalServerError)
+		log.Printf("Error encoding guest: %v", err)
+	}
+}
+
+func (p *PsHandler) CreateGuest(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var g models.GuestInput
+	if err := json.NewDecoder(r.Body).Decode(&g); err != nil {
+		http.Error(w, `{"error": "invalid request body"}`, http.StatusBadRequest)
+		log.Printf("Error decoding guest input: %v", err)
+		return
+	}
+	defer r.Body.Close()
+	g, fieldErrs := services.ValidateGuest(g)
+	if fieldErrs != nil {
+		writeFieldErrors(w, fieldErrs)
+		return
+	}
+	id, err := CreateGuest(p.dbpool, g)
+	if errors.Is(err, ErrDuplicatePassport) {
+		http.Error(w, `{"error": "passport number belongs to another guest"}`, http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error": "failed to create guest"}`, http.StatusBadRequest)
+		log.Printf("Error creating guest: %v", err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Guest created successfully", "id": strconv.Itoa(id)})
+}
+
+func (p *PsHandler) UpdateGuest(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, `{"error": "invalid id"}`, http.StatusBadRequest)
+		return
+	}
+	var g models.GuestInput
+	if err := json.NewDecoder(r.Body).Decode(&g); err != nil {
+		http.Error(w, `{"error": "invalid request body"}`, http.StatusBadRequest)
+		log.Printf("Error decoding guest input: %v", err)
+		return
+	}
+	defer r.Body.Close()
+	g, fieldErrs := services.ValidateGuest(g)
+	if fieldErrs != nil {
+		writeFieldErrors(w, fieldErrs)
+		return
+	}
+	err = UpdateGuest(p.dbpool, id, g)
+	if errors.Is(err, ErrDuplicatePassport) {
+		http.Error(w, `{"error": "passport number belongs to another guest"}`, http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error": "failed to update guest"}`, http.StatusBadRequest)
+		log.Printf("Error updating guest: %v", err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	response := make(map[string]string)
+	response["message"] = "success"
+	json.NewEncoder(w).Encode(response)
+}
+
+func (p *PsHandler) DeleteGuest(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, `{"error": "invalid id"}`, http.StatusBadRequest)
+		return
+	}
+	err = DeleteGuest(p.dbpool, id)
+	if errors.Is(err, ErrGuestHasBookings) {
+		http.Error(w, `{"error": "guest has bookings and cannot be deleted"}`, http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error": "failed to delete guest"}`, http.StatusBadRequest)
+		log.Printf("Error deleting guest: %v", err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	response := make(map[string]string)
+	response["message"] = "success"
+	json.NewEncoder(w).Encode(response)
+}
+
+func (p *PsHandler) FindDuplicateGuests(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	minScore := DefaultDuplicateScore
+	if s := r.URL.Query().Get("min_score"); s != "" {
+		var err error
+		minScore, err = strconv.ParseFloat(s, 64)
+		if err != nil || minScore < 0 || minScore > 1 {
+			http.Error(w, `{"error": "min_score must be between 0 and 1"}`, http.StatusBadRequest)
+			return
+		}
+	}
+	candidates, err := FindDuplicateGuests(p.dbpool, minScore)
+	if err != nil {
+		http.Error(w, `{"error": "failed to find duplicate guests"}`, http.StatusInternalServerError)
+		log.Printf("Error finding duplicate guests: %v", err)
+		return
+	}
+	if candidates == nil {
+		candidates = []models.DuplicateCandidate{}
+	}
+	if !fullPassportAccess(r) {
+		for i := range candidates {
+			candidates[i].Guest.PassportNo = services.MaskPassport(candidates[i].Guest.PassportNo)
+			candidates[i].Duplicate.PassportNo = services.MaskPassport(candidates[i].Duplicate.PassportNo)
+		}
+	}
+	if err := json.NewEncoder(w).Encode(candidates); err != nil {
+		http.Error(w, `{"error": "failed to encode response"}`, http.StatusInternalServerError)
+		log.Printf("Error encoding duplicate guests: %v", err)
+	}
+}
+
+func (p *PsHandler) MergeGuests(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var in models.MergeGuestsInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, `{"error": "invalid request body"}`, http.StatusBadRequest)
+		log.Printf("Error decoding merge input: %v", err)
+		return
+	}
+	defer r.Body.Close()
+	result, err := MergeGuests(p.dbpool, in, userIDFromRequest(r))
+	if err != nil {
+		http.Error(w, `{"error": "failed to merge guests"}`, http.StatusBadRequest)
+		log.Printf("Error merging guests: %v", err)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		log.Printf("Error encoding merge result: %v", err)
+	}
+}
+
+func (p *PsHandler) ExportGuestData(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, `{"error": "invalid id"}`, http.StatusBadRequest)
+		return
+	}
+	export, err := ExportGuestData(p.dbpool, id, userIDFromRequest(r))
+	if err != nil {
+		http.Error(w, `{"error": "failed to export guest data"}`, http.StatusBadRequest)
+		log.Printf("Error exporting guest data: %v", err)
+		return
+	}
+	w.Header().Set("Content-Disposition", `attachment; filename="guest-`+strconv.Itoa(id)+`.json"`)
+	if err := json.NewEncoder(w).Encode(export); err != nil {
+		log.Printf("Error encoding guest data export: %v", err)
+	}
+}
+
+func (p *PsHandler) EraseGuest(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, `{"error": "invalid id"}`, http.StatusBadRequest)
+		return
+	}
+	var in models.EraseGuestInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, `{"error": "invalid request body"}`, http.StatusBadRequest)
+		log.Printf("Error decoding erase input: %v", err)
+		return
+	}
+	defer r.Body.Close()
+	if strings.TrimSpace(in.Reason) == "" {
+		http.Error(w, `{"error": "reason is required"}`, http.StatusBadRequest)
+		return
+	}
+	err = EraseGuest(p.dbpool, id, in.Reason, userIDFromRequest(r))
+	if errors.Is(err, ErrGuestErased) {
+		http.Error(w, `{"error": "guest data already erased"}`, http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error": "failed to erase guest"}`, http.StatusBadRequest)
+		log.Printf("Error erasing guest: %v", err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	response := make(map[string]string)
+	response["message"] = "success"
+	json.NewEncoder(w).Encode(response)
+}
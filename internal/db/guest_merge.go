@@ -0,0 +1,145 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"log"
+	"mis_kursach_backend/internal/models"
+	"mis_kursach_backend/internal/services"
+)
+
+// Минимальная оценка, с которой пара профилей считается возможным дубликатом
+const DefaultDuplicateScore = 0.6
+
+func FindDuplicateGuests(dbpool *pgxpool.Pool, minScore float64) ([]models.DuplicateCandidate, error) {
+	var guests []models.Guest
+	err := pgxscan.Select(context.Background(), dbpool, &guests,
+		`SELECT `+guestColumns+` FROM GUESTS ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("error getting guests: %v", err)
+	}
+	if err = revealGuests(guests); err != nil {
+		return nil, err
+	}
+	return services.FindDuplicateGuests(guests, minScore), nil
+}
+
+// MergeGuests переносит историю дубликата на основной профиль и удаляет дубликат.
+// Вместе с бронированиями переходят баллы лояльности, жалобы гостя, вложения, записи чёрного списка
+// и уведомления о миграционном учёте; уведомление по бронированию, где был и основной профиль, удаляется.
+// Пустые телефон и email основного профиля заполняются из дубликата.
+func MergeGuests(dbpool *pgxpool.Pool, in models.MergeGuestsInput, userID *int) (models.MergeResult, error) {
+	result := models.MergeResult{SurvivorID: in.SurvivorID, DuplicateID: in.DuplicateID}
+	if in.SurvivorID == in.DuplicateID {
+		return result, fmt.Errorf("cannot merge guest %d into itself", in.SurvivorID)
+	}
+	ctx := context.Background()
+	tx, err := dbpool.Begin(ctx)
+	if err != nil {
+		return result, fmt.Errorf("error beginning transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var guests []models.Guest
+	err = pgxscan.Select(ctx, tx, &guests,
+		`SELECT `+guestColumns+` FROM GUESTS WHERE id IN ($1, $2) ORDER BY id FOR UPDATE`,
+		in.SurvivorID, in.DuplicateID)
+	if err != nil {
+		return result, fmt.Errorf("error getting guests: %v", err)
+	}
+	if len(guests) != 2 {
+		return result, fmt.Errorf("guests %d and %d not found", in.SurvivorID, in.DuplicateID)
+	}
+	survivor, duplicate := guests[0], guests[1]
+	if survivor.ID != in.SurvivorID {
+		survivor, duplicate = duplicate, survivor
+	}
+	if survivor.ErasedAt != nil || duplicate.ErasedAt != nil {
+		return result, fmt.Errorf("erased guests cannot be merged")
+	}
+
+	// если оба профиля записаны в одно бронирование, строка дубликата лишняя
+	_, err = tx.Exec(ctx,
+		`DELETE FROM GUESTS_IN_BOOKINGS
+		WHERE guest_id = $1
+			AND booking_id IN (SELECT booking_id FROM GUESTS_IN_BOOKINGS WHERE guest_id = $2)`,
+		duplicate.ID, survivor.ID)
+	if err != nil {
+		return result, fmt.Errorf("error removing shared bookings: %v", err)
+	}
+	moved, err := tx.Exec(ctx,
+		`UPDATE GUESTS_IN_BOOKINGS SET guest_id = $1 WHERE guest_id = $2`, survivor.ID, duplicate.ID)
+	if err != nil {
+		log.Printf("error moving guest bookings: %v", err)
+		return result, fmt.Errorf("error moving guest bookings: %v", err)
+	}
+	result.BookingsMoved = int(moved.RowsAffected())
+	_, err = tx.Exec(ctx,
+		`UPDATE LOYALTY_TRANSACTIONS SET guest_id = $1 WHERE guest_id = $2`, survivor.ID, duplicate.ID)
+	if err != nil {
+		return result, fmt.Errorf("error moving loyalty points: %v", err)
+	}
+	_, err = tx.Exec(ctx,
+		`UPDATE ATTACHMENTS SET guest_id = $1 WHERE guest_id = $2`, survivor.ID, duplicate.ID)
+	if err != nil {
+		return result, fmt.Errorf("error moving attachments: %v", err)
+	}
+	_, err = tx.Exec(ctx,
+		`UPDATE COMPLAINTS SET guest_id = $1 WHERE guest_id = $2`, survivor.ID, duplicate.ID)
+	if err != nil {
+		return result, fmt.Errorf("error moving complaints: %v", err)
+	}
+	_, err = tx.Exec(ctx,
+		`UPDATE GUEST_BLACKLIST SET guest_id = $1 WHERE guest_id = $2`, survivor.ID, duplicate.ID)
+	if err != nil {
+		return result, fmt.Errorf("error moving blacklist entries: %v", err)
+	}
+	_, err = tx.Exec(ctx,
+		`DELETE FROM MIGRATION_NOTICES
+		WHERE guest_id = $1
+			AND booking_id IN (SELECT booking_id FROM MIGRATION_NOTICES WHERE guest_id = $2)`,
+		duplicate.ID, survivor.ID)
+	if err != nil {
+		return result, fmt.Errorf("error removing shared migration notices: %v", err)
+	}
+	_, err = tx.Exec(ctx,
+		`UPDATE MIGRATION_NOTICES SET guest_id = $1 WHERE guest_id = $2`, survivor.ID, duplicate.ID)
+	if err != nil {
+		return result, fmt.Errorf("error moving migration notices: %v", err)
+	}
+
+	if survivor.PhoneNumber == "" && duplicate.PhoneNumber != "" {
+		_, err = tx.Exec(ctx, `UPDATE GUESTS SET phone_number = $1 WHERE id = $2`, duplicate.PhoneNumber, survivor.ID)
+		if err != nil {
+			return result, fmt.Errorf("error updating guest: %v", err)
+		}
+	}
+	if survivor.Email == nil && duplicate.Email != nil {
+		_, err = tx.Exec(ctx, `UPDATE GUESTS SET email = $1 WHERE id = $2`, *duplicate.Email, survivor.ID)
+		if err != nil {
+			return result, fmt.Errorf("error updating guest: %v", err)
+		}
+	}
+	_, err = tx.Exec(ctx, `DELETE FROM GUESTS WHERE id = $1`, duplicate.ID)
+	if err != nil {
+		return result, fmt.Errorf("error deleting duplicate guest: %v", err)
+	}
+	// в журнал паспорт попадает только маскированным
+	if err = revealPassport(&duplicate.PassportNo, duplicate.PassportEnc); err != nil {
+		return result, err
+	}
+	duplicate.PassportNo = services.MaskPassport(duplicate.PassportNo)
+	err = writeAudit(ctx, tx, "guest", survivor.ID, "merge", map[string]any{
+		"duplicate":      duplicate,
+		"bookings_moved": result.BookingsMoved,
+	}, userID)
+	if err != nil {
+		return result, err
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return result, fmt.Errorf("error committing guest merge: %v", err)
+	}
+	return result, nil
+}
@@ -0,0 +1,201 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"log"
+	"mis_kursach_backend/internal/models"
+	"time"
+)
+
+// ErrGuestErased возвращается при повторном удалении данных гостя
+var ErrGuestErased = errors.New("guest data already erased")
+
+// ExportGuestData собирает все данные гостя по его запросу и записывает выгрузку в журнал
+func ExportGuestData(dbpool *pgxpool.Pool, id int, userID *int) (models.GuestDataExport, error) {
+	export := models.GuestDataExport{ExportedAt: time.Now()}
+	guest, err := GetGuestByID(dbpool, id)
+	if err != nil {
+		return export, err
+	}
+	export.Guest = guest
+	err = pgxscan.Select(context.Background(), dbpool, &export.Payments,
+		`SELECT p.id, p.booking_id, p.amount, p.original_amount, p.original_currency, p.exchange_rate,
+				p.pay_date, pm.name AS method_name, ps.name AS status_name
+		FROM payments p
+		JOIN payment_methods pm ON p.method_code = pm.code
+		JOIN payment_statuses ps ON p.status_code = ps.status_code
+		WHERE p.booking_id IN (SELECT booking_id FROM GUESTS_IN_BOOKINGS WHERE guest_id = $1)
+		ORDER BY p.pay_date, p.id`, id)
+	if err != nil {
+		return export, fmt.Errorf("error getting guest payments: %v", err)
+	}
+	for i := range export.Payments {
+		export.Payments[i].OriginalAmount = export.Payments[i].OriginalAmount.WithCurrency(export.Payments[i].OriginalCurrency)
+	}
+	err = pgxscan.Select(context.Background(), dbpool, &export.Complaints,
+		complaintSelect+`
+		WHERE C.GUEST_ID = $1
+			OR C.BOOKING_ID IN (SELECT booking_id FROM GUESTS_IN_BOOKINGS WHERE guest_id = $1)
+		ORDER BY C.ISSUE_DATE, C.ID`, id)
+	if err != nil {
+		return export, fmt.Errorf("error getting guest complaints: %v", err)
+	}
+	if export.Payments == nil {
+		export.Payments = []models.PaymentResponse{}
+	}
+	// в выгрузку входят и документы, приложенные к бронированиям гостя
+	err = pgxscan.Select(context.Background(), dbpool, &export.Attachments,
+		`SELECT `+attachmentColumns+` FROM ATTACHMENTS
+		WHERE guest_id = $1
+			OR booking_id IN (SELECT booking_id FROM GUESTS_IN_BOOKINGS WHERE guest_id = $1)
+		ORDER BY uploaded_at DESC, id DESC`, id)
+	if err != nil {
+		return export, fmt.Errorf("error getting guest attachments: %v", err)
+	}
+	if export.Attachments == nil {
+		export.Attachments = []models.Attachment{}
+	}
+	if export.Complaints == nil {
+		export.Complaints = []models.ComplaintResponse{}
+	}
+	if export.Guest.Bookings == nil {
+		export.Guest.Bookings = []models.BookingResponse{}
+	}
+	err = writeAudit(context.Background(), dbpool, "guest", id, "export", map[string]any{}, userID)
+	return export, err
+}
+
+// EraseGuest обезличивает гостя: имя, контакты и документ удаляются, а бронирования, платежи
+// и проводки остаются для бухгалтерии, привязанные к обезличенному профилю.
+// Комментарии к жалобам гостя и по его бронированиям тоже удаляются — в них бывают персональные данные;
+// в журнале этих жалоб стираются тексты комментариев и прежние значения комментария к жалобе.
+// Из AUDIT_LOG убираются снимок поглощённого при слиянии профиля, причины внесения в чёрный список,
+// комментарии к компенсациям и имена файлов удалённых документов гостя и его бронирований.
+func EraseGuest(dbpool *pgxpool.Pool, id int, reason string, userID *int) error {
+	ctx := context.Background()
+	tx, err := dbpool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("error beginning transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var erasedAt *time.Time
+	err = tx.QueryRow(ctx, `SELECT erased_at FROM GUESTS WHERE id = $1 FOR UPDATE`, id).Scan(&erasedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("guest with ID %d not found", id)
+		}
+		return fmt.Errorf("error getting guest: %v", err)
+	}
+	if erasedAt != nil {
+		return ErrGuestErased
+	}
+	_, err = tx.Exec(ctx,
+		`UPDATE GUESTS
+		SET name = $2, phone_number = '', email = NULL, passport_no = NULL, passport_enc = NULL, passport_hash = NULL,
+			document_type = 'other', nationality = NULL, erased_at = $3,
+			visa_number = NULL, visa_expires_at = NULL, migration_card_no = NULL, entry_date = NULL,
+			migration_card_expires_at = NULL
+		WHERE id = $1`, id, fmt.Sprintf("Гость #%d (данные удалены)", id), time.Now())
+	if err != nil {
+		log.Printf("error erasing guest: %v", err)
+		return fmt.Errorf("error erasing guest: %v", err)
+	}
+	redacted, err := tx.Exec(ctx,
+		`UPDATE COMPLAINTS SET commentary = NULL
+		WHERE commentary IS NOT NULL
+			AND (guest_id = $1 OR booking_id IN (SELECT booking_id FROM GUESTS_IN_BOOKINGS WHERE guest_id = $1))`, id)
+	if err != nil {
+		return fmt.Errorf("error redacting complaints: %v", err)
+	}
+	_, err = tx.Exec(ctx,
+		`UPDATE COMPLAINT_ACTIVITY SET body = NULL, changes = changes - 'commentary', redacted_at = $2
+		WHERE redacted_at IS NULL AND (body IS NOT NULL OR changes ? 'commentary')
+			AND complaint_id IN (SELECT id FROM COMPLAINTS
+				WHERE guest_id = $1 OR booking_id IN (SELECT booking_id FROM GUESTS_IN_BOOKINGS WHERE guest_id = $1))`,
+		id, time.Now())
+	if err != nil {
+		return fmt.Errorf("error redacting complaint activity: %v", err)
+	}
+	_, err = tx.Exec(ctx,
+		`UPDATE COMPLAINT_COMPENSATIONS SET commentary = NULL
+		WHERE commentary IS NOT NULL
+			AND complaint_id IN (SELECT id FROM COMPLAINTS
+				WHERE guest_id = $1 OR booking_id IN (SELECT booking_id FROM GUESTS_IN_BOOKINGS WHERE guest_id = $1))`, id)
+	if err != nil {
+		return fmt.Errorf("error redacting complaint compensations: %v", err)
+	}
+	// сканы документов — те же персональные данные, файлы удаляются вместе с записями,
+	// включая документы, приложенные к бронированиям гостя
+	var attachments []struct {
+		ID         int
+		StorageKey string
+	}
+	err = pgxscan.Select(ctx, tx, &attachments,
+		`DELETE FROM ATTACHMENTS
+		WHERE guest_id = $1
+			OR booking_id IN (SELECT booking_id FROM GUESTS_IN_BOOKINGS WHERE guest_id = $1)
+		RETURNING id, storage_key`, id)
+	if err != nil {
+		return fmt.Errorf("error deleting guest attachments: %v", err)
+	}
+	attachmentIDs := make([]int, len(attachments))
+	attachmentKeys := make([]string, len(attachments))
+	for i, a := range attachments {
+		attachmentIDs[i] = a.ID
+		attachmentKeys[i] = a.StorageKey
+	}
+	if err = redactGuestAudit(ctx, tx, id, attachmentIDs); err != nil {
+		return err
+	}
+	err = writeAudit(ctx, tx, "guest", id, "erase", map[string]any{
+		"reason":              reason,
+		"complaints_redacted": redacted.RowsAffected(),
+		"attachments_deleted": len(attachments),
+	}, userID)
+	if err != nil {
+		return err
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("error committing guest erasure: %v", err)
+	}
+	deleteAttachmentFiles(attachmentKeys)
+	return nil
+}
+
+// redactGuestAudit убирает персональные данные гостя из DETAILS журнала. От слияния остаётся
+// только ID поглощённого профиля, остальные записи теряют свободный текст.
+func redactGuestAudit(ctx context.Context, q execer, guestID int, attachmentIDs []int) error {
+	_, err := q.Exec(ctx,
+		`UPDATE AUDIT_LOG
+		SET details = CASE action
+			WHEN 'merge' THEN (details - 'duplicate') || jsonb_build_object('duplicate_id', details->'duplicate'->'id')
+			ELSE details - 'reason'
+		END
+		WHERE entity = 'guest' AND entity_id = $1
+			AND (action = 'blacklist' OR (action = 'merge' AND details ? 'duplicate'))`, guestID)
+	if err != nil {
+		return fmt.Errorf("error redacting guest audit log: %v", err)
+	}
+	_, err = q.Exec(ctx,
+		`UPDATE AUDIT_LOG SET details = details - 'commentary'
+		WHERE entity = 'complaint' AND action = 'compensate'
+			AND entity_id IN (SELECT id FROM COMPLAINTS
+				WHERE guest_id = $1 OR booking_id IN (SELECT booking_id FROM GUESTS_IN_BOOKINGS WHERE guest_id = $1))`,
+		guestID)
+	if err != nil {
+		return fmt.Errorf("error redacting complaint audit log: %v", err)
+	}
+	_, err = q.Exec(ctx,
+		`UPDATE AUDIT_LOG SET details = details - 'file_name'
+		WHERE entity = 'attachment' AND entity_id = ANY($1)`, attachmentIDs)
+	if err != nil {
+		return fmt.Errorf("error redacting attachment audit log: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"log"
+	"mis_kursach_backend/internal/models"
+	"mis_kursach_backend/internal/services"
+	"time"
+)
+
+// ErrInvalidHousekeepingTransition возвращается при недопустимой смене статуса уборки
+var ErrInvalidHousekeepingTransition = errors.New("invalid housekeeping status transition")
+
+// ErrRoomNotReady возвращается при заселении в номер, который не убран и не проверен
+var ErrRoomNotReady = errors.New("room is not ready for check-in")
+
+// SetRoomHousekeeping меняет статус уборки номера. Когда номер убран,
+// его незакрытые задания на сегодня и прошлые дни считаются выполненными.
+func SetRoomHousekeeping(dbpool *pgxpool.Pool, in models.RoomHousekeepingInput, userID *int) error {
+	ctx := context.Background()
+	tx, err := dbpool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("error beginning transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var previous string
+	err = tx.QueryRow(ctx,
+		`SELECT housekeeping_status FROM ROOMS WHERE number = $1 FOR UPDATE`, in.Room).Scan(&previous)
+	if err != nil {
+		return fmt.Errorf("room %d not found: %v", in.Room, err)
+	}
+	if !services.CanTransitionHousekeeping(previous, in.Status) {
+		return fmt.Errorf("%w: %s -> %s", ErrInvalidHousekeepingTransition, previous, in.Status)
+	}
+	_, err = tx.Exec(ctx,
+		`UPDATE ROOMS SET housekeeping_status = $1, housekeeping_updated_at = NOW(), housekeeping_updated_by = $2
+		WHERE number = $3`, in.Status, userID, in.Room)
+	if err != nil {
+		log.Printf("error updating housekeeping status: %v", err)
+		return fmt.Errorf("error updating housekeeping status: %v", err)
+	}
+	if services.ReadyForCheckIn(in.Status) {
+		_, err = tx.Exec(ctx,
+			`UPDATE HOUSEKEEPING_TASKS SET completed_at = NOW(), completed_by = $1
+			WHERE room = $2 AND task_date <= CURRENT_DATE AND completed_at IS NULL`, userID, in.Room)
+		if err != nil {
+			return fmt.Errorf("error completing housekeeping tasks: %v", err)
+		}
+	}
+	err = writeAudit(ctx, tx, "room", in.Room, "housekeeping", map[string]any{
+		"from": previous,
+		"to":   in.Status,
+	}, userID)
+	if err != nil {
+		return err
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("error committing housekeeping status: %v", err)
+	}
+	return nil
+}
+
+// GenerateHousekeepingTasks составляет список уборки на день: номера, из которых гости выезжают,
+// и занятые номера, в которых гости остаются. Повторный вызов добавляет только недостающие задания.
+func GenerateHousekeepingTasks(dbpool *pgxpool.Pool, date time.Time) (models.HousekeepingGenerateResult, error) {
+	ctx := context.Background()
+	date = dateOnly(date)
+	result := models.HousekeepingGenerateResult{TaskDate: date}
+	tx, err := dbpool.Begin(ctx)
+	if err != nil {
+		return result, fmt.Errorf("error beginning transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// выезды добавляются первыми: если в номере в тот же день и выезд, и проживание, нужна полная уборка
+	tag, err := tx.Exec(ctx,
+		`INSERT INTO HOUSEKEEPING_TASKS (task_date, room, booking_id, kind)
+		SELECT DISTINCT ON (gib.room) $1::date, gib.room, b.id, $2
+		FROM BOOKINGS b
+		JOIN GUESTS_IN_BOOKINGS gib ON gib.booking_id = b.id
+		WHERE b.check_in IS NOT NULL AND COALESCE(b.check_out::date, b.end_date) = $1
+		ORDER BY gib.room, b.id
+		ON CONFLICT (task_date, room) DO NOTHING`, date, models.HousekeepingTaskDeparture)
+	if err != nil {
+		return result, fmt.Errorf("error creating departure tasks: %v", err)
+	}
+	result.Departures = int(tag.RowsAffected())
+	tag, err = tx.Exec(ctx,
+		`INSERT INTO HOUSEKEEPING_TASKS (task_date, room, booking_id, kind)
+		SELECT DISTINCT ON (gib.room) $1::date, gib.room, b.id, $2
+		FROM BOOKINGS b
+		JOIN GUESTS_IN_BOOKINGS gib ON gib.booking_id = b.id
+		WHERE b.check_in IS NOT NULL AND b.check_out IS NULL
+			AND b.start_date < $1 AND b.end_date > $1
+		ORDER BY gib.room, b.id
+		ON CONFLICT (task_date, room) DO NOTHING`, date, models.HousekeepingTaskStayover)
+	if err != nil {
+		return result, fmt.Errorf("error creating stayover tasks: %v", err)
+	}
+	result.Stayovers = int(tag.RowsAffected())
+	if err = tx.Commit(ctx); err != nil {
+		return result, fmt.Errorf("error committing housekeeping tasks: %v", err)
+	}
+	return result, nil
+}
+
+// GetHousekeepingTasks возвращает задания на день; если передан assignedTo — только задания этой горничной
+func GetHousekeepingTasks(dbpool *pgxpool.Pool, date time.Time, assignedTo *int) ([]models.HousekeepingTask, error) {
+	var tasks []models.HousekeepingTask
+	err := pgxscan.Select(context.Background(), dbpool, &tasks,
+		`SELECT t.id, t.task_date, t.room, t.booking_id, t.kind, r.housekeeping_status AS status,
+				t.assigned_to, u.username AS assignee, t.completed_at, t.completed_by
+		FROM HOUSEKEEPING_TASKS t
+		JOIN ROOMS r ON r.number = t.room
+		LEFT JOIN USERS u ON u.id = t.assigned_to
+		WHERE t.task_date = $1 AND ($2::int IS NULL OR t.assigned_to = $2)
+		ORDER BY t.kind, t.room`, dateOnly(date), assignedTo)
+	if err != nil {
+		return nil, fmt.Errorf("error getting housekeeping tasks: %v", err)
+	}
+	return tasks, nil
+}
+
+func AssignHousekeepingTask(dbpool *pgxpool.Pool, in models.AssignHousekeepingTaskInput, userID *int) error {
+	ctx := context.Background()
+	tx, err := dbpool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("error beginning transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var previous *int
+	err = tx.QueryRow(ctx,
+		`SELECT assigned_to FROM HOUSEKEEPING_TASKS WHERE id = $1 FOR UPDATE`, in.TaskID).Scan(&previous)
+	if err != nil {
+		return fmt.Errorf("housekeeping task with ID %d not found: %v", in.TaskID, err)
+	}
+	_, err = tx.Exec(ctx, `UPDATE HOUSEKEEPING_TASKS SET assigned_to = $1 WHERE id = $2`, in.UserID, in.TaskID)
+	if err != nil {
+		log.Printf("error assigning housekeeping task: %v", err)
+		return fmt.Errorf("error assigning housekeeping task: %v", err)
+	}
+	err = writeAudit(ctx, tx, "housekeeping_task", in.TaskID, "assign", map[string]any{
+		"from": previous,
+		"to":   in.UserID,
+	}, userID)
+	if err != nil {
+		return err
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("error committing housekeeping assignment: %v", err)
+	}
+	return nil
+}
+
+// roomsNotReady возвращает номера бронирования, в которые пока нельзя заселять
+func roomsNotReady(ctx context.Context, q pgxscan.Querier, bookingID int) ([]int, error) {
+	var rooms []int
+	err := pgxscan.Select(ctx, q, &rooms,
+		`SELECT DISTINCT r.number
+		FROM GUESTS_IN_BOOKINGS gib
+		JOIN ROOMS r ON r.number = gib.room
+		WHERE gib.booking_id = $1 AND r.housekeeping_status NOT IN ($2, $3)
+		ORDER BY r.number`, bookingID, models.HousekeepingClean, models.HousekeepingInspected)
+	if err != nil {
+		return nil, fmt.Errorf("error checking housekeeping status: %v", err)
+	}
+	return rooms, nil
+}
+
+// RunHousekeepingTasks составляет список уборки на текущий бизнес-день
+func RunHousekeepingTasks(dbpool *pgxpool.Pool) {
+	date, err := CurrentBusinessDate(dbpool)
+	if err != nil {
+		log.Printf("Error getting business date: %v", err)
+		return
+	}
+	result, err := GenerateHousekeepingTasks(dbpool, date)
+	if err != nil {
+		log.Printf("Error generating housekeeping tasks for %s: %v", date.Format("2006-01-02"), err)
+		return
+	}
+	log.Printf("Housekeeping tasks for %s: %d departures, %d stayovers",
+		date.Format("2006-01-02"), result.Departures, result.Stayovers)
+}
@@ -0,0 +1,139 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"mis_kursach_backend/internal/models"
+	"mis_kursach_backend/internal/services"
+	"net/http"
+	"time"
+)
+
+// housekeepingDate читает ?date=ГГГГ-ММ-ДД, по умолчанию — сегодня
+func housekeepingDate(r *http.Request) (time.Time, error) {
+	if s := r.URL.Query().Get("date"); s != "" {
+		return time.Parse("2006-01-02", s)
+	}
+	return dateOnly(time.Now()), nil
+}
+
+func (p *PsHandler) GetHousekeepingTasks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	date, err := housekeepingDate(r)
+	if err != nil {
+		http.Error(w, `{"error": "invalid date"}`, http.StatusBadRequest)
+		return
+	}
+	// ?mine=true — только задания текущего пользователя, например для горничной на смене
+	var assignedTo *int
+	if r.URL.Query().Get("mine") == "true" {
+		assignedTo = userIDFromRequest(r)
+		if assignedTo == nil {
+			http.Error(w, `{"error": "unauthorized"}`, http.StatusUnauthorized)
+			return
+		}
+	}
+	tasks, err := GetHousekeepingTasks(p.dbpool, date, assignedTo)
+	if err != nil {
+		http.Error(w, `{"error": "failed to get housekeeping tasks"}`, http.StatusInternalServerError)
+		log.Printf("Error getting housekeeping tasks: %v", err)
+		return
+	}
+	if tasks == nil {
+		tasks = []models.HousekeepingTask{}
+	}
+	if err := json.NewEncoder(w).Encode(tasks); err != nil {
+		http.Error(w, `{"error": "failed to encode response"}`, http.StatusInternalServerError)
+		log.Printf("Error encoding housekeeping tasks: %v", err)
+	}
+}
+
+func (p *PsHandler) GenerateHousekeepingTasks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	date, err := housekeepingDate(r)
+	if err != nil {
+		http.Error(w, `{"error": "invalid date"}`, http.StatusBadRequest)
+		return
+	}
+	result, err := GenerateHousekeepingTasks(p.dbpool, date)
+	if err != nil {
+		http.Error(w, `{"error": "failed to generate housekeeping tasks"}`, http.StatusInternalServerError)
+		log.Printf("Error generating housekeeping tasks: %v", err)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		http.Error(w, `{"error": "failed to encode response"}`, http.StatusInternalServerError)
+		log.Printf("Error encoding housekeeping result: %v", err)
+	}
+}
+
+func (p *PsHandler) UpdateRoomHousekeeping(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var in models.RoomHousekeepingInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, `{"error": "invalid request body"}`, http.StatusBadRequest)
+		log.Printf("Error decoding housekeeping status: %v", err)
+		return
+	}
+	defer r.Body.Close()
+	errs := models.FieldErrors{}
+	if in.Room <= 0 {
+		errs["room"] = "room is required"
+	}
+	if !services.IsHousekeepingStatus(in.Status) {
+		errs["status"] = "status must be dirty, cleaning, clean or inspected"
+	}
+	if len(errs) > 0 {
+		writeFieldErrors(w, errs)
+		return
+	}
+	// проверку уборки проводит старшая смена, а не сама горничная
+	role := roleFromRequest(r)
+	if in.Status == models.HousekeepingInspected && role != models.RoleAdmin && role != models.RoleManager {
+		http.Error(w, `{"error": "only a manager can mark a room as inspected"}`, http.StatusForbidden)
+		return
+	}
+	err := SetRoomHousekeeping(p.dbpool, in, userIDFromRequest(r))
+	if errors.Is(err, ErrInvalidHousekeepingTransition) {
+		http.Error(w, `{"error": "invalid housekeeping status transition"}`, http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error": "failed to update housekeeping status"}`, http.StatusBadRequest)
+		log.Printf("Error updating housekeeping status: %v", err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "success"})
+}
+
+func (p *PsHandler) AssignHousekeepingTask(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var in models.AssignHousekeepingTaskInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, `{"error": "invalid request body"}`, http.StatusBadRequest)
+		log.Printf("Error decoding housekeeping assignment: %v", err)
+		return
+	}
+	defer r.Body.Close()
+	if in.TaskID <= 0 {
+		http.Error(w, `{"error": "task_id is required"}`, http.StatusBadRequest)
+		return
+	}
+	userID := userIDFromRequest(r)
+	role := roleFromRequest(r)
+	if role != models.RoleAdmin && role != models.RoleManager {
+		if in.UserID == nil || userID == nil || *in.UserID != *userID {
+			http.Error(w, `{"error": "only a manager can assign tasks to other users"}`, http.StatusForbidden)
+			return
+		}
+	}
+	if err := AssignHousekeepingTask(p.dbpool, in, userID); err != nil {
+		http.Error(w, `{"error": "failed to assign housekeeping task"}`, http.StatusBadRequest)
+		log.Printf("Error assigning housekeeping task: %v", err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "success"})
+}
@@ -0,0 +1,191 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"log"
+	"mis_kursach_backend/internal/models"
+	"mis_kursach_backend/internal/services"
+	"time"
+)
+
+// PostTransaction записывает сбалансированную проводку в транзакции tx вызывающего кода,
+// чтобы проводка фиксировалась или откатывалась вместе с изменением, которое она отражает
+func PostTransaction(ctx context.Context, tx pgx.Tx, txn models.LedgerTransaction) (int, error) {
+	if err := services.CheckBalanced(txn.Entries); err != nil {
+		return 0, err
+	}
+	// без явной даты проводка относится к текущему открытому бизнес-дню
+	if txn.BusinessDate.IsZero() {
+		date, err := currentBusinessDate(ctx, tx)
+		if err != nil {
+			return 0, err
+		}
+		txn.BusinessDate = date
+	} else if err := ensureDateOpen(tx, txn.BusinessDate); err != nil {
+		return 0, err
+	}
+
+	var id int
+	err := tx.QueryRow(ctx,
+		`INSERT INTO LEDGER_TRANSACTIONS (kind, booking_id, payment_id, folio_charge_id, memo, business_date, posted_at, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`,
+		txn.Kind, txn.BookingID, txn.PaymentID, txn.FolioChargeID, txn.Memo, txn.BusinessDate, time.Now(), txn.CreatedBy).Scan(&id)
+	if err != nil {
+		log.Printf("error inserting ledger transaction: %v", err)
+		return 0, fmt.Errorf("error inserting ledger transaction: %v", err)
+	}
+	for _, e := range txn.Entries {
+		_, err = tx.Exec(ctx,
+			`INSERT INTO LEDGER_ENTRIES (transaction_id, account_code, debit, credit) VALUES ($1, $2, $3, $4)`,
+			id, e.AccountCode, e.Debit, e.Credit)
+		if err != nil {
+			log.Printf("error inserting ledger entry: %v", err)
+			return 0, fmt.Errorf("error inserting ledger entry: %v", err)
+		}
+	}
+	return id, nil
+}
+
+// reverseNet сторнирует остаток по всем проводкам, связанным с бронированием, платежом или строкой фолио.
+// column — одна из колонок LEDGER_TRANSACTIONS, не пользовательский ввод.
+func reverseNet(ctx context.Context, tx pgx.Tx, column string, id int, txn models.LedgerTransaction) error {
+	var net []models.LedgerEntry
+	err := pgxscan.Select(ctx, tx, &net,
+		`SELECT le.account_code, SUM(le.debit) AS debit, SUM(le.credit) AS credit
+		FROM LEDGER_ENTRIES le
+		JOIN LEDGER_TRANSACTIONS lt ON lt.id = le.transaction_id
+		WHERE lt.`+column+` = $1
+		GROUP BY le.account_code
+		ORDER BY le.account_code`, id)
+	if err != nil {
+		return fmt.Errorf("error getting ledger balance for %s %d: %v", column, id, err)
+	}
+	txn.Entries = services.ReverseEntries(net)
+	if len(txn.Entries) == 0 {
+		// проводок не было или они уже сторнированы
+		return nil
+	}
+	_, err = PostTransaction(ctx, tx, txn)
+	return err
+}
+
+// postRoomNight проводит начисление за ночь проживания и, отдельной проводкой, скидку
+func postRoomNight(ctx context.Context, tx pgx.Tx, bookingID int, date time.Time, quote models.BookingQuote, userID *int) error {
+	_, err := PostTransaction(ctx, tx, models.LedgerTransaction{
+		Kind:         models.LedgerKindRoomCharge,
+		BookingID:    &bookingID,
+		Memo:         fmt.Sprintf("Проживание за %s", date.Format("2006-01-02")),
+		BusinessDate: date,
+		CreatedBy:    userID,
+		Entries:      services.ChargeEntries(models.AccountRoomRevenue, quote.BookingSum, quote.Taxes),
+	})
+	if err != nil {
+		return err
+	}
+	if quote.DiscountAmount.IsPositive() {
+		_, err = PostTransaction(ctx, tx, models.LedgerTransaction{
+			Kind:         models.LedgerKindDiscount,
+			BookingID:    &bookingID,
+			Memo:         fmt.Sprintf("Скидка %s%% за %s", quote.DiscountPercent, date.Format("2006-01-02")),
+			BusinessDate: date,
+			CreatedBy:    userID,
+			Entries:      services.DiscountEntries(quote.DiscountAmount),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// paymentLedgerAccount возвращает счёт, на который поступают деньги по способу оплаты платежа
+func paymentLedgerAccount(ctx context.Context, q pgxscan.Querier, paymentID int) (string, error) {
+	var account string
+	err := pgxscan.Get(ctx, q, &account,
+		`SELECT pm.ledger_account FROM PAYMENTS p
+		JOIN PAYMENT_METHODS pm ON pm.code = p.method_code
+		WHERE p.id = $1`, paymentID)
+	if err != nil {
+		return "", fmt.Errorf("error getting payment ledger account: %v", err)
+	}
+	return account, nil
+}
+
+func GetAccounts(dbpool *pgxpool.Pool) ([]models.Account, error) {
+	var accounts []models.Account
+	err := pgxscan.Select(context.Background(), dbpool, &accounts, `SELECT code, name, type FROM ACCOUNTS ORDER BY code`)
+	if err != nil {
+		return nil, fmt.Errorf("error getting accounts: %v", err)
+	}
+	return accounts, nil
+}
+
+// GetTrialBalance — оборотно-сальдовая ведомость на конец бизнес-дня asOf
+func GetTrialBalance(dbpool *pgxpool.Pool, asOf time.Time) (models.TrialBalance, error) {
+	tb := models.TrialBalance{AsOf: asOf, Currency: models.DefaultCurrency}
+	err := pgxscan.Select(context.Background(), dbpool, &tb.Rows,
+		`SELECT a.code AS account_code, a.name, a.type,
+				COALESCE(SUM(le.debit), 0) AS debit, COALESCE(SUM(le.credit), 0) AS credit
+		FROM ACCOUNTS a
+		LEFT JOIN (
+			SELECT le.account_code, le.debit, le.credit
+			FROM LEDGER_ENTRIES le
+			JOIN LEDGER_TRANSACTIONS lt ON lt.id = le.transaction_id
+			WHERE lt.business_date <= $1
+		) le ON le.account_code = a.code
+		GROUP BY a.code, a.name, a.type
+		ORDER BY a.code`, asOf)
+	if err != nil {
+		return tb, fmt.Errorf("error getting trial balance: %v", err)
+	}
+	for i, row := range tb.Rows {
+		tb.Rows[i].Balance = services.NormalBalance(row.Type, row.Debit, row.Credit)
+		tb.TotalDebit = tb.TotalDebit.Add(row.Debit)
+		tb.TotalCredit = tb.TotalCredit.Add(row.Credit)
+	}
+	return tb, nil
+}
+
+// GetAccountStatement — выписка по счёту за бизнес-дни [from, to] с нарастающим сальдо
+func GetAccountStatement(dbpool *pgxpool.Pool, code string, from, to time.Time) (models.AccountStatement, error) {
+	st := models.AccountStatement{From: from, To: to}
+	err := pgxscan.Get(context.Background(), dbpool, &st.Account,
+		`SELECT code, name, type FROM ACCOUNTS WHERE code = $1`, code)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return st, fmt.Errorf("account %s not found", code)
+		}
+		return st, fmt.Errorf("error getting account: %v", err)
+	}
+	var openingDebit, openingCredit models.Money
+	err = dbpool.QueryRow(context.Background(),
+		`SELECT COALESCE(SUM(le.debit), 0), COALESCE(SUM(le.credit), 0)
+		FROM LEDGER_ENTRIES le
+		JOIN LEDGER_TRANSACTIONS lt ON lt.id = le.transaction_id
+		WHERE le.account_code = $1 AND lt.business_date < $2`, code, from).Scan(&openingDebit, &openingCredit)
+	if err != nil {
+		return st, fmt.Errorf("error getting opening balance: %v", err)
+	}
+	st.OpeningBalance = services.NormalBalance(st.Account.Type, openingDebit, openingCredit)
+	err = pgxscan.Select(context.Background(), dbpool, &st.Lines,
+		`SELECT lt.id AS transaction_id, lt.kind, lt.booking_id, lt.memo, lt.business_date, lt.posted_at, le.debit, le.credit
+		FROM LEDGER_ENTRIES le
+		JOIN LEDGER_TRANSACTIONS lt ON lt.id = le.transaction_id
+		WHERE le.account_code = $1 AND lt.business_date BETWEEN $2 AND $3
+		ORDER BY lt.business_date, lt.posted_at, lt.id, le.id`, code, from, to)
+	if err != nil {
+		return st, fmt.Errorf("error getting account statement: %v", err)
+	}
+	balance := st.OpeningBalance
+	for i, line := range st.Lines {
+		balance = balance.Add(services.NormalBalance(st.Account.Type, line.Debit, line.Credit))
+		st.Lines[i].Balance = balance
+	}
+	st.ClosingBalance = balance
+	return st, nil
+}
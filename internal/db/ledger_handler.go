@@ -0,0 +1,104 @@
+package db
+
+import (
+	"encoding/json"
+	"log"
+	"mis_kursach_backend/internal/models"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+func (p *PsHandler) GetAccounts(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	accounts, err := GetAccounts(p.dbpool)
+	if err != nil {
+		http.Error(w, `{"error": "failed to get accounts"}`, http.StatusInternalServerError)
+		log.Printf("Error getting accounts: %v", err)
+		return
+	}
+	if accounts == nil {
+		accounts = []models.Account{}
+	}
+	if err := json.NewEncoder(w).Encode(accounts); err != nil {
+		http.Error(w, `{"error": "failed to encode response"}`, http.StatusInternalServerError)
+		log.Printf("Error encoding accounts: %v", err)
+	}
+}
+
+func (p *PsHandler) GetTrialBalance(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	asOf := time.Now().Truncate(24 * time.Hour)
+	if s := r.URL.Query().Get("as_of"); s != "" {
+		var err error
+		asOf, err = time.Parse("2006-01-02", s)
+		if err != nil {
+			http.Error(w, `{"error": "invalid as_of date"}`, http.StatusBadRequest)
+			return
+		}
+	}
+	tb, err := GetTrialBalance(p.dbpool, asOf)
+	if err != nil {
+		http.Error(w, `{"error": "failed to get trial balance"}`, http.StatusInternalServerError)
+		log.Printf("Error getting trial balance: %v", err)
+		return
+	}
+	if tb.Rows == nil {
+		tb.Rows = []models.TrialBalanceRow{}
+	}
+	if err := json.NewEncoder(w).Encode(tb); err != nil {
+		http.Error(w, `{"error": "failed to encode response"}`, http.StatusInternalServerError)
+		log.Printf("Error encoding trial balance: %v", err)
+	}
+}
+
+func (p *PsHandler) GetAccountStatement(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	code := r.URL.Query().Get("account")
+	if code == "" {
+		http.Error(w, `{"error": "account is required"}`, http.StatusBadRequest)
+		return
+	}
+	from, err := time.Parse("2006-01-02", r.URL.Query().Get("from"))
+	if err != nil {
+		http.Error(w, `{"error": "invalid from date"}`, http.StatusBadRequest)
+		return
+	}
+	to, err := time.Parse("2006-01-02", r.URL.Query().Get("to"))
+	if err != nil {
+		http.Error(w, `{"error": "invalid to date"}`, http.StatusBadRequest)
+		return
+	}
+	st, err := GetAccountStatement(p.dbpool, code, from, to)
+	if err != nil {
+		http.Error(w, `{"error": "failed to get account statement"}`, http.StatusBadRequest)
+		log.Printf("Error getting account statement: %v", err)
+		return
+	}
+	if st.Lines == nil {
+		st.Lines = []models.StatementLine{}
+	}
+	if err := json.NewEncoder(w).Encode(st); err != nil {
+		http.Error(w, `{"error": "failed to encode response"}`, http.StatusInternalServerError)
+		log.Printf("Error encoding account statement: %v", err)
+	}
+}
+
+func (p *PsHandler) RefundPayment(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		http.Error(w, `{"error": "invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+	err = RefundPayment(p.dbpool, id, userIDFromRequest(r))
+	if err != nil {
+		http.Error(w, `{"error": "failed to refund payment"}`, http.StatusBadRequest)
+		log.Printf("Error refunding payment: %v", err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	response := make(map[string]string)
+	response["message"] = "success"
+	json.NewEncoder(w).Encode(response)
+}
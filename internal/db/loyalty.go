@@ -0,0 +1,322 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"log"
+	"mis_kursach_backend/internal/models"
+	"mis_kursach_backend/internal/services"
+	"time"
+)
+
+const loyaltyColumns = `id, guest_id, kind, points, remaining, booking_id, payment_id, expires_at, created_at, reversed_at`
+
+func GetLoyaltyTiers(dbpool *pgxpool.Pool) ([]models.LoyaltyTier, error) {
+	var tiers []models.LoyaltyTier
+	err := pgxscan.Select(context.Background(), dbpool, &tiers,
+		`SELECT code, name, min_points, discount_percent FROM LOYALTY_TIERS ORDER BY min_points`)
+	if err != nil {
+		return nil, fmt.Errorf("error getting loyalty tiers: %v", err)
+	}
+	return tiers, nil
+}
+
+// loyaltyTierForPassport возвращает уровень гостя с этим паспортом; новый гость получает базовый уровень
+func loyaltyTierForPassport(dbpool *pgxpool.Pool, passport string) (models.LoyaltyTier, error) {
+	tiers, err := GetLoyaltyTiers(dbpool)
+	if err != nil {
+		return models.LoyaltyTier{}, err
+	}
+	var points int
+	err = dbpool.QueryRow(context.Background(),
+		`SELECT COALESCE(SUM(lt.points), 0)
+		FROM LOYALTY_TRANSACTIONS lt
+		JOIN GUESTS g ON g.id = lt.guest_id
+		WHERE (g.passport_hash = $1 OR g.passport_no = $2)
+			AND lt.kind = 'earn' AND lt.created_at >= $3`,
+		Passports.BlindIndex(passport), passport, time.Now().Add(-services.LoyaltyQualifyingPeriod)).Scan(&points)
+	if err != nil {
+		return models.LoyaltyTier{}, fmt.Errorf("error getting qualifying points: %v", err)
+	}
+	tier, _ := services.TierFor(tiers, points)
+	return tier, nil
+}
+
+// bookingLoyaltyGuest — гость, которому начисляются и с которого списываются баллы по бронированию.
+// Если в бронировании несколько гостей, это первый из них.
+func bookingLoyaltyGuest(ctx context.Context, q pgxscan.Querier, bookingID int) (int, error) {
+	var guestID *int
+	err := pgxscan.Get(ctx, q, &guestID,
+		`SELECT MIN(gib.guest_id)
+		FROM GUESTS_IN_BOOKINGS gib
+		JOIN GUESTS g ON g.id = gib.guest_id
+		WHERE gib.booking_id = $1 AND g.erased_at IS NULL`, bookingID)
+	if err != nil {
+		return 0, fmt.Errorf("error getting booking guest: %v", err)
+	}
+	if guestID == nil {
+		return 0, nil
+	}
+	return *guestID, nil
+}
+
+// earnLoyaltyPoints начисляет баллы за выезд по сумме, оплаченной гостем деньгами, в транзакции выезда.
+// Оплата баллами и сертификатами баллов не приносит. Повторно за бронирование баллы не начисляются.
+func earnLoyaltyPoints(ctx context.Context, tx pgx.Tx, bookingID int) (int, error) {
+	guestID, err := bookingLoyaltyGuest(ctx, tx, bookingID)
+	if err != nil || guestID == 0 {
+		return 0, err
+	}
+	var paid models.Money
+	err = tx.QueryRow(ctx,
+		`SELECT COALESCE(SUM(p.amount), 0)
+		FROM PAYMENTS p
+		JOIN PAYMENT_METHODS pm ON pm.code = p.method_code
+		WHERE p.booking_id = $1 AND p.status_code = $2 AND NOT pm.is_voucher AND NOT pm.is_loyalty`,
+		bookingID, models.PaymentStatusPaid).Scan(&paid)
+	if err != nil {
+		return 0, fmt.Errorf("error getting booking payments: %v", err)
+	}
+	points := services.PointsEarned(paid)
+	if points == 0 {
+		return 0, nil
+	}
+	now := time.Now()
+	var id int
+	err = tx.QueryRow(ctx,
+		`INSERT INTO LOYALTY_TRANSACTIONS (guest_id, kind, points, remaining, booking_id, expires_at, created_at)
+		VALUES ($1, $2, $3, $3, $4, $5, $6)
+		ON CONFLICT (booking_id) WHERE kind = 'earn' DO NOTHING
+		RETURNING id`,
+		guestID, models.LoyaltyEarn, points, bookingID, dateOnly(now.Add(services.LoyaltyPointsTTL)), now).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		log.Printf("error inserting loyalty points: %v", err)
+		return 0, fmt.Errorf("error inserting loyalty points: %v", err)
+	}
+	_, err = PostTransaction(ctx, tx, models.LedgerTransaction{
+		Kind:      models.LedgerKindLoyaltyAccrual,
+		BookingID: &bookingID,
+		Memo:      fmt.Sprintf("Начисление %d баллов за бронирование %d", points, bookingID),
+		Entries:   services.LoyaltyAccrualEntries(services.PointsValue(points)),
+	})
+	if err != nil {
+		return 0, err
+	}
+	return points, nil
+}
+
+// redeemLoyaltyPoints оплачивает бронирование баллами гостя. Списываются сначала баллы,
+// которые сгорят раньше. Платёж сразу считается подтверждённым.
+func redeemLoyaltyPoints(dbpool *pgxpool.Pool, p models.CreatePaymentInput) error {
+	if p.Currency != "" && p.Currency != models.DefaultCurrency {
+		return fmt.Errorf("loyalty points are redeemed in %s only", models.DefaultCurrency)
+	}
+	points, ok := services.PointsForAmount(p.Amount)
+	if !ok || points <= 0 {
+		return fmt.Errorf("amount must be a positive multiple of the point value %s", services.PointsValue(1))
+	}
+	ctx := context.Background()
+	tx, err := dbpool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("error beginning transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	guestID, err := bookingLoyaltyGuest(ctx, tx, p.BookingID)
+	if err != nil {
+		return err
+	}
+	if guestID == 0 {
+		return fmt.Errorf("booking %d has no guest to redeem points from", p.BookingID)
+	}
+	var lots []models.LoyaltyTransaction
+	err = pgxscan.Select(ctx, tx, &lots,
+		`SELECT `+loyaltyColumns+` FROM LOYALTY_TRANSACTIONS
+		WHERE guest_id = $1 AND remaining > 0 AND expires_at >= $2
+		ORDER BY expires_at, id
+		FOR UPDATE`, guestID, dateOnly(time.Now()))
+	if err != nil {
+		return fmt.Errorf("error getting loyalty points: %v", err)
+	}
+	balance := 0
+	for _, lot := range lots {
+		balance += lot.Remaining
+	}
+	if balance < points {
+		return fmt.Errorf("guest %d has only %d points", guestID, balance)
+	}
+
+	// при возврате платежа баллы вернутся со сроком самой поздней из списанных партий
+	var latestExpiry time.Time
+	left := points
+	for _, lot := range lots {
+		if left == 0 {
+			break
+		}
+		take := min(left, lot.Remaining)
+		_, err = tx.Exec(ctx, `UPDATE LOYALTY_TRANSACTIONS SET remaining = remaining - $1 WHERE id = $2`, take, lot.ID)
+		if err != nil {
+			return fmt.Errorf("error updating loyalty points: %v", err)
+		}
+		if lot.ExpiresAt.After(latestExpiry) {
+			latestExpiry = *lot.ExpiresAt
+		}
+		left -= take
+	}
+
+	var paymentID int
+	err = tx.QueryRow(ctx,
+		`INSERT INTO Payments(
+				booking_id, pay_date, amount,
+				original_amount, original_currency, exchange_rate,
+				method_code, status_code) VALUES ($1, $2, $3, $3, $4, 1, $5, $6) RETURNING id`,
+		p.BookingID, time.Now(), p.Amount, models.DefaultCurrency, p.MethodCode, models.PaymentStatusPaid).Scan(&paymentID)
+	if err != nil {
+		log.Printf("error inserting payment: %v", err)
+		return fmt.Errorf("error inserting payment: %v", err)
+	}
+	_, err = tx.Exec(ctx,
+		`INSERT INTO LOYALTY_TRANSACTIONS (guest_id, kind, points, remaining, booking_id, payment_id, expires_at, created_at)
+		VALUES ($1, $2, $3, 0, $4, $5, $6, $7)`,
+		guestID, models.LoyaltyRedeem, -points, p.BookingID, paymentID, latestExpiry, time.Now())
+	if err != nil {
+		log.Printf("error inserting loyalty redemption: %v", err)
+		return fmt.Errorf("error inserting loyalty redemption: %v", err)
+	}
+	_, err = PostTransaction(ctx, tx, models.LedgerTransaction{
+		Kind:      models.LedgerKindPayment,
+		BookingID: &p.BookingID,
+		PaymentID: &paymentID,
+		Memo:      fmt.Sprintf("Оплата баллами (%d) по бронированию %d", points, p.BookingID),
+		Entries:   services.PaymentEntries(models.AccountLoyaltyLiability, p.Amount),
+	})
+	if err != nil {
+		return err
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("error committing loyalty redemption: %v", err)
+	}
+	return nil
+}
+
+// restoreLoyaltyRedemption возвращает гостю баллы, списанные платежом.
+// Возвращает false, если платёж был сделан не баллами.
+func restoreLoyaltyRedemption(ctx context.Context, q execer, paymentID int) (bool, error) {
+	result, err := q.Exec(ctx,
+		`WITH r AS (
+			UPDATE LOYALTY_TRANSACTIONS SET reversed_at = $2
+			WHERE payment_id = $1 AND kind = 'redeem' AND reversed_at IS NULL
+			RETURNING guest_id, points, booking_id, payment_id, expires_at
+		)
+		INSERT INTO LOYALTY_TRANSACTIONS (guest_id, kind, points, remaining, booking_id, payment_id, expires_at, created_at)
+		SELECT guest_id, 'restore', -points, -points, booking_id, payment_id, expires_at, $2
+		FROM r`, paymentID, time.Now())
+	if err != nil {
+		log.Printf("error restoring loyalty points: %v", err)
+		return false, fmt.Errorf("error restoring loyalty points: %v", err)
+	}
+	return result.RowsAffected() > 0, nil
+}
+
+func GetLoyaltyStatement(dbpool *pgxpool.Pool, guestID int) (models.LoyaltyStatement, error) {
+	var exists bool
+	err := dbpool.QueryRow(context.Background(),
+		`SELECT EXISTS(SELECT 1 FROM GUESTS WHERE id = $1)`, guestID).Scan(&exists)
+	if err != nil {
+		return models.LoyaltyStatement{}, fmt.Errorf("error getting guest: %v", err)
+	}
+	if !exists {
+		return models.LoyaltyStatement{}, fmt.Errorf("guest with ID %d not found", guestID)
+	}
+	var txns []models.LoyaltyTransaction
+	err = pgxscan.Select(context.Background(), dbpool, &txns,
+		`SELECT `+loyaltyColumns+` FROM LOYALTY_TRANSACTIONS
+		WHERE guest_id = $1
+		ORDER BY created_at DESC, id DESC`, guestID)
+	if err != nil {
+		return models.LoyaltyStatement{}, fmt.Errorf("error getting loyalty transactions: %v", err)
+	}
+	if txns == nil {
+		txns = []models.LoyaltyTransaction{}
+	}
+	tiers, err := GetLoyaltyTiers(dbpool)
+	if err != nil {
+		return models.LoyaltyStatement{}, err
+	}
+	return services.LoyaltyStatement(guestID, txns, tiers, time.Now()), nil
+}
+
+// ExpireLoyaltyPoints списывает остатки партий баллов со сроком раньше asOf
+func ExpireLoyaltyPoints(dbpool *pgxpool.Pool, asOf time.Time) (models.LoyaltyExpiryResult, error) {
+	result := models.LoyaltyExpiryResult{AsOf: asOf}
+	ctx := context.Background()
+	tx, err := dbpool.Begin(ctx)
+	if err != nil {
+		return result, fmt.Errorf("error beginning transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var lots []models.LoyaltyTransaction
+	err = pgxscan.Select(ctx, tx, &lots,
+		`SELECT `+loyaltyColumns+` FROM LOYALTY_TRANSACTIONS
+		WHERE remaining > 0 AND expires_at < $1
+		ORDER BY guest_id, id
+		FOR UPDATE`, asOf)
+	if err != nil {
+		return result, fmt.Errorf("error getting expired loyalty points: %v", err)
+	}
+	expired := map[int]int{}
+	for _, lot := range lots {
+		expired[lot.GuestID] += lot.Remaining
+		result.Points += lot.Remaining
+	}
+	if result.Points == 0 {
+		return result, nil
+	}
+	_, err = tx.Exec(ctx, `UPDATE LOYALTY_TRANSACTIONS SET remaining = 0 WHERE remaining > 0 AND expires_at < $1`, asOf)
+	if err != nil {
+		return result, fmt.Errorf("error expiring loyalty points: %v", err)
+	}
+	now := time.Now()
+	for guestID, points := range expired {
+		_, err = tx.Exec(ctx,
+			`INSERT INTO LOYALTY_TRANSACTIONS (guest_id, kind, points, remaining, expires_at, created_at)
+			VALUES ($1, $2, $3, 0, $4, $5)`,
+			guestID, models.LoyaltyExpire, -points, asOf, now)
+		if err != nil {
+			return result, fmt.Errorf("error inserting loyalty expiry: %v", err)
+		}
+	}
+	result.Guests = len(expired)
+	_, err = PostTransaction(ctx, tx, models.LedgerTransaction{
+		Kind:    models.LedgerKindLoyaltyExpiry,
+		Memo:    fmt.Sprintf("Сгорание %d баллов на %s", result.Points, asOf.Format("2006-01-02")),
+		Entries: services.LoyaltyExpiryEntries(services.PointsValue(result.Points)),
+	})
+	if err != nil {
+		return result, err
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return result, fmt.Errorf("error committing loyalty expiry: %v", err)
+	}
+	return result, nil
+}
+
+// RunLoyaltyExpiry — ежедневное задание: сжигает баллы с истёкшим сроком
+func RunLoyaltyExpiry(dbpool *pgxpool.Pool) {
+	result, err := ExpireLoyaltyPoints(dbpool, dateOnly(time.Now()))
+	if err != nil {
+		log.Printf("Error expiring loyalty points: %v", err)
+		return
+	}
+	if result.Points > 0 {
+		log.Printf("Expired %d loyalty points of %d guests", result.Points, result.Guests)
+	}
+}
@@ -0,0 +1,69 @@
+package db
+
+import (
+	"encoding/json"
+	"github.com/go-chi/chi/v5"
+	"log"
+	"mis_kursach_backend/internal/models"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+func (p *PsHandler) GetLoyaltyTiers(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	tiers, err := GetLoyaltyTiers(p.dbpool)
+	if err != nil {
+		http.Error(w, `{"error": "failed to get loyalty tiers"}`, http.StatusInternalServerError)
+		log.Printf("Error getting loyalty tiers: %v", err)
+		return
+	}
+	if tiers == nil {
+		tiers = []models.LoyaltyTier{}
+	}
+	if err := json.NewEncoder(w).Encode(tiers); err != nil {
+		http.Error(w, `{"error": "failed to encode response"}`, http.StatusInternalServerError)
+		log.Printf("Error encoding loyalty tiers: %v", err)
+	}
+}
+
+func (p *PsHandler) GetLoyaltyStatement(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	guestID, err := strconv.Atoi(chi.URLParam(r, "guest_id"))
+	if err != nil {
+		http.Error(w, `{"error": "invalid guest_id"}`, http.StatusBadRequest)
+		return
+	}
+	statement, err := GetLoyaltyStatement(p.dbpool, guestID)
+	if err != nil {
+		http.Error(w, `{"error": "failed to get loyalty statement"}`, http.StatusNotFound)
+		log.Printf("Error getting loyalty statement: %v", err)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(statement); err != nil {
+		http.Error(w, `{"error": "failed to encode response"}`, http.StatusInternalServerError)
+		log.Printf("Error encoding loyalty statement: %v", err)
+	}
+}
+
+func (p *PsHandler) ExpireLoyaltyPoints(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	asOf := dateOnly(time.Now())
+	if s := r.URL.Query().Get("as_of"); s != "" {
+		var err error
+		asOf, err = time.Parse("2006-01-02", s)
+		if err != nil {
+			http.Error(w, `{"error": "invalid as_of date"}`, http.StatusBadRequest)
+			return
+		}
+	}
+	result, err := ExpireLoyaltyPoints(p.dbpool, asOf)
+	if err != nil {
+		http.Error(w, `{"error": "failed to expire loyalty points"}`, http.StatusInternalServerError)
+		log.Printf("Error expiring loyalty points: %v", err)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		log.Printf("Error encoding loyalty expiry: %v", err)
+	}
+}
@@ -0,0 +1,190 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"log"
+	"mis_kursach_backend/internal/models"
+	"mis_kursach_backend/internal/services"
+	"time"
+)
+
+// MigrationLookbackDays — за сколько дней по умолчанию показываются неподанные уведомления.
+// Более старые заезды были до учёта уведомлений в системе и в список не попадают.
+const MigrationLookbackDays = 30
+
+const migrationArrivalsQuery = `SELECT b.id AS booking_id, g.id AS guest_id, g.name AS guest_name,
+		g.nationality, g.document_type, COALESCE(g.passport_no, '') AS passport_no, g.passport_enc, gib.room,
+		g.visa_number, g.visa_expires_at, g.migration_card_no, g.entry_date, g.migration_card_expires_at,
+		b.check_in::date AS arrival_date, b.end_date AS departure_date, mn.exported_at
+	FROM BOOKINGS b
+	JOIN GUESTS_IN_BOOKINGS gib ON gib.booking_id = b.id
+	JOIN GUESTS g ON g.id = gib.guest_id
+	LEFT JOIN MIGRATION_NOTICES mn ON mn.booking_id = b.id AND mn.guest_id = g.id
+	WHERE b.check_in IS NOT NULL
+		AND g.nationality IS NOT NULL AND g.nationality <> 'RU'
+		AND g.erased_at IS NULL`
+
+// holidayChecker загружает праздники периода и возвращает проверку дня
+func holidayChecker(dbpool *pgxpool.Pool, from, to time.Time) (func(time.Time) bool, error) {
+	var dates []time.Time
+	err := pgxscan.Select(context.Background(), dbpool, &dates,
+		`SELECT holiday_date FROM HOLIDAYS WHERE holiday_date BETWEEN $1 AND $2`, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("error getting holidays: %v", err)
+	}
+	holidays := make(map[time.Time]bool, len(dates))
+	for _, d := range dates {
+		holidays[dateOnly(d)] = true
+	}
+	return func(day time.Time) bool { return holidays[dateOnly(day)] }, nil
+}
+
+// fillMigrationArrivals расшифровывает паспорта и рассчитывает сроки подачи
+func fillMigrationArrivals(dbpool *pgxpool.Pool, arrivals []models.MigrationArrival) error {
+	if len(arrivals) == 0 {
+		return nil
+	}
+	today := dateOnly(time.Now())
+	from, to := arrivals[0].ArrivalDate, today
+	for _, a := range arrivals {
+		if a.ArrivalDate.Before(from) {
+			from = a.ArrivalDate
+		}
+		if a.ArrivalDate.After(to) {
+			to = a.ArrivalDate
+		}
+	}
+	// в запасе две недели: сроки считаются в рабочих днях, а праздники бывают подряд
+	isHoliday, err := holidayChecker(dbpool, from, to.AddDate(0, 0, 14))
+	if err != nil {
+		return err
+	}
+	for i := range arrivals {
+		a := &arrivals[i]
+		if err := revealPassport(&a.PassportNo, a.PassportEnc); err != nil {
+			return fmt.Errorf("guest %d: %v", a.GuestID, err)
+		}
+		a.Deadline = services.MigrationDeadline(dateOnly(a.ArrivalDate), isHoliday)
+		a.DaysLeft = daysBetween(today, a.Deadline)
+		a.Overdue = a.DaysLeft < 0
+		a.Missing = services.MigrationMissing(*a)
+	}
+	return nil
+}
+
+// GetPendingMigrations — заезды иностранных гостей начиная с since, по которым уведомление ещё не подано
+func GetPendingMigrations(dbpool *pgxpool.Pool, since time.Time) ([]models.MigrationArrival, error) {
+	var arrivals []models.MigrationArrival
+	err := pgxscan.Select(context.Background(), dbpool, &arrivals,
+		migrationArrivalsQuery+`
+		AND b.check_in::date >= $1 AND mn.filed_at IS NULL
+	ORDER BY b.check_in, b.id, g.id`, since)
+	if err != nil {
+		return nil, fmt.Errorf("error getting pending migration registrations: %v", err)
+	}
+	if err = fillMigrationArrivals(dbpool, arrivals); err != nil {
+		return nil, err
+	}
+	return arrivals, nil
+}
+
+// GetMigrationDeadlines считает ожидающие уведомления для панели показателей
+func GetMigrationDeadlines(dbpool *pgxpool.Pool) (models.MigrationDeadlines, error) {
+	var deadlines models.MigrationDeadlines
+	arrivals, err := GetPendingMigrations(dbpool, dateOnly(time.Now()).AddDate(0, 0, -MigrationLookbackDays))
+	if err != nil {
+		return deadlines, err
+	}
+	for _, a := range arrivals {
+		deadlines.Pending++
+		switch {
+		case a.Overdue:
+			deadlines.Overdue++
+		case a.DaysLeft == 0:
+			deadlines.DueToday++
+		}
+		if len(a.Missing) > 0 {
+			deadlines.Incomplete++
+		}
+	}
+	return deadlines, nil
+}
+
+// ExportMigrationNotices выгружает ожидающие уведомления с полными данными и отмечает время выгрузки.
+// Уведомления, которым не хватает данных, возвращаются отдельно и не выгружаются.
+func ExportMigrationNotices(dbpool *pgxpool.Pool, since time.Time, userID *int) (models.MigrationNoticeBatch, []models.MigrationArrival, error) {
+	now := time.Now()
+	batch := models.MigrationNoticeBatch{GeneratedAt: now.Format(time.RFC3339), Notices: []models.MigrationNotice{}}
+	skipped := []models.MigrationArrival{}
+	arrivals, err := GetPendingMigrations(dbpool, since)
+	if err != nil {
+		return batch, skipped, err
+	}
+	ctx := context.Background()
+	tx, err := dbpool.Begin(ctx)
+	if err != nil {
+		return batch, skipped, fmt.Errorf("error beginning transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	for _, a := range arrivals {
+		if len(a.Missing) > 0 {
+			skipped = append(skipped, a)
+			continue
+		}
+		_, err = tx.Exec(ctx,
+			`INSERT INTO MIGRATION_NOTICES (booking_id, guest_id, arrival_date, deadline, exported_at)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (booking_id, guest_id) DO UPDATE SET exported_at = EXCLUDED.exported_at, deadline = EXCLUDED.deadline`,
+			a.BookingID, a.GuestID, a.ArrivalDate, a.Deadline, now)
+		if err != nil {
+			log.Printf("error saving migration notice: %v", err)
+			return batch, skipped, fmt.Errorf("error saving migration notice: %v", err)
+		}
+		// в выгрузку попадает полный номер документа, поэтому каждая выгрузка фиксируется в журнале
+		err = writeAudit(ctx, tx, "guest", a.GuestID, "migration_export", map[string]any{"booking_id": a.BookingID}, userID)
+		if err != nil {
+			return batch, skipped, err
+		}
+		batch.Notices = append(batch.Notices, services.MigrationNoticeFor(a))
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return batch, skipped, fmt.Errorf("error committing migration export: %v", err)
+	}
+	return batch, skipped, nil
+}
+
+// MarkMigrationFiled отмечает уведомление поданным; подать можно и без выгрузки, вручную
+func MarkMigrationFiled(dbpool *pgxpool.Pool, in models.MarkMigrationFiledInput, userID *int) error {
+	var arrivals []models.MigrationArrival
+	err := pgxscan.Select(context.Background(), dbpool, &arrivals,
+		migrationArrivalsQuery+` AND b.id = $1 AND g.id = $2`, in.BookingID, in.GuestID)
+	if err != nil {
+		return fmt.Errorf("error getting migration registration: %v", err)
+	}
+	if len(arrivals) == 0 {
+		return fmt.Errorf("guest %d of booking %d is not a checked-in foreign guest", in.GuestID, in.BookingID)
+	}
+	if err = fillMigrationArrivals(dbpool, arrivals); err != nil {
+		return err
+	}
+	a := arrivals[0]
+	var confirmation *string
+	if in.ConfirmationNo != "" {
+		confirmation = &in.ConfirmationNo
+	}
+	_, err = dbpool.Exec(context.Background(),
+		`INSERT INTO MIGRATION_NOTICES (booking_id, guest_id, arrival_date, deadline, filed_at, filed_by, confirmation_no)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (booking_id, guest_id) DO UPDATE
+		SET filed_at = EXCLUDED.filed_at, filed_by = EXCLUDED.filed_by, confirmation_no = EXCLUDED.confirmation_no`,
+		a.BookingID, a.GuestID, a.ArrivalDate, a.Deadline, time.Now(), userID, confirmation)
+	if err != nil {
+		log.Printf("error marking migration notice filed: %v", err)
+		return fmt.Errorf("error marking migration notice filed: %v", err)
+	}
+	return nil
+}
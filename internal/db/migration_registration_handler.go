@@ -0,0 +1,98 @@
+package db
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"log"
+	"mis_kursach_backend/internal/models"
+	"mis_kursach_backend/internal/services"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// migrationSince читает ?since=ГГГГ-ММ-ДД, по умолчанию — последние MigrationLookbackDays дней
+func migrationSince(r *http.Request) (time.Time, error) {
+	if s := r.URL.Query().Get("since"); s != "" {
+		return time.Parse("2006-01-02", s)
+	}
+	return dateOnly(time.Now()).AddDate(0, 0, -MigrationLookbackDays), nil
+}
+
+func (p *PsHandler) GetPendingMigrations(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	since, err := migrationSince(r)
+	if err != nil {
+		http.Error(w, `{"error": "invalid since date"}`, http.StatusBadRequest)
+		return
+	}
+	arrivals, err := GetPendingMigrations(p.dbpool, since)
+	if err != nil {
+		http.Error(w, `{"error": "failed to get pending migration registrations"}`, http.StatusInternalServerError)
+		log.Printf("Error getting pending migration registrations: %v", err)
+		return
+	}
+	if arrivals == nil {
+		arrivals = []models.MigrationArrival{}
+	}
+	if !fullPassportAccess(r) {
+		for i := range arrivals {
+			arrivals[i].PassportNo = services.MaskPassport(arrivals[i].PassportNo)
+		}
+	}
+	if err := json.NewEncoder(w).Encode(arrivals); err != nil {
+		http.Error(w, `{"error": "failed to encode response"}`, http.StatusInternalServerError)
+		log.Printf("Error encoding pending migration registrations: %v", err)
+	}
+}
+
+// ExportMigrationNotices отдаёт XML-файл для программы постановки на миграционный учёт.
+// Число пропущенных из-за неполных данных уведомлений — в заголовке X-Skipped-Notices.
+func (p *PsHandler) ExportMigrationNotices(w http.ResponseWriter, r *http.Request) {
+	since, err := migrationSince(r)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		http.Error(w, `{"error": "invalid since date"}`, http.StatusBadRequest)
+		return
+	}
+	batch, skipped, err := ExportMigrationNotices(p.dbpool, since, userIDFromRequest(r))
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		http.Error(w, `{"error": "failed to export migration notices"}`, http.StatusInternalServerError)
+		log.Printf("Error exporting migration notices: %v", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	w.Header().Set("Content-Disposition",
+		fmt.Sprintf(`attachment; filename="migration_notices_%s.xml"`, time.Now().Format("20060102_150405")))
+	w.Header().Set("X-Skipped-Notices", strconv.Itoa(len(skipped)))
+	w.Write([]byte(xml.Header))
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(batch); err != nil {
+		log.Printf("Error encoding migration notices: %v", err)
+	}
+}
+
+func (p *PsHandler) MarkMigrationFiled(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var in models.MarkMigrationFiledInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, `{"error": "invalid request body"}`, http.StatusBadRequest)
+		log.Printf("Error decoding migration filing: %v", err)
+		return
+	}
+	defer r.Body.Close()
+	if in.BookingID <= 0 || in.GuestID <= 0 {
+		http.Error(w, `{"error": "booking_id and guest_id are required"}`, http.StatusBadRequest)
+		return
+	}
+	if err := MarkMigrationFiled(p.dbpool, in, userIDFromRequest(r)); err != nil {
+		http.Error(w, `{"error": "failed to mark migration notice filed"}`, http.StatusBadRequest)
+		log.Printf("Error marking migration notice filed: %v", err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "success"})
+}
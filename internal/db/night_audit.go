@@ -0,0 +1,341 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"log"
+	"mis_kursach_backend/internal/models"
+	"mis_kursach_backend/internal/services"
+	"time"
+)
+
+// ErrDateClosed возвращается при попытке изменить данные закрытого бизнес-дня
+var ErrDateClosed = errors.New("business date is closed")
+
+// ErrAuditUnreconciled возвращается, если платежи не сходятся с главной книгой
+var ErrAuditUnreconciled = errors.New("payments do not reconcile with the ledger")
+
+// inHouseBooking — бронирование, гость которого проживал в номере в аудируемую ночь
+type inHouseBooking struct {
+	BookingID       int            `db:"booking_id"`
+	Room            int            `db:"room"`
+	StartDate       time.Time      `db:"start_date"`
+	EndDate         time.Time      `db:"end_date"`
+	BookingSum      models.Money   `db:"booking_sum"`
+	TotalSum        models.Money   `db:"total_sum"`
+	TaxSum          models.Money   `db:"tax_sum"`
+	DiscountPercent models.Decimal `db:"discount_percent"`
+}
+
+func dateOnly(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func daysBetween(from, to time.Time) int {
+	return int(dateOnly(to).Sub(dateOnly(from)).Hours() / 24)
+}
+
+// CurrentBusinessDate — открытый бизнес-день: следующий за последним закрытым.
+// Пока аудит ни разу не проводился, бизнес-день совпадает с календарным.
+func CurrentBusinessDate(dbpool *pgxpool.Pool) (time.Time, error) {
+	return currentBusinessDate(context.Background(), dbpool)
+}
+
+func currentBusinessDate(ctx context.Context, q pgxscan.Querier) (time.Time, error) {
+	var date time.Time
+	err := pgxscan.Get(ctx, q, &date,
+		`SELECT COALESCE(MAX(business_date) + 1, CURRENT_DATE) FROM BUSINESS_DAYS`)
+	if err != nil {
+		return date, fmt.Errorf("error getting business date: %v", err)
+	}
+	return date, nil
+}
+
+// ensureDateOpen запрещает изменения, датированные закрытым бизнес-днём
+func ensureDateOpen(q pgxscan.Querier, date time.Time) error {
+	var closed bool
+	err := pgxscan.Get(context.Background(), q, &closed,
+		`SELECT EXISTS(SELECT 1 FROM BUSINESS_DAYS WHERE business_date >= $1::date)`, dateOnly(date))
+	if err != nil {
+		return fmt.Errorf("error checking business date: %v", err)
+	}
+	if closed {
+		return fmt.Errorf("%w: %s", ErrDateClosed, date.Format("2006-01-02"))
+	}
+	return nil
+}
+
+// RunNightAudit закрывает бизнес-день: сверяет платежи с книгой, начисляет проживание за ночь,
+// отмечает незаезды и сохраняет отчёт. После этого день закрыт для изменений задним числом.
+// При расхождении в платежах день не закрывается, пока не передан force.
+func RunNightAudit(dbpool *pgxpool.Pool, date time.Time, force bool, userID *int) (models.NightAuditReport, error) {
+	date = dateOnly(date)
+	report := models.NightAuditReport{
+		BusinessDate: date,
+		ClosedBy:     userID,
+		Forced:       force,
+		Currency:     models.DefaultCurrency,
+	}
+	ctx := context.Background()
+	tx, err := dbpool.Begin(ctx)
+	if err != nil {
+		return report, fmt.Errorf("error beginning transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+	// аудит по расписанию и ручной запуск не должны закрывать один день одновременно:
+	// второй ждёт, пока первый закончит, и затем видит, что день уже закрыт
+	_, err = tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext('night_audit'), $1::date - DATE '2000-01-01')`, date)
+	if err != nil {
+		return report, fmt.Errorf("error locking business date: %v", err)
+	}
+	current, err := currentBusinessDate(ctx, tx)
+	if err != nil {
+		return report, err
+	}
+	if !date.Equal(current) {
+		return report, fmt.Errorf("only the open business date %s can be closed", current.Format("2006-01-02"))
+	}
+	if date.After(dateOnly(time.Now())) {
+		return report, fmt.Errorf("business date %s has not started yet", date.Format("2006-01-02"))
+	}
+
+	report.Discrepancies, err = reconcilePayments(ctx, tx, date)
+	if err != nil {
+		return report, err
+	}
+	if len(report.Discrepancies) > 0 && !force {
+		return report, ErrAuditUnreconciled
+	}
+	err = tx.QueryRow(ctx,
+		`SELECT COUNT(*) FROM PAYMENTS WHERE status_code = $1 AND pay_date::date <= $2`,
+		models.PaymentStatusPending, date).Scan(&report.UnconfirmedCount)
+	if err != nil {
+		return report, fmt.Errorf("error counting unconfirmed payments: %v", err)
+	}
+
+	report.RoomCharges, err = postRoomCharges(ctx, tx, date, userID)
+	if err != nil {
+		return report, err
+	}
+	report.NoShows, err = flagNoShows(ctx, tx, date)
+	if err != nil {
+		return report, err
+	}
+
+	err = tx.QueryRow(ctx,
+		`SELECT
+			(SELECT COUNT(*) FROM ROOMS r
+			JOIN ROOM_CATEGORIES rc ON rc.code = r.category_code
+			WHERE r.active AND rc.active),
+			(SELECT COUNT(DISTINCT gib.room)
+			FROM BOOKINGS b
+			JOIN GUESTS_IN_BOOKINGS gib ON gib.booking_id = b.id
+			WHERE b.check_in::date <= $1 AND (b.check_out IS NULL OR b.check_out::date > $1)
+				AND b.start_date <= $1 AND b.end_date > $1)`,
+		date).Scan(&report.RoomsTotal, &report.RoomsOccupied)
+	if err != nil {
+		return report, fmt.Errorf("error getting occupancy: %v", err)
+	}
+	err = tx.QueryRow(ctx,
+		`SELECT
+			COALESCE(SUM(le.credit - le.debit) FILTER (WHERE le.account_code = $2), 0),
+			COALESCE(SUM(le.credit - le.debit) FILTER (WHERE le.account_code = $3), 0)
+		FROM LEDGER_ENTRIES le
+		JOIN LEDGER_TRANSACTIONS lt ON lt.id = le.transaction_id
+		WHERE lt.business_date = $1`,
+		date, models.AccountRoomRevenue, models.AccountExtrasRevenue).Scan(&report.RoomRevenue, &report.ExtrasRevenue)
+	if err != nil {
+		return report, fmt.Errorf("error getting revenue: %v", err)
+	}
+	err = pgxscan.Select(ctx, tx, &report.Payments,
+		`SELECT le.account_code, SUM(le.debit) AS received, SUM(le.credit) AS refunded
+		FROM LEDGER_ENTRIES le
+		JOIN LEDGER_TRANSACTIONS lt ON lt.id = le.transaction_id
+		WHERE lt.business_date = $1 AND lt.payment_id IS NOT NULL
+			AND le.account_code IN (SELECT ledger_account FROM PAYMENT_METHODS)
+		GROUP BY le.account_code
+		ORDER BY le.account_code`, date)
+	if err != nil {
+		return report, fmt.Errorf("error getting payment totals: %v", err)
+	}
+
+	report.ClosedAt = time.Now()
+	body, err := json.Marshal(report)
+	if err != nil {
+		return report, fmt.Errorf("error encoding night audit report: %v", err)
+	}
+	// день закрывается в той же транзакции, что и начисления: либо всё, либо ничего
+	_, err = tx.Exec(ctx,
+		`INSERT INTO BUSINESS_DAYS (business_date, closed_at, closed_by, forced, report) VALUES ($1, $2, $3, $4, $5)`,
+		date, report.ClosedAt, userID, force, body)
+	if err != nil {
+		log.Printf("error closing business date: %v", err)
+		return report, fmt.Errorf("error closing business date: %v", err)
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return report, fmt.Errorf("error committing night audit: %v", err)
+	}
+	return report, nil
+}
+
+// RunPendingNightAudits закрывает все прошедшие бизнес-дни по порядку.
+// Останавливается на первом дне, который не удалось закрыть без вмешательства сотрудника.
+func RunPendingNightAudits(dbpool *pgxpool.Pool) {
+	for {
+		date, err := CurrentBusinessDate(dbpool)
+		if err != nil {
+			log.Printf("Error getting business date: %v", err)
+			return
+		}
+		if !date.Before(dateOnly(time.Now())) {
+			return
+		}
+		_, err = RunNightAudit(dbpool, date, false, nil)
+		if err != nil {
+			log.Printf("Error running night audit for %s: %v", date.Format("2006-01-02"), err)
+			return
+		}
+		log.Printf("Business date %s closed", date.Format("2006-01-02"))
+	}
+}
+
+// reconcilePayments сравнивает статусы платежей с проводками по долгу гостя:
+// подтверждённый платёж должен погасить долг на полную сумму, возвращённый и ожидающий — давать ноль.
+// Сверка идёт по счёту гостя, а не по денежному счёту: оплата сертификатом на ночи
+// списывает с обязательства не ту сумму, которой гасит долг.
+func reconcilePayments(ctx context.Context, q pgxscan.Querier, date time.Time) ([]models.PaymentDiscrepancy, error) {
+	var discrepancies []models.PaymentDiscrepancy
+	err := pgxscan.Select(ctx, q, &discrepancies,
+		`SELECT p.id AS payment_id, p.booking_id, ps.name AS status,
+				CASE WHEN p.status_code = $2 THEN p.amount ELSE 0 END AS expected,
+				COALESCE(SUM(le.credit - le.debit), 0) AS posted
+		FROM PAYMENTS p
+		JOIN PAYMENT_STATUSES ps ON ps.status_code = p.status_code
+		LEFT JOIN LEDGER_TRANSACTIONS lt ON lt.payment_id = p.id AND lt.business_date <= $1
+		LEFT JOIN LEDGER_ENTRIES le ON le.transaction_id = lt.id AND le.account_code = $3
+		WHERE p.pay_date::date <= $1
+		GROUP BY p.id, p.booking_id, ps.name, p.status_code, p.amount
+		HAVING CASE WHEN p.status_code = $2 THEN p.amount ELSE 0 END <> COALESCE(SUM(le.credit - le.debit), 0)
+		ORDER BY p.id`, date, models.PaymentStatusPaid, models.AccountGuestReceivable)
+	if err != nil {
+		return nil, fmt.Errorf("error reconciling payments: %v", err)
+	}
+	return discrepancies, nil
+}
+
+// postRoomCharges начисляет проживание за ночь date всем заселённым гостям.
+// Ночи, уже начисленные при прошлом запуске, пропускаются.
+func postRoomCharges(ctx context.Context, tx pgx.Tx, date time.Time, userID *int) ([]models.NightAuditCharge, error) {
+	var bookings []inHouseBooking
+	err := pgxscan.Select(ctx, tx, &bookings,
+		`SELECT b.id AS booking_id, MIN(gib.room) AS room, b.start_date, b.end_date,
+				b.booking_sum, b.total_sum, b.tax_sum, COALESCE(d.amount, 0) AS discount_percent
+		FROM BOOKINGS b
+		JOIN GUESTS_IN_BOOKINGS gib ON gib.booking_id = b.id
+		LEFT JOIN DISCOUNTS d ON d.id = b.discount_id
+		WHERE b.check_in::date <= $1 AND (b.check_out IS NULL OR b.check_out::date > $1)
+			AND b.start_date <= $1 AND b.end_date > $1
+			AND NOT EXISTS (
+				SELECT 1 FROM LEDGER_TRANSACTIONS lt
+				WHERE lt.booking_id = b.id AND lt.kind = $2 AND lt.business_date = $1
+			)
+		GROUP BY b.id, d.amount
+		ORDER BY b.id`, date, models.LedgerKindRoomCharge)
+	if err != nil {
+		return nil, fmt.Errorf("error getting in-house bookings: %v", err)
+	}
+	var charges []models.NightAuditCharge
+	for _, b := range bookings {
+		var taxes []models.TaxLine
+		err = pgxscan.Select(ctx, tx, &taxes,
+			`SELECT tax_rule_id, name, charge_type, taxable, amount, inclusive
+			FROM TAX_LINES
+			WHERE booking_id = $1 AND folio_charge_id IS NULL
+			ORDER BY id`, b.BookingID)
+		if err != nil {
+			return charges, fmt.Errorf("error getting booking taxes: %v", err)
+		}
+		// сумма скидки не хранится отдельно: итог = сумма проживания - скидка + налоги сверх цены
+		stay := models.BookingQuote{
+			Nights:          daysBetween(b.StartDate, b.EndDate),
+			BookingSum:      b.BookingSum,
+			DiscountPercent: b.DiscountPercent,
+			DiscountAmount:  b.BookingSum.Add(b.TaxSum).Sub(b.TotalSum),
+			Taxes:           taxes,
+			TaxSum:          b.TaxSum,
+			TotalSum:        b.TotalSum,
+		}
+		night := services.NightShare(stay, daysBetween(b.StartDate, date))
+		err = postRoomNight(ctx, tx, b.BookingID, date, night, userID)
+		if err != nil {
+			return charges, err
+		}
+		charges = append(charges, models.NightAuditCharge{
+			BookingID: b.BookingID,
+			Room:      b.Room,
+			Amount:    night.BookingSum,
+			Discount:  night.DiscountAmount,
+			Tax:       services.TaxTotal(night.Taxes),
+		})
+	}
+	return charges, nil
+}
+
+// flagNoShows отмечает бронирования с заездом в день date, гости которых так и не заселились
+func flagNoShows(ctx context.Context, tx pgx.Tx, date time.Time) ([]models.NoShowCandidate, error) {
+	_, err := tx.Exec(ctx,
+		`UPDATE BOOKINGS SET no_show_candidate = TRUE
+		WHERE start_date = $1 AND check_in IS NULL AND check_out IS NULL`, date)
+	if err != nil {
+		log.Printf("error flagging no-shows: %v", err)
+		return nil, fmt.Errorf("error flagging no-shows: %v", err)
+	}
+	var noShows []models.NoShowCandidate
+	err = pgxscan.Select(ctx, tx, &noShows,
+		`SELECT b.id AS booking_id, MIN(gib.room) AS room, MIN(g.name) AS guest_name, b.start_date
+		FROM BOOKINGS b
+		JOIN GUESTS_IN_BOOKINGS gib ON gib.booking_id = b.id
+		JOIN GUESTS g ON g.id = gib.guest_id
+		WHERE b.start_date = $1 AND b.no_show_candidate
+		GROUP BY b.id, b.start_date
+		ORDER BY b.id`, date)
+	if err != nil {
+		return nil, fmt.Errorf("error getting no-shows: %v", err)
+	}
+	return noShows, nil
+}
+
+func GetBusinessDays(dbpool *pgxpool.Pool, from, to time.Time) ([]models.BusinessDay, error) {
+	var days []models.BusinessDay
+	err := pgxscan.Select(context.Background(), dbpool, &days,
+		`SELECT business_date, closed_at, closed_by, forced
+		FROM BUSINESS_DAYS
+		WHERE business_date BETWEEN $1 AND $2
+		ORDER BY business_date DESC`, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("error getting business days: %v", err)
+	}
+	return days, nil
+}
+
+func GetNightAuditReport(dbpool *pgxpool.Pool, date time.Time) (models.NightAuditReport, error) {
+	var report models.NightAuditReport
+	var body []byte
+	err := dbpool.QueryRow(context.Background(),
+		`SELECT report FROM BUSINESS_DAYS WHERE business_date = $1`, dateOnly(date)).Scan(&body)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return report, fmt.Errorf("business date %s is not closed", date.Format("2006-01-02"))
+		}
+		return report, fmt.Errorf("error getting night audit report: %v", err)
+	}
+	if err = json.Unmarshal(body, &report); err != nil {
+		return report, fmt.Errorf("error decoding night audit report: %v", err)
+	}
+	return report, nil
+}
@@ -0,0 +1,104 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"log"
+	"mis_kursach_backend/internal/models"
+	"net/http"
+	"time"
+)
+
+func (p *PsHandler) GetBusinessDate(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	date, err := CurrentBusinessDate(p.dbpool)
+	if err != nil {
+		http.Error(w, `{"error": "failed to get business date"}`, http.StatusInternalServerError)
+		log.Printf("Error getting business date: %v", err)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{"business_date": date.Format("2006-01-02")})
+}
+
+func (p *PsHandler) RunNightAudit(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	date, err := CurrentBusinessDate(p.dbpool)
+	if err != nil {
+		http.Error(w, `{"error": "failed to get business date"}`, http.StatusInternalServerError)
+		log.Printf("Error getting business date: %v", err)
+		return
+	}
+	if s := r.URL.Query().Get("date"); s != "" {
+		date, err = time.Parse("2006-01-02", s)
+		if err != nil {
+			http.Error(w, `{"error": "invalid date"}`, http.StatusBadRequest)
+			return
+		}
+	}
+	force := r.URL.Query().Get("force") == "true"
+	report, err := RunNightAudit(p.dbpool, date, force, userIDFromRequest(r))
+	if errors.Is(err, ErrAuditUnreconciled) {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":         "payments do not reconcile",
+			"discrepancies": report.Discrepancies,
+		})
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error": "failed to run night audit"}`, http.StatusBadRequest)
+		log.Printf("Error running night audit: %v", err)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		http.Error(w, `{"error": "failed to encode response"}`, http.StatusInternalServerError)
+		log.Printf("Error encoding night audit report: %v", err)
+	}
+}
+
+func (p *PsHandler) GetBusinessDays(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	from, err := time.Parse("2006-01-02", r.URL.Query().Get("from"))
+	if err != nil {
+		http.Error(w, `{"error": "invalid from date"}`, http.StatusBadRequest)
+		return
+	}
+	to, err := time.Parse("2006-01-02", r.URL.Query().Get("to"))
+	if err != nil {
+		http.Error(w, `{"error": "invalid to date"}`, http.StatusBadRequest)
+		return
+	}
+	days, err := GetBusinessDays(p.dbpool, from, to)
+	if err != nil {
+		http.Error(w, `{"error": "failed to get business days"}`, http.StatusInternalServerError)
+		log.Printf("Error getting business days: %v", err)
+		return
+	}
+	if days == nil {
+		days = []models.BusinessDay{}
+	}
+	if err := json.NewEncoder(w).Encode(days); err != nil {
+		http.Error(w, `{"error": "failed to encode response"}`, http.StatusInternalServerError)
+		log.Printf("Error encoding business days: %v", err)
+	}
+}
+
+func (p *PsHandler) GetNightAuditReport(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	date, err := time.Parse("2006-01-02", chi.URLParam(r, "date"))
+	if err != nil {
+		http.Error(w, `{"error": "invalid date"}`, http.StatusBadRequest)
+		return
+	}
+	report, err := GetNightAuditReport(p.dbpool, date)
+	if err != nil {
+		http.Error(w, `{"error": "report not found"}`, http.StatusNotFound)
+		log.Printf("Error getting night audit report: %v", err)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		http.Error(w, `{"error": "failed to encode response"}`, http.StatusInternalServerError)
+		log.Printf("Error encoding night audit report: %v", err)
+	}
+}
@@ -0,0 +1,92 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"log"
+	"mis_kursach_backend/internal/services"
+)
+
+// Passports шифрует номера паспортов гостей; задаётся при запуске из конфигурации
+var Passports *services.PassportCipher
+
+// sealPassport возвращает шифротекст номера и его слепой индекс для поиска
+func sealPassport(passport string) (string, string, error) {
+	enc, err := Passports.Encrypt(passport)
+	if err != nil {
+		return "", "", err
+	}
+	return enc, Passports.BlindIndex(passport), nil
+}
+
+// revealPassport расшифровывает номер; записи, ещё не перешифрованные командой ротации, хранятся открыто
+func revealPassport(passportNo *string, passportEnc *string) error {
+	if passportEnc == nil {
+		return nil
+	}
+	plain, err := Passports.Decrypt(*passportEnc)
+	if err != nil {
+		return err
+	}
+	*passportNo = plain
+	return nil
+}
+
+// RotatePassportKeys перешифровывает активным ключом все номера, зашифрованные другими ключами
+// или ещё хранящиеся открыто. Возвращает число обновлённых записей.
+func RotatePassportKeys(dbpool *pgxpool.Pool) (int, error) {
+	ctx := context.Background()
+	type row struct {
+		id          int
+		passportNo  *string
+		passportEnc *string
+	}
+	rows, err := dbpool.Query(ctx,
+		`SELECT id, passport_no, passport_enc FROM GUESTS
+		WHERE passport_enc IS NULL OR passport_enc NOT LIKE $1 || ':%'
+		ORDER BY id`, Passports.ActiveKeyID())
+	if err != nil {
+		return 0, fmt.Errorf("error getting guests to rotate: %v", err)
+	}
+	var pending []row
+	for rows.Next() {
+		var r row
+		if err := rows.Scan(&r.id, &r.passportNo, &r.passportEnc); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("error scanning guest: %v", err)
+		}
+		pending = append(pending, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("error getting guests to rotate: %v", err)
+	}
+
+	rotated := 0
+	for _, r := range pending {
+		var plain string
+		if r.passportNo != nil {
+			plain = *r.passportNo
+		}
+		if err := revealPassport(&plain, r.passportEnc); err != nil {
+			return rotated, fmt.Errorf("guest %d: %v", r.id, err)
+		}
+		if plain == "" {
+			continue
+		}
+		enc, hash, err := sealPassport(plain)
+		if err != nil {
+			return rotated, fmt.Errorf("guest %d: %v", r.id, err)
+		}
+		_, err = dbpool.Exec(ctx,
+			`UPDATE GUESTS SET passport_enc = $1, passport_hash = $2, passport_no = NULL WHERE id = $3`,
+			enc, hash, r.id)
+		if err != nil {
+			return rotated, fmt.Errorf("error updating guest %d: %v", r.id, err)
+		}
+		rotated++
+	}
+	log.Printf("Re-encrypted %d passport numbers with key %s", rotated, Passports.ActiveKeyID())
+	return rotated, nil
+}
@@ -2,6 +2,7 @@ package db
 
 import (
 	"encoding/json"
+	"errors"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/jwtauth/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -11,54 +12,176 @@ import (
 	"mis_kursach_backend/internal/services"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 )
 
 type PsHandler struct {
 	dbpool  *pgxpool.Pool
 	jwtauth *jwtauth.JWTAuth
+	// guestAuth подписывает токены гостей, вошедших по номеру бронирования
+	guestAuth *jwtauth.JWTAuth
 }
 
 func PsRoutes(dbpool *pgxpool.Pool, config configs.Config) chi.Router {
 	r := chi.NewRouter()
 	tokenAuth := services.GenerateAuthToken(config)
-	handler := &PsHandler{dbpool: dbpool, jwtauth: tokenAuth}
+	guestAuth := services.GenerateGuestAuthToken(config)
+	handler := &PsHandler{dbpool: dbpool, jwtauth: tokenAuth, guestAuth: guestAuth}
 	r.Group(func(r chi.Router) {
 		r.Use(jwtauth.Verifier(tokenAuth))
 		r.Use(jwtauth.Authenticator(tokenAuth))
+
+		r.Get("/GetServices", handler.GetServices)
+		r.Post("/CreateService", handler.CreateService)
+		r.Put("/UpdateService/{id}", handler.UpdateService)
+		r.Get("/GetFolio/{id}", handler.GetFolio)
+		r.Post("/PostCharge", handler.PostCharge)
+		r.Post("/VoidCharge", handler.VoidCharge)
+		r.Get("/GetInvoice/{id}", handler.GetInvoice)
+		r.Post("/CheckOutBooking", handler.CheckOutBooking)
+		r.Post("/CreatePayment", handler.CreatePayment)
+		r.Post("/ConfirmPayment", handler.ConfirmPayment)
+		r.Post("/ConfirmBooking", handler.ConfirmBooking)
+		// удаление сторнирует проводки и возвращает сертификаты и баллы — только для старшей смены
+		r.With(RequireRole(models.RoleAdmin, models.RoleManager)).Delete("/DeleteBooking/{id}", handler.DeleteBooking)
+		r.With(RequireRole(models.RoleAdmin, models.RoleManager)).Delete("/DeletePayment/{id}", handler.DeletePayment)
+
+		r.Get("/GetExchangeRates", handler.GetExchangeRates)
+		r.Post("/SetExchangeRate", handler.SetExchangeRate)
+
+		r.Get("/GetTaxRules", handler.GetTaxRules)
+		r.Post("/CreateTaxRule", handler.CreateTaxRule)
+		r.Put("/UpdateTaxRule/{id}", handler.UpdateTaxRule)
+		r.Get("/GetTaxSummary", handler.GetTaxSummary)
+		r.Post("/QuoteBooking", handler.QuoteBooking)
+
+		r.Get("/GetAccounts", handler.GetAccounts)
+		r.Get("/GetTrialBalance", handler.GetTrialBalance)
+		r.Get("/GetAccountStatement", handler.GetAccountStatement)
+		r.Post("/RefundPayment", handler.RefundPayment)
+
+		r.Get("/GetBusinessDate", handler.GetBusinessDate)
+		r.Post("/RunNightAudit", handler.RunNightAudit)
+		r.Get("/GetBusinessDays", handler.GetBusinessDays)
+		r.Get("/GetNightAuditReport/{date}", handler.GetNightAuditReport)
+
+		r.Get("/GetCompanies", handler.GetCompanies)
+		r.Get("/GetCompanyByID/{id}", handler.GetCompanyByID)
+		r.Post("/CreateCompany", handler.CreateCompany)
+		r.Put("/UpdateCompany/{id}", handler.UpdateCompany)
+		r.Post("/SetCompanyRate", handler.SetCompanyRate)
+		r.Post("/CreateCompanyInvoice", handler.CreateCompanyInvoice)
+		r.Get("/GetCompanyInvoices", handler.GetCompanyInvoices)
+		r.Get("/GetCompanyInvoice/{id}", handler.GetCompanyInvoice)
+		r.Post("/PayCompanyInvoice", handler.PayCompanyInvoice)
+		r.Get("/GetCompanyAging", handler.GetCompanyAging)
+
+		r.Post("/IssueVoucher", handler.IssueVoucher)
+		r.Get("/GetVouchers", handler.GetVouchers)
+		r.Get("/GetVoucher/{code}", handler.GetVoucher)
+		r.Get("/GetVoucherLiability", handler.GetVoucherLiability)
+
+		r.Get("/GetAllGuests", handler.GetAllGuests)
+		r.Get("/GetGuestByID/{id}", handler.GetGuestByID)
+		r.Post("/CreateGuest", handler.CreateGuest)
+		r.Put("/UpdateGuest/{id}", handler.UpdateGuest)
+		r.Delete("/DeleteGuest/{id}", handler.DeleteGuest)
+		r.Get("/FindDuplicateGuests", handler.FindDuplicateGuests)
+		r.Post("/MergeGuests", handler.MergeGuests)
+		r.With(RequireRole(models.RoleAdmin, models.RoleManager)).Get("/ExportGuestData/{id}", handler.ExportGuestData)
+		r.With(RequireRole(models.RoleAdmin, models.RoleManager)).Post("/EraseGuest/{id}", handler.EraseGuest)
+
+		r.Get("/GetAuditLog", handler.GetAuditLog)
+
+		// учётные записи сотрудников заводит только администратор: роль даёт доступ к персональным данным гостей
+		r.With(RequireRole(models.RoleAdmin)).Post("/CreateUser", handler.CreateUser)
+		r.With(RequireRole(models.RoleAdmin)).Delete("/DeleteUser", handler.DeleteUser)
+		r.With(RequireRole(models.RoleAdmin)).Post("/SetUserRole", handler.SetUserRole)
+
+		r.Get("/GetLoyaltyTiers", handler.GetLoyaltyTiers)
+		r.Get("/GetLoyaltyStatement/{guest_id}", handler.GetLoyaltyStatement)
+		r.With(RequireRole(models.RoleAdmin, models.RoleManager)).Post("/ExpireLoyaltyPoints", handler.ExpireLoyaltyPoints)
+
+		r.Get("/GetBlacklist", handler.GetBlacklist)
+		r.Get("/CheckBlacklist", handler.CheckBlacklist)
+		r.With(RequireRole(models.RoleAdmin, models.RoleManager)).Post("/AddToBlacklist", handler.AddToBlacklist)
+		r.With(RequireRole(models.RoleAdmin, models.RoleManager)).Post("/LiftBlacklistEntry/{id}", handler.LiftBlacklistEntry)
+
+		// доступ к вложениям зависит от их вида и проверяется в обработчиках
+		r.Post("/UploadAttachment", handler.UploadAttachment)
+		r.Get("/GetAttachments", handler.GetAttachments)
+		r.Get("/DownloadAttachment/{id}", handler.DownloadAttachment)
+		r.Delete("/DeleteAttachment/{id}", handler.DeleteAttachment)
+
+		r.Get("/GetComplaintCategories", handler.GetComplaintCategories)
+		// жалобы содержат имена гостей и журнал работы сотрудников
+		r.Get("/GetAllComplaints", handler.GetAllComplaints)
+		r.Get("/GetComplaintByID/{id}", handler.GetComplaintByID)
+		// изменения жалоб записываются в журнал от имени пользователя из токена
+		r.Post("/CreateComplaint", handler.CreateComplaint)
+		r.Put("/UpdateComplaint", handler.UpdateComplaint)
+		r.Post("/ResolveComplaint", handler.ResolveComplaint)
+		r.With(RequireRole(models.RoleAdmin, models.RoleManager)).Delete("/DeleteComplaint/{id}", handler.DeleteComplaint)
+		r.Post("/AssignComplaint", handler.AssignComplaint)
+		r.Post("/AddComplaintComment", handler.AddComplaintComment)
+		r.With(RequireRole(models.RoleAdmin, models.RoleManager, models.RoleReceptionist)).Post("/CompensateComplaint", handler.CompensateComplaint)
+		r.With(RequireRole(models.RoleAdmin, models.RoleManager)).Get("/GetComplaintCostReport", handler.GetComplaintCostReport)
+		r.With(RequireRole(models.RoleAdmin, models.RoleManager)).Get("/GetComplaintAnalytics", handler.GetComplaintAnalytics)
+		r.With(RequireRole(models.RoleAdmin, models.RoleManager)).Get("/GetEscalatedComplaints", handler.GetEscalatedComplaints)
+
+		r.With(RequireRole(models.RoleAdmin)).Post("/CreateRoom", handler.CreateRoom)
+		r.With(RequireRole(models.RoleAdmin)).Put("/UpdateRoom/{number}", handler.UpdateRoom)
+		r.With(RequireRole(models.RoleAdmin)).Post("/DeactivateRoom/{number}", handler.DeactivateRoom)
+		r.With(RequireRole(models.RoleAdmin)).Post("/ActivateRoom/{number}", handler.ActivateRoom)
+		r.With(RequireRole(models.RoleAdmin)).Post("/CreateRoomCategory", handler.CreateRoomCategory)
+		r.With(RequireRole(models.RoleAdmin)).Put("/UpdateRoomCategory/{code}", handler.UpdateRoomCategory)
+		r.With(RequireRole(models.RoleAdmin)).Post("/DeactivateRoomCategory/{code}", handler.DeactivateRoomCategory)
+		r.With(RequireRole(models.RoleAdmin)).Post("/ActivateRoomCategory/{code}", handler.ActivateRoomCategory)
+		r.With(RequireRole(models.RoleAdmin)).Get("/GetTariffs", handler.GetTariffs)
+		r.With(RequireRole(models.RoleAdmin)).Post("/CreateTariff", handler.CreateTariff)
+		r.With(RequireRole(models.RoleAdmin)).Put("/UpdateTariff/{code}", handler.UpdateTariff)
+
+		r.Get("/GetHousekeepingTasks", handler.GetHousekeepingTasks)
+		r.With(RequireRole(models.RoleAdmin, models.RoleManager)).Post("/GenerateHousekeepingTasks", handler.GenerateHousekeepingTasks)
+		r.With(RequireRole(models.RoleAdmin, models.RoleManager, models.RoleHousekeeper)).Post("/UpdateRoomHousekeeping", handler.UpdateRoomHousekeeping)
+		r.With(RequireRole(models.RoleAdmin, models.RoleManager, models.RoleHousekeeper)).Post("/AssignHousekeepingTask", handler.AssignHousekeepingTask)
+
+		r.Get("/GetPendingMigrations", handler.GetPendingMigrations)
+		r.With(RequireRole(models.RoleAdmin, models.RoleManager, models.RoleReceptionist)).Post("/ExportMigrationNotices", handler.ExportMigrationNotices)
+		r.With(RequireRole(models.RoleAdmin, models.RoleManager, models.RoleReceptionist)).Post("/MarkMigrationFiled", handler.MarkMigrationFiled)
 	})
 
 	r.Group(func(r chi.Router) {
+		// токен здесь необязателен, но если передан — из него берутся пользователь и роль
+		r.Use(jwtauth.Verifier(tokenAuth))
+
 		r.Post("/login", handler.Login)
 		r.Post("/logout", handler.Logout)
+
+		r.Post("/GuestRequestAccessCode", handler.GuestRequestAccessCode)
+		r.Post("/GuestLogin", handler.GuestLogin)
 		r.Get("/GetAllBookings", handler.GetAllBookings)
 		r.Get("/GetBookingByID/{id}", handler.GetBookingByID)
 		r.Post("/CreateBooking", handler.CreateBooking)
-		r.Delete("/DeleteBooking/{id}", handler.DeleteBooking)
-		r.Post("/ConfirmBooking", handler.ConfirmBooking)
-
-		r.Get("/GetAllComplaints", handler.GetAllComplaints)
-		r.Delete("/DeleteComplaint/{id}", handler.DeleteComplaint)
-		r.Get("/GetComplaintByID/{id}", handler.GetComplaintByID)
-		r.Post("/CreateComplaint", handler.CreateComplaint)
-		r.Put("/UpdateComplaint", handler.UpdateComplaint)
 
 		r.Get("/GetAllPayments", handler.GetAllPayments)
 		r.Get("/GetPaymentByID/{id}", handler.GetPaymentByID)
-		r.Delete("/DeletePayment/{id}", handler.DeletePayment)
 		// TODO: UPDATE PAYMENT
 
-		r.Post("/CreateUser", handler.CreateUser)
-		r.Delete("/DeleteUser", handler.DeleteUser)
-		// TODO: UPDATE USER
-
 		r.Get("/SetMetrics", handler.SetMetrics)
 		r.Get("/GetAllRooms", handler.GetAllRooms)
 		r.Get("/GetRoomCategories", handler.GetRoomCategories)
 		r.Get("/GetPaymentMethods", handler.GetPaymentMethods)
 		r.Get("/GetFreeRooms", handler.GetFreeRooms)
-		r.Post("/ResolveComplaint", handler.ResolveComplaint)
-		r.Post("/ConfirmPayment", handler.ConfirmPayment)
+	})
+	r.Group(func(r chi.Router) {
+		// кабинет гостя: токен из GuestLogin даёт доступ только к одному бронированию
+		r.Use(jwtauth.Verifier(guestAuth))
+		r.Use(jwtauth.Authenticator(guestAuth))
+
+		r.Get("/GuestGetBooking", handler.GuestGetBooking)
+		r.Post("/GuestCreateComplaint", handler.GuestCreateComplaint)
 	})
 
 	return r
@@ -126,15 +249,59 @@ func (p *PsHandler) CreateBooking(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	defer r.Body.Close()
+	guest, fieldErrs := services.ValidateGuest(models.GuestInput{
+		Name:         b.GuestName,
+		PhoneNumber:  b.GuestPhoneNumber,
+		Email:        b.GuestEmail,
+		PassportNo:   b.GuestPassportNumber,
+		DocumentType: b.GuestDocumentType,
+		Nationality:  b.GuestNationality,
+	})
+	if fieldErrs != nil {
+		writeFieldErrors(w, renameFields(fieldErrs, bookingGuestFields))
+		return
+	}
+	b.GuestName, b.GuestPhoneNumber, b.GuestPassportNumber = guest.Name, guest.PhoneNumber, guest.PassportNo
+	b.GuestDocumentType, b.GuestNationality, b.GuestEmail = guest.DocumentType, guest.Nationality, guest.Email
+
+	var override *models.BlacklistOverride
+	if strings.TrimSpace(b.BlacklistOverride) != "" {
+		if roleFromRequest(r) != models.RoleAdmin {
+			http.Error(w, `{"error": "only an admin can override the blacklist"}`, http.StatusForbidden)
+			return
+		}
+		override = &models.BlacklistOverride{Justification: strings.TrimSpace(b.BlacklistOverride), UserID: userIDFromRequest(r)}
+	}
 
-	err := CreateBooking(p.dbpool, b)
+	matches, err := CreateBooking(p.dbpool, b, override)
+	if errors.Is(err, ErrGuestBlacklisted) {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]any{"error": "guest is blacklisted", "matches": matches})
+		return
+	}
+	if errors.Is(err, ErrCreditLimit) {
+		http.Error(w, `{"error": "company credit limit exceeded"}`, http.StatusConflict)
+		log.Printf("Error creating booking: %v", err)
+		return
+	}
+	if errors.Is(err, ErrRoomUnavailable) {
+		http.Error(w, `{"error": "room is not available for booking"}`, http.StatusConflict)
+		return
+	}
+	if errors.Is(err, ErrBookingPaymentMethod) {
+		http.Error(w, `{"error": "pay with a voucher or loyalty points after the booking is created"}`, http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		http.Error(w, `{"error": "failed to create booking"}`, http.StatusBadRequest)
 		log.Printf("Error creating booking: %v", err)
 		return
 	}
+	if matches == nil {
+		matches = []models.BlacklistMatch{}
+	}
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(map[string]string{"message": "Booking created successfully"})
+	json.NewEncoder(w).Encode(map[string]any{"message": "Booking created successfully", "warnings": matches})
 }
 
 func (p *PsHandler) DeleteBooking(w http.ResponseWriter, r *http.Request) {
@@ -144,6 +311,22 @@ func (p *PsHandler) DeleteBooking(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, `{"error": "invalid id"}`, http.StatusBadRequest)
 	}
 	err = DeleteBooking(p.dbpool, id)
+	if errors.Is(err, ErrDateClosed) {
+		http.Error(w, `{"error": "business date is closed"}`, http.StatusConflict)
+		return
+	}
+	if errors.Is(err, ErrBookingInvoiced) {
+		http.Error(w, `{"error": "booking is on a company invoice"}`, http.StatusConflict)
+		return
+	}
+	if errors.Is(err, ErrBookingPaid) {
+		http.Error(w, `{"error": "refund the booking's payments before deleting it"}`, http.StatusConflict)
+		return
+	}
+	if errors.Is(err, ErrBookingCompensated) {
+		http.Error(w, `{"error": "booking has complaint compensations"}`, http.StatusConflict)
+		return
+	}
 	if err != nil {
 		http.Error(w, `{"error": "failed to delete booking"}`, http.StatusNotFound)
 		return
@@ -154,9 +337,13 @@ func (p *PsHandler) DeleteBooking(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
-func (p *PsHandler) GetAllComplaints(w http.ResponseWriter, _ *http.Request) {
+func (p *PsHandler) GetAllComplaints(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	complaints, err := GetAllComplaints(p.dbpool)
+	complaints, err := GetAllComplaints(p.dbpool, r.URL.Query().Get("category"))
+	if errors.Is(err, ErrUnknownComplaintCategory) {
+		http.Error(w, `{"error": "unknown complaint category"}`, http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		http.Error(w, `{"error": "failed to get complaints"}`, http.StatusInternalServerError)
 		log.Printf("Error getting all complaints: %v", err)
@@ -174,6 +361,23 @@ func (p *PsHandler) GetAllComplaints(w http.ResponseWriter, _ *http.Request) {
 	}
 }
 
+func (p *PsHandler) GetEscalatedComplaints(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	complaints, err := GetEscalatedComplaints(p.dbpool)
+	if err != nil {
+		http.Error(w, `{"error": "failed to get escalated complaints"}`, http.StatusInternalServerError)
+		log.Printf("Error getting escalated complaints: %v", err)
+		return
+	}
+	if complaints == nil {
+		complaints = []models.ComplaintResponse{}
+	}
+	if err := json.NewEncoder(w).Encode(complaints); err != nil {
+		http.Error(w, `{"error": "failed to encode response"}`, http.StatusInternalServerError)
+		log.Printf("Error encoding escalated complaints: %v", err)
+	}
+}
+
 func (p *PsHandler) GetComplaintByID(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	id, err := strconv.Atoi(chi.URLParam(r, "id"))
@@ -204,7 +408,19 @@ func (p *PsHandler) CreateComplaint(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	defer r.Body.Close()
-	err := CreateComplaint(p.dbpool, c)
+	if c.Priority != "" && !services.IsComplaintPriority(c.Priority) {
+		http.Error(w, `{"error": "priority must be low, normal, high or urgent"}`, http.StatusBadRequest)
+		return
+	}
+	err := CreateComplaint(p.dbpool, c, userIDFromRequest(r))
+	if errors.Is(err, ErrUnknownComplaintCategory) {
+		http.Error(w, `{"error": "unknown complaint category"}`, http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, ErrComplaintBookingMismatch) {
+		http.Error(w, `{"error": "room and guest must belong to the booking"}`, http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		http.Error(w, `{"error": "failed to create complaint"}`, http.StatusBadRequest)
 		log.Printf("Error creating complaint: %v", err)
@@ -220,7 +436,11 @@ func (p *PsHandler) DeleteComplaint(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		http.Error(w, `{"error": "invalid id"}`, http.StatusBadRequest)
 	}
-	err = DeleteComplaint(p.dbpool, id)
+	err = DeleteComplaint(p.dbpool, id, userIDFromRequest(r))
+	if errors.Is(err, ErrComplaintCompensated) {
+		http.Error(w, `{"error": "complaint has compensations and cannot be deleted"}`, http.StatusConflict)
+		return
+	}
 	if err != nil {
 		http.Error(w, `{"error": "complaint not found"}`, http.StatusNotFound)
 		return
@@ -249,8 +469,20 @@ func (p *PsHandler) UpdateComplaint(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, `{"error": "status is required"}`, http.StatusBadRequest)
 		return
 	}
+	if c.Priority != "" && !services.IsComplaintPriority(c.Priority) {
+		http.Error(w, `{"error": "priority must be low, normal, high or urgent"}`, http.StatusBadRequest)
+		return
+	}
 
-	err := UpdateComplaint(p.dbpool, c)
+	err := UpdateComplaint(p.dbpool, c, userIDFromRequest(r))
+	if errors.Is(err, ErrInvalidComplaintTransition) {
+		http.Error(w, `{"error": "invalid complaint status transition"}`, http.StatusConflict)
+		return
+	}
+	if errors.Is(err, ErrUnknownComplaintCategory) {
+		http.Error(w, `{"error": "unknown complaint category"}`, http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		http.Error(w, `{"error": "failed to update complaint"}`, http.StatusBadRequest)
 		log.Printf("Error updating complaint: %v", err)
@@ -301,6 +533,30 @@ func (p *PsHandler) GetPaymentByID(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (p *PsHandler) CreatePayment(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var in models.CreatePaymentInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, `{"error": "invalid request body"}`, http.StatusBadRequest)
+		log.Printf("Error decoding payment input: %v", err)
+		return
+	}
+	defer r.Body.Close()
+	// при оплате сертификатом на ночи сумма считается по цене ночи бронирования
+	if !in.Amount.IsPositive() && in.VoucherCode == "" {
+		http.Error(w, `{"error": "amount must be positive"}`, http.StatusBadRequest)
+		return
+	}
+	err := CreatePayment(p.dbpool, in)
+	if err != nil {
+		http.Error(w, `{"error": "failed to create payment"}`, http.StatusBadRequest)
+		log.Printf("Error creating payment: %v", err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Payment created successfully"})
+}
+
 func (p *PsHandler) DeletePayment(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	id, err := strconv.Atoi(chi.URLParam(r, "id"))
@@ -308,6 +564,10 @@ func (p *PsHandler) DeletePayment(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, `{"error": "invalid id"}`, http.StatusBadRequest)
 	}
 	err = DeletePayment(p.dbpool, id)
+	if errors.Is(err, ErrDateClosed) {
+		http.Error(w, `{"error": "business date is closed"}`, http.StatusConflict)
+		return
+	}
 	if err != nil {
 		http.Error(w, `{"error": "payment not found"}`, http.StatusNotFound)
 		return
@@ -394,6 +654,7 @@ func (p *PsHandler) Login(w http.ResponseWriter, r *http.Request) {
 	claims := map[string]interface{}{
 		"user_id":  user.ID,
 		"username": user.Username,
+		"role":     user.Role,
 		"exp":      time.Now().Add(time.Hour * 24).Unix()}
 	_, tokenString, err := p.jwtauth.Encode(claims)
 	if err != nil {
@@ -402,6 +663,7 @@ func (p *PsHandler) Login(w http.ResponseWriter, r *http.Request) {
 	response := make(map[string]string)
 	response["token"] = tokenString
 	response["username"] = user.Username
+	response["role"] = user.Role
 	json.NewEncoder(w).Encode(response)
 }
 
@@ -410,60 +672,6 @@ func (p *PsHandler) Logout(w http.ResponseWriter, _ *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
-func (p *PsHandler) CreateGuest(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	var g models.Guest
-	if err := json.NewDecoder(r.Body).Decode(&g); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte(`{"error": "invalid request body"}`))
-		log.Printf("Error decoding request body: %v", err)
-		return
-	}
-	err := CreateGuest(p.dbpool, g)
-	if err != nil {
-		http.Error(w, `{"error": "failed to create guest"}`, http.StatusInternalServerError)
-		log.Printf("Error creating guest: %v", err)
-		return
-	}
-	w.WriteHeader(http.StatusOK)
-	response := make(map[string]string)
-	response["message"] = "success"
-	json.NewEncoder(w).Encode(response)
-}
-
-func (p *PsHandler) GetAllGuests(w http.ResponseWriter) {
-	w.Header().Set("Content-Type", "application/json")
-	guests, err := GetAllGuests(p.dbpool)
-	if err != nil {
-		http.Error(w, `{"error": "failed to get guests"}`, http.StatusInternalServerError)
-		return
-	}
-	if err := json.NewEncoder(w).Encode(guests); err != nil {
-		http.Error(w, `{"error": "failed to encode response"}`, http.StatusInternalServerError)
-		log.Printf("Error encoding guests: %v", err)
-		return
-	}
-}
-
-func (p *PsHandler) DeleteGuest(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	id, err := strconv.Atoi(r.URL.Query().Get("id"))
-	if err != nil {
-		log.Printf("Invalid id: %v", err)
-		http.Error(w, `{"error": "invalid request body"}`, http.StatusBadRequest)
-		return
-	}
-	err = DeleteGuest(p.dbpool, id)
-	if err != nil {
-		log.Printf("Failed to delete guest: %v", err)
-		http.Error(w, `{"error": "failed to delete guest"}`, http.StatusInternalServerError)
-	}
-	w.WriteHeader(http.StatusOK)
-	response := make(map[string]string)
-	response["message"] = "success"
-	json.NewEncoder(w).Encode(response)
-}
-
 func (p *PsHandler) GetRoomCategories(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	categories, err := GetRoomCategories(p.dbpool)
@@ -525,7 +733,22 @@ func (p *PsHandler) ConfirmBooking(w http.ResponseWriter, r *http.Request) {
 		log.Printf("Error decoding request body: %v", err)
 		return
 	}
-	err = ConfirmBooking(p.dbpool, id)
+	// заселить в неубранный номер может только администратор или менеджер
+	override := r.URL.Query().Get("override") == "true"
+	if override {
+		role := roleFromRequest(r)
+		if role != models.RoleAdmin && role != models.RoleManager {
+			http.Error(w, `{"error": "only a manager can override the housekeeping check"}`, http.StatusForbidden)
+			return
+		}
+	}
+	notReady, err := ConfirmBooking(p.dbpool, id, override, userIDFromRequest(r))
+	if errors.Is(err, ErrRoomNotReady) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]any{"error": "room is not ready for check-in", "rooms": notReady})
+		return
+	}
 	if err != nil {
 		http.Error(w, `{"error": "failed to confirm booking"}`, http.StatusInternalServerError)
 		log.Printf("Error confirming booking: %v", err)
@@ -552,7 +775,11 @@ func (p *PsHandler) ResolveComplaint(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = ResolveComplaint(p.dbpool, id, statusCode)
+	err = ChangeComplaintStatus(p.dbpool, id, statusCode, userIDFromRequest(r))
+	if errors.Is(err, ErrInvalidComplaintTransition) {
+		http.Error(w, `{"error": "invalid complaint status transition"}`, http.StatusConflict)
+		return
+	}
 	if err != nil {
 		http.Error(w, `{"error": "failed to resolve complaint"}`, http.StatusInternalServerError)
 		log.Printf("Error resolving complaint: %v", err)
@@ -569,7 +796,7 @@ func (p *PsHandler) ConfirmPayment(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		http.Error(w, `{"error": "invalid request body"}`, http.StatusBadRequest)
 	}
-	err = ConfirmPayment(p.dbpool, id)
+	err = ConfirmPayment(p.dbpool, id, userIDFromRequest(r))
 	if err != nil {
 		http.Error(w, `{"error": "failed to confirm payment"}`, http.StatusInternalServerError)
 		log.Printf("Error confirming payment: %v", err)
@@ -579,3 +806,72 @@ func (p *PsHandler) ConfirmPayment(w http.ResponseWriter, r *http.Request) {
 	response["message"] = "success"
 	json.NewEncoder(w).Encode(response)
 }
+
+// userIDFromRequest достаёт ID пользователя из JWT, если токен был передан
+func userIDFromRequest(r *http.Request) *int {
+	_, claims, err := jwtauth.FromContext(r.Context())
+	if err != nil || claims == nil {
+		return nil
+	}
+	// числа в claims после разбора JSON приходят как float64
+	id, ok := claims["user_id"].(float64)
+	if !ok {
+		return nil
+	}
+	userID := int(id)
+	return &userID
+}
+
+// roleFromRequest достаёт роль пользователя из JWT; пустая строка, если роли нет
+func roleFromRequest(r *http.Request) string {
+	_, claims, err := jwtauth.FromContext(r.Context())
+	if err != nil || claims == nil {
+		return ""
+	}
+	role, _ := claims["role"].(string)
+	return role
+}
+
+// RequireRole пропускает запрос только пользователей с одной из указанных ролей
+func RequireRole(roles ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			role := roleFromRequest(r)
+			for _, allowed := range roles {
+				if role == allowed {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+			w.Header().Set("Content-Type", "application/json")
+			http.Error(w, `{"error": "forbidden"}`, http.StatusForbidden)
+		})
+	}
+}
+
+func (p *PsHandler) SetUserRole(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var in models.SetUserRoleInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, `{"error": "invalid request body"}`, http.StatusBadRequest)
+		log.Printf("Error decoding user role: %v", err)
+		return
+	}
+	defer r.Body.Close()
+	switch in.Role {
+	case models.RoleAdmin, models.RoleManager, models.RoleReceptionist, models.RoleHousekeeper:
+	default:
+		http.Error(w, `{"error": "unknown role"}`, http.StatusBadRequest)
+		return
+	}
+	err := SetUserRole(p.dbpool, in.Username, in.Role)
+	if err != nil {
+		http.Error(w, `{"error": "failed to set user role"}`, http.StatusBadRequest)
+		log.Printf("Error setting user role: %v", err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	response := make(map[string]string)
+	response["message"] = "success"
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,269 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"log"
+	"mis_kursach_backend/internal/models"
+)
+
+// ErrRoomExists возвращается при создании номера, который уже есть
+var ErrRoomExists = errors.New("room already exists")
+
+// ErrRoomUnavailable возвращается при бронировании номера, выведенного из продажи
+var ErrRoomUnavailable = errors.New("room is not available for booking")
+
+// ErrHasFutureBookings возвращается при выводе из продажи или изменении номера либо категории с будущими бронированиями
+var ErrHasFutureBookings = errors.New("room or category has future bookings")
+
+// ErrInactiveRoomCategory возвращается при добавлении номера или тарифа в категорию, выведенную из продажи
+var ErrInactiveRoomCategory = errors.New("room category is not active")
+
+// futureBookings — бронирования, которые ещё не закончились и по которым гость не выехал
+const futureBookings = `SELECT 1 FROM BOOKINGS b
+			JOIN GUESTS_IN_BOOKINGS gib ON gib.booking_id = b.id
+			JOIN ROOMS r ON r.number = gib.room
+			WHERE b.end_date > CURRENT_DATE AND b.check_out IS NULL`
+
+func roomHasFutureBookings(ctx context.Context, q pgxscan.Querier, number int) (bool, error) {
+	var exists bool
+	err := pgxscan.Get(ctx, q, &exists, `SELECT EXISTS(`+futureBookings+` AND r.number = $1)`, number)
+	if err != nil {
+		return false, fmt.Errorf("error checking room bookings: %v", err)
+	}
+	return exists, nil
+}
+
+func categoryActive(ctx context.Context, q pgxscan.Querier, code int) error {
+	var active bool
+	err := pgxscan.Get(ctx, q, &active, `SELECT active FROM ROOM_CATEGORIES WHERE code = $1`, code)
+	if err != nil {
+		return fmt.Errorf("room category %d not found: %v", code, err)
+	}
+	if !active {
+		return ErrInactiveRoomCategory
+	}
+	return nil
+}
+
+func CreateRoom(dbpool *pgxpool.Pool, in models.RoomInput, userID *int) error {
+	ctx := context.Background()
+	var exists bool
+	err := dbpool.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM ROOMS WHERE number = $1)`, in.Number).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("error checking room: %v", err)
+	}
+	if exists {
+		return ErrRoomExists
+	}
+	if err = categoryActive(ctx, dbpool, in.CategoryCode); err != nil {
+		return err
+	}
+	state := models.RoomStateFree
+	if in.StateCode != nil {
+		state = *in.StateCode
+	}
+	_, err = dbpool.Exec(ctx,
+		`INSERT INTO ROOMS (number, category_code, state_code) VALUES ($1, $2, $3)`, in.Number, in.CategoryCode, state)
+	if err != nil {
+		log.Printf("error inserting room: %v", err)
+		return fmt.Errorf("error inserting room: %v", err)
+	}
+	return writeAudit(ctx, dbpool, "room", in.Number, "create", in, userID)
+}
+
+// UpdateRoom меняет категорию и состояние номера; категорию номера с будущими бронированиями менять нельзя
+func UpdateRoom(dbpool *pgxpool.Pool, number int, in models.RoomInput, userID *int) error {
+	ctx := context.Background()
+	var current models.Room
+	err := pgxscan.Get(ctx, dbpool, &current,
+		`SELECT number, category_code, state_code, active FROM ROOMS WHERE number = $1`, number)
+	if err != nil {
+		return fmt.Errorf("room %d not found: %v", number, err)
+	}
+	if in.CategoryCode != current.CategoryCode {
+		if err = categoryActive(ctx, dbpool, in.CategoryCode); err != nil {
+			return err
+		}
+		booked, err := roomHasFutureBookings(ctx, dbpool, number)
+		if err != nil {
+			return err
+		}
+		if booked {
+			return ErrHasFutureBookings
+		}
+	}
+	state := current.StateCode
+	if in.StateCode != nil {
+		state = *in.StateCode
+	}
+	_, err = dbpool.Exec(ctx,
+		`UPDATE ROOMS SET category_code = $1, state_code = $2 WHERE number = $3`, in.CategoryCode, state, number)
+	if err != nil {
+		log.Printf("error updating room: %v", err)
+		return fmt.Errorf("error updating room: %v", err)
+	}
+	return writeAudit(ctx, dbpool, "room", number, "update", map[string]any{
+		"from": map[string]int{"category_code": current.CategoryCode, "state_code": current.StateCode},
+		"to":   map[string]int{"category_code": in.CategoryCode, "state_code": state},
+	}, userID)
+}
+
+// SetRoomActive выводит номер из продажи или возвращает его; номер с будущими бронированиями вывести нельзя
+func SetRoomActive(dbpool *pgxpool.Pool, number int, active bool, userID *int) error {
+	ctx := context.Background()
+	if !active {
+		booked, err := roomHasFutureBookings(ctx, dbpool, number)
+		if err != nil {
+			return err
+		}
+		if booked {
+			return ErrHasFutureBookings
+		}
+	}
+	result, err := dbpool.Exec(ctx, `UPDATE ROOMS SET active = $1 WHERE number = $2`, active, number)
+	if err != nil {
+		log.Printf("error updating room: %v", err)
+		return fmt.Errorf("error updating room: %v", err)
+	}
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("room %d not found", number)
+	}
+	action := "activate"
+	if !active {
+		action = "deactivate"
+	}
+	return writeAudit(ctx, dbpool, "room", number, action, map[string]any{}, userID)
+}
+
+func CreateRoomCategory(dbpool *pgxpool.Pool, in models.RoomCategoryInput, userID *int) (int, error) {
+	ctx := context.Background()
+	var code int
+	err := dbpool.QueryRow(ctx,
+		`INSERT INTO ROOM_CATEGORIES (name, capacity) VALUES ($1, $2) RETURNING code`, in.Name, in.Capacity).Scan(&code)
+	if err != nil {
+		log.Printf("error inserting room category: %v", err)
+		return 0, fmt.Errorf("error inserting room category: %v", err)
+	}
+	return code, writeAudit(ctx, dbpool, "room_category", code, "create", in, userID)
+}
+
+// UpdateRoomCategory меняет название и вместимость категории.
+// Вместимость нельзя сделать меньше числа гостей в будущих бронированиях номеров этой категории.
+func UpdateRoomCategory(dbpool *pgxpool.Pool, code int, in models.RoomCategoryInput, userID *int) error {
+	ctx := context.Background()
+	var current models.RoomCategory
+	err := pgxscan.Get(ctx, dbpool, &current,
+		`SELECT code, name, capacity, active FROM ROOM_CATEGORIES WHERE code = $1`, code)
+	if err != nil {
+		return fmt.Errorf("room category %d not found: %v", code, err)
+	}
+	if in.Capacity < current.Capacity {
+		var overbooked bool
+		err = dbpool.QueryRow(ctx,
+			`SELECT EXISTS(`+futureBookings+` AND r.category_code = $1 AND b.adults + b.children > $2)`,
+			code, in.Capacity).Scan(&overbooked)
+		if err != nil {
+			return fmt.Errorf("error checking category bookings: %v", err)
+		}
+		if overbooked {
+			return ErrHasFutureBookings
+		}
+	}
+	_, err = dbpool.Exec(ctx,
+		`UPDATE ROOM_CATEGORIES SET name = $1, capacity = $2 WHERE code = $3`, in.Name, in.Capacity, code)
+	if err != nil {
+		log.Printf("error updating room category: %v", err)
+		return fmt.Errorf("error updating room category: %v", err)
+	}
+	return writeAudit(ctx, dbpool, "room_category", code, "update", map[string]any{
+		"from": map[string]any{"name": current.Name, "capacity": current.Capacity},
+		"to":   in,
+	}, userID)
+}
+
+// SetRoomCategoryActive выводит категорию из продажи или возвращает её.
+// Номера выведенной категории не предлагаются к бронированию; категорию с будущими бронированиями вывести нельзя.
+func SetRoomCategoryActive(dbpool *pgxpool.Pool, code int, active bool, userID *int) error {
+	ctx := context.Background()
+	if !active {
+		var booked bool
+		err := dbpool.QueryRow(ctx,
+			`SELECT EXISTS(`+futureBookings+` AND r.category_code = $1)`, code).Scan(&booked)
+		if err != nil {
+			return fmt.Errorf("error checking category bookings: %v", err)
+		}
+		if booked {
+			return ErrHasFutureBookings
+		}
+	}
+	result, err := dbpool.Exec(ctx, `UPDATE ROOM_CATEGORIES SET active = $1 WHERE code = $2`, active, code)
+	if err != nil {
+		log.Printf("error updating room category: %v", err)
+		return fmt.Errorf("error updating room category: %v", err)
+	}
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("room category %d not found", code)
+	}
+	action := "activate"
+	if !active {
+		action = "deactivate"
+	}
+	return writeAudit(ctx, dbpool, "room_category", code, action, map[string]any{}, userID)
+}
+
+func GetTariffs(dbpool *pgxpool.Pool) ([]models.Tariff, error) {
+	var tariffs []models.Tariff
+	err := pgxscan.Select(context.Background(), dbpool, &tariffs,
+		`SELECT code, category_code, base_price, day_code FROM TARIFFS ORDER BY category_code, code`)
+	if err != nil {
+		return nil, fmt.Errorf("error getting tariffs: %v", err)
+	}
+	return tariffs, nil
+}
+
+func CreateTariff(dbpool *pgxpool.Pool, in models.TariffInput, userID *int) (int, error) {
+	ctx := context.Background()
+	if err := categoryActive(ctx, dbpool, in.CategoryCode); err != nil {
+		return 0, err
+	}
+	var code int
+	err := dbpool.QueryRow(ctx,
+		`INSERT INTO TARIFFS (category_code, base_price, day_code) VALUES ($1, $2, $3) RETURNING code`,
+		in.CategoryCode, in.BasePrice, in.DayCode).Scan(&code)
+	if err != nil {
+		log.Printf("error inserting tariff: %v", err)
+		return 0, fmt.Errorf("error inserting tariff: %v", err)
+	}
+	return code, writeAudit(ctx, dbpool, "tariff", code, "create", in, userID)
+}
+
+// UpdateTariff меняет тариф; уже созданные бронирования сохраняют рассчитанную при создании цену
+func UpdateTariff(dbpool *pgxpool.Pool, code int, in models.TariffInput, userID *int) error {
+	ctx := context.Background()
+	var current models.Tariff
+	err := pgxscan.Get(ctx, dbpool, &current,
+		`SELECT code, category_code, base_price, day_code FROM TARIFFS WHERE code = $1`, code)
+	if err != nil {
+		return fmt.Errorf("tariff %d not found: %v", code, err)
+	}
+	if in.CategoryCode != current.CategoryCode {
+		if err = categoryActive(ctx, dbpool, in.CategoryCode); err != nil {
+			return err
+		}
+	}
+	_, err = dbpool.Exec(ctx,
+		`UPDATE TARIFFS SET category_code = $1, base_price = $2, day_code = $3 WHERE code = $4`,
+		in.CategoryCode, in.BasePrice, in.DayCode, code)
+	if err != nil {
+		log.Printf("error updating tariff: %v", err)
+		return fmt.Errorf("error updating tariff: %v", err)
+	}
+	return writeAudit(ctx, dbpool, "tariff", code, "update", map[string]any{
+		"from": map[string]any{"category_code": current.CategoryCode, "base_price": current.BasePrice, "day_code": current.DayCode},
+		"to":   in,
+	}, userID)
+}
@@ -0,0 +1,232 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"log"
+	"mis_kursach_backend/internal/models"
+	"mis_kursach_backend/internal/services"
+	"net/http"
+	"strconv"
+)
+
+// writeRoomError отвечает на ошибки изменения номеров, категорий и тарифов
+func writeRoomError(w http.ResponseWriter, err error, action string) {
+	switch {
+	case errors.Is(err, ErrRoomExists):
+		http.Error(w, `{"error": "room already exists"}`, http.StatusConflict)
+	case errors.Is(err, ErrHasFutureBookings):
+		http.Error(w, `{"error": "room or category has future bookings"}`, http.StatusConflict)
+	case errors.Is(err, ErrInactiveRoomCategory):
+		http.Error(w, `{"error": "room category is not active"}`, http.StatusBadRequest)
+	default:
+		http.Error(w, `{"error": "failed to `+action+`"}`, http.StatusBadRequest)
+		log.Printf("Error trying to %s: %v", action, err)
+	}
+}
+
+func (p *PsHandler) CreateRoom(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var in models.RoomInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, `{"error": "invalid request body"}`, http.StatusBadRequest)
+		log.Printf("Error decoding room: %v", err)
+		return
+	}
+	defer r.Body.Close()
+	if errs := services.ValidateRoom(in); errs != nil {
+		writeFieldErrors(w, errs)
+		return
+	}
+	if err := CreateRoom(p.dbpool, in, userIDFromRequest(r)); err != nil {
+		writeRoomError(w, err, "create room")
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]any{"message": "success", "number": in.Number})
+}
+
+func (p *PsHandler) UpdateRoom(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	number, err := strconv.Atoi(chi.URLParam(r, "number"))
+	if err != nil {
+		http.Error(w, `{"error": "invalid room number"}`, http.StatusBadRequest)
+		return
+	}
+	var in models.RoomInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, `{"error": "invalid request body"}`, http.StatusBadRequest)
+		log.Printf("Error decoding room: %v", err)
+		return
+	}
+	defer r.Body.Close()
+	// номер комнаты — ключ, на который ссылаются бронирования, поэтому он не меняется
+	in.Number = number
+	if errs := services.ValidateRoom(in); errs != nil {
+		writeFieldErrors(w, errs)
+		return
+	}
+	if err := UpdateRoom(p.dbpool, number, in, userIDFromRequest(r)); err != nil {
+		writeRoomError(w, err, "update room")
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{"message": "success"})
+}
+
+func (p *PsHandler) DeactivateRoom(w http.ResponseWriter, r *http.Request) {
+	p.setRoomActive(w, r, false)
+}
+
+func (p *PsHandler) ActivateRoom(w http.ResponseWriter, r *http.Request) {
+	p.setRoomActive(w, r, true)
+}
+
+func (p *PsHandler) setRoomActive(w http.ResponseWriter, r *http.Request, active bool) {
+	w.Header().Set("Content-Type", "application/json")
+	number, err := strconv.Atoi(chi.URLParam(r, "number"))
+	if err != nil {
+		http.Error(w, `{"error": "invalid room number"}`, http.StatusBadRequest)
+		return
+	}
+	if err := SetRoomActive(p.dbpool, number, active, userIDFromRequest(r)); err != nil {
+		writeRoomError(w, err, "update room")
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{"message": "success"})
+}
+
+func (p *PsHandler) CreateRoomCategory(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var in models.RoomCategoryInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, `{"error": "invalid request body"}`, http.StatusBadRequest)
+		log.Printf("Error decoding room category: %v", err)
+		return
+	}
+	defer r.Body.Close()
+	in, errs := services.ValidateRoomCategory(in)
+	if errs != nil {
+		writeFieldErrors(w, errs)
+		return
+	}
+	code, err := CreateRoomCategory(p.dbpool, in, userIDFromRequest(r))
+	if err != nil {
+		writeRoomError(w, err, "create room category")
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]any{"message": "success", "code": code})
+}
+
+func (p *PsHandler) UpdateRoomCategory(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	code, err := strconv.Atoi(chi.URLParam(r, "code"))
+	if err != nil {
+		http.Error(w, `{"error": "invalid category code"}`, http.StatusBadRequest)
+		return
+	}
+	var in models.RoomCategoryInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, `{"error": "invalid request body"}`, http.StatusBadRequest)
+		log.Printf("Error decoding room category: %v", err)
+		return
+	}
+	defer r.Body.Close()
+	in, errs := services.ValidateRoomCategory(in)
+	if errs != nil {
+		writeFieldErrors(w, errs)
+		return
+	}
+	if err := UpdateRoomCategory(p.dbpool, code, in, userIDFromRequest(r)); err != nil {
+		writeRoomError(w, err, "update room category")
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{"message": "success"})
+}
+
+func (p *PsHandler) DeactivateRoomCategory(w http.ResponseWriter, r *http.Request) {
+	p.setRoomCategoryActive(w, r, false)
+}
+
+func (p *PsHandler) ActivateRoomCategory(w http.ResponseWriter, r *http.Request) {
+	p.setRoomCategoryActive(w, r, true)
+}
+
+func (p *PsHandler) setRoomCategoryActive(w http.ResponseWriter, r *http.Request, active bool) {
+	w.Header().Set("Content-Type", "application/json")
+	code, err := strconv.Atoi(chi.URLParam(r, "code"))
+	if err != nil {
+		http.Error(w, `{"error": "invalid category code"}`, http.StatusBadRequest)
+		return
+	}
+	if err := SetRoomCategoryActive(p.dbpool, code, active, userIDFromRequest(r)); err != nil {
+		writeRoomError(w, err, "update room category")
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{"message": "success"})
+}
+
+func (p *PsHandler) GetTariffs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	tariffs, err := GetTariffs(p.dbpool)
+	if err != nil {
+		http.Error(w, `{"error": "failed to get tariffs"}`, http.StatusInternalServerError)
+		log.Printf("Error getting tariffs: %v", err)
+		return
+	}
+	if tariffs == nil {
+		tariffs = []models.Tariff{}
+	}
+	if err := json.NewEncoder(w).Encode(tariffs); err != nil {
+		http.Error(w, `{"error": "failed to encode response"}`, http.StatusInternalServerError)
+		log.Printf("Error encoding tariffs: %v", err)
+	}
+}
+
+func (p *PsHandler) CreateTariff(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var in models.TariffInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, `{"error": "invalid request body"}`, http.StatusBadRequest)
+		log.Printf("Error decoding tariff: %v", err)
+		return
+	}
+	defer r.Body.Close()
+	if errs := services.ValidateTariff(in); errs != nil {
+		writeFieldErrors(w, errs)
+		return
+	}
+	code, err := CreateTariff(p.dbpool, in, userIDFromRequest(r))
+	if err != nil {
+		writeRoomError(w, err, "create tariff")
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]any{"message": "success", "code": code})
+}
+
+func (p *PsHandler) UpdateTariff(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	code, err := strconv.Atoi(chi.URLParam(r, "code"))
+	if err != nil {
+		http.Error(w, `{"error": "invalid tariff code"}`, http.StatusBadRequest)
+		return
+	}
+	var in models.TariffInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, `{"error": "invalid request body"}`, http.StatusBadRequest)
+		log.Printf("Error decoding tariff: %v", err)
+		return
+	}
+	defer r.Body.Close()
+	if errs := services.ValidateTariff(in); errs != nil {
+		writeFieldErrors(w, errs)
+		return
+	}
+	if err := UpdateTariff(p.dbpool, code, in, userIDFromRequest(r)); err != nil {
+		writeRoomError(w, err, "update tariff")
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{"message": "success"})
+}
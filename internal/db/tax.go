@@ -0,0 +1,157 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"log"
+	"mis_kursach_backend/internal/models"
+	"time"
+)
+
+const taxRuleColumns = `id, name, charge_type, calc, rate, amount, inclusive, basis,
+		exempt_children, active, valid_from, valid_to`
+
+func GetTaxRules(dbpool *pgxpool.Pool) ([]models.TaxRule, error) {
+	var rules []models.TaxRule
+	err := pgxscan.Select(context.Background(), dbpool, &rules,
+		`SELECT `+taxRuleColumns+` FROM TAX_RULES ORDER BY charge_type, id`)
+	if err != nil {
+		return nil, fmt.Errorf("error getting tax rules: %v", err)
+	}
+	return rules, nil
+}
+
+// getActiveTaxRules возвращает правила, действующие на дату
+func getActiveTaxRules(dbpool *pgxpool.Pool, date time.Time) ([]models.TaxRule, error) {
+	var rules []models.TaxRule
+	err := pgxscan.Select(context.Background(), dbpool, &rules,
+		`SELECT `+taxRuleColumns+` FROM TAX_RULES
+		WHERE active AND valid_from <= $1 AND (valid_to IS NULL OR valid_to >= $1)
+		ORDER BY id`, date)
+	if err != nil {
+		return nil, fmt.Errorf("error getting active tax rules: %v", err)
+	}
+	return rules, nil
+}
+
+func CreateTaxRule(dbpool *pgxpool.Pool, t models.TaxRuleInput) (int, error) {
+	var id int
+	validFrom, validTo, err := parseTaxRuleDates(t)
+	if err != nil {
+		return 0, err
+	}
+	active := true
+	if t.Active != nil {
+		active = *t.Active
+	}
+	err = pgxscan.Get(context.Background(), dbpool, &id,
+		`INSERT INTO TAX_RULES (name, charge_type, calc, rate, amount, inclusive, basis,
+				exempt_children, active, valid_from, valid_to)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) RETURNING ID`,
+		t.Name, t.ChargeType, t.Calc, t.Rate, t.Amount, t.Inclusive, t.Basis,
+		t.ExemptChildren, active, validFrom, validTo)
+	if err != nil {
+		return 0, fmt.Errorf("error inserting tax rule: %v", err)
+	}
+	return id, nil
+}
+
+func UpdateTaxRule(dbpool *pgxpool.Pool, id int, t models.TaxRuleInput) error {
+	validFrom, validTo, err := parseTaxRuleDates(t)
+	if err != nil {
+		return err
+	}
+	result, err := dbpool.Exec(context.Background(),
+		`UPDATE TAX_RULES
+		SET name = $1, charge_type = $2, calc = $3, rate = $4, amount = $5, inclusive = $6, basis = $7,
+			exempt_children = $8, active = COALESCE($9, active), valid_from = $10, valid_to = $11
+		WHERE id = $12`,
+		t.Name, t.ChargeType, t.Calc, t.Rate, t.Amount, t.Inclusive, t.Basis,
+		t.ExemptChildren, t.Active, validFrom, validTo, id)
+	if err != nil {
+		return fmt.Errorf("error updating tax rule: %v", err)
+	}
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("tax rule with ID %d not found", id)
+	}
+	return nil
+}
+
+func parseTaxRuleDates(t models.TaxRuleInput) (time.Time, *time.Time, error) {
+	validFrom := time.Now().Truncate(24 * time.Hour)
+	if t.ValidFrom != "" {
+		var err error
+		validFrom, err = time.Parse("2006-01-02", t.ValidFrom)
+		if err != nil {
+			return validFrom, nil, fmt.Errorf("couldn't parse valid_from: %v", err)
+		}
+	}
+	if t.ValidTo == nil || *t.ValidTo == "" {
+		return validFrom, nil, nil
+	}
+	validTo, err := time.Parse("2006-01-02", *t.ValidTo)
+	if err != nil {
+		return validFrom, nil, fmt.Errorf("couldn't parse valid_to: %v", err)
+	}
+	if validTo.Before(validFrom) {
+		return validFrom, nil, fmt.Errorf("valid_to is before valid_from")
+	}
+	return validFrom, &validTo, nil
+}
+
+func insertTaxLines(ctx context.Context, q execer, bookingID int, folioChargeID *int, lines []models.TaxLine) error {
+	for _, l := range lines {
+		_, err := q.Exec(ctx,
+			`INSERT INTO TAX_LINES (booking_id, folio_charge_id, tax_rule_id, name, charge_type,
+					taxable, amount, inclusive, created_at)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+			bookingID, folioChargeID, l.RuleID, l.Name, l.ChargeType, l.Taxable, l.Amount, l.Inclusive, time.Now())
+		if err != nil {
+			log.Printf("error inserting tax line: %v", err)
+			return fmt.Errorf("error inserting tax line: %v", err)
+		}
+	}
+	return nil
+}
+
+// GetBookingTaxes возвращает налоги по проживанию и по неаннулированным строкам фолио
+func GetBookingTaxes(dbpool *pgxpool.Pool, bookingID int) ([]models.TaxLine, error) {
+	return getBookingTaxes(context.Background(), dbpool, bookingID)
+}
+
+func getBookingTaxes(ctx context.Context, q pgxscan.Querier, bookingID int) ([]models.TaxLine, error) {
+	var lines []models.TaxLine
+	err := pgxscan.Select(ctx, q, &lines,
+		`SELECT tl.tax_rule_id, tl.name, tl.charge_type, tl.taxable, tl.amount, tl.inclusive
+		FROM TAX_LINES tl
+		LEFT JOIN FOLIO_CHARGES fc ON fc.id = tl.folio_charge_id
+		WHERE tl.booking_id = $1 AND fc.voided_at IS NULL
+		ORDER BY tl.id`, bookingID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting booking taxes: %v", err)
+	}
+	return lines, nil
+}
+
+// GetTaxSummary сводит начисленные налоги за период [from, to] по видам налогов
+func GetTaxSummary(dbpool *pgxpool.Pool, from, to time.Time) (models.TaxSummary, error) {
+	summary := models.TaxSummary{From: from, To: to, Currency: models.DefaultCurrency}
+	err := pgxscan.Select(context.Background(), dbpool, &summary.Rows,
+		`SELECT tl.name, tl.charge_type, tl.inclusive,
+				SUM(tl.taxable) AS taxable, SUM(tl.amount) AS amount
+		FROM TAX_LINES tl
+		LEFT JOIN FOLIO_CHARGES fc ON fc.id = tl.folio_charge_id
+		WHERE tl.created_at >= $1 AND tl.created_at < $2 + INTERVAL '1 DAY'
+			AND fc.voided_at IS NULL
+		GROUP BY tl.name, tl.charge_type, tl.inclusive
+		ORDER BY tl.charge_type, tl.name`, from, to)
+	if err != nil {
+		return summary, fmt.Errorf("error getting tax summary: %v", err)
+	}
+	for _, row := range summary.Rows {
+		summary.Total = summary.Total.Add(row.Amount)
+	}
+	return summary, nil
+}
@@ -0,0 +1,166 @@
+package db
+
+import (
+	"encoding/json"
+	"github.com/go-chi/chi/v5"
+	"log"
+	"mis_kursach_backend/internal/models"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+func (p *PsHandler) GetTaxRules(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	rules, err := GetTaxRules(p.dbpool)
+	if err != nil {
+		http.Error(w, `{"error": "failed to get tax rules"}`, http.StatusInternalServerError)
+		log.Printf("Error getting tax rules: %v", err)
+		return
+	}
+	if rules == nil {
+		rules = []models.TaxRule{}
+	}
+	if err := json.NewEncoder(w).Encode(rules); err != nil {
+		http.Error(w, `{"error": "failed to encode response"}`, http.StatusInternalServerError)
+		log.Printf("Error encoding tax rules: %v", err)
+	}
+}
+
+// validateTaxRule возвращает текст ошибки или пустую строку
+func validateTaxRule(t models.TaxRuleInput) string {
+	if t.Name == "" {
+		return "name is required"
+	}
+	if t.ChargeType != models.ChargeTypeRoom && t.ChargeType != models.ChargeTypeService {
+		return "charge_type must be room or service"
+	}
+	if t.Basis != models.TaxBasisStay && t.Basis != models.TaxBasisNight && t.Basis != models.TaxBasisGuestNight {
+		return "basis must be stay, night or guest_night"
+	}
+	switch t.Calc {
+	case models.TaxCalcPercent:
+		if t.Rate.Sign() <= 0 {
+			return "rate must be positive"
+		}
+	case models.TaxCalcFixed:
+		if !t.Amount.IsPositive() {
+			return "amount must be positive"
+		}
+	default:
+		return "calc must be percent or fixed"
+	}
+	return ""
+}
+
+func (p *PsHandler) CreateTaxRule(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var t models.TaxRuleInput
+	if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
+		http.Error(w, `{"error": "invalid request body"}`, http.StatusBadRequest)
+		log.Printf("Error decoding tax rule: %v", err)
+		return
+	}
+	defer r.Body.Close()
+	if t.Basis == "" {
+		t.Basis = models.TaxBasisStay
+	}
+	if msg := validateTaxRule(t); msg != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": msg})
+		return
+	}
+	id, err := CreateTaxRule(p.dbpool, t)
+	if err != nil {
+		http.Error(w, `{"error": "failed to create tax rule"}`, http.StatusBadRequest)
+		log.Printf("Error creating tax rule: %v", err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Tax rule created successfully", "id": strconv.Itoa(id)})
+}
+
+func (p *PsHandler) UpdateTaxRule(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, `{"error": "invalid id"}`, http.StatusBadRequest)
+		return
+	}
+	var t models.TaxRuleInput
+	if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
+		http.Error(w, `{"error": "invalid request body"}`, http.StatusBadRequest)
+		log.Printf("Error decoding tax rule: %v", err)
+		return
+	}
+	defer r.Body.Close()
+	if t.Basis == "" {
+		t.Basis = models.TaxBasisStay
+	}
+	if msg := validateTaxRule(t); msg != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": msg})
+		return
+	}
+	err = UpdateTaxRule(p.dbpool, id, t)
+	if err != nil {
+		http.Error(w, `{"error": "failed to update tax rule"}`, http.StatusBadRequest)
+		log.Printf("Error updating tax rule: %v", err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	response := make(map[string]string)
+	response["message"] = "success"
+	json.NewEncoder(w).Encode(response)
+}
+
+func (p *PsHandler) GetTaxSummary(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	from, err := time.Parse("2006-01-02", r.URL.Query().Get("from"))
+	if err != nil {
+		http.Error(w, `{"error": "invalid from date"}`, http.StatusBadRequest)
+		return
+	}
+	to, err := time.Parse("2006-01-02", r.URL.Query().Get("to"))
+	if err != nil {
+		http.Error(w, `{"error": "invalid to date"}`, http.StatusBadRequest)
+		return
+	}
+	summary, err := GetTaxSummary(p.dbpool, from, to)
+	if err != nil {
+		http.Error(w, `{"error": "failed to get tax summary"}`, http.StatusInternalServerError)
+		log.Printf("Error getting tax summary: %v", err)
+		return
+	}
+	if summary.Rows == nil {
+		summary.Rows = []models.TaxSummaryRow{}
+	}
+	if err := json.NewEncoder(w).Encode(summary); err != nil {
+		http.Error(w, `{"error": "failed to encode response"}`, http.StatusInternalServerError)
+		log.Printf("Error encoding tax summary: %v", err)
+	}
+}
+
+func (p *PsHandler) QuoteBooking(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var b models.CreateBookingInput
+	if err := json.NewDecoder(r.Body).Decode(&b); err != nil {
+		http.Error(w, `{"error": "invalid request body"}`, http.StatusBadRequest)
+		log.Printf("Error decoding booking input: %v", err)
+		return
+	}
+	defer r.Body.Close()
+	quote, err := QuoteBooking(p.dbpool, b)
+	if err != nil {
+		http.Error(w, `{"error": "failed to quote booking"}`, http.StatusBadRequest)
+		log.Printf("Error quoting booking: %v", err)
+		return
+	}
+	if quote.Taxes == nil {
+		quote.Taxes = []models.TaxLine{}
+	}
+	if err := json.NewEncoder(w).Encode(quote); err != nil {
+		http.Error(w, `{"error": "failed to encode response"}`, http.StatusInternalServerError)
+		log.Printf("Error encoding quote: %v", err)
+	}
+}
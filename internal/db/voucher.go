@@ -0,0 +1,283 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"log"
+	"mis_kursach_backend/internal/models"
+	"mis_kursach_backend/internal/services"
+	"time"
+)
+
+const voucherColumns = `id, code, kind, value, balance, nights, nights_remaining,
+	sold_to, issued_at, expires_at, created_by`
+
+// IssueVoucher продаёт сертификат: сумма продажи поступает на счёт способа оплаты и становится обязательством
+func IssueVoucher(dbpool *pgxpool.Pool, in models.IssueVoucherInput, userID *int) (models.Voucher, error) {
+	var voucher models.Voucher
+	expiresAt, err := time.Parse("2006-01-02", in.ExpiresAt)
+	if err != nil {
+		return voucher, fmt.Errorf("couldn't parse expires_at: %v", err)
+	}
+	code := services.NormalizeVoucherCode(in.Code)
+	if code == "" {
+		code, err = services.GenerateVoucherCode()
+		if err != nil {
+			return voucher, err
+		}
+	}
+	var nights *int
+	if in.Kind == models.VoucherKindNights {
+		nights = &in.Nights
+	}
+	var account string
+	err = dbpool.QueryRow(context.Background(),
+		`SELECT ledger_account FROM PAYMENT_METHODS WHERE code = $1 AND NOT is_voucher AND NOT is_loyalty`, in.MethodCode).Scan(&account)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return voucher, fmt.Errorf("payment method %d cannot be used to buy a voucher", in.MethodCode)
+		}
+		return voucher, fmt.Errorf("error getting payment method: %v", err)
+	}
+	ctx := context.Background()
+	tx, err := dbpool.Begin(ctx)
+	if err != nil {
+		return voucher, fmt.Errorf("error beginning transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+	err = pgxscan.Get(ctx, tx, &voucher,
+		`INSERT INTO VOUCHERS (code, kind, value, balance, nights, nights_remaining, sold_to, issued_at, expires_at, created_by)
+		VALUES ($1, $2, $3, $3, $4, $4, $5, $6, $7, $8)
+		RETURNING `+voucherColumns,
+		code, in.Kind, in.Value, nights, in.SoldTo, time.Now(), expiresAt, userID)
+	if err != nil {
+		log.Printf("error inserting voucher: %v", err)
+		return voucher, fmt.Errorf("error inserting voucher: %v", err)
+	}
+	_, err = PostTransaction(ctx, tx, models.LedgerTransaction{
+		Kind:      models.LedgerKindVoucherSale,
+		Memo:      fmt.Sprintf("Продажа сертификата %s", voucher.Code),
+		CreatedBy: userID,
+		Entries:   services.VoucherSaleEntries(account, voucher.Value),
+	})
+	if err != nil {
+		return voucher, err
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return voucher, fmt.Errorf("error committing voucher sale: %v", err)
+	}
+	return voucher, nil
+}
+
+func GetVouchers(dbpool *pgxpool.Pool) ([]models.Voucher, error) {
+	var vouchers []models.Voucher
+	err := pgxscan.Select(context.Background(), dbpool, &vouchers,
+		`SELECT `+voucherColumns+` FROM VOUCHERS ORDER BY issued_at DESC, id DESC`)
+	if err != nil {
+		return nil, fmt.Errorf("error getting vouchers: %v", err)
+	}
+	return vouchers, nil
+}
+
+func GetVoucherByCode(dbpool *pgxpool.Pool, code string) (models.VoucherDetails, error) {
+	var details models.VoucherDetails
+	err := pgxscan.Get(context.Background(), dbpool, &details.Voucher,
+		`SELECT `+voucherColumns+` FROM VOUCHERS WHERE code = $1`, services.NormalizeVoucherCode(code))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return details, fmt.Errorf("voucher %s not found", code)
+		}
+		return details, fmt.Errorf("error getting voucher: %v", err)
+	}
+	err = pgxscan.Select(context.Background(), dbpool, &details.Redemptions,
+		`SELECT id, voucher_id, payment_id, booking_id, amount, value, nights, redeemed_at, reversed_at
+		FROM VOUCHER_REDEMPTIONS
+		WHERE voucher_id = $1
+		ORDER BY redeemed_at, id`, details.ID)
+	if err != nil {
+		return details, fmt.Errorf("error getting voucher redemptions: %v", err)
+	}
+	return details, nil
+}
+
+// redeemVoucher оплачивает бронирование сертификатом. Сертификат на сумму гасится частично
+// на сумму платежа, но не больше своего остатка и долга по фолио; сертификат на ночи —
+// на p.Nights ночей по цене ночи бронирования.
+// Платёж сразу считается подтверждённым.
+func redeemVoucher(dbpool *pgxpool.Pool, p models.CreatePaymentInput) error {
+	code := services.NormalizeVoucherCode(p.VoucherCode)
+	if code == "" {
+		return fmt.Errorf("voucher code is required")
+	}
+	if p.Currency != "" && p.Currency != models.DefaultCurrency {
+		return fmt.Errorf("vouchers are redeemed in %s only", models.DefaultCurrency)
+	}
+	ctx := context.Background()
+	tx, err := dbpool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("error beginning transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var v models.Voucher
+	err = pgxscan.Get(ctx, tx, &v,
+		`SELECT `+voucherColumns+` FROM VOUCHERS WHERE code = $1 FOR UPDATE`, code)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("voucher %s not found", code)
+		}
+		return fmt.Errorf("error getting voucher: %v", err)
+	}
+	if v.ExpiresAt.Before(dateOnly(time.Now())) {
+		return fmt.Errorf("voucher %s expired on %s", code, v.ExpiresAt.Format("2006-01-02"))
+	}
+
+	// amount гасит долг гостя, value списывается с остатка сертификата
+	var amount, value models.Money
+	nights := 0
+	switch v.Kind {
+	case models.VoucherKindValue:
+		amount = p.Amount
+		if !amount.IsPositive() {
+			return fmt.Errorf("amount must be positive")
+		}
+		// сертификатом нельзя заплатить больше его остатка и больше долга по бронированию,
+		// иначе переплата осталась бы на фолио, а сертификат был бы списан
+		var balance models.Money
+		err = pgxscan.Get(ctx, tx, &balance,
+			`SELECT `+bookingBalanceSQL+` FROM BOOKINGS b WHERE b.id = $1 FOR UPDATE`, p.BookingID)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return fmt.Errorf("booking with ID %d not found", p.BookingID)
+			}
+			return fmt.Errorf("error getting folio balance: %v", err)
+		}
+		if !balance.IsPositive() {
+			return fmt.Errorf("booking %d has nothing to pay", p.BookingID)
+		}
+		if amount.Cmp(v.Balance) > 0 {
+			amount = v.Balance
+		}
+		if amount.Cmp(balance) > 0 {
+			amount = balance
+		}
+		if !amount.IsPositive() {
+			return fmt.Errorf("voucher %s has no balance left", code)
+		}
+		value = amount
+	case models.VoucherKindNights:
+		nights = p.Nights
+		if nights <= 0 {
+			nights = 1
+		}
+		if nights > *v.NightsRemaining {
+			return fmt.Errorf("voucher %s has %d nights left", code, *v.NightsRemaining)
+		}
+		var totalSum models.Money
+		var startDate, endDate time.Time
+		err = tx.QueryRow(ctx,
+			`SELECT total_sum, start_date, end_date FROM BOOKINGS WHERE id = $1`, p.BookingID).
+			Scan(&totalSum, &startDate, &endDate)
+		if err != nil {
+			return fmt.Errorf("error getting booking: %v", err)
+		}
+		bookingNights := daysBetween(startDate, endDate)
+		if nights > bookingNights {
+			return fmt.Errorf("booking %d has only %d nights", p.BookingID, bookingNights)
+		}
+		amount = services.NightsShare(totalSum, bookingNights, nights)
+		value = services.NightsShare(v.Balance, *v.NightsRemaining, nights)
+	}
+
+	var paymentID int
+	err = tx.QueryRow(ctx,
+		`INSERT INTO Payments(
+				booking_id, pay_date, amount,
+				original_amount, original_currency, exchange_rate,
+				method_code, status_code) VALUES ($1, $2, $3, $3, $4, 1, $5, $6) RETURNING id`,
+		p.BookingID, time.Now(), amount, models.DefaultCurrency, p.MethodCode, models.PaymentStatusPaid).Scan(&paymentID)
+	if err != nil {
+		log.Printf("error inserting payment: %v", err)
+		return fmt.Errorf("error inserting payment: %v", err)
+	}
+	_, err = tx.Exec(ctx,
+		`UPDATE VOUCHERS SET balance = balance - $1, nights_remaining = nights_remaining - $2 WHERE id = $3`,
+		value, nights, v.ID)
+	if err != nil {
+		return fmt.Errorf("error updating voucher: %v", err)
+	}
+	_, err = tx.Exec(ctx,
+		`INSERT INTO VOUCHER_REDEMPTIONS (voucher_id, payment_id, booking_id, amount, value, nights, redeemed_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		v.ID, paymentID, p.BookingID, amount, value, nights, time.Now())
+	if err != nil {
+		log.Printf("error inserting voucher redemption: %v", err)
+		return fmt.Errorf("error inserting voucher redemption: %v", err)
+	}
+	_, err = PostTransaction(ctx, tx, models.LedgerTransaction{
+		Kind:      models.LedgerKindPayment,
+		BookingID: &p.BookingID,
+		PaymentID: &paymentID,
+		Memo:      fmt.Sprintf("Оплата сертификатом %s", code),
+		Entries:   services.VoucherRedemptionEntries(amount, value),
+	})
+	if err != nil {
+		return err
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("error committing voucher redemption: %v", err)
+	}
+	return nil
+}
+
+// restoreVoucherRedemption возвращает на сертификат сумму и ночи, списанные платежом.
+// Возвращает false, если платёж был сделан не сертификатом.
+func restoreVoucherRedemption(ctx context.Context, q execer, paymentID int) (bool, error) {
+	result, err := q.Exec(ctx,
+		`WITH r AS (
+			UPDATE VOUCHER_REDEMPTIONS SET reversed_at = $2
+			WHERE payment_id = $1 AND reversed_at IS NULL
+			RETURNING voucher_id, value, nights
+		)
+		UPDATE VOUCHERS v
+		SET balance = v.balance + r.value, nights_remaining = v.nights_remaining + r.nights
+		FROM r
+		WHERE v.id = r.voucher_id`, paymentID, time.Now())
+	if err != nil {
+		log.Printf("error restoring voucher: %v", err)
+		return false, fmt.Errorf("error restoring voucher: %v", err)
+	}
+	return result.RowsAffected() > 0, nil
+}
+
+// GetVoucherLiability — обязательства по проданным сертификатам на конец дня asOf
+func GetVoucherLiability(dbpool *pgxpool.Pool, asOf time.Time) (models.VoucherLiability, error) {
+	liability := models.VoucherLiability{AsOf: asOf, Currency: models.DefaultCurrency}
+	err := pgxscan.Select(context.Background(), dbpool, &liability.Vouchers,
+		`SELECT v.id, v.code, v.kind, v.value, v.nights, v.sold_to, v.issued_at, v.expires_at, v.created_by,
+				v.value - COALESCE(SUM(r.value), 0) AS balance,
+				v.nights - COALESCE(SUM(r.nights), 0) AS nights_remaining
+		FROM VOUCHERS v
+		LEFT JOIN VOUCHER_REDEMPTIONS r ON r.voucher_id = v.id
+			AND r.redeemed_at < $1 + INTERVAL '1 DAY'
+			AND (r.reversed_at IS NULL OR r.reversed_at >= $1 + INTERVAL '1 DAY')
+		WHERE v.issued_at < $1 + INTERVAL '1 DAY'
+		GROUP BY v.id
+		HAVING v.value - COALESCE(SUM(r.value), 0) > 0
+		ORDER BY v.expires_at, v.id`, asOf)
+	if err != nil {
+		return liability, fmt.Errorf("error getting voucher liability: %v", err)
+	}
+	for _, v := range liability.Vouchers {
+		if v.ExpiresAt.Before(asOf) {
+			liability.Expired = liability.Expired.Add(v.Balance)
+		} else {
+			liability.Outstanding = liability.Outstanding.Add(v.Balance)
+		}
+	}
+	return liability, nil
+}
@@ -0,0 +1,103 @@
+package db
+
+import (
+	"encoding/json"
+	"github.com/go-chi/chi/v5"
+	"log"
+	"mis_kursach_backend/internal/models"
+	"net/http"
+	"time"
+)
+
+func (p *PsHandler) IssueVoucher(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var in models.IssueVoucherInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, `{"error": "invalid request body"}`, http.StatusBadRequest)
+		log.Printf("Error decoding voucher input: %v", err)
+		return
+	}
+	defer r.Body.Close()
+	if in.Kind != models.VoucherKindValue && in.Kind != models.VoucherKindNights {
+		http.Error(w, `{"error": "kind must be value or nights"}`, http.StatusBadRequest)
+		return
+	}
+	if in.Kind == models.VoucherKindNights && in.Nights <= 0 {
+		http.Error(w, `{"error": "nights must be positive"}`, http.StatusBadRequest)
+		return
+	}
+	if !in.Value.IsPositive() {
+		http.Error(w, `{"error": "value must be positive"}`, http.StatusBadRequest)
+		return
+	}
+	voucher, err := IssueVoucher(p.dbpool, in, userIDFromRequest(r))
+	if err != nil {
+		http.Error(w, `{"error": "failed to issue voucher"}`, http.StatusBadRequest)
+		log.Printf("Error issuing voucher: %v", err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(voucher); err != nil {
+		log.Printf("Error encoding voucher: %v", err)
+	}
+}
+
+func (p *PsHandler) GetVouchers(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	vouchers, err := GetVouchers(p.dbpool)
+	if err != nil {
+		http.Error(w, `{"error": "failed to get vouchers"}`, http.StatusInternalServerError)
+		log.Printf("Error getting vouchers: %v", err)
+		return
+	}
+	if vouchers == nil {
+		vouchers = []models.Voucher{}
+	}
+	if err := json.NewEncoder(w).Encode(vouchers); err != nil {
+		http.Error(w, `{"error": "failed to encode response"}`, http.StatusInternalServerError)
+		log.Printf("Error encoding vouchers: %v", err)
+	}
+}
+
+func (p *PsHandler) GetVoucher(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	voucher, err := GetVoucherByCode(p.dbpool, chi.URLParam(r, "code"))
+	if err != nil {
+		http.Error(w, `{"error": "voucher not found"}`, http.StatusNotFound)
+		log.Printf("Error getting voucher: %v", err)
+		return
+	}
+	if voucher.Redemptions == nil {
+		voucher.Redemptions = []models.VoucherRedemption{}
+	}
+	if err := json.NewEncoder(w).Encode(voucher); err != nil {
+		http.Error(w, `{"error": "failed to encode response"}`, http.StatusInternalServerError)
+		log.Printf("Error encoding voucher: %v", err)
+	}
+}
+
+func (p *PsHandler) GetVoucherLiability(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	asOf := dateOnly(time.Now())
+	if s := r.URL.Query().Get("as_of"); s != "" {
+		var err error
+		asOf, err = time.Parse("2006-01-02", s)
+		if err != nil {
+			http.Error(w, `{"error": "invalid as_of date"}`, http.StatusBadRequest)
+			return
+		}
+	}
+	liability, err := GetVoucherLiability(p.dbpool, asOf)
+	if err != nil {
+		http.Error(w, `{"error": "failed to get voucher liability"}`, http.StatusInternalServerError)
+		log.Printf("Error getting voucher liability: %v", err)
+		return
+	}
+	if liability.Vouchers == nil {
+		liability.Vouchers = []models.Voucher{}
+	}
+	if err := json.NewEncoder(w).Encode(liability); err != nil {
+		http.Error(w, `{"error": "failed to encode response"}`, http.StatusInternalServerError)
+		log.Printf("Error encoding voucher liability: %v", err)
+	}
+}
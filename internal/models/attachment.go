@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+const (
+	AttachmentPassportScan     = "passport_scan"
+	AttachmentRegistrationCard = "registration_card"
+	AttachmentOther            = "other"
+)
+
+// Attachment represents the attachments table; the file itself is kept in storage under StorageKey
+type Attachment struct {
+	ID          int       `json:"id" db:"id"`
+	GuestID     *int      `json:"guest_id" db:"guest_id"`
+	BookingID   *int      `json:"booking_id" db:"booking_id"`
+	Kind        string    `json:"kind" db:"kind"`
+	FileName    string    `json:"file_name" db:"file_name"`
+	ContentType string    `json:"content_type" db:"content_type"`
+	SizeBytes   int64     `json:"size_bytes" db:"size_bytes"`
+	SHA256      string    `json:"sha256" db:"sha256"`
+	StorageKey  string    `json:"-" db:"storage_key"`
+	UploadedBy  *int      `json:"uploaded_by" db:"uploaded_by"`
+	UploadedAt  time.Time `json:"uploaded_at" db:"uploaded_at"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// AuditEntry represents the audit_log table
+type AuditEntry struct {
+	ID        int             `json:"id" db:"id"`
+	Entity    string          `json:"entity" db:"entity"`
+	EntityID  int             `json:"entity_id" db:"entity_id"`
+	Action    string          `json:"action" db:"action"`
+	Details   json.RawMessage `json:"details" db:"details"`
+	UserID    *int            `json:"user_id" db:"user_id"`
+	CreatedAt time.Time       `json:"created_at" db:"created_at"`
+}
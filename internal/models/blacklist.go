@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+const (
+	BlacklistBlock = "block"
+	BlacklistWarn  = "warn"
+)
+
+// BlacklistEntry represents the guest_blacklist table
+type BlacklistEntry struct {
+	ID        int        `json:"id" db:"id"`
+	GuestID   int        `json:"guest_id" db:"guest_id"`
+	GuestName string     `json:"guest_name" db:"guest_name"`
+	Reason    string     `json:"reason" db:"reason"`
+	Severity  string     `json:"severity" db:"severity"`
+	CreatedBy *int       `json:"created_by" db:"created_by"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at" db:"expires_at"`
+	LiftedAt  *time.Time `json:"lifted_at" db:"lifted_at"`
+	LiftedBy  *int       `json:"lifted_by" db:"lifted_by"`
+}
+
+type CreateBlacklistInput struct {
+	GuestID int    `json:"guest_id"`
+	Reason  string `json:"reason"`
+	// Severity — block запрещает бронирование, warn только предупреждает
+	Severity  string  `json:"severity"`
+	ExpiresAt *string `json:"expires_at"`
+}
+
+// BlacklistMatch is an active blacklist entry that matched a booking's guest
+type BlacklistMatch struct {
+	BlacklistEntry
+	PassportMatch bool `json:"passport_match" db:"passport_match"`
+	PhoneMatch    bool `json:"phone_match" db:"phone_match"`
+}
+
+// BlacklistOverride lets an admin book a blocked guest; the justification goes to the audit log
+type BlacklistOverride struct {
+	Justification string
+	UserID        *int
+}
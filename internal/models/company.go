@@ -0,0 +1,108 @@
+package models
+
+import "time"
+
+const (
+	CompanyInvoiceIssued = "issued"
+	CompanyInvoicePaid   = "paid"
+)
+
+// Company represents the companies table
+type Company struct {
+	ID               int       `json:"id" db:"id"`
+	Name             string    `json:"name" db:"name"`
+	TaxID            string    `json:"tax_id" db:"tax_id"`
+	Email            *string   `json:"email" db:"email"`
+	PhoneNumber      *string   `json:"phone_number" db:"phone_number"`
+	Address          *string   `json:"address" db:"address"`
+	CreditLimit      Money     `json:"credit_limit" db:"credit_limit"`
+	PaymentTermsDays int       `json:"payment_terms_days" db:"payment_terms_days"`
+	Active           bool      `json:"active" db:"active"`
+	CreatedAt        time.Time `json:"created_at" db:"created_at"`
+}
+
+type CompanyInput struct {
+	Name             string  `json:"name"`
+	TaxID            string  `json:"tax_id"`
+	Email            *string `json:"email"`
+	PhoneNumber      *string `json:"phone_number"`
+	Address          *string `json:"address"`
+	CreditLimit      Money   `json:"credit_limit"`
+	PaymentTermsDays *int    `json:"payment_terms_days"`
+	Active           *bool   `json:"active"`
+}
+
+// CompanyRate represents the company_rates table
+type CompanyRate struct {
+	CompanyID    int    `json:"company_id" db:"company_id"`
+	CategoryCode int    `json:"category_code" db:"category_code"`
+	CategoryName string `json:"category_name" db:"category_name"`
+	Price        Money  `json:"price" db:"price"`
+}
+
+// CompanyAccount is a company profile with its contract rates and current exposure
+type CompanyAccount struct {
+	Company
+	Rates []CompanyRate `json:"rates"`
+	// Outstanding — неоплаченные счета плюс ещё не выставленные проживания
+	Outstanding     Money `json:"outstanding"`
+	AvailableCredit Money `json:"available_credit"`
+}
+
+// CompanyInvoice represents the company_invoices table
+type CompanyInvoice struct {
+	ID          int                  `json:"id" db:"id"`
+	CompanyID   int                  `json:"company_id" db:"company_id"`
+	CompanyName string               `json:"company_name" db:"company_name"`
+	PeriodStart time.Time            `json:"period_start" db:"period_start"`
+	PeriodEnd   time.Time            `json:"period_end" db:"period_end"`
+	IssueDate   time.Time            `json:"issue_date" db:"issue_date"`
+	DueDate     time.Time            `json:"due_date" db:"due_date"`
+	Amount      Money                `json:"amount" db:"amount"`
+	PaidAmount  Money                `json:"paid_amount" db:"paid_amount"`
+	Status      string               `json:"status" db:"status"`
+	Lines       []CompanyInvoiceLine `json:"lines" db:"-"`
+}
+
+// CompanyInvoiceLine is a single stay included in a consolidated invoice
+type CompanyInvoiceLine struct {
+	BookingID int        `json:"booking_id" db:"booking_id"`
+	GuestName string     `json:"guest_name" db:"guest_name"`
+	Room      int        `json:"room" db:"room"`
+	StartDate time.Time  `json:"start_date" db:"start_date"`
+	EndDate   time.Time  `json:"end_date" db:"end_date"`
+	CheckOut  *time.Time `json:"check_out" db:"check_out"`
+	Amount    Money      `json:"amount" db:"amount"`
+}
+
+type CreateCompanyInvoiceInput struct {
+	CompanyID int `json:"company_id"`
+	// Month — расчётный месяц в формате ГГГГ-ММ
+	Month string `json:"month"`
+}
+
+type PayCompanyInvoiceInput struct {
+	InvoiceID  int   `json:"invoice_id"`
+	Amount     Money `json:"amount"`
+	MethodCode int   `json:"payment_method_code"`
+}
+
+// OutstandingInvoice is an unpaid part of a company invoice used for aging
+type OutstandingInvoice struct {
+	CompanyID   int       `db:"company_id"`
+	CompanyName string    `db:"company_name"`
+	DueDate     time.Time `db:"due_date"`
+	Outstanding Money     `db:"outstanding"`
+}
+
+// CompanyAging splits a company's unpaid invoices by days past due
+type CompanyAging struct {
+	CompanyID   int    `json:"company_id"`
+	CompanyName string `json:"company_name"`
+	Current     Money  `json:"current"`
+	Days1To30   Money  `json:"days_1_30"`
+	Days31To60  Money  `json:"days_31_60"`
+	Days61To90  Money  `json:"days_61_90"`
+	Over90      Money  `json:"over_90"`
+	Total       Money  `json:"total"`
+}
@@ -0,0 +1,180 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// коды complaint_statuses
+const (
+	ComplaintStatusInProgress = 1
+	ComplaintStatusOpen       = 2
+	ComplaintStatusResolved   = 3
+)
+
+const (
+	ComplaintPriorityLow    = "low"
+	ComplaintPriorityNormal = "normal"
+	ComplaintPriorityHigh   = "high"
+	ComplaintPriorityUrgent = "urgent"
+)
+
+// ComplaintCategoryOther is used when a complaint is filed without a category
+const ComplaintCategoryOther = "other"
+
+// ComplaintCategory represents the complaint_categories table
+type ComplaintCategory struct {
+	Code     string `json:"code" db:"code"`
+	Name     string `json:"name" db:"name"`
+	SLAHours int    `json:"sla_hours" db:"sla_hours"`
+}
+
+type AssignComplaintInput struct {
+	ComplaintID int `json:"complaint_id"`
+	// UserID — исполнитель; null снимает назначение
+	UserID *int `json:"user_id"`
+}
+
+// ComplaintEscalation is an overdue complaint handed over to managers by the SLA checker
+type ComplaintEscalation struct {
+	ID         int       `json:"id" db:"id"`
+	SLADueAt   time.Time `json:"sla_due_at" db:"sla_due_at"`
+	AssignedTo *int      `json:"assigned_to" db:"assigned_to"`
+}
+
+// виды записей complaint_activity
+const (
+	ComplaintActivityCreated      = "created"
+	ComplaintActivityComment      = "comment"
+	ComplaintActivityStatus       = "status"
+	ComplaintActivityAssignment   = "assignment"
+	ComplaintActivityEdit         = "edit"
+	ComplaintActivityEscalation   = "escalation"
+	ComplaintActivityCompensation = "compensation"
+	ComplaintActivityDeleted      = "deleted"
+)
+
+// ComplaintActivity is one append-only entry of the complaint log
+type ComplaintActivity struct {
+	ID          int     `json:"id" db:"id"`
+	ComplaintID int     `json:"complaint_id" db:"complaint_id"`
+	Kind        string  `json:"kind" db:"kind"`
+	Body        *string `json:"body" db:"body"`
+	// Changes — изменённые поля: {"status": {"from": "Открыта", "to": "В работе"}}
+	Changes    json.RawMessage `json:"changes" db:"changes"`
+	UserID     *int            `json:"user_id" db:"user_id"`
+	Username   *string         `json:"username" db:"username"`
+	CreatedAt  time.Time       `json:"created_at" db:"created_at"`
+	RedactedAt *time.Time      `json:"redacted_at" db:"redacted_at"`
+}
+
+// FieldChange is the before/after value of one edited field
+type FieldChange struct {
+	From any `json:"from"`
+	To   any `json:"to"`
+}
+
+// ComplaintDetails is a complaint together with its activity log, oldest entry first
+type ComplaintDetails struct {
+	ComplaintResponse
+	Activity      []ComplaintActivity     `json:"activity"`
+	Compensations []ComplaintCompensation `json:"compensations"`
+}
+
+type ComplaintCommentInput struct {
+	ComplaintID int    `json:"complaint_id"`
+	Body        string `json:"body"`
+}
+
+// виды complaint_compensations
+const (
+	CompensationFolioCredit = "folio_credit"
+	CompensationFreeNight   = "free_night"
+	CompensationRefund      = "refund"
+)
+
+// ComplaintCompensation represents the complaint_compensations table
+type ComplaintCompensation struct {
+	ID          int       `json:"id" db:"id"`
+	ComplaintID int       `json:"complaint_id" db:"complaint_id"`
+	BookingID   *int      `json:"booking_id" db:"booking_id"`
+	Kind        string    `json:"kind" db:"kind"`
+	Amount      Money     `json:"amount" db:"amount"`
+	Nights      *int      `json:"nights" db:"nights"`
+	MethodCode  *int      `json:"method_code" db:"method_code"`
+	Commentary  *string   `json:"commentary" db:"commentary"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	CreatedBy   *int      `json:"created_by" db:"created_by"`
+}
+
+type CompensateComplaintInput struct {
+	ComplaintID int `json:"complaint_id"`
+	// BookingID нужен, только если жалоба не привязана к бронированию
+	BookingID *int   `json:"booking_id"`
+	Kind      string `json:"kind"`
+	// Amount — сумма скидки или возврата; для бесплатной ночи считается по цене бронирования
+	Amount *Money `json:"amount"`
+	// Nights — число бесплатных ночей, по умолчанию одна
+	Nights int `json:"nights"`
+	// MethodCode — способ оплаты, которым возвращаются деньги
+	MethodCode *int    `json:"method_code"`
+	Commentary *string `json:"commentary"`
+}
+
+// ComplaintCostRow is the cost of complaints of one category over the report period
+type ComplaintCostRow struct {
+	Category      string `json:"category" db:"category"`
+	CategoryName  string `json:"category_name" db:"category_name"`
+	Complaints    int    `json:"complaints" db:"complaints"`
+	Compensations int    `json:"compensations" db:"compensations"`
+	FolioCredits  Money  `json:"folio_credits" db:"folio_credits"`
+	FreeNights    Money  `json:"free_nights" db:"free_nights"`
+	Refunds       Money  `json:"refunds" db:"refunds"`
+	Total         Money  `json:"total" db:"-"`
+}
+
+// ComplaintCostReport rolls compensations given in [From, To] up by complaint category
+type ComplaintCostReport struct {
+	From  time.Time          `json:"from"`
+	To    time.Time          `json:"to"`
+	Rows  []ComplaintCostRow `json:"rows"`
+	Total Money              `json:"total"`
+}
+
+// ComplaintAnalytics is complaint statistics for the date range [From, To], shaped for charts
+type ComplaintAnalytics struct {
+	From       time.Time `json:"from"`
+	To         time.Time `json:"to"`
+	Complaints int       `json:"complaints"`
+	Resolved   int       `json:"resolved"`
+	// MedianResolutionHours — медиана времени от подачи до решения; null, если решённых жалоб нет
+	MedianResolutionHours *float64                 `json:"median_resolution_hours"`
+	Weekly                []ComplaintWeek          `json:"weekly"`
+	ByCategory            []ComplaintCategoryStats `json:"by_category"`
+	ByRoom                []ComplaintRoomStats     `json:"by_room"`
+	// RepeatedRooms — номера, на которые за период пожаловались несколько раз
+	RepeatedRooms []ComplaintRoomStats `json:"repeated_rooms"`
+}
+
+// ComplaintWeek is one week of the complaint rate trend; weeks start on Monday
+type ComplaintWeek struct {
+	WeekStart  time.Time `json:"week_start" db:"week_start"`
+	Complaints int       `json:"complaints" db:"complaints"`
+	RoomNights int       `json:"room_nights" db:"room_nights"`
+	// PerHundredRoomNights — жалоб на 100 занятых номеро-ночей
+	PerHundredRoomNights float64 `json:"per_hundred_room_nights" db:"-"`
+}
+
+type ComplaintCategoryStats struct {
+	Category              string   `json:"category" db:"category"`
+	CategoryName          string   `json:"category_name" db:"category_name"`
+	Complaints            int      `json:"complaints" db:"complaints"`
+	Resolved              int      `json:"resolved" db:"resolved"`
+	MedianResolutionHours *float64 `json:"median_resolution_hours" db:"median_resolution_hours"`
+}
+
+type ComplaintRoomStats struct {
+	Room       int      `json:"room" db:"room"`
+	Complaints int      `json:"complaints" db:"complaints"`
+	Categories []string `json:"categories" db:"categories"`
+}
@@ -0,0 +1,77 @@
+package models
+
+import "time"
+
+// Service represents the services table (catalog of extra charges)
+type Service struct {
+	ID      int     `json:"id" db:"id"`
+	Code    string  `json:"code" db:"code"`
+	Name    string  `json:"name" db:"name"`
+	Price   Money   `json:"price" db:"price"`
+	TaxRate Decimal `json:"tax_rate" db:"tax_rate"`
+	Active  bool    `json:"active" db:"active"`
+}
+
+type ServiceInput struct {
+	Code    string  `json:"code"`
+	Name    string  `json:"name"`
+	Price   Money   `json:"price"`
+	TaxRate Decimal `json:"tax_rate"`
+	Active  *bool   `json:"active"`
+}
+
+// FolioCharge represents the folio_charges table
+type FolioCharge struct {
+	ID          int        `json:"id" db:"id"`
+	BookingID   int        `json:"booking_id" db:"booking_id"`
+	ServiceID   int        `json:"service_id" db:"service_id"`
+	ServiceName string     `json:"service_name" db:"service_name"`
+	Quantity    int        `json:"quantity" db:"quantity"`
+	UnitPrice   Money      `json:"unit_price" db:"unit_price"`
+	TaxRate     Decimal    `json:"tax_rate" db:"tax_rate"`
+	Amount      Money      `json:"amount" db:"amount"`
+	TaxAmount   Money      `json:"tax_amount" db:"tax_amount"`
+	Commentary  *string    `json:"commentary" db:"commentary"`
+	PostedAt    time.Time  `json:"posted_at" db:"posted_at"`
+	PostedBy    *int       `json:"posted_by" db:"posted_by"`
+	VoidedAt    *time.Time `json:"voided_at" db:"voided_at"`
+	VoidedBy    *int       `json:"voided_by" db:"voided_by"`
+	VoidReason  *string    `json:"void_reason" db:"void_reason"`
+}
+
+type PostChargeInput struct {
+	BookingID  int     `json:"booking_id"`
+	ServiceID  int     `json:"service_id"`
+	Quantity   int     `json:"quantity"`
+	UnitPrice  *Money  `json:"unit_price"`
+	Commentary *string `json:"commentary"`
+}
+
+type VoidChargeInput struct {
+	ID     int    `json:"id"`
+	Reason string `json:"reason"`
+}
+
+// Folio is the running account of a booking: room charges, extras and payments
+type Folio struct {
+	BookingID   int   `json:"booking_id"`
+	RoomTotal   Money `json:"room_total"`
+	ExtrasTotal Money `json:"extras_total"`
+	TaxTotal    Money `json:"tax_total"`
+	Total       Money `json:"total"`
+	Paid        Money `json:"paid"`
+	// BilledToCompany — сумма, перенесённая в счёт организации
+	BilledToCompany Money `json:"billed_to_company"`
+	// Compensations — скидки и бесплатные ночи, выданные по жалобам
+	Compensations Money         `json:"compensations"`
+	Balance       Money         `json:"balance"`
+	Charges       []FolioCharge `json:"charges"`
+}
+
+type Invoice struct {
+	IssuedAt time.Time         `json:"issued_at"`
+	Booking  BookingResponse   `json:"booking"`
+	Folio    Folio             `json:"folio"`
+	Taxes    []TaxLine         `json:"taxes"`
+	Payments []PaymentResponse `json:"payments"`
+}
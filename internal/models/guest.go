@@ -0,0 +1,93 @@
+package models
+
+import "time"
+
+const (
+	DocumentRussianPassport = "ru_passport"
+	DocumentForeignPassport = "foreign_passport"
+	DocumentOther           = "other"
+)
+
+type GuestInput struct {
+	Name         string `json:"name"`
+	PhoneNumber  string `json:"phone_number"`
+	Email        string `json:"email"`
+	PassportNo   string `json:"passport_no"`
+	DocumentType string `json:"document_type"`
+	Nationality  string `json:"nationality"`
+	// миграционные документы заполняются только для иностранных граждан, даты в формате ГГГГ-ММ-ДД
+	VisaNumber             string `json:"visa_number"`
+	VisaExpiresAt          string `json:"visa_expires_at"`
+	MigrationCardNo        string `json:"migration_card_no"`
+	EntryDate              string `json:"entry_date"`
+	MigrationCardExpiresAt string `json:"migration_card_expires_at"`
+}
+
+// FieldErrors maps a request field to the reason it was rejected
+type FieldErrors map[string]string
+
+// GuestProfile is a guest with the history of their stays
+type GuestProfile struct {
+	ID           int        `json:"id" db:"id"`
+	Name         string     `json:"name" db:"name"`
+	PhoneNumber  string     `json:"phone_number" db:"phone_number"`
+	Email        *string    `json:"email" db:"email"`
+	PassportNo   string     `json:"passport_no" db:"passport_no"`
+	PassportEnc  *string    `json:"-" db:"passport_enc"`
+	DocumentType string     `json:"document_type" db:"document_type"`
+	Nationality  *string    `json:"nationality" db:"nationality"`
+	ErasedAt     *time.Time `json:"erased_at" db:"erased_at"`
+	MigrationDocuments
+	Bookings []BookingResponse `json:"bookings" db:"-"`
+}
+
+// GuestSearch holds the filters of the guest list; empty filters are ignored
+type GuestSearch struct {
+	Name     string
+	Phone    string
+	Passport string
+	Page     int
+	PageSize int
+}
+
+type GuestPage struct {
+	Items    []Guest `json:"items"`
+	Total    int     `json:"total"`
+	Page     int     `json:"page"`
+	PageSize int     `json:"page_size"`
+}
+
+// DuplicateCandidate is a pair of guest profiles that probably belong to the same person
+type DuplicateCandidate struct {
+	Guest     Guest    `json:"guest"`
+	Duplicate Guest    `json:"duplicate"`
+	Score     float64  `json:"score"`
+	Reasons   []string `json:"reasons"`
+}
+
+type MergeGuestsInput struct {
+	SurvivorID  int `json:"survivor_id"`
+	DuplicateID int `json:"duplicate_id"`
+}
+
+// MergeResult reports what was moved from the duplicate to the surviving profile
+type MergeResult struct {
+	SurvivorID    int `json:"survivor_id"`
+	DuplicateID   int `json:"duplicate_id"`
+	BookingsMoved int `json:"bookings_moved"`
+}
+
+// GuestDataExport is everything the hotel stores about a guest, returned on a data subject request
+type GuestDataExport struct {
+	ExportedAt time.Time           `json:"exported_at"`
+	Guest      GuestProfile        `json:"guest"`
+	Payments   []PaymentResponse   `json:"payments"`
+	Complaints []ComplaintResponse `json:"complaints"`
+	// Attachments — только список файлов; сами файлы выгружаются отдельно
+	Attachments []Attachment `json:"attachments"`
+}
+
+type EraseGuestInput struct {
+	// Reason — основание удаления, например номер обращения гостя
+	Reason string `json:"reason"`
+}
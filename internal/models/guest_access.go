@@ -0,0 +1,54 @@
+package models
+
+import "time"
+
+type GuestAccessCodeRequest struct {
+	Reference string `json:"reference"`
+}
+
+// GuestLoginInput identifies a guest by the booking reference and either their last name or an emailed code
+type GuestLoginInput struct {
+	Reference string `json:"reference"`
+	LastName  string `json:"last_name"`
+	Code      string `json:"code"`
+}
+
+const (
+	BalancePaid          = "paid"
+	BalancePartiallyPaid = "partially_paid"
+	BalanceUnpaid        = "unpaid"
+	BalanceCompanyBilled = "company_billed"
+)
+
+// GuestBookingView is the read-only part of a booking shown to the guest
+type GuestBookingView struct {
+	Reference     string           `json:"reference" db:"reference"`
+	StartDate     time.Time        `json:"start_date" db:"start_date"`
+	EndDate       time.Time        `json:"end_date" db:"end_date"`
+	CheckIn       *time.Time       `json:"check_in" db:"check_in"`
+	CheckOut      *time.Time       `json:"check_out" db:"check_out"`
+	BookingStatus string           `json:"booking_status" db:"booking_status"`
+	RoomCategory  string           `json:"room_category" db:"room_category"`
+	TotalSum      Money            `json:"total_sum" db:"total_sum"`
+	TaxSum        Money            `json:"tax_sum" db:"tax_sum"`
+	ExtrasSum     Money            `json:"extras_sum" db:"extras_sum"`
+	PaidSum       Money            `json:"paid_sum" db:"paid_sum"`
+	Compensations Money            `json:"compensations" db:"compensations"`
+	Balance       Money            `json:"balance" db:"balance"`
+	PaymentStatus string           `json:"payment_status" db:"-"`
+	Complaints    []GuestComplaint `json:"complaints" db:"-"`
+}
+
+// GuestComplaint is a complaint as the guest who filed it sees it
+type GuestComplaint struct {
+	ID        int       `json:"id" db:"id"`
+	Reason    string    `json:"reason" db:"reason"`
+	IssueDate time.Time `json:"issue_date" db:"issue_date"`
+	Status    string    `json:"status" db:"status"`
+}
+
+type GuestComplaintInput struct {
+	Reason     string  `json:"reason"`
+	Commentary *string `json:"commentary"`
+	Category   string  `json:"category"`
+}
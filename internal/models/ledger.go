@@ -0,0 +1,110 @@
+package models
+
+import "time"
+
+const (
+	AccountGuestReceivable   = "guest_receivable"
+	AccountRoomRevenue       = "room_revenue"
+	AccountExtrasRevenue     = "extras_revenue"
+	AccountTaxPayable        = "tax_payable"
+	AccountCash              = "cash"
+	AccountCardClearing      = "card_clearing"
+	AccountCompanyReceivable = "company_receivable"
+	AccountVoucherLiability  = "voucher_liability"
+	AccountLoyaltyLiability  = "loyalty_liability"
+	AccountLoyaltyExpense    = "loyalty_expense"
+	AccountCompensation      = "complaint_compensation"
+
+	AccountTypeAsset     = "asset"
+	AccountTypeLiability = "liability"
+	AccountTypeRevenue   = "revenue"
+	AccountTypeExpense   = "expense"
+)
+
+const (
+	LedgerKindRoomCharge     = "room_charge"
+	LedgerKindDiscount       = "discount"
+	LedgerKindExtrasCharge   = "extras_charge"
+	LedgerKindVoid           = "void"
+	LedgerKindPayment        = "payment"
+	LedgerKindRefund         = "refund"
+	LedgerKindReversal       = "reversal"
+	LedgerKindCompanyInvoice = "company_invoice"
+	LedgerKindCompanyPayment = "company_payment"
+	LedgerKindVoucherSale    = "voucher_sale"
+	LedgerKindLoyaltyAccrual = "loyalty_accrual"
+	LedgerKindLoyaltyExpiry  = "loyalty_expiry"
+	LedgerKindCompensation   = "compensation"
+)
+
+const (
+	PaymentStatusPending  = 1
+	PaymentStatusPaid     = 2
+	PaymentStatusRefunded = 3
+)
+
+// Account represents the accounts table
+type Account struct {
+	Code string `json:"code" db:"code"`
+	Name string `json:"name" db:"name"`
+	Type string `json:"type" db:"type"`
+}
+
+// LedgerEntry represents the ledger_entries table; exactly one of Debit and Credit is non-zero
+type LedgerEntry struct {
+	AccountCode string `json:"account_code" db:"account_code"`
+	Debit       Money  `json:"debit" db:"debit"`
+	Credit      Money  `json:"credit" db:"credit"`
+}
+
+// LedgerTransaction represents the ledger_transactions table with its balanced entries
+type LedgerTransaction struct {
+	ID            int           `json:"id" db:"id"`
+	Kind          string        `json:"kind" db:"kind"`
+	BookingID     *int          `json:"booking_id" db:"booking_id"`
+	PaymentID     *int          `json:"payment_id" db:"payment_id"`
+	FolioChargeID *int          `json:"folio_charge_id" db:"folio_charge_id"`
+	Memo          string        `json:"memo" db:"memo"`
+	BusinessDate  time.Time     `json:"business_date" db:"business_date"`
+	PostedAt      time.Time     `json:"posted_at" db:"posted_at"`
+	CreatedBy     *int          `json:"created_by" db:"created_by"`
+	Entries       []LedgerEntry `json:"entries"`
+}
+
+type TrialBalanceRow struct {
+	AccountCode string `json:"account_code" db:"account_code"`
+	Name        string `json:"name" db:"name"`
+	Type        string `json:"type" db:"type"`
+	Debit       Money  `json:"debit" db:"debit"`
+	Credit      Money  `json:"credit" db:"credit"`
+	Balance     Money  `json:"balance" db:"-"`
+}
+
+type TrialBalance struct {
+	AsOf        time.Time         `json:"as_of"`
+	Currency    string            `json:"currency"`
+	Rows        []TrialBalanceRow `json:"rows"`
+	TotalDebit  Money             `json:"total_debit"`
+	TotalCredit Money             `json:"total_credit"`
+}
+
+type StatementLine struct {
+	TransactionID int       `json:"transaction_id" db:"transaction_id"`
+	Kind          string    `json:"kind" db:"kind"`
+	BookingID     *int      `json:"booking_id" db:"booking_id"`
+	Memo          *string   `json:"memo" db:"memo"`
+	BusinessDate  time.Time `json:"business_date" db:"business_date"`
+	PostedAt      time.Time `json:"posted_at" db:"posted_at"`
+	Debit         Money     `json:"debit" db:"debit"`
+	Credit        Money     `json:"credit" db:"credit"`
+	Balance       Money     `json:"balance" db:"-"`
+}
+
+type AccountStatement struct {
+	Account        Account         `json:"account"`
+	From           time.Time       `json:"from"`
+	To             time.Time       `json:"to"`
+	OpeningBalance Money           `json:"opening_balance"`
+	ClosingBalance Money           `json:"closing_balance"`
+	Lines          []StatementLine `json:"lines"`
+}
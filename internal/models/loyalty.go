@@ -0,0 +1,61 @@
+package models
+
+import "time"
+
+const (
+	LoyaltyEarn    = "earn"
+	LoyaltyRedeem  = "redeem"
+	LoyaltyRestore = "restore"
+	LoyaltyExpire  = "expire"
+)
+
+// LoyaltyTier represents the loyalty_tiers table
+type LoyaltyTier struct {
+	Code string `json:"code" db:"code"`
+	Name string `json:"name" db:"name"`
+	// MinPoints — сколько баллов нужно заработать за последние 12 месяцев
+	MinPoints       int     `json:"min_points" db:"min_points"`
+	DiscountPercent Decimal `json:"discount_percent" db:"discount_percent"`
+}
+
+// LoyaltyTransaction represents the loyalty_transactions table.
+// Начисления — партии баллов: Remaining уменьшается при оплате и сгорании.
+type LoyaltyTransaction struct {
+	ID         int        `json:"id" db:"id"`
+	GuestID    int        `json:"guest_id" db:"guest_id"`
+	Kind       string     `json:"kind" db:"kind"`
+	Points     int        `json:"points" db:"points"`
+	Remaining  int        `json:"remaining" db:"remaining"`
+	BookingID  *int       `json:"booking_id" db:"booking_id"`
+	PaymentID  *int       `json:"payment_id" db:"payment_id"`
+	ExpiresAt  *time.Time `json:"expires_at" db:"expires_at"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	ReversedAt *time.Time `json:"reversed_at" db:"reversed_at"`
+}
+
+type ExpiringPoints struct {
+	ExpiresAt time.Time `json:"expires_at" db:"expires_at"`
+	Points    int       `json:"points" db:"points"`
+}
+
+// LoyaltyStatement is a guest's points balance, tier and history
+type LoyaltyStatement struct {
+	GuestID          int          `json:"guest_id"`
+	Balance          int          `json:"balance"`
+	BalanceValue     Money        `json:"balance_value"`
+	QualifyingPoints int          `json:"qualifying_points"`
+	Tier             LoyaltyTier  `json:"tier"`
+	NextTier         *LoyaltyTier `json:"next_tier"`
+	Earned           int          `json:"earned"`
+	Redeemed         int          `json:"redeemed"`
+	Expired          int          `json:"expired"`
+	// Expiring — баллы, которые сгорят в ближайшие 90 дней
+	Expiring     []ExpiringPoints     `json:"expiring"`
+	Transactions []LoyaltyTransaction `json:"transactions"`
+}
+
+type LoyaltyExpiryResult struct {
+	AsOf   time.Time `json:"as_of"`
+	Guests int       `json:"guests"`
+	Points int       `json:"points"`
+}
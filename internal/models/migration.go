@@ -0,0 +1,76 @@
+package models
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+// MigrationDocuments holds the visa and migration card of a foreign guest
+type MigrationDocuments struct {
+	VisaNumber             *string    `json:"visa_number" db:"visa_number"`
+	VisaExpiresAt          *time.Time `json:"visa_expires_at" db:"visa_expires_at"`
+	MigrationCardNo        *string    `json:"migration_card_no" db:"migration_card_no"`
+	EntryDate              *time.Time `json:"entry_date" db:"entry_date"`
+	MigrationCardExpiresAt *time.Time `json:"migration_card_expires_at" db:"migration_card_expires_at"`
+}
+
+// MigrationArrival is a stay of a foreign guest that needs a migration registration notice
+type MigrationArrival struct {
+	BookingID    int     `json:"booking_id" db:"booking_id"`
+	GuestID      int     `json:"guest_id" db:"guest_id"`
+	GuestName    string  `json:"guest_name" db:"guest_name"`
+	Nationality  string  `json:"nationality" db:"nationality"`
+	DocumentType string  `json:"document_type" db:"document_type"`
+	PassportNo   string  `json:"passport_no" db:"passport_no"`
+	PassportEnc  *string `json:"-" db:"passport_enc"`
+	Room         int     `json:"room" db:"room"`
+	MigrationDocuments
+	ArrivalDate   time.Time  `json:"arrival_date" db:"arrival_date"`
+	DepartureDate time.Time  `json:"departure_date" db:"departure_date"`
+	Deadline      time.Time  `json:"deadline" db:"-"`
+	DaysLeft      int        `json:"days_left" db:"-"`
+	Overdue       bool       `json:"overdue" db:"-"`
+	ExportedAt    *time.Time `json:"exported_at" db:"exported_at"`
+	// Missing — поля, без которых уведомление не выгружается
+	Missing []string `json:"missing" db:"-"`
+}
+
+// MigrationDeadlines is the registration deadline tracker shown on the dashboard
+type MigrationDeadlines struct {
+	Pending  int `json:"pending"`
+	DueToday int `json:"due_today"`
+	Overdue  int `json:"overdue"`
+	// Incomplete — ожидающие уведомления, у которых не хватает данных для выгрузки
+	Incomplete int `json:"incomplete"`
+}
+
+type MarkMigrationFiledInput struct {
+	BookingID      int    `json:"booking_id"`
+	GuestID        int    `json:"guest_id"`
+	ConfirmationNo string `json:"confirmation_no"`
+}
+
+// MigrationNoticeBatch is the XML document imported by the migration registration software
+type MigrationNoticeBatch struct {
+	XMLName     xml.Name          `xml:"migrationNotices"`
+	GeneratedAt string            `xml:"generatedAt,attr"`
+	Notices     []MigrationNotice `xml:"notice"`
+}
+
+type MigrationNotice struct {
+	BookingID       int    `xml:"bookingId"`
+	GuestID         int    `xml:"guestId"`
+	FullName        string `xml:"person>fullName"`
+	Citizenship     string `xml:"person>citizenship"`
+	DocumentType    string `xml:"document>type"`
+	DocumentNumber  string `xml:"document>number"`
+	VisaNumber      string `xml:"visa>number,omitempty"`
+	VisaExpires     string `xml:"visa>expires,omitempty"`
+	MigrationCardNo string `xml:"migrationCard>number"`
+	EntryDate       string `xml:"migrationCard>entryDate"`
+	CardExpires     string `xml:"migrationCard>expires,omitempty"`
+	Room            int    `xml:"stay>room"`
+	ArrivalDate     string `xml:"stay>arrivalDate"`
+	DepartureDate   string `xml:"stay>departureDate"`
+	Deadline        string `xml:"deadline"`
+}
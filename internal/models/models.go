@@ -11,9 +11,9 @@ type Booking struct {
 	CheckIn    *time.Time    `json:"check_in" db:"check_in"`
 	CheckOut   *time.Time    `json:"check_out" db:"check_out"`
 	BabyBed    bool          `json:"baby_bed" db:"baby_bed"`
-	BookingSum float64       `json:"booking_sum" db:"booking_sum"`
+	BookingSum Money         `json:"booking_sum" db:"booking_sum"`
 	DiscountID int           `json:"discount_id" db:"discount_id"`
-	TotalSum   float64       `json:"total_sum" db:"total_sum"`
+	TotalSum   Money         `json:"total_sum" db:"total_sum"`
 	Status     BookingStatus `json:"status" db:"status"`
 	Discount   Discount      `json:"discount" db:"discount"`
 	Complaints []Complaint   `json:"complaints" db:"complaints"`
@@ -32,20 +32,52 @@ type CreateBookingInput struct {
 	GuestName           string  `json:"guest_name"`
 	GuestPassportNumber string  `json:"guest_passport_number"`
 	GuestPhoneNumber    string  `json:"guest_phone_number"`
+	GuestEmail          string  `json:"guest_email"`
+	GuestDocumentType   string  `json:"guest_document_type"`
+	GuestNationality    string  `json:"guest_nationality"`
 	MethodCode          int     `json:"payment_method_code"`
+	Adults              int     `json:"adults"`
+	Children            int     `json:"children"`
+	// CompanyID — организация, которой выставляется счёт вместо гостя
+	CompanyID *int `json:"company_id"`
+	// BlacklistOverride — обоснование бронирования гостя из чёрного списка, только для администратора
+	BlacklistOverride string `json:"blacklist_override"`
+}
+
+// BookingQuote is the price calculation for a stay
+type BookingQuote struct {
+	Nights          int     `json:"nights"`
+	Adults          int     `json:"adults"`
+	Children        int     `json:"children"`
+	BasePrice       Money   `json:"base_price"`
+	BookingSum      Money   `json:"booking_sum"`
+	DiscountID      int     `json:"discount_id"`
+	DiscountPercent Decimal `json:"discount_percent"`
+	DiscountAmount  Money   `json:"discount_amount"`
+	// LoyaltyTier — уровень гостя в программе лояльности, его скидка входит в DiscountPercent
+	LoyaltyTier    string    `json:"loyalty_tier,omitempty"`
+	LoyaltyPercent Decimal   `json:"loyalty_percent"`
+	Taxes          []TaxLine `json:"taxes"`
+	TaxSum         Money     `json:"tax_sum"`
+	TotalSum       Money     `json:"total_sum"`
 }
 
 type BookingResponse struct {
 	ID             int        `json:"id"`
+	Reference      string     `json:"reference" db:"reference"`
 	StartDate      time.Time  `json:"start_date" db:"start_date"`
 	EndDate        time.Time  `json:"end_date" db:"end_date"`
 	CheckIn        *time.Time `json:"check_in" db:"check_in"`
 	CheckOut       *time.Time `json:"check_out" db:"check_out"`
 	BabyBed        bool       `json:"baby_bed" db:"baby_bed"`
-	BookingSum     float64    `json:"booking_sum" db:"booking_sum"`
-	TotalSum       float64    `json:"total_sum" db:"total_sum"`
+	BookingSum     Money      `json:"booking_sum" db:"booking_sum"`
+	TotalSum       Money      `json:"total_sum" db:"total_sum"`
+	TaxSum         Money      `json:"tax_sum" db:"tax_sum"`
 	BookingStatus  string     `json:"booking_status"`
-	DiscountAmount float64    `json:"discount_amount"`
+	DiscountAmount Decimal    `json:"discount_amount"`
+	ExtrasSum      Money      `json:"extras_sum" db:"extras_sum"`
+	Balance        Money      `json:"balance" db:"balance"`
+	CompanyID      *int       `json:"company_id" db:"company_id"`
 	Room           int        `json:"room"`
 	GuestName      string     `json:"guest_name"`
 }
@@ -71,7 +103,14 @@ type Complaint struct {
 type CreateComplaintInput struct {
 	Reason     string  `json:"reason"`
 	Commentary *string `json:"commentary"`
-	BookingID  *int    `json:"booking_id"`
+	// BookingID, Room и GuestID необязательны: жалоба может быть ни к чему не привязана
+	BookingID *int `json:"booking_id"`
+	Room      *int `json:"room"`
+	GuestID   *int `json:"guest_id"`
+	// Category и Priority необязательны: по умолчанию other и normal
+	Category   string `json:"category"`
+	Priority   string `json:"priority"`
+	AssignedTo *int   `json:"assigned_to"`
 }
 
 type ComplaintResponse struct {
@@ -81,17 +120,35 @@ type ComplaintResponse struct {
 	IssueDate  time.Time `json:"issue_date"`
 	BookingID  *int      `json:"booking_id"`
 	Status     string    `json:"status"`
-	Room       int       `json:"room"`
+	StatusCode int       `json:"status_code"`
+	// Room — номер из жалобы, а если он не указан — номер из бронирования
+	Room         *int       `json:"room"`
+	GuestID      *int       `json:"guest_id"`
+	GuestName    *string    `json:"guest_name"`
+	Category     string     `json:"category"`
+	CategoryName string     `json:"category_name"`
+	Priority     string     `json:"priority"`
+	AssignedTo   *int       `json:"assigned_to"`
+	AssigneeName *string    `json:"assignee_name"`
+	SLADueAt     time.Time  `json:"sla_due_at"`
+	ResolvedAt   *time.Time `json:"resolved_at"`
+	EscalatedAt  *time.Time `json:"escalated_at"`
+	// CompensationTotal — сумма всех компенсаций, выданных по жалобе
+	CompensationTotal Money `json:"compensation_total"`
+	// Overdue — жалоба не решена, а срок SLA прошёл
+	Overdue bool `json:"overdue"`
 }
 
 type UpdateComplaintRequest struct {
 	ID         int     `json:"id"`
 	Reason     string  `json:"reason"`
 	Commentary *string `json:"commentary"`
-	IssueDate  string  `json:"issue_date"`
 	BookingID  *int    `json:"booking_id"`
 	Status     string  `json:"status"`
 	Room       int     `json:"room"`
+	// Category и Priority необязательны: пустое значение оставляет прежнее
+	Category string `json:"category"`
+	Priority string `json:"priority"`
 }
 
 // ComplaintStatus represents the complaints_statuses table
@@ -104,44 +161,85 @@ type ComplaintStatus struct {
 type Discount struct {
 	ID        int     `json:"id" db:"id"`
 	MinNights int     `json:"min_nights" db:"min_nights"`
-	Amount    float64 `json:"amount" db:"amount"`
+	Amount    Decimal `json:"amount" db:"amount"`
 }
 
 // Guest represents the guests table
 type Guest struct {
-	ID          int       `json:"id"`
-	Name        string    `json:"name"`
-	PhoneNumber string    `json:"phone_number"`
-	PassportNo  string    `json:"passport_no"`
-	Bookings    []Booking `json:"bookings"`
+	ID           int        `json:"id"`
+	Name         string     `json:"name"`
+	PhoneNumber  string     `json:"phone_number"`
+	Email        *string    `json:"email"`
+	PassportNo   string     `json:"passport_no"`
+	PassportEnc  *string    `json:"-"`
+	DocumentType string     `json:"document_type"`
+	Nationality  *string    `json:"nationality"`
+	ErasedAt     *time.Time `json:"erased_at"`
+	MigrationDocuments
+	Bookings []Booking `json:"bookings,omitempty"`
 }
 
 // Payment represents the payments table
 type Payment struct {
-	ID         int           `json:"id"`
-	BookingID  int           `json:"booking_id"`
-	PayDate    time.Time     `json:"pay_date"`
-	Amount     float64       `json:"amount"`
-	MethodCode int           `json:"method_code"`
-	StatusCode int           `json:"status_code"`
-	Booking    Booking       `json:"booking"`
-	Method     PaymentMethod `json:"method"`
-	Status     PaymentStatus `json:"status"`
+	ID               int            `json:"id"`
+	BookingID        int            `json:"booking_id"`
+	PayDate          time.Time      `json:"pay_date"`
+	Amount           Money          `json:"amount"`
+	OriginalAmount   CurrencyAmount `json:"original_amount"`
+	OriginalCurrency string         `json:"original_currency"`
+	ExchangeRate     Decimal        `json:"exchange_rate"`
+	MethodCode       int            `json:"method_code"`
+	StatusCode       int            `json:"status_code"`
+	Booking          Booking        `json:"booking"`
+	Method           PaymentMethod  `json:"method"`
+	Status           PaymentStatus  `json:"status"`
+}
+
+type CreatePaymentInput struct {
+	BookingID  int   `json:"booking_id"`
+	Amount     Money `json:"amount"`
+	MethodCode int   `json:"payment_method_code"`
+	// Currency — валюта, в которой гость платит; пусто — базовая валюта отеля
+	Currency string `json:"currency"`
+	// VoucherCode и Nights — для оплаты подарочным сертификатом
+	VoucherCode string `json:"voucher_code"`
+	Nights      int    `json:"nights"`
 }
 
 type PaymentResponse struct {
-	ID         int       `json:"id"`
-	BookingID  int       `json:"booking_id"`
-	PayDate    time.Time `json:"pay_date"`
-	Amount     float64   `json:"amount"`
-	MethodName string    `json:"method_name"`
-	StatusName string    `json:"status_name"`
+	ID               int            `json:"id"`
+	BookingID        int            `json:"booking_id"`
+	PayDate          time.Time      `json:"pay_date"`
+	Amount           Money          `json:"amount"`
+	OriginalAmount   CurrencyAmount `json:"original_amount"`
+	OriginalCurrency string         `json:"original_currency"`
+	ExchangeRate     Decimal        `json:"exchange_rate"`
+	MethodName       string         `json:"method_name"`
+	StatusName       string         `json:"status_name"`
+}
+
+// ExchangeRate represents the exchange_rates table: how many base currency units one unit of Currency costs
+type ExchangeRate struct {
+	ID            int       `json:"id" db:"id"`
+	Currency      string    `json:"currency" db:"currency"`
+	Rate          Decimal   `json:"rate" db:"rate"`
+	EffectiveDate time.Time `json:"effective_date" db:"effective_date"`
+	CreatedBy     *int      `json:"created_by" db:"created_by"`
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+}
+
+type ExchangeRateInput struct {
+	Currency      string  `json:"currency"`
+	Rate          Decimal `json:"rate"`
+	EffectiveDate string  `json:"effective_date"`
 }
 
 // PaymentMethod represents the payment_methods table
 type PaymentMethod struct {
-	Code int    `json:"code"`
-	Name string `json:"name"`
+	Code      int    `json:"code"`
+	Name      string `json:"name"`
+	IsVoucher bool   `json:"is_voucher"`
+	IsLoyalty bool   `json:"is_loyalty"`
 }
 
 // PaymentStatus represents the payment_statuses table
@@ -155,6 +253,7 @@ type Room struct {
 	Number       int          `json:"number"`
 	CategoryCode int          `json:"category_code"`
 	StateCode    int          `json:"state_code"`
+	Active       bool         `json:"active"`
 	Category     RoomCategory `json:"category"`
 	State        RoomState    `json:"state"`
 }
@@ -164,6 +263,7 @@ type RoomCategory struct {
 	Code     int    `json:"code"`
 	Name     string `json:"name"`
 	Capacity int    `json:"capacity"`
+	Active   bool   `json:"active"`
 }
 
 // RoomState represents the room_states table
@@ -176,7 +276,7 @@ type RoomState struct {
 type Tariff struct {
 	Code         int               `json:"code"`
 	CategoryCode int               `json:"category_code"`
-	BasePrice    float64           `json:"base_price"`
+	BasePrice    Money             `json:"base_price"`
 	DayCode      int               `json:"day_code"`
 	Category     RoomCategory      `json:"category"`
 	Coefficient  TariffCoefficient `json:"coefficient"`
@@ -185,7 +285,7 @@ type Tariff struct {
 // TariffCoefficient represents the tariff_coefficients table
 type TariffCoefficient struct {
 	DayCode     int     `json:"day_code"`
-	Coefficient float64 `json:"coefficient"`
+	Coefficient Decimal `json:"coefficient"`
 }
 
 // Holiday represents the holidays table
@@ -195,22 +295,41 @@ type Holiday struct {
 }
 
 type SetMetricsResponse struct {
-	Occupancy             int `json:"occupancy"`
-	UnpaidBookings        int `json:"unpaid_bookings"`
-	CurrentBookings       int `json:"current_bookings"`
-	OpenComplaints        int `json:"open_complaints"`
-	FreeRooms             int `json:"free_rooms"`
-	RoomsUnderMaintenance int `json:"rooms_under_maintenance"`
-	Revenue7Days          int `json:"revenue_7_days"`
-	RevPar                int `json:"revpar"`
-	NewGuests7Days        int `json:"new_guests_7_days"`
-	RevPac                int `json:"revpac"`
+	Occupancy       int `json:"occupancy"`
+	UnpaidBookings  int `json:"unpaid_bookings"`
+	CurrentBookings int `json:"current_bookings"`
+	OpenComplaints  int `json:"open_complaints"`
+	// EscalatedComplaints — нерешённые жалобы с истёкшим SLA, переданные менеджерам
+	EscalatedComplaints   int   `json:"escalated_complaints"`
+	FreeRooms             int   `json:"free_rooms"`
+	RoomsUnderMaintenance int   `json:"rooms_under_maintenance"`
+	Revenue7Days          Money `json:"revenue_7_days"`
+	RevPar                Money `json:"revpar"`
+	NewGuests7Days        int   `json:"new_guests_7_days"`
+	RevPac                Money `json:"revpac"`
+	// Currency — базовая валюта, в которой агрегированы денежные показатели
+	Currency string `json:"currency"`
+	// MigrationDeadlines — сроки подачи уведомлений о прибытии иностранных гостей
+	MigrationDeadlines MigrationDeadlines `json:"migration_deadlines"`
 }
 
 type User struct {
 	ID       int    `json:"user_id"`
 	Username string `json:"username"`
 	Hash     string `json:"hash"`
+	Role     string `json:"role"`
+}
+
+const (
+	RoleAdmin        = "admin"
+	RoleManager      = "manager"
+	RoleReceptionist = "receptionist"
+	RoleHousekeeper  = "housekeeper"
+)
+
+type SetUserRoleInput struct {
+	Username string `json:"username"`
+	Role     string `json:"role"`
 }
 
 type UserRequestBody struct {
@@ -0,0 +1,456 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// RoundingMode задаёт правило округления при умножении и делении сумм
+type RoundingMode int
+
+const (
+	// RoundHalfUp — арифметическое округление (0.5 копейки вверх по модулю)
+	RoundHalfUp RoundingMode = iota
+	// RoundHalfEven — банковское округление (0.5 копейки к чётному)
+	RoundHalfEven
+	// RoundDown — отбрасывание дробной части копеек
+	RoundDown
+)
+
+const (
+	moneyScale   = 2 // знаков после запятой у всех поддерживаемых валют
+	decimalScale = 6 // знаков после запятой у ставок, процентов и курсов
+)
+
+// ErrMoneyOverflow возвращается, если результат не помещается в int64
+var ErrMoneyOverflow = errors.New("money: amount out of range")
+
+// ErrDivisionByZero возвращается при делении суммы на ноль
+var ErrDivisionByZero = errors.New("money: division by zero")
+
+// DefaultCurrency — базовая валюта отеля, в которой хранятся и считаются все суммы Money
+var DefaultCurrency = "RUB"
+
+// Money is a fixed-point amount in the hotel base currency stored in minor units (kopecks, cents).
+// Amounts in other currencies are kept as CurrencyAmount, so they cannot be mixed by accident.
+type Money struct {
+	minor int64
+}
+
+// CurrencyAmount is an amount in an explicit currency, such as what a guest actually paid.
+// It has no arithmetic: convert it to Money before adding it to hotel amounts.
+type CurrencyAmount struct {
+	minor    int64
+	currency string
+}
+
+// Decimal is a fixed-point number with six fractional digits used for rates, percents and coefficients
+type Decimal struct {
+	micro int64
+}
+
+func NewMoney(minor int64) Money {
+	return Money{minor: minor}
+}
+
+// ParseMoney разбирает сумму вида "1234.50"; лишние знаки округляются по RoundHalfUp
+func ParseMoney(s string) (Money, error) {
+	minor, err := parseFixed(s, moneyScale, RoundHalfUp)
+	if err != nil {
+		return Money{}, fmt.Errorf("invalid money amount %q: %v", s, err)
+	}
+	return Money{minor: minor}, nil
+}
+
+func (m Money) Minor() int64 {
+	return m.minor
+}
+
+func (m Money) IsZero() bool     { return m.minor == 0 }
+func (m Money) IsPositive() bool { return m.minor > 0 }
+func (m Money) IsNegative() bool { return m.minor < 0 }
+
+// Add складывает суммы; переполнение при сложении — ошибка программы и приводит к панике
+func (m Money) Add(o Money) Money {
+	sum, ok := addInt64(m.minor, o.minor)
+	if !ok {
+		panic(ErrMoneyOverflow)
+	}
+	return Money{minor: sum}
+}
+
+// Sub вычитает сумму; переполнение, как и в Add, приводит к панике
+func (m Money) Sub(o Money) Money {
+	if o.minor == math.MinInt64 {
+		panic(ErrMoneyOverflow)
+	}
+	return m.Add(Money{minor: -o.minor})
+}
+
+func (m Money) Neg() Money {
+	return Money{minor: -m.minor}
+}
+
+// Mul умножает сумму на целое число, например цену на количество
+func (m Money) Mul(n int64) (Money, error) {
+	product, ok := mulInt64(m.minor, n)
+	if !ok {
+		return Money{}, ErrMoneyOverflow
+	}
+	return Money{minor: product}, nil
+}
+
+// Cmp возвращает -1, 0 или 1 в зависимости от того, меньше, равна или больше сумма m суммы o
+func (m Money) Cmp(o Money) int {
+	switch {
+	case m.minor < o.minor:
+		return -1
+	case m.minor > o.minor:
+		return 1
+	}
+	return 0
+}
+
+// MulDecimal умножает сумму на коэффициент с округлением до копеек
+func (m Money) MulDecimal(d Decimal, mode RoundingMode) (Money, error) {
+	return m.MulRatio(d, DecimalFromInt(1), mode)
+}
+
+// MulRatio вычисляет m * num / den с округлением до копеек
+func (m Money) MulRatio(num, den Decimal, mode RoundingMode) (Money, error) {
+	if den.micro == 0 {
+		return Money{}, ErrDivisionByZero
+	}
+	n := new(big.Int).Mul(big.NewInt(m.minor), big.NewInt(num.micro))
+	d := big.NewInt(den.micro)
+	if !new(big.Int).Quo(n, d).IsInt64() {
+		return Money{}, ErrMoneyOverflow
+	}
+	return Money{minor: divRound(n, d, mode)}, nil
+}
+
+// Percent возвращает p процентов от суммы
+func (m Money) Percent(p Decimal, mode RoundingMode) (Money, error) {
+	return m.MulRatio(p, DecimalFromInt(100), mode)
+}
+
+// Allocate делит сумму на n частей без потери копеек: остаток достаётся первым частям
+func (m Money) Allocate(n int) []Money {
+	if n <= 0 {
+		return nil
+	}
+	parts := make([]Money, n)
+	share := m.minor / int64(n)
+	rest := m.minor % int64(n)
+	for i := range parts {
+		parts[i] = Money{minor: share}
+		switch {
+		case rest > 0:
+			parts[i].minor++
+			rest--
+		case rest < 0:
+			parts[i].minor--
+			rest++
+		}
+	}
+	return parts
+}
+
+func (m Money) String() string {
+	return formatFixed(m.minor, moneyScale)
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+func (m *Money) UnmarshalJSON(data []byte) error {
+	// null, как и в encoding/json, оставляет значение без изменений
+	if string(data) == "null" {
+		return nil
+	}
+	s, err := unquoteNumber(data)
+	if err != nil {
+		return err
+	}
+	parsed, err := ParseMoney(s)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// Scan реализует sql.Scanner для колонок NUMERIC и FLOAT
+func (m *Money) Scan(src interface{}) error {
+	minor, err := scanFixed(src, moneyScale)
+	if err != nil {
+		return fmt.Errorf("cannot scan money: %v", err)
+	}
+	m.minor = minor
+	return nil
+}
+
+// Value реализует driver.Valuer: сумма передаётся в БД строкой, без потери точности
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
+
+func NewCurrencyAmount(amount Money, currency string) CurrencyAmount {
+	return CurrencyAmount{minor: amount.minor, currency: currency}
+}
+
+func (a CurrencyAmount) Minor() int64 {
+	return a.minor
+}
+
+func (a CurrencyAmount) Currency() string {
+	return a.currency
+}
+
+// WithCurrency задаёт валюту суммы, прочитанной из БД: валюта хранится в отдельной колонке
+func (a CurrencyAmount) WithCurrency(currency string) CurrencyAmount {
+	return CurrencyAmount{minor: a.minor, currency: currency}
+}
+
+func (a CurrencyAmount) String() string {
+	return formatFixed(a.minor, moneyScale) + " " + a.currency
+}
+
+type currencyAmountJSON struct {
+	Amount   Money  `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+// MarshalJSON отдаёт сумму вместе с валютой: {"amount": 12.50, "currency": "EUR"}
+func (a CurrencyAmount) MarshalJSON() ([]byte, error) {
+	return json.Marshal(currencyAmountJSON{Amount: Money{minor: a.minor}, Currency: a.currency})
+}
+
+func (a *CurrencyAmount) UnmarshalJSON(data []byte) error {
+	var v currencyAmountJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*a = CurrencyAmount{minor: v.Amount.minor, currency: v.Currency}
+	return nil
+}
+
+// Scan читает только сумму; валюту задаёт WithCurrency
+func (a *CurrencyAmount) Scan(src interface{}) error {
+	minor, err := scanFixed(src, moneyScale)
+	if err != nil {
+		return fmt.Errorf("cannot scan money: %v", err)
+	}
+	a.minor = minor
+	return nil
+}
+
+func (a CurrencyAmount) Value() (driver.Value, error) {
+	return formatFixed(a.minor, moneyScale), nil
+}
+
+// DecimalFromInt — целое число для констант в коде; выход за диапазон, как и в MustParseDecimal, приводит к панике
+func DecimalFromInt(n int64) Decimal {
+	micro, ok := mulInt64(n, pow10(decimalScale))
+	if !ok {
+		panic(ErrMoneyOverflow)
+	}
+	return Decimal{micro: micro}
+}
+
+func ParseDecimal(s string) (Decimal, error) {
+	micro, err := parseFixed(s, decimalScale, RoundHalfUp)
+	if err != nil {
+		return Decimal{}, fmt.Errorf("invalid decimal %q: %v", s, err)
+	}
+	return Decimal{micro: micro}, nil
+}
+
+// MustParseDecimal — ParseDecimal для констант в коде
+func MustParseDecimal(s string) Decimal {
+	d, err := ParseDecimal(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func (d Decimal) IsZero() bool { return d.micro == 0 }
+
+func (d Decimal) Sign() int {
+	switch {
+	case d.micro < 0:
+		return -1
+	case d.micro > 0:
+		return 1
+	}
+	return 0
+}
+
+func (d Decimal) Cmp(o Decimal) int {
+	switch {
+	case d.micro < o.micro:
+		return -1
+	case d.micro > o.micro:
+		return 1
+	}
+	return 0
+}
+
+func (d Decimal) Add(o Decimal) Decimal {
+	sum, ok := addInt64(d.micro, o.micro)
+	if !ok {
+		panic(ErrMoneyOverflow)
+	}
+	return Decimal{micro: sum}
+}
+
+func (d Decimal) String() string {
+	s := formatFixed(d.micro, decimalScale)
+	s = strings.TrimRight(s, "0")
+	return strings.TrimSuffix(s, ".")
+}
+
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	s, err := unquoteNumber(data)
+	if err != nil {
+		return err
+	}
+	parsed, err := ParseDecimal(s)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+func (d *Decimal) Scan(src interface{}) error {
+	micro, err := scanFixed(src, decimalScale)
+	if err != nil {
+		return fmt.Errorf("cannot scan decimal: %v", err)
+	}
+	d.micro = micro
+	return nil
+}
+
+func (d Decimal) Value() (driver.Value, error) {
+	return d.String(), nil
+}
+
+func pow10(n int) int64 {
+	p := int64(1)
+	for i := 0; i < n; i++ {
+		p *= 10
+	}
+	return p
+}
+
+// addInt64 складывает числа; ok = false при переполнении
+func addInt64(a, b int64) (int64, bool) {
+	sum := a + b
+	return sum, (sum > a) == (b > 0)
+}
+
+// mulInt64 перемножает числа; ok = false при переполнении
+func mulInt64(a, b int64) (int64, bool) {
+	if a == 0 || b == 0 {
+		return 0, true
+	}
+	product := a * b
+	if (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) || product/b != a {
+		return 0, false
+	}
+	return product, true
+}
+
+// divRound делит num на den (den > 0) с заданным правилом округления
+func divRound(num, den *big.Int, mode RoundingMode) int64 {
+	q, r := new(big.Int).QuoRem(num, den, new(big.Int))
+	if r.Sign() == 0 || mode == RoundDown {
+		return q.Int64()
+	}
+	// сравниваем удвоенный остаток с делителем, чтобы понять, больше ли он половины
+	twice := new(big.Int).Abs(r)
+	twice.Lsh(twice, 1)
+	cmp := twice.Cmp(den)
+	up := cmp > 0 || (cmp == 0 && (mode == RoundHalfUp || q.Bit(0) == 1))
+	if up {
+		if num.Sign() < 0 {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+	return q.Int64()
+}
+
+func parseFixed(s string, scale int, mode RoundingMode) (int64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, fmt.Errorf("empty value")
+	}
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return 0, fmt.Errorf("not a number")
+	}
+	num := new(big.Int).Mul(r.Num(), big.NewInt(pow10(scale)))
+	result := divRound(num, r.Denom(), mode)
+	if !new(big.Int).Quo(num, r.Denom()).IsInt64() {
+		return 0, fmt.Errorf("value out of range")
+	}
+	return result, nil
+}
+
+func formatFixed(v int64, scale int) string {
+	sign := ""
+	u := uint64(v)
+	if v < 0 {
+		sign = "-"
+		u = uint64(-v)
+	}
+	p := uint64(pow10(scale))
+	return fmt.Sprintf("%s%d.%0*d", sign, u/p, scale, u%p)
+}
+
+func scanFixed(src interface{}, scale int) (int64, error) {
+	switch v := src.(type) {
+	case nil:
+		return 0, nil
+	case int64:
+		return v * pow10(scale), nil
+	case float64:
+		// кратчайшее десятичное представление, без двоичного хвоста float
+		return parseFixed(strconv.FormatFloat(v, 'f', -1, 64), scale, RoundHalfUp)
+	case string:
+		return parseFixed(v, scale, RoundHalfUp)
+	case []byte:
+		return parseFixed(string(v), scale, RoundHalfUp)
+	}
+	return 0, fmt.Errorf("unsupported type %T", src)
+}
+
+// unquoteNumber принимает число как JSON-число или строку; null обрабатывает вызывающий
+func unquoteNumber(data []byte) (string, error) {
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return "", err
+		}
+		return s, nil
+	}
+	return string(data), nil
+}
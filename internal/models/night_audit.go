@@ -0,0 +1,61 @@
+package models
+
+import "time"
+
+// NightAuditCharge is a room night posted to a booking during the night audit
+type NightAuditCharge struct {
+	BookingID int   `json:"booking_id"`
+	Room      int   `json:"room"`
+	Amount    Money `json:"amount"`
+	Discount  Money `json:"discount"`
+	Tax       Money `json:"tax"`
+}
+
+// NoShowCandidate is an arrival for the business date that never checked in
+type NoShowCandidate struct {
+	BookingID int       `json:"booking_id" db:"booking_id"`
+	Room      int       `json:"room" db:"room"`
+	GuestName string    `json:"guest_name" db:"guest_name"`
+	StartDate time.Time `json:"start_date" db:"start_date"`
+}
+
+// PaymentDiscrepancy is a payment whose status does not match its ledger postings
+type PaymentDiscrepancy struct {
+	PaymentID int    `json:"payment_id" db:"payment_id"`
+	BookingID int    `json:"booking_id" db:"booking_id"`
+	Status    string `json:"status" db:"status"`
+	Expected  Money  `json:"expected" db:"expected"`
+	Posted    Money  `json:"posted" db:"posted"`
+}
+
+type PaymentTotal struct {
+	AccountCode string `json:"account_code" db:"account_code"`
+	Received    Money  `json:"received" db:"received"`
+	Refunded    Money  `json:"refunded" db:"refunded"`
+}
+
+// NightAuditReport is the end-of-day snapshot stored in business_days.report
+type NightAuditReport struct {
+	BusinessDate     time.Time            `json:"business_date"`
+	ClosedAt         time.Time            `json:"closed_at"`
+	ClosedBy         *int                 `json:"closed_by"`
+	Forced           bool                 `json:"forced"`
+	Currency         string               `json:"currency"`
+	RoomsTotal       int                  `json:"rooms_total"`
+	RoomsOccupied    int                  `json:"rooms_occupied"`
+	RoomCharges      []NightAuditCharge   `json:"room_charges"`
+	RoomRevenue      Money                `json:"room_revenue"`
+	ExtrasRevenue    Money                `json:"extras_revenue"`
+	NoShows          []NoShowCandidate    `json:"no_shows"`
+	Payments         []PaymentTotal       `json:"payments"`
+	UnconfirmedCount int                  `json:"unconfirmed_payments"`
+	Discrepancies    []PaymentDiscrepancy `json:"discrepancies"`
+}
+
+// BusinessDay represents the business_days table without the report body
+type BusinessDay struct {
+	BusinessDate time.Time `json:"business_date" db:"business_date"`
+	ClosedAt     time.Time `json:"closed_at" db:"closed_at"`
+	ClosedBy     *int      `json:"closed_by" db:"closed_by"`
+	Forced       bool      `json:"forced" db:"forced"`
+}
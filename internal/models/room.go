@@ -0,0 +1,76 @@
+package models
+
+import "time"
+
+// коды room_states
+const (
+	RoomStateFree        = 1
+	RoomStateOccupied    = 2
+	RoomStateMaintenance = 3
+)
+
+type RoomInput struct {
+	Number       int `json:"number"`
+	CategoryCode int `json:"category_code"`
+	// StateCode необязателен: новый номер свободен, при изменении пустое значение оставляет прежнее
+	StateCode *int `json:"state_code"`
+}
+
+type RoomCategoryInput struct {
+	Name     string `json:"name"`
+	Capacity int    `json:"capacity"`
+}
+
+type TariffInput struct {
+	CategoryCode int   `json:"category_code"`
+	BasePrice    Money `json:"base_price"`
+	DayCode      int   `json:"day_code"`
+}
+
+// статусы уборки номера
+const (
+	HousekeepingDirty     = "dirty"
+	HousekeepingCleaning  = "cleaning"
+	HousekeepingClean     = "clean"
+	HousekeepingInspected = "inspected"
+)
+
+// виды заданий на уборку
+const (
+	HousekeepingTaskDeparture = "departure"
+	HousekeepingTaskStayover  = "stayover"
+)
+
+// HousekeepingTask is a room cleaning job for a single day.
+type HousekeepingTask struct {
+	ID       int       `json:"id" db:"id"`
+	TaskDate time.Time `json:"task_date" db:"task_date"`
+	Room     int       `json:"room" db:"room"`
+	// BookingID — бронирование, из-за которого появилось задание; пусто, если оно удалено
+	BookingID *int   `json:"booking_id" db:"booking_id"`
+	Kind      string `json:"kind" db:"kind"`
+	// Status — текущий статус уборки номера
+	Status      string     `json:"status" db:"status"`
+	AssignedTo  *int       `json:"assigned_to" db:"assigned_to"`
+	Assignee    *string    `json:"assignee" db:"assignee"`
+	CompletedAt *time.Time `json:"completed_at" db:"completed_at"`
+	CompletedBy *int       `json:"completed_by" db:"completed_by"`
+}
+
+// HousekeepingGenerateResult reports how many tasks were created for a day.
+type HousekeepingGenerateResult struct {
+	TaskDate   time.Time `json:"task_date"`
+	Departures int       `json:"departures"`
+	Stayovers  int       `json:"stayovers"`
+}
+
+type RoomHousekeepingInput struct {
+	Room   int    `json:"room"`
+	Status string `json:"status"`
+}
+
+type AssignHousekeepingTaskInput struct {
+	TaskID int `json:"task_id"`
+	// UserID — горничная; пустое значение снимает назначение
+	UserID *int `json:"user_id"`
+}
@@ -0,0 +1,71 @@
+package models
+
+import "time"
+
+const (
+	ChargeTypeRoom    = "room"
+	ChargeTypeService = "service"
+
+	TaxCalcPercent = "percent"
+	TaxCalcFixed   = "fixed"
+
+	TaxBasisStay       = "stay"
+	TaxBasisNight      = "night"
+	TaxBasisGuestNight = "guest_night"
+)
+
+// TaxRule represents the tax_rules table
+type TaxRule struct {
+	ID             int        `json:"id" db:"id"`
+	Name           string     `json:"name" db:"name"`
+	ChargeType     string     `json:"charge_type" db:"charge_type"`
+	Calc           string     `json:"calc" db:"calc"`
+	Rate           Decimal    `json:"rate" db:"rate"`
+	Amount         Money      `json:"amount" db:"amount"`
+	Inclusive      bool       `json:"inclusive" db:"inclusive"`
+	Basis          string     `json:"basis" db:"basis"`
+	ExemptChildren bool       `json:"exempt_children" db:"exempt_children"`
+	Active         bool       `json:"active" db:"active"`
+	ValidFrom      time.Time  `json:"valid_from" db:"valid_from"`
+	ValidTo        *time.Time `json:"valid_to" db:"valid_to"`
+}
+
+type TaxRuleInput struct {
+	Name           string  `json:"name"`
+	ChargeType     string  `json:"charge_type"`
+	Calc           string  `json:"calc"`
+	Rate           Decimal `json:"rate"`
+	Amount         Money   `json:"amount"`
+	Inclusive      bool    `json:"inclusive"`
+	Basis          string  `json:"basis"`
+	ExemptChildren bool    `json:"exempt_children"`
+	Active         *bool   `json:"active"`
+	ValidFrom      string  `json:"valid_from"`
+	ValidTo        *string `json:"valid_to"`
+}
+
+// TaxLine is one tax applied to a charge; inclusive taxes are already part of the price
+type TaxLine struct {
+	RuleID     *int   `json:"rule_id" db:"tax_rule_id"`
+	Name       string `json:"name" db:"name"`
+	ChargeType string `json:"charge_type" db:"charge_type"`
+	Taxable    Money  `json:"taxable" db:"taxable"`
+	Amount     Money  `json:"amount" db:"amount"`
+	Inclusive  bool   `json:"inclusive" db:"inclusive"`
+}
+
+type TaxSummaryRow struct {
+	Name       string `json:"name" db:"name"`
+	ChargeType string `json:"charge_type" db:"charge_type"`
+	Inclusive  bool   `json:"inclusive" db:"inclusive"`
+	Taxable    Money  `json:"taxable" db:"taxable"`
+	Amount     Money  `json:"amount" db:"amount"`
+}
+
+type TaxSummary struct {
+	From     time.Time       `json:"from"`
+	To       time.Time       `json:"to"`
+	Currency string          `json:"currency"`
+	Rows     []TaxSummaryRow `json:"rows"`
+	Total    Money           `json:"total"`
+}
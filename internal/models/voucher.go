@@ -0,0 +1,64 @@
+package models
+
+import "time"
+
+const (
+	VoucherKindValue  = "value"
+	VoucherKindNights = "nights"
+)
+
+// Voucher represents the vouchers table
+type Voucher struct {
+	ID              int       `json:"id" db:"id"`
+	Code            string    `json:"code" db:"code"`
+	Kind            string    `json:"kind" db:"kind"`
+	Value           Money     `json:"value" db:"value"`
+	Balance         Money     `json:"balance" db:"balance"`
+	Nights          *int      `json:"nights" db:"nights"`
+	NightsRemaining *int      `json:"nights_remaining" db:"nights_remaining"`
+	SoldTo          *string   `json:"sold_to" db:"sold_to"`
+	IssuedAt        time.Time `json:"issued_at" db:"issued_at"`
+	ExpiresAt       time.Time `json:"expires_at" db:"expires_at"`
+	CreatedBy       *int      `json:"created_by" db:"created_by"`
+}
+
+type IssueVoucherInput struct {
+	// Code — необязателен, по умолчанию генерируется
+	Code string `json:"code"`
+	Kind string `json:"kind"`
+	// Value — сумма, которую платит покупатель сертификата
+	Value      Money   `json:"value"`
+	Nights     int     `json:"nights"`
+	ExpiresAt  string  `json:"expires_at"`
+	SoldTo     *string `json:"sold_to"`
+	MethodCode int     `json:"payment_method_code"`
+}
+
+// VoucherRedemption represents the voucher_redemptions table
+type VoucherRedemption struct {
+	ID         int        `json:"id" db:"id"`
+	VoucherID  int        `json:"voucher_id" db:"voucher_id"`
+	PaymentID  int        `json:"payment_id" db:"payment_id"`
+	BookingID  int        `json:"booking_id" db:"booking_id"`
+	Amount     Money      `json:"amount" db:"amount"`
+	Value      Money      `json:"value" db:"value"`
+	Nights     int        `json:"nights" db:"nights"`
+	RedeemedAt time.Time  `json:"redeemed_at" db:"redeemed_at"`
+	ReversedAt *time.Time `json:"reversed_at" db:"reversed_at"`
+}
+
+type VoucherDetails struct {
+	Voucher
+	Redemptions []VoucherRedemption `json:"redemptions"`
+}
+
+// VoucherLiability is the outstanding balance of sold vouchers on a date
+type VoucherLiability struct {
+	AsOf     time.Time `json:"as_of"`
+	Currency string    `json:"currency"`
+	Vouchers []Voucher `json:"vouchers"`
+	// Outstanding — остаток по действующим сертификатам
+	Outstanding Money `json:"outstanding"`
+	// Expired — непогашенный остаток по истёкшим сертификатам
+	Expired Money `json:"expired"`
+}
@@ -0,0 +1,33 @@
+package services
+
+import (
+	"mis_kursach_backend/internal/models"
+	"time"
+)
+
+// AgeInvoices раскладывает неоплаченные счета по организациям и срокам просрочки на дату asOf.
+// Счета ожидаются упорядоченными по организации.
+func AgeInvoices(invoices []models.OutstandingInvoice, asOf time.Time) []models.CompanyAging {
+	var result []models.CompanyAging
+	for _, inv := range invoices {
+		if len(result) == 0 || result[len(result)-1].CompanyID != inv.CompanyID {
+			result = append(result, models.CompanyAging{CompanyID: inv.CompanyID, CompanyName: inv.CompanyName})
+		}
+		a := &result[len(result)-1]
+		overdue := int(asOf.Sub(inv.DueDate).Hours() / 24)
+		switch {
+		case overdue <= 0:
+			a.Current = a.Current.Add(inv.Outstanding)
+		case overdue <= 30:
+			a.Days1To30 = a.Days1To30.Add(inv.Outstanding)
+		case overdue <= 60:
+			a.Days31To60 = a.Days31To60.Add(inv.Outstanding)
+		case overdue <= 90:
+			a.Days61To90 = a.Days61To90.Add(inv.Outstanding)
+		default:
+			a.Over90 = a.Over90.Add(inv.Outstanding)
+		}
+		a.Total = a.Total.Add(inv.Outstanding)
+	}
+	return result
+}
@@ -0,0 +1,75 @@
+package services
+
+import (
+	"mis_kursach_backend/internal/models"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// AttachmentMaxSize — наибольший размер загружаемого файла, 10 МБ
+const AttachmentMaxSize = 10 << 20
+
+// допустимые типы файлов определяются по содержимому, а не по расширению или заголовку запроса
+var attachmentContentTypes = []string{"image/jpeg", "image/png", "image/webp", "application/pdf"}
+
+// attachmentViewRoles — кто может загружать, видеть и скачивать вложения каждого вида.
+// Сканы документов содержат паспортные данные, поэтому горничным доступны только прочие файлы.
+var attachmentViewRoles = map[string][]string{
+	models.AttachmentPassportScan:     {models.RoleAdmin, models.RoleManager, models.RoleReceptionist},
+	models.AttachmentRegistrationCard: {models.RoleAdmin, models.RoleManager, models.RoleReceptionist},
+	models.AttachmentOther:            {models.RoleAdmin, models.RoleManager, models.RoleReceptionist, models.RoleHousekeeper},
+}
+
+// SniffAttachment определяет тип файла по первым байтам; false, если такой тип не принимается
+func SniffAttachment(head []byte) (string, bool) {
+	contentType, _, _ := strings.Cut(http.DetectContentType(head), ";")
+	return contentType, slices.Contains(attachmentContentTypes, contentType)
+}
+
+func IsAttachmentKind(kind string) bool {
+	_, ok := attachmentViewRoles[kind]
+	return ok
+}
+
+func CanAccessAttachment(role, kind string) bool {
+	return slices.Contains(attachmentViewRoles[kind], role)
+}
+
+// CanDeleteAttachment — удалять вложения могут только администратор и менеджер
+func CanDeleteAttachment(role string) bool {
+	return role == models.RoleAdmin || role == models.RoleManager
+}
+
+// AccessibleAttachmentKinds — виды вложений, которые роль видит в списке
+func AccessibleAttachmentKinds(role string) []string {
+	kinds := []string{}
+	for kind := range attachmentViewRoles {
+		if CanAccessAttachment(role, kind) {
+			kinds = append(kinds, kind)
+		}
+	}
+	slices.Sort(kinds)
+	return kinds
+}
+
+// SanitizeFileName оставляет от имени файла только базовое имя без управляющих символов и кавычек,
+// чтобы его можно было безопасно вернуть в Content-Disposition
+func SanitizeFileName(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, `\`, "/"))
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || r == '"' || r == '/' {
+			return -1
+		}
+		return r
+	}, name)
+	if name == "" || name == "." {
+		return "file"
+	}
+	if len([]rune(name)) > 200 {
+		name = string([]rune(name)[:200])
+	}
+	return name
+}
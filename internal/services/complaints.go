@@ -0,0 +1,101 @@
+package services
+
+import (
+	"math"
+	"mis_kursach_backend/internal/models"
+	"slices"
+	"time"
+)
+
+// ComplaintEscalationInterval — как часто проверяются просроченные жалобы
+const ComplaintEscalationInterval = 15 * time.Minute
+
+// RepeatedComplaintThreshold — со скольких жалоб за период номер считается проблемным
+const RepeatedComplaintThreshold = 2
+
+// ComplaintAnalyticsMaxDays — наибольший период аналитики жалоб
+const ComplaintAnalyticsMaxDays = 731
+
+// complaintPriorityPercent — доля срока SLA категории для каждого приоритета
+var complaintPriorityPercent = map[string]int64{
+	models.ComplaintPriorityLow:    200,
+	models.ComplaintPriorityNormal: 100,
+	models.ComplaintPriorityHigh:   50,
+	models.ComplaintPriorityUrgent: 25,
+}
+
+// complaintTransitions — допустимые переходы статусов жалобы.
+// Решённую жалобу можно вернуть в работу, если гость снова обратился.
+var complaintTransitions = map[int][]int{
+	models.ComplaintStatusOpen:       {models.ComplaintStatusInProgress, models.ComplaintStatusResolved},
+	models.ComplaintStatusInProgress: {models.ComplaintStatusOpen, models.ComplaintStatusResolved},
+	models.ComplaintStatusResolved:   {models.ComplaintStatusInProgress},
+}
+
+func IsComplaintPriority(priority string) bool {
+	_, ok := complaintPriorityPercent[priority]
+	return ok
+}
+
+// ComplaintSLADue — срок решения жалобы: часы SLA категории, сокращённые или увеличенные по приоритету
+func ComplaintSLADue(issued time.Time, slaHours int, priority string) time.Time {
+	percent, ok := complaintPriorityPercent[priority]
+	if !ok {
+		percent = 100
+	}
+	return issued.Add(time.Duration(slaHours) * time.Hour * time.Duration(percent) / 100)
+}
+
+// CanTransitionComplaint сообщает, можно ли перевести жалобу из статуса from в статус to
+func CanTransitionComplaint(from, to int) bool {
+	return slices.Contains(complaintTransitions[from], to)
+}
+
+// ValidateCompensation проверяет, что для вида компенсации переданы нужные поля
+func ValidateCompensation(in models.CompensateComplaintInput) models.FieldErrors {
+	errs := models.FieldErrors{}
+	if in.ComplaintID <= 0 {
+		errs["complaint_id"] = "complaint_id is required"
+	}
+	switch in.Kind {
+	case models.CompensationFolioCredit, models.CompensationRefund:
+		if in.Amount == nil || !in.Amount.IsPositive() {
+			errs["amount"] = "amount must be positive"
+		}
+	case models.CompensationFreeNight:
+		if in.Amount != nil {
+			errs["amount"] = "free night amount is taken from the booking price"
+		}
+		if in.Nights < 0 {
+			errs["nights"] = "nights cannot be negative"
+		}
+	default:
+		errs["kind"] = "kind must be folio_credit, free_night or refund"
+	}
+	if (in.Kind == models.CompensationRefund) != (in.MethodCode != nil) {
+		errs["method_code"] = "method_code is required for refunds only"
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// ComplaintRate — жалоб на 100 номеро-ночей с точностью до сотых; без занятых ночей — 0
+func ComplaintRate(complaints, roomNights int) float64 {
+	if roomNights <= 0 {
+		return 0
+	}
+	return math.Round(float64(complaints)*100*100/float64(roomNights)) / 100
+}
+
+// RepeatedRooms оставляет номера, на которые пожаловались не меньше RepeatedComplaintThreshold раз
+func RepeatedRooms(rooms []models.ComplaintRoomStats) []models.ComplaintRoomStats {
+	repeated := []models.ComplaintRoomStats{}
+	for _, r := range rooms {
+		if r.Complaints >= RepeatedComplaintThreshold {
+			repeated = append(repeated, r)
+		}
+	}
+	return repeated
+}
@@ -0,0 +1,26 @@
+package services
+
+import (
+	"fmt"
+	"mis_kursach_backend/internal/models"
+	"strings"
+)
+
+// NormalizeCurrency приводит код валюты ISO 4217 к верхнему регистру и проверяет формат
+func NormalizeCurrency(code string) (string, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if len(code) != 3 {
+		return "", fmt.Errorf("invalid currency code %q", code)
+	}
+	for _, c := range code {
+		if c < 'A' || c > 'Z' {
+			return "", fmt.Errorf("invalid currency code %q", code)
+		}
+	}
+	return code, nil
+}
+
+// ConvertToBase переводит сумму в базовую валюту; rate — сколько единиц базовой валюты стоит единица исходной
+func ConvertToBase(amount models.CurrencyAmount, rate models.Decimal) (models.Money, error) {
+	return models.NewMoney(amount.Minor()).MulDecimal(rate, Rounding)
+}
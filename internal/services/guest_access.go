@@ -0,0 +1,89 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/go-chi/jwtauth/v5"
+	"math/big"
+	"mis_kursach_backend/configs"
+	"strings"
+	"unicode"
+)
+
+const (
+	// AccessCodeTTL — сколько минут действует код из письма
+	AccessCodeTTL = 15
+	// AccessCodeMaxAttempts — после стольких неверных попыток код перестаёт действовать
+	AccessCodeMaxAttempts = 5
+	// GuestSessionTTL — сколько минут действует токен гостя после входа
+	GuestSessionTTL = 30
+	// GuestLoginWindow — за сколько минут считаются неудачные попытки входа гостя
+	GuestLoginWindow = 15
+	// GuestLoginMaxPerReference — сколько неудачных попыток за окно допускается по одному номеру бронирования
+	GuestLoginMaxPerReference = 5
+	// GuestLoginMaxPerIP — то же для одного адреса, чтобы нельзя было перебирать разные номера
+	GuestLoginMaxPerIP = 20
+)
+
+// GenerateBookingReference возвращает случайный номер бронирования из 8 символов, который сообщают гостю
+func GenerateBookingReference() (string, error) {
+	var b strings.Builder
+	for i := 0; i < 8; i++ {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(voucherAlphabet))))
+		if err != nil {
+			return "", fmt.Errorf("error generating booking reference: %v", err)
+		}
+		b.WriteByte(voucherAlphabet[n.Int64()])
+	}
+	return b.String(), nil
+}
+
+// NormalizeBookingReference убирает пробелы и дефисы, которые гость мог ввести
+func NormalizeBookingReference(reference string) string {
+	return strings.ToUpper(strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, reference))
+}
+
+// GenerateAccessCode возвращает одноразовый код из 6 цифр
+func GenerateAccessCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", fmt.Errorf("error generating access code: %v", err)
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
+// HashAccessCode — в базе хранится только хеш кода, привязанный к бронированию
+func HashAccessCode(reference, code string) string {
+	sum := sha256.Sum256([]byte(reference + ":" + strings.TrimSpace(code)))
+	return hex.EncodeToString(sum[:])
+}
+
+// MatchesLastName сверяет фамилию с полным именем гостя. Фамилия бывает и первым словом
+// (Иванов Иван Иванович), и последним (John Smith); регистр и ё/е не различаются.
+func MatchesLastName(fullName, lastName string) bool {
+	normalize := func(s string) string {
+		return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(s)), "ё", "е")
+	}
+	words := strings.Fields(normalize(fullName))
+	lastName = normalize(lastName)
+	if len(words) == 0 || lastName == "" {
+		return false
+	}
+	return words[0] == lastName || words[len(words)-1] == lastName
+}
+
+// GenerateGuestAuthToken — токены гостей подписываются отдельным ключом,
+// чтобы с ними нельзя было войти в методы для сотрудников
+func GenerateGuestAuthToken(config configs.Config) *jwtauth.JWTAuth {
+	mac := hmac.New(sha256.New, []byte(config.JWTConfig.Secret))
+	mac.Write([]byte("guest-self-service"))
+	return jwtauth.New("HS256", mac.Sum(nil), nil)
+}
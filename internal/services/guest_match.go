@@ -0,0 +1,143 @@
+package services
+
+import (
+	"mis_kursach_backend/internal/models"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Веса признаков в итоговой оценке сходства гостей
+const (
+	nameWeight     = 0.5
+	phoneWeight    = 0.25
+	passportWeight = 0.25
+)
+
+// NormalizePhone оставляет только цифры; российский номер с ведущей 8 приводится к 7
+func NormalizePhone(phone string) string {
+	digits := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, phone)
+	if len(digits) == 11 && digits[0] == '8' {
+		digits = "7" + digits[1:]
+	}
+	return digits
+}
+
+// NormalizePassport убирает пробелы и разделители и переводит буквы в верхний регистр
+func NormalizePassport(passport string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToUpper(r)
+		}
+		return -1
+	}, passport)
+}
+
+// normalizeName приводит ФИО к нижнему регистру, заменяет ё на е и сортирует слова,
+// чтобы «Иванов Иван» и «иван иванов» совпадали
+func normalizeName(name string) string {
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	for i, w := range words {
+		words[i] = strings.ReplaceAll(w, "ё", "е")
+	}
+	sort.Strings(words)
+	return strings.Join(words, " ")
+}
+
+// levenshtein — редакционное расстояние между строками в символах
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// similarity возвращает сходство строк от 0 до 1 по расстоянию Левенштейна
+func similarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	longest := max(len(ra), len(rb))
+	if longest == 0 {
+		return 0
+	}
+	return 1 - float64(levenshtein(ra, rb))/float64(longest)
+}
+
+// GuestMatchScore оценивает, насколько вероятно, что два профиля принадлежат одному человеку.
+// Пустые телефон или паспорт не добавляют баллов, но и не штрафуют.
+func GuestMatchScore(a, b models.Guest) (float64, []string) {
+	var score float64
+	var reasons []string
+
+	if s := similarity(normalizeName(a.Name), normalizeName(b.Name)); s >= 0.8 {
+		score += nameWeight * s
+		if s == 1 {
+			reasons = append(reasons, "same name")
+		} else {
+			reasons = append(reasons, "similar name")
+		}
+	}
+
+	phoneA, phoneB := NormalizePhone(a.PhoneNumber), NormalizePhone(b.PhoneNumber)
+	if phoneA != "" && phoneA == phoneB {
+		score += phoneWeight
+		reasons = append(reasons, "same phone")
+	}
+
+	passA, passB := NormalizePassport(a.PassportNo), NormalizePassport(b.PassportNo)
+	if passA != "" && passB != "" {
+		switch d := levenshtein([]rune(passA), []rune(passB)); {
+		case d == 0:
+			score += passportWeight
+			reasons = append(reasons, "same passport")
+		case d == 1:
+			// одна опечатка или переставленная цифра
+			score += passportWeight * 0.8
+			reasons = append(reasons, "passport differs by one character")
+		}
+	}
+	return score, reasons
+}
+
+// FindDuplicateGuests сравнивает профили попарно и возвращает пары с оценкой не ниже minScore,
+// самые вероятные дубликаты первыми
+func FindDuplicateGuests(guests []models.Guest, minScore float64) []models.DuplicateCandidate {
+	var candidates []models.DuplicateCandidate
+	for i := 0; i < len(guests); i++ {
+		for j := i + 1; j < len(guests); j++ {
+			score, reasons := GuestMatchScore(guests[i], guests[j])
+			if score < minScore {
+				continue
+			}
+			candidates = append(candidates, models.DuplicateCandidate{
+				Guest:     guests[i],
+				Duplicate: guests[j],
+				Score:     float64(int(score*100+0.5)) / 100,
+				Reasons:   reasons,
+			})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Score > candidates[j].Score
+	})
+	return candidates
+}
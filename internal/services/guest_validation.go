@@ -0,0 +1,118 @@
+package services
+
+import (
+	"mis_kursach_backend/internal/models"
+	"net/mail"
+	"regexp"
+	"strings"
+)
+
+var (
+	// серия 4 цифры + номер 6 цифр
+	ruPassportRe = regexp.MustCompile(`^\d{10}$`)
+	// заграничный паспорт РФ: серия 2 цифры + номер 7 цифр
+	foreignPassportRe = regexp.MustCompile(`^\d{9}$`)
+	// прочие документы: буквы и цифры латиницей или кириллицей
+	otherDocumentRe = regexp.MustCompile(`^[A-ZА-ЯЁ0-9]{5,20}$`)
+	nationalityRe   = regexp.MustCompile(`^[A-Z]{2}$`)
+)
+
+// NormalizePhoneE164 приводит телефон к виду +<код страны><номер>.
+// Российские номера принимаются и без кода страны: 8XXXXXXXXXX, 9XXXXXXXXX.
+func NormalizePhoneE164(phone string) (string, bool) {
+	phone = strings.TrimSpace(phone)
+	international := strings.HasPrefix(phone, "+")
+	digits := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, phone)
+	switch {
+	case international:
+	case len(digits) == 11 && (digits[0] == '8' || digits[0] == '7'):
+		digits = "7" + digits[1:]
+	case len(digits) == 10 && digits[0] == '9':
+		digits = "7" + digits
+	default:
+		return "", false
+	}
+	if len(digits) < 8 || len(digits) > 15 || digits[0] == '0' {
+		return "", false
+	}
+	if digits[0] == '7' && len(digits) != 11 {
+		return "", false
+	}
+	return "+" + digits, true
+}
+
+// ValidateGuest проверяет и нормализует данные гостя. Пустой тип документа означает паспорт РФ,
+// для документов РФ гражданство по умолчанию RU.
+func ValidateGuest(in models.GuestInput) (models.GuestInput, models.FieldErrors) {
+	errs := models.FieldErrors{}
+	in.Name = strings.Join(strings.Fields(in.Name), " ")
+	if in.Name == "" {
+		errs["name"] = "name is required"
+	}
+
+	if strings.TrimSpace(in.PhoneNumber) != "" {
+		phone, ok := NormalizePhoneE164(in.PhoneNumber)
+		if ok {
+			in.PhoneNumber = phone
+		} else {
+			errs["phone_number"] = "phone number must include a country code, e.g. +7 916 123-45-67"
+		}
+	} else {
+		in.PhoneNumber = ""
+	}
+
+	in.Email = strings.ToLower(strings.TrimSpace(in.Email))
+	if in.Email != "" {
+		addr, err := mail.ParseAddress(in.Email)
+		if err != nil || addr.Address != in.Email {
+			errs["email"] = "email address is invalid"
+		}
+	}
+
+	if in.DocumentType == "" {
+		in.DocumentType = models.DocumentRussianPassport
+	}
+	in.Nationality = strings.ToUpper(strings.TrimSpace(in.Nationality))
+	russian := in.DocumentType == models.DocumentRussianPassport || in.DocumentType == models.DocumentForeignPassport
+	if russian && in.Nationality == "" {
+		in.Nationality = "RU"
+	}
+	switch {
+	case in.Nationality == "":
+		errs["nationality"] = "nationality is required"
+	case !nationalityRe.MatchString(in.Nationality):
+		errs["nationality"] = "nationality must be an ISO 3166-1 alpha-2 code"
+	case russian && in.Nationality != "RU":
+		errs["nationality"] = "russian documents are issued to RU citizens only"
+	}
+
+	in.PassportNo = NormalizePassport(in.PassportNo)
+	switch in.DocumentType {
+	case models.DocumentRussianPassport:
+		if !ruPassportRe.MatchString(in.PassportNo) {
+			errs["passport_no"] = "russian passport number must have 10 digits"
+		}
+	case models.DocumentForeignPassport:
+		if !foreignPassportRe.MatchString(in.PassportNo) {
+			errs["passport_no"] = "foreign passport number must have 9 digits"
+		}
+	case models.DocumentOther:
+		if !otherDocumentRe.MatchString(in.PassportNo) {
+			errs["passport_no"] = "document number must have 5 to 20 letters or digits"
+		}
+	default:
+		errs["document_type"] = "document type must be ru_passport, foreign_passport or other"
+	}
+	if _, bad := errs["nationality"]; !bad {
+		validateMigrationDocuments(&in, errs)
+	}
+	if len(errs) == 0 {
+		return in, nil
+	}
+	return in, errs
+}
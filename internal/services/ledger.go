@@ -0,0 +1,173 @@
+package services
+
+import (
+	"fmt"
+	"mis_kursach_backend/internal/models"
+)
+
+func debit(account string, amount models.Money) models.LedgerEntry {
+	return models.LedgerEntry{AccountCode: account, Debit: amount}
+}
+
+func credit(account string, amount models.Money) models.LedgerEntry {
+	return models.LedgerEntry{AccountCode: account, Credit: amount}
+}
+
+// compact убирает нулевые строки и переворачивает отрицательные суммы на другую сторону
+func compact(entries []models.LedgerEntry) []models.LedgerEntry {
+	var result []models.LedgerEntry
+	for _, e := range entries {
+		net := e.Debit.Sub(e.Credit)
+		switch {
+		case net.IsPositive():
+			result = append(result, debit(e.AccountCode, net))
+		case net.IsNegative():
+			result = append(result, credit(e.AccountCode, net.Neg()))
+		}
+	}
+	return result
+}
+
+// ChargeEntries — начисление гостю: долг гостя на всю сумму с налогами сверх цены,
+// выручка за вычетом включённых в цену налогов, налоги — в обязательства
+func ChargeEntries(revenueAccount string, gross models.Money, taxes []models.TaxLine) []models.LedgerEntry {
+	var included, excluded models.Money
+	for _, t := range taxes {
+		if t.Inclusive {
+			included = included.Add(t.Amount)
+		} else {
+			excluded = excluded.Add(t.Amount)
+		}
+	}
+	return compact([]models.LedgerEntry{
+		debit(models.AccountGuestReceivable, gross.Add(excluded)),
+		credit(revenueAccount, gross.Sub(included)),
+		credit(models.AccountTaxPayable, included.Add(excluded)),
+	})
+}
+
+// DiscountEntries уменьшает выручку от проживания и долг гостя на сумму скидки
+func DiscountEntries(discount models.Money) []models.LedgerEntry {
+	return compact([]models.LedgerEntry{
+		debit(models.AccountRoomRevenue, discount),
+		credit(models.AccountGuestReceivable, discount),
+	})
+}
+
+// PaymentEntries — поступление денег на счёт способа оплаты в погашение долга гостя
+func PaymentEntries(account string, amount models.Money) []models.LedgerEntry {
+	return compact([]models.LedgerEntry{
+		debit(account, amount),
+		credit(models.AccountGuestReceivable, amount),
+	})
+}
+
+// RefundEntries — возврат денег гостю
+func RefundEntries(account string, amount models.Money) []models.LedgerEntry {
+	return compact([]models.LedgerEntry{
+		debit(models.AccountGuestReceivable, amount),
+		credit(account, amount),
+	})
+}
+
+// CompanyInvoiceEntries переносит долг гостя на организацию, которой выставлен счёт
+func CompanyInvoiceEntries(amount models.Money) []models.LedgerEntry {
+	return compact([]models.LedgerEntry{
+		debit(models.AccountCompanyReceivable, amount),
+		credit(models.AccountGuestReceivable, amount),
+	})
+}
+
+// CompanyPaymentEntries — оплата счёта организацией
+func CompanyPaymentEntries(account string, amount models.Money) []models.LedgerEntry {
+	return compact([]models.LedgerEntry{
+		debit(account, amount),
+		credit(models.AccountCompanyReceivable, amount),
+	})
+}
+
+// VoucherSaleEntries — продажа сертификата: деньги получены, возникает обязательство перед держателем
+func VoucherSaleEntries(account string, amount models.Money) []models.LedgerEntry {
+	return compact([]models.LedgerEntry{
+		debit(account, amount),
+		credit(models.AccountVoucherLiability, amount),
+	})
+}
+
+// VoucherRedemptionEntries — оплата сертификатом: долг гостя гасится на amount, обязательство — на value.
+// У сертификата на ночи они различаются, разница относится на выручку от проживания.
+func VoucherRedemptionEntries(amount, value models.Money) []models.LedgerEntry {
+	return compact([]models.LedgerEntry{
+		debit(models.AccountVoucherLiability, value),
+		debit(models.AccountRoomRevenue, amount.Sub(value)),
+		credit(models.AccountGuestReceivable, amount),
+	})
+}
+
+// ReverseEntries меняет дебет и кредит местами
+func ReverseEntries(entries []models.LedgerEntry) []models.LedgerEntry {
+	reversed := make([]models.LedgerEntry, 0, len(entries))
+	for _, e := range entries {
+		reversed = append(reversed, models.LedgerEntry{AccountCode: e.AccountCode, Debit: e.Credit, Credit: e.Debit})
+	}
+	return compact(reversed)
+}
+
+// CheckBalanced проверяет, что проводка не пустая и сумма дебета равна сумме кредита
+func CheckBalanced(entries []models.LedgerEntry) error {
+	if len(entries) == 0 {
+		return fmt.Errorf("ledger transaction has no entries")
+	}
+	var debits, credits models.Money
+	for _, e := range entries {
+		if e.Debit.IsNegative() || e.Credit.IsNegative() {
+			return fmt.Errorf("negative amount on account %s", e.AccountCode)
+		}
+		debits = debits.Add(e.Debit)
+		credits = credits.Add(e.Credit)
+	}
+	if debits.Cmp(credits) != 0 {
+		return fmt.Errorf("ledger transaction is not balanced: debit %s, credit %s", debits, credits)
+	}
+	return nil
+}
+
+// NormalBalance возвращает сальдо счёта с учётом его стороны: активы и расходы — дебетовые
+func NormalBalance(accountType string, debits, credits models.Money) models.Money {
+	if accountType == models.AccountTypeAsset || accountType == models.AccountTypeExpense {
+		return debits.Sub(credits)
+	}
+	return credits.Sub(debits)
+}
+
+// LoyaltyAccrualEntries — начисление баллов: расход на программу лояльности и обязательство перед гостем
+func LoyaltyAccrualEntries(value models.Money) []models.LedgerEntry {
+	return compact([]models.LedgerEntry{
+		debit(models.AccountLoyaltyExpense, value),
+		credit(models.AccountLoyaltyLiability, value),
+	})
+}
+
+// LoyaltyExpiryEntries — сгорание баллов снимает обязательство и уменьшает расход
+func LoyaltyExpiryEntries(value models.Money) []models.LedgerEntry {
+	return compact([]models.LedgerEntry{
+		debit(models.AccountLoyaltyLiability, value),
+		credit(models.AccountLoyaltyExpense, value),
+	})
+}
+
+// CompensationCreditEntries — скидка по жалобе: расход на компенсации и уменьшение долга гостя
+func CompensationCreditEntries(amount models.Money) []models.LedgerEntry {
+	return compact([]models.LedgerEntry{
+		debit(models.AccountCompensation, amount),
+		credit(models.AccountGuestReceivable, amount),
+	})
+}
+
+// CompensationRefundEntries — возврат денег по жалобе относится на расход, долг гостя не меняется
+func CompensationRefundEntries(account string, amount models.Money) []models.LedgerEntry {
+	return compact([]models.LedgerEntry{
+		debit(models.AccountCompensation, amount),
+		credit(account, amount),
+	})
+}
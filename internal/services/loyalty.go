@@ -0,0 +1,106 @@
+package services
+
+import (
+	"mis_kursach_backend/internal/models"
+	"sort"
+	"time"
+)
+
+const (
+	// LoyaltyEarnPercent — сколько баллов начисляется с каждых 100 оплаченных рублей
+	LoyaltyEarnPercent = 5
+	// LoyaltyPointsTTL — срок жизни начисленных баллов
+	LoyaltyPointsTTL = 365 * 24 * time.Hour
+	// LoyaltyQualifyingPeriod — за какой период считаются баллы для определения уровня
+	LoyaltyQualifyingPeriod = 365 * 24 * time.Hour
+)
+
+// LoyaltyPointValue — стоимость одного балла при оплате, в минимальных единицах базовой валюты
+const LoyaltyPointValue = 100
+
+// PointsEarned — баллы за оплаченную сумму, дробная часть отбрасывается
+func PointsEarned(paid models.Money) int {
+	if !paid.IsPositive() {
+		return 0
+	}
+	return int(paid.Minor() * LoyaltyEarnPercent / 100 / LoyaltyPointValue)
+}
+
+// PointsValue — сколько стоят баллы при оплате
+func PointsValue(points int) models.Money {
+	return models.NewMoney(int64(points) * LoyaltyPointValue)
+}
+
+// PointsForAmount переводит сумму оплаты в баллы; false, если сумма не кратна стоимости балла
+func PointsForAmount(amount models.Money) (int, bool) {
+	if amount.Minor()%LoyaltyPointValue != 0 {
+		return 0, false
+	}
+	return int(amount.Minor() / LoyaltyPointValue), true
+}
+
+// TierFor выбирает уровень с наибольшим порогом, не превышающим число баллов, и следующий за ним
+func TierFor(tiers []models.LoyaltyTier, points int) (models.LoyaltyTier, *models.LoyaltyTier) {
+	var current models.LoyaltyTier
+	var next *models.LoyaltyTier
+	found := false
+	for i, t := range tiers {
+		if t.MinPoints <= points {
+			if !found || t.MinPoints > current.MinPoints {
+				current = t
+				found = true
+			}
+		} else if next == nil || t.MinPoints < next.MinPoints {
+			next = &tiers[i]
+		}
+	}
+	return current, next
+}
+
+// WithLoyaltyDiscount добавляет скидку уровня лояльности к скидке за длительность проживания
+func WithLoyaltyDiscount(discount models.Discount, tier models.LoyaltyTier) models.Discount {
+	discount.Amount = discount.Amount.Add(tier.DiscountPercent)
+	return discount
+}
+
+// LoyaltyStatement сводит историю баллов гостя: остаток, заработанные, потраченные и сгоревшие баллы,
+// уровень по баллам за последние 12 месяцев и баллы, которые сгорят в ближайшие 90 дней
+func LoyaltyStatement(guestID int, txns []models.LoyaltyTransaction, tiers []models.LoyaltyTier, now time.Time) models.LoyaltyStatement {
+	st := models.LoyaltyStatement{GuestID: guestID, Transactions: txns, Expiring: []models.ExpiringPoints{}}
+	// даты из базы приходят полуночью UTC
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	horizon := today.AddDate(0, 0, 90)
+	qualifyingSince := now.Add(-LoyaltyQualifyingPeriod)
+	expiring := map[time.Time]int{}
+	for _, t := range txns {
+		switch t.Kind {
+		case models.LoyaltyEarn:
+			st.Earned += t.Points
+			if !t.CreatedAt.Before(qualifyingSince) {
+				st.QualifyingPoints += t.Points
+			}
+		case models.LoyaltyRedeem:
+			if t.ReversedAt == nil {
+				st.Redeemed -= t.Points
+			}
+		case models.LoyaltyExpire:
+			st.Expired -= t.Points
+		}
+		// остаток хранится в партиях начисления и возврата
+		if t.Remaining > 0 && t.ExpiresAt != nil && !t.ExpiresAt.Before(today) {
+			st.Balance += t.Remaining
+			if t.ExpiresAt.Before(horizon) {
+				expiring[*t.ExpiresAt] += t.Remaining
+			}
+		}
+	}
+	for date, points := range expiring {
+		st.Expiring = append(st.Expiring, models.ExpiringPoints{ExpiresAt: date, Points: points})
+	}
+	sort.Slice(st.Expiring, func(i, j int) bool {
+		return st.Expiring[i].ExpiresAt.Before(st.Expiring[j].ExpiresAt)
+	})
+	st.BalanceValue = PointsValue(st.Balance)
+	st.Tier, st.NextTier = TierFor(tiers, st.QualifyingPoints)
+	return st
+}
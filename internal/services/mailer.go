@@ -0,0 +1,54 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"mime"
+	"mis_kursach_backend/configs"
+	"net/smtp"
+	"strings"
+)
+
+// Mailer отправляет письма гостям и сотрудникам
+type Mailer interface {
+	Send(to, subject, body string) error
+}
+
+// NewMailer возвращает SMTP-отправщик; без SMTP_HOST письма не отправляются, а только отмечаются в логе
+func NewMailer(config configs.SMTPConfig) Mailer {
+	if config.Host == "" {
+		return disabledMailer{}
+	}
+	return smtpMailer{config: config}
+}
+
+type smtpMailer struct {
+	config configs.SMTPConfig
+}
+
+func (m smtpMailer) Send(to, subject, body string) error {
+	var auth smtp.Auth
+	if m.config.Username != "" {
+		auth = smtp.PlainAuth("", m.config.Username, m.config.Password, m.config.Host)
+	}
+	// заголовки не должны содержать переводов строк из пользовательского ввода
+	to = strings.NewReplacer("\r", "", "\n", "").Replace(to)
+	msg := "From: " + m.config.From + "\r\n" +
+		"To: " + to + "\r\n" +
+		"Subject: " + mime.QEncoding.Encode("utf-8", subject) + "\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: text/plain; charset=UTF-8\r\n" +
+		"\r\n" + body
+	err := smtp.SendMail(m.config.Host+":"+m.config.Port, auth, m.config.From, []string{to}, []byte(msg))
+	if err != nil {
+		return fmt.Errorf("error sending mail: %v", err)
+	}
+	return nil
+}
+
+type disabledMailer struct{}
+
+func (disabledMailer) Send(to, subject, _ string) error {
+	log.Printf("SMTP is not configured, mail %q to %s was not sent", subject, to)
+	return nil
+}
@@ -0,0 +1,120 @@
+package services
+
+import (
+	"mis_kursach_backend/internal/models"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// MigrationDeadlineWorkingDays — за сколько рабочих дней после прибытия нужно подать уведомление
+const MigrationDeadlineWorkingDays = 1
+
+var migrationDocumentRe = regexp.MustCompile(`^[A-Z0-9]{4,32}$`)
+
+// MigrationDeadline — последний день подачи уведомления: прибытие плюс рабочие дни,
+// выходные и праздники (isHoliday) не считаются
+func MigrationDeadline(arrival time.Time, isHoliday func(time.Time) bool) time.Time {
+	day := arrival
+	for left := MigrationDeadlineWorkingDays; left > 0; {
+		day = day.AddDate(0, 0, 1)
+		if day.Weekday() == time.Saturday || day.Weekday() == time.Sunday || isHoliday(day) {
+			continue
+		}
+		left--
+	}
+	return day
+}
+
+// MigrationMissing перечисляет данные, без которых уведомление о прибытии не примут
+func MigrationMissing(a models.MigrationArrival) []string {
+	missing := []string{}
+	if a.PassportNo == "" {
+		missing = append(missing, "passport_no")
+	}
+	if a.MigrationCardNo == nil || *a.MigrationCardNo == "" {
+		missing = append(missing, "migration_card_no")
+	}
+	if a.EntryDate == nil {
+		missing = append(missing, "entry_date")
+	}
+	return missing
+}
+
+// MigrationNoticeFor переводит прибытие в запись выгрузки
+func MigrationNoticeFor(a models.MigrationArrival) models.MigrationNotice {
+	n := models.MigrationNotice{
+		BookingID:      a.BookingID,
+		GuestID:        a.GuestID,
+		FullName:       a.GuestName,
+		Citizenship:    a.Nationality,
+		DocumentType:   a.DocumentType,
+		DocumentNumber: a.PassportNo,
+		Room:           a.Room,
+		ArrivalDate:    a.ArrivalDate.Format("2006-01-02"),
+		DepartureDate:  a.DepartureDate.Format("2006-01-02"),
+		Deadline:       a.Deadline.Format("2006-01-02"),
+	}
+	if a.VisaNumber != nil {
+		n.VisaNumber = *a.VisaNumber
+	}
+	if a.VisaExpiresAt != nil {
+		n.VisaExpires = a.VisaExpiresAt.Format("2006-01-02")
+	}
+	if a.MigrationCardNo != nil {
+		n.MigrationCardNo = *a.MigrationCardNo
+	}
+	if a.EntryDate != nil {
+		n.EntryDate = a.EntryDate.Format("2006-01-02")
+	}
+	if a.MigrationCardExpiresAt != nil {
+		n.CardExpires = a.MigrationCardExpiresAt.Format("2006-01-02")
+	}
+	return n
+}
+
+// validateMigrationDocuments проверяет визу и миграционную карту; у граждан РФ их быть не должно
+func validateMigrationDocuments(in *models.GuestInput, errs models.FieldErrors) {
+	in.VisaNumber = NormalizePassport(in.VisaNumber)
+	in.MigrationCardNo = NormalizePassport(in.MigrationCardNo)
+	in.VisaExpiresAt = strings.TrimSpace(in.VisaExpiresAt)
+	in.EntryDate = strings.TrimSpace(in.EntryDate)
+	in.MigrationCardExpiresAt = strings.TrimSpace(in.MigrationCardExpiresAt)
+	if in.Nationality == "RU" {
+		if in.VisaNumber != "" || in.VisaExpiresAt != "" || in.MigrationCardNo != "" ||
+			in.EntryDate != "" || in.MigrationCardExpiresAt != "" {
+			errs["migration_card_no"] = "visa and migration card are only recorded for foreign citizens"
+		}
+		return
+	}
+	if in.VisaNumber != "" && !migrationDocumentRe.MatchString(in.VisaNumber) {
+		errs["visa_number"] = "visa number must have 4 to 32 latin letters or digits"
+	}
+	if in.MigrationCardNo != "" && !migrationDocumentRe.MatchString(in.MigrationCardNo) {
+		errs["migration_card_no"] = "migration card number must have 4 to 32 latin letters or digits"
+	}
+	dates := map[string]string{
+		"visa_expires_at":           in.VisaExpiresAt,
+		"entry_date":                in.EntryDate,
+		"migration_card_expires_at": in.MigrationCardExpiresAt,
+	}
+	parsed := map[string]time.Time{}
+	for field, value := range dates {
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			errs[field] = "date must be in YYYY-MM-DD format"
+			continue
+		}
+		parsed[field] = t
+	}
+	entry, hasEntry := parsed["entry_date"]
+	if hasEntry && entry.After(time.Now()) {
+		errs["entry_date"] = "entry date cannot be in the future"
+	}
+	if expires, ok := parsed["migration_card_expires_at"]; ok && hasEntry && expires.Before(entry) {
+		errs["migration_card_expires_at"] = "migration card cannot expire before the entry date"
+	}
+}
@@ -0,0 +1,30 @@
+package services
+
+import "mis_kursach_backend/internal/models"
+
+// NightShare возвращает долю расчёта за проживание, приходящуюся на ночь night (с нуля).
+// Копейки от деления достаются первым ночам, поэтому доли всех ночей в сумме дают полный расчёт.
+func NightShare(stay models.BookingQuote, night int) models.BookingQuote {
+	share := func(m models.Money) models.Money {
+		return m.Allocate(stay.Nights)[night]
+	}
+	taxes := make([]models.TaxLine, 0, len(stay.Taxes))
+	for _, t := range stay.Taxes {
+		t.Taxable = share(t.Taxable)
+		t.Amount = share(t.Amount)
+		taxes = append(taxes, t)
+	}
+	return models.BookingQuote{
+		Nights:          1,
+		Adults:          stay.Adults,
+		Children:        stay.Children,
+		BasePrice:       stay.BasePrice,
+		BookingSum:      share(stay.BookingSum),
+		DiscountID:      stay.DiscountID,
+		DiscountPercent: stay.DiscountPercent,
+		DiscountAmount:  share(stay.DiscountAmount),
+		Taxes:           taxes,
+		TaxSum:          share(stay.TaxSum),
+		TotalSum:        share(stay.TotalSum),
+	}
+}
@@ -0,0 +1,115 @@
+package services
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"mis_kursach_backend/configs"
+	"strings"
+)
+
+// PassportCipher шифрует номера паспортов AES-256-GCM. Шифротекст хранится как "<id ключа>:<base64>",
+// поэтому после смены активного ключа старые записи остаются читаемыми до перешифрования.
+type PassportCipher struct {
+	keys     map[string]cipher.AEAD
+	activeID string
+	indexKey []byte
+}
+
+func NewPassportCipher(config configs.CryptoConfig) (*PassportCipher, error) {
+	c := &PassportCipher{keys: map[string]cipher.AEAD{}}
+	for _, entry := range strings.Split(config.PassportKeys, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		id, encoded, ok := strings.Cut(entry, ":")
+		if !ok || id == "" {
+			return nil, fmt.Errorf("passport key %q must look like id:base64", entry)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("passport key %s must be 32 bytes in base64", id)
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("error creating cipher for key %s: %v", id, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("error creating cipher for key %s: %v", id, err)
+		}
+		if _, dup := c.keys[id]; dup {
+			return nil, fmt.Errorf("passport key %s is listed twice", id)
+		}
+		c.keys[id] = aead
+		if c.activeID == "" {
+			c.activeID = id
+		}
+	}
+	if c.activeID == "" {
+		return nil, fmt.Errorf("PASSPORT_KEYS is not set")
+	}
+	indexKey, err := base64.StdEncoding.DecodeString(config.PassportIndexKey)
+	if err != nil || len(indexKey) < 32 {
+		return nil, fmt.Errorf("PASSPORT_INDEX_KEY must be at least 32 bytes in base64")
+	}
+	c.indexKey = indexKey
+	return c, nil
+}
+
+func (c *PassportCipher) Encrypt(passport string) (string, error) {
+	aead := c.keys[c.activeID]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("error generating nonce: %v", err)
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(passport), []byte(c.activeID))
+	return c.activeID + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (c *PassportCipher) Decrypt(value string) (string, error) {
+	id, encoded, ok := strings.Cut(value, ":")
+	if !ok {
+		return "", fmt.Errorf("malformed encrypted passport")
+	}
+	aead, ok := c.keys[id]
+	if !ok {
+		return "", fmt.Errorf("passport key %s is not configured", id)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", fmt.Errorf("malformed encrypted passport")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, ciphertext, []byte(id))
+	if err != nil {
+		return "", fmt.Errorf("error decrypting passport with key %s: %v", id, err)
+	}
+	return string(plain), nil
+}
+
+func (c *PassportCipher) ActiveKeyID() string {
+	return c.activeID
+}
+
+// BlindIndex — HMAC нормализованного номера: по нему ищется точное совпадение без расшифровки
+func (c *PassportCipher) BlindIndex(passport string) string {
+	mac := hmac.New(sha256.New, c.indexKey)
+	mac.Write([]byte(NormalizePassport(passport)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// MaskPassport оставляет видимыми только последние четыре символа
+func MaskPassport(passport string) string {
+	r := []rune(passport)
+	if len(r) <= 4 {
+		return strings.Repeat("*", len(r))
+	}
+	return strings.Repeat("*", len(r)-4) + string(r[len(r)-4:])
+}
@@ -0,0 +1,61 @@
+package services
+
+import (
+	"mis_kursach_backend/internal/models"
+)
+
+// Rounding — правило округления, применяемое при расчёте цен и скидок
+const Rounding = models.RoundHalfUp
+
+// DiscountForNights выбирает скидку с наибольшим порогом MinNights, не превышающим число ночей
+func DiscountForNights(discounts []models.Discount, nights int) models.Discount {
+	var best models.Discount
+	found := false
+	for _, d := range discounts {
+		if d.MinNights > nights {
+			continue
+		}
+		if !found || d.MinNights > best.MinNights {
+			best = d
+			found = true
+		}
+	}
+	return best
+}
+
+// QuoteBooking считает стоимость проживания: базовая цена за ночь, умноженная на число ночей,
+// минус скидка, плюс налоги, которые начисляются сверх цены
+func QuoteBooking(basePrice models.Money, discount models.Discount, rules []models.TaxRule, ctx TaxContext) (models.BookingQuote, error) {
+	bookingSum, err := basePrice.Mul(int64(ctx.Nights))
+	if err != nil {
+		return models.BookingQuote{}, err
+	}
+	discountAmount, err := bookingSum.Percent(discount.Amount, Rounding)
+	if err != nil {
+		return models.BookingQuote{}, err
+	}
+	netSum := bookingSum.Sub(discountAmount)
+	taxes, err := ApplyTaxes(rules, models.ChargeTypeRoom, netSum, ctx)
+	if err != nil {
+		return models.BookingQuote{}, err
+	}
+	taxSum := ExclusiveTaxTotal(taxes)
+	return models.BookingQuote{
+		Nights:          ctx.Nights,
+		Adults:          ctx.Adults,
+		Children:        ctx.Children,
+		BasePrice:       basePrice,
+		BookingSum:      bookingSum,
+		DiscountID:      discount.ID,
+		DiscountPercent: discount.Amount,
+		DiscountAmount:  discountAmount,
+		Taxes:           taxes,
+		TaxSum:          taxSum,
+		TotalSum:        netSum.Add(taxSum),
+	}, nil
+}
+
+// IncludedTax выделяет налог, уже включённый в сумму: amount * rate / (100 + rate)
+func IncludedTax(amount models.Money, ratePercent models.Decimal) (models.Money, error) {
+	return amount.MulRatio(ratePercent, ratePercent.Add(models.DecimalFromInt(100)), Rounding)
+}
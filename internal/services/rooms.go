@@ -0,0 +1,83 @@
+package services
+
+import (
+	"mis_kursach_backend/internal/models"
+	"slices"
+	"strings"
+)
+
+// MaxRoomCapacity — наибольшая вместимость категории номера
+const MaxRoomCapacity = 10
+
+func ValidateRoom(in models.RoomInput) models.FieldErrors {
+	errs := models.FieldErrors{}
+	if in.Number <= 0 {
+		errs["number"] = "number must be positive"
+	}
+	if in.CategoryCode <= 0 {
+		errs["category_code"] = "category_code is required"
+	}
+	if in.StateCode != nil && (*in.StateCode < models.RoomStateFree || *in.StateCode > models.RoomStateMaintenance) {
+		errs["state_code"] = "state_code must be 1 (free), 2 (occupied) or 3 (maintenance)"
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func ValidateRoomCategory(in models.RoomCategoryInput) (models.RoomCategoryInput, models.FieldErrors) {
+	errs := models.FieldErrors{}
+	in.Name = strings.TrimSpace(in.Name)
+	if in.Name == "" {
+		errs["name"] = "name is required"
+	}
+	if in.Capacity <= 0 || in.Capacity > MaxRoomCapacity {
+		errs["capacity"] = "capacity must be between 1 and 10"
+	}
+	if len(errs) > 0 {
+		return in, errs
+	}
+	return in, nil
+}
+
+func ValidateTariff(in models.TariffInput) models.FieldErrors {
+	errs := models.FieldErrors{}
+	if in.CategoryCode <= 0 {
+		errs["category_code"] = "category_code is required"
+	}
+	if !in.BasePrice.IsPositive() {
+		errs["base_price"] = "base_price must be positive"
+	}
+	if in.DayCode <= 0 {
+		errs["day_code"] = "day_code is required"
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// housekeepingTransitions — допустимые переходы статуса уборки.
+// Вернуть номер в «грязные» можно из любого статуса: например, если проверка не пройдена.
+var housekeepingTransitions = map[string][]string{
+	models.HousekeepingDirty:     {models.HousekeepingCleaning, models.HousekeepingClean},
+	models.HousekeepingCleaning:  {models.HousekeepingDirty, models.HousekeepingClean},
+	models.HousekeepingClean:     {models.HousekeepingDirty, models.HousekeepingInspected},
+	models.HousekeepingInspected: {models.HousekeepingDirty},
+}
+
+func IsHousekeepingStatus(status string) bool {
+	_, ok := housekeepingTransitions[status]
+	return ok
+}
+
+// CanTransitionHousekeeping сообщает, можно ли перевести номер из статуса уборки from в to
+func CanTransitionHousekeeping(from, to string) bool {
+	return slices.Contains(housekeepingTransitions[from], to)
+}
+
+// ReadyForCheckIn сообщает, можно ли заселять гостя в номер с таким статусом уборки
+func ReadyForCheckIn(status string) bool {
+	return status == models.HousekeepingClean || status == models.HousekeepingInspected
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"fmt"
+	"mis_kursach_backend/internal/models"
+)
+
+// TaxContext — параметры проживания, от которых зависят налоги с фиксированной ставкой
+type TaxContext struct {
+	Nights   int
+	Adults   int
+	Children int
+}
+
+// units возвращает число облагаемых единиц для правила с фиксированной суммой
+func (c TaxContext) units(rule models.TaxRule) int64 {
+	switch rule.Basis {
+	case models.TaxBasisNight:
+		return int64(c.Nights)
+	case models.TaxBasisGuestNight:
+		guests := c.Adults
+		if !rule.ExemptChildren {
+			guests += c.Children
+		}
+		return int64(guests * c.Nights)
+	}
+	return 1
+}
+
+// RulesIncludeVAT сообщает, есть ли среди действующих правил для chargeType процентный налог,
+// включённый в цену. Такое правило и есть НДС, и ставка из каталога услуг тогда не применяется.
+func RulesIncludeVAT(rules []models.TaxRule, chargeType string) bool {
+	for _, rule := range rules {
+		if rule.ChargeType == chargeType && rule.Active && rule.Calc == models.TaxCalcPercent && rule.Inclusive {
+			return true
+		}
+	}
+	return false
+}
+
+// ApplyTaxes рассчитывает налоги для начисления типа chargeType на сумму base.
+// Правила других типов начислений пропускаются.
+func ApplyTaxes(rules []models.TaxRule, chargeType string, base models.Money, ctx TaxContext) ([]models.TaxLine, error) {
+	var lines []models.TaxLine
+	for _, rule := range rules {
+		if rule.ChargeType != chargeType || !rule.Active {
+			continue
+		}
+		var amount models.Money
+		var err error
+		switch rule.Calc {
+		case models.TaxCalcPercent:
+			if rule.Inclusive {
+				amount, err = IncludedTax(base, rule.Rate)
+			} else {
+				amount, err = base.Percent(rule.Rate, Rounding)
+			}
+		case models.TaxCalcFixed:
+			amount, err = rule.Amount.Mul(ctx.units(rule))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error applying tax %s: %v", rule.Name, err)
+		}
+		if amount.IsZero() {
+			continue
+		}
+		ruleID := rule.ID
+		lines = append(lines, models.TaxLine{
+			RuleID:     &ruleID,
+			Name:       rule.Name,
+			ChargeType: chargeType,
+			Taxable:    base,
+			Amount:     amount,
+			Inclusive:  rule.Inclusive,
+		})
+	}
+	return lines, nil
+}
+
+// ExclusiveTaxTotal — сумма налогов, которые начисляются сверх цены
+func ExclusiveTaxTotal(lines []models.TaxLine) models.Money {
+	var total models.Money
+	for _, l := range lines {
+		if !l.Inclusive {
+			total = total.Add(l.Amount)
+		}
+	}
+	return total
+}
+
+// TaxTotal — сумма всех налогов, включённых в цену и начисленных сверх неё
+func TaxTotal(lines []models.TaxLine) models.Money {
+	var total models.Money
+	for _, l := range lines {
+		total = total.Add(l.Amount)
+	}
+	return total
+}
@@ -0,0 +1,42 @@
+package services
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"mis_kursach_backend/internal/models"
+	"strings"
+)
+
+// без похожих друг на друга символов (0/O, 1/I)
+const voucherAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// GenerateVoucherCode возвращает случайный код вида GC-XXXX-XXXX
+func GenerateVoucherCode() (string, error) {
+	var b strings.Builder
+	b.WriteString("GC")
+	for i := 0; i < 8; i++ {
+		if i%4 == 0 {
+			b.WriteByte('-')
+		}
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(voucherAlphabet))))
+		if err != nil {
+			return "", fmt.Errorf("error generating voucher code: %v", err)
+		}
+		b.WriteByte(voucherAlphabet[n.Int64()])
+	}
+	return b.String(), nil
+}
+
+func NormalizeVoucherCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// NightsShare возвращает долю суммы total, распределённой на nights ночей, за первые k из них
+func NightsShare(total models.Money, nights, k int) models.Money {
+	var share models.Money
+	for _, part := range total.Allocate(nights)[:k] {
+		share = share.Add(part)
+	}
+	return share
+}
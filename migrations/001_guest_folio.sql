@@ -0,0 +1,36 @@
+-- Каталог дополнительных услуг (мини-бар, прачечная, ресторан)
+CREATE TABLE IF NOT EXISTS SERVICES (
+    ID       SERIAL PRIMARY KEY,
+    CODE     VARCHAR(32)    NOT NULL UNIQUE,
+    NAME     VARCHAR(255)   NOT NULL,
+    PRICE    NUMERIC(12, 2) NOT NULL CHECK (PRICE >= 0),
+    TAX_RATE NUMERIC(5, 2)  NOT NULL DEFAULT 0,
+    ACTIVE   BOOLEAN        NOT NULL DEFAULT TRUE
+);
+
+-- Строки фолио: начисления за услуги в рамках бронирования.
+-- Строки не удаляются, а аннулируются (VOIDED_AT).
+CREATE TABLE IF NOT EXISTS FOLIO_CHARGES (
+    ID          SERIAL PRIMARY KEY,
+    BOOKING_ID  INT            NOT NULL REFERENCES BOOKINGS (ID),
+    SERVICE_ID  INT            NOT NULL REFERENCES SERVICES (ID),
+    QUANTITY    INT            NOT NULL CHECK (QUANTITY > 0),
+    UNIT_PRICE  NUMERIC(12, 2) NOT NULL,
+    TAX_RATE    NUMERIC(5, 2)  NOT NULL,
+    AMOUNT      NUMERIC(12, 2) NOT NULL,
+    TAX_AMOUNT  NUMERIC(12, 2) NOT NULL,
+    COMMENTARY  TEXT,
+    POSTED_AT   TIMESTAMP      NOT NULL DEFAULT NOW(),
+    POSTED_BY   INT REFERENCES USERS (ID),
+    VOIDED_AT   TIMESTAMP,
+    VOIDED_BY   INT REFERENCES USERS (ID),
+    VOID_REASON TEXT
+);
+
+CREATE INDEX IF NOT EXISTS FOLIO_CHARGES_BOOKING_ID_IDX ON FOLIO_CHARGES (BOOKING_ID);
+
+INSERT INTO SERVICES (CODE, NAME, PRICE, TAX_RATE)
+VALUES ('MINIBAR', 'Мини-бар', 0, 20),
+       ('LAUNDRY', 'Прачечная', 500, 20),
+       ('RESTAURANT', 'Ресторан', 0, 20)
+ON CONFLICT (CODE) DO NOTHING;
@@ -0,0 +1,17 @@
+-- Денежные суммы храним в NUMERIC, чтобы не терять копейки при округлении float
+ALTER TABLE BOOKINGS
+    ALTER COLUMN BOOKING_SUM TYPE NUMERIC(12, 2) USING ROUND(BOOKING_SUM::NUMERIC, 2),
+    ALTER COLUMN TOTAL_SUM TYPE NUMERIC(12, 2) USING ROUND(TOTAL_SUM::NUMERIC, 2);
+
+ALTER TABLE PAYMENTS
+    ALTER COLUMN AMOUNT TYPE NUMERIC(12, 2) USING ROUND(AMOUNT::NUMERIC, 2);
+
+ALTER TABLE TARIFFS
+    ALTER COLUMN BASE_PRICE TYPE NUMERIC(12, 2) USING ROUND(BASE_PRICE::NUMERIC, 2);
+
+-- Процент скидки и коэффициенты тарифов — не деньги, но тоже с фиксированной точкой
+ALTER TABLE DISCOUNTS
+    ALTER COLUMN AMOUNT TYPE NUMERIC(9, 4) USING AMOUNT::NUMERIC;
+
+ALTER TABLE TARIFF_COEFFICIENTS
+    ALTER COLUMN COEFFICIENT TYPE NUMERIC(12, 6) USING COEFFICIENT::NUMERIC;
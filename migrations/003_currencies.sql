@@ -0,0 +1,27 @@
+-- Курсы валют к базовой валюте отеля, действующие с указанной даты
+CREATE TABLE IF NOT EXISTS EXCHANGE_RATES (
+    ID             SERIAL PRIMARY KEY,
+    CURRENCY       CHAR(3)        NOT NULL,
+    RATE           NUMERIC(18, 6) NOT NULL CHECK (RATE > 0),
+    EFFECTIVE_DATE DATE           NOT NULL,
+    CREATED_BY     INT REFERENCES USERS (ID),
+    CREATED_AT     TIMESTAMP      NOT NULL DEFAULT NOW(),
+    UNIQUE (CURRENCY, EFFECTIVE_DATE)
+);
+
+-- AMOUNT остаётся суммой в базовой валюте, исходная сумма платежа хранится отдельно
+ALTER TABLE PAYMENTS
+    ADD COLUMN IF NOT EXISTS ORIGINAL_AMOUNT   NUMERIC(12, 2),
+    ADD COLUMN IF NOT EXISTS ORIGINAL_CURRENCY CHAR(3),
+    ADD COLUMN IF NOT EXISTS EXCHANGE_RATE     NUMERIC(18, 6) NOT NULL DEFAULT 1;
+
+-- Платежи до этой миграции принимались только в рублях, поэтому они заполняются как RUB.
+-- Другую базовую валюту можно задать только для пустой базы — см. 025_base_currency.sql.
+UPDATE PAYMENTS
+SET ORIGINAL_AMOUNT   = AMOUNT,
+    ORIGINAL_CURRENCY = 'RUB'
+WHERE ORIGINAL_AMOUNT IS NULL;
+
+ALTER TABLE PAYMENTS
+    ALTER COLUMN ORIGINAL_AMOUNT SET NOT NULL,
+    ALTER COLUMN ORIGINAL_CURRENCY SET NOT NULL;
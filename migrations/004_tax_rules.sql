@@ -0,0 +1,43 @@
+-- Налоговые правила по типу начисления (room — проживание, service — доп. услуги).
+-- CALC = 'percent': RATE — процент от суммы; CALC = 'fixed': AMOUNT за единицу BASIS.
+CREATE TABLE IF NOT EXISTS TAX_RULES (
+    ID              SERIAL PRIMARY KEY,
+    NAME            VARCHAR(255)   NOT NULL,
+    CHARGE_TYPE     VARCHAR(16)    NOT NULL CHECK (CHARGE_TYPE IN ('room', 'service')),
+    CALC            VARCHAR(16)    NOT NULL CHECK (CALC IN ('percent', 'fixed')),
+    RATE            NUMERIC(9, 4)  NOT NULL DEFAULT 0,
+    AMOUNT          NUMERIC(12, 2) NOT NULL DEFAULT 0,
+    INCLUSIVE       BOOLEAN        NOT NULL DEFAULT FALSE,
+    BASIS           VARCHAR(16)    NOT NULL DEFAULT 'stay' CHECK (BASIS IN ('stay', 'night', 'guest_night')),
+    EXEMPT_CHILDREN BOOLEAN        NOT NULL DEFAULT FALSE,
+    ACTIVE          BOOLEAN        NOT NULL DEFAULT TRUE,
+    VALID_FROM      DATE           NOT NULL DEFAULT CURRENT_DATE,
+    VALID_TO        DATE
+);
+
+-- Разбивка налогов по бронированиям и строкам фолио
+CREATE TABLE IF NOT EXISTS TAX_LINES (
+    ID              SERIAL PRIMARY KEY,
+    BOOKING_ID      INT            NOT NULL REFERENCES BOOKINGS (ID),
+    FOLIO_CHARGE_ID INT REFERENCES FOLIO_CHARGES (ID),
+    TAX_RULE_ID     INT REFERENCES TAX_RULES (ID),
+    NAME            VARCHAR(255)   NOT NULL,
+    CHARGE_TYPE     VARCHAR(16)    NOT NULL,
+    TAXABLE         NUMERIC(12, 2) NOT NULL,
+    AMOUNT          NUMERIC(12, 2) NOT NULL,
+    INCLUSIVE       BOOLEAN        NOT NULL,
+    CREATED_AT      TIMESTAMP      NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS TAX_LINES_BOOKING_ID_IDX ON TAX_LINES (BOOKING_ID);
+CREATE INDEX IF NOT EXISTS TAX_LINES_CREATED_AT_IDX ON TAX_LINES (CREATED_AT);
+
+-- Число гостей нужно для туристического налога; TAX_SUM — налоги сверх цены, включённые в TOTAL_SUM
+ALTER TABLE BOOKINGS
+    ADD COLUMN IF NOT EXISTS ADULTS   INT            NOT NULL DEFAULT 1,
+    ADD COLUMN IF NOT EXISTS CHILDREN INT            NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS TAX_SUM  NUMERIC(12, 2) NOT NULL DEFAULT 0;
+
+INSERT INTO TAX_RULES (NAME, CHARGE_TYPE, CALC, RATE, AMOUNT, INCLUSIVE, BASIS, EXEMPT_CHILDREN)
+VALUES ('НДС', 'room', 'percent', 20, 0, TRUE, 'stay', FALSE),
+       ('Туристический налог', 'room', 'fixed', 0, 100, FALSE, 'guest_night', TRUE);
@@ -0,0 +1,68 @@
+-- План счетов
+CREATE TABLE IF NOT EXISTS ACCOUNTS (
+    CODE VARCHAR(32)  PRIMARY KEY,
+    NAME VARCHAR(255) NOT NULL,
+    TYPE VARCHAR(16)  NOT NULL CHECK (TYPE IN ('asset', 'liability', 'revenue', 'expense'))
+);
+
+INSERT INTO ACCOUNTS (CODE, NAME, TYPE)
+VALUES ('guest_receivable', 'Расчёты с гостями', 'asset'),
+       ('room_revenue', 'Выручка от проживания', 'revenue'),
+       ('extras_revenue', 'Выручка от доп. услуг', 'revenue'),
+       ('tax_payable', 'Налоги к уплате', 'liability'),
+       ('cash', 'Касса', 'asset'),
+       ('card_clearing', 'Расчёты по картам', 'asset')
+ON CONFLICT (CODE) DO NOTHING;
+
+-- Проводки только добавляются; исправление — сторнирующей проводкой.
+-- BOOKING_ID и PAYMENT_ID без внешних ключей: история остаётся после удаления бронирования.
+CREATE TABLE IF NOT EXISTS LEDGER_TRANSACTIONS (
+    ID              SERIAL PRIMARY KEY,
+    KIND            VARCHAR(32) NOT NULL,
+    BOOKING_ID      INT,
+    PAYMENT_ID      INT,
+    FOLIO_CHARGE_ID INT,
+    MEMO            TEXT,
+    BUSINESS_DATE   DATE        NOT NULL DEFAULT CURRENT_DATE,
+    POSTED_AT       TIMESTAMP   NOT NULL DEFAULT NOW(),
+    CREATED_BY      INT REFERENCES USERS (ID)
+);
+
+CREATE TABLE IF NOT EXISTS LEDGER_ENTRIES (
+    ID             SERIAL PRIMARY KEY,
+    TRANSACTION_ID INT            NOT NULL REFERENCES LEDGER_TRANSACTIONS (ID),
+    ACCOUNT_CODE   VARCHAR(32)    NOT NULL REFERENCES ACCOUNTS (CODE),
+    DEBIT          NUMERIC(12, 2) NOT NULL DEFAULT 0 CHECK (DEBIT >= 0),
+    CREDIT         NUMERIC(12, 2) NOT NULL DEFAULT 0 CHECK (CREDIT >= 0),
+    CHECK ((DEBIT = 0) <> (CREDIT = 0))
+);
+
+CREATE INDEX IF NOT EXISTS LEDGER_TRANSACTIONS_BOOKING_ID_IDX ON LEDGER_TRANSACTIONS (BOOKING_ID);
+CREATE INDEX IF NOT EXISTS LEDGER_ENTRIES_ACCOUNT_CODE_IDX ON LEDGER_ENTRIES (ACCOUNT_CODE);
+
+CREATE OR REPLACE FUNCTION LEDGER_APPEND_ONLY() RETURNS TRIGGER AS
+$$
+BEGIN
+    RAISE EXCEPTION 'ledger is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS LEDGER_TRANSACTIONS_APPEND_ONLY ON LEDGER_TRANSACTIONS;
+CREATE TRIGGER LEDGER_TRANSACTIONS_APPEND_ONLY
+    BEFORE UPDATE OR DELETE ON LEDGER_TRANSACTIONS
+    FOR EACH ROW EXECUTE FUNCTION LEDGER_APPEND_ONLY();
+
+DROP TRIGGER IF EXISTS LEDGER_ENTRIES_APPEND_ONLY ON LEDGER_ENTRIES;
+CREATE TRIGGER LEDGER_ENTRIES_APPEND_ONLY
+    BEFORE UPDATE OR DELETE ON LEDGER_ENTRIES
+    FOR EACH ROW EXECUTE FUNCTION LEDGER_APPEND_ONLY();
+
+-- Счёт, на который приходят деньги по способу оплаты
+ALTER TABLE PAYMENT_METHODS
+    ADD COLUMN IF NOT EXISTS LEDGER_ACCOUNT VARCHAR(32) NOT NULL DEFAULT 'card_clearing' REFERENCES ACCOUNTS (CODE);
+
+UPDATE PAYMENT_METHODS SET LEDGER_ACCOUNT = 'cash' WHERE LOWER(NAME) LIKE '%налич%';
+
+INSERT INTO PAYMENT_STATUSES (STATUS_CODE, NAME)
+VALUES (3, 'Возвращён')
+ON CONFLICT (STATUS_CODE) DO NOTHING;
@@ -0,0 +1,15 @@
+-- Закрытые бизнес-дни. Дата попадает сюда после ночного аудита,
+-- после чего изменения задним числом в этот день запрещены.
+CREATE TABLE IF NOT EXISTS BUSINESS_DAYS (
+    BUSINESS_DATE DATE      PRIMARY KEY,
+    CLOSED_AT     TIMESTAMP NOT NULL DEFAULT NOW(),
+    CLOSED_BY     INT REFERENCES USERS (ID),
+    FORCED        BOOLEAN   NOT NULL DEFAULT FALSE,
+    REPORT        JSONB     NOT NULL
+);
+
+-- Заезд не состоялся: гость не заселился в день заезда
+ALTER TABLE BOOKINGS
+    ADD COLUMN IF NOT EXISTS NO_SHOW_CANDIDATE BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX IF NOT EXISTS LEDGER_TRANSACTIONS_BUSINESS_DATE_IDX ON LEDGER_TRANSACTIONS (BUSINESS_DATE);
@@ -0,0 +1,59 @@
+-- Организации, проживание сотрудников которых оплачивается по договору
+CREATE TABLE IF NOT EXISTS COMPANIES (
+    ID                 SERIAL PRIMARY KEY,
+    NAME               VARCHAR(255)   NOT NULL,
+    TAX_ID             VARCHAR(20)    NOT NULL UNIQUE,
+    EMAIL              VARCHAR(255),
+    PHONE_NUMBER       VARCHAR(20),
+    ADDRESS            TEXT,
+    CREDIT_LIMIT       NUMERIC(12, 2) NOT NULL DEFAULT 0 CHECK (CREDIT_LIMIT >= 0),
+    PAYMENT_TERMS_DAYS INT            NOT NULL DEFAULT 30 CHECK (PAYMENT_TERMS_DAYS >= 0),
+    ACTIVE             BOOLEAN        NOT NULL DEFAULT TRUE,
+    CREATED_AT         TIMESTAMP      NOT NULL DEFAULT NOW()
+);
+
+-- Договорная цена за ночь по категории номера, заменяет базовый тариф
+CREATE TABLE IF NOT EXISTS COMPANY_RATES (
+    COMPANY_ID    INT            NOT NULL REFERENCES COMPANIES (ID),
+    CATEGORY_CODE INT            NOT NULL REFERENCES ROOM_CATEGORIES (CODE),
+    PRICE         NUMERIC(12, 2) NOT NULL CHECK (PRICE > 0),
+    PRIMARY KEY (COMPANY_ID, CATEGORY_CODE)
+);
+
+-- Бронирование, оплачиваемое организацией, а не гостем
+ALTER TABLE BOOKINGS
+    ADD COLUMN IF NOT EXISTS COMPANY_ID INT REFERENCES COMPANIES (ID);
+
+CREATE TABLE IF NOT EXISTS COMPANY_INVOICES (
+    ID           SERIAL PRIMARY KEY,
+    COMPANY_ID   INT            NOT NULL REFERENCES COMPANIES (ID),
+    PERIOD_START DATE           NOT NULL,
+    PERIOD_END   DATE           NOT NULL,
+    ISSUE_DATE   DATE           NOT NULL,
+    DUE_DATE     DATE           NOT NULL,
+    AMOUNT       NUMERIC(12, 2) NOT NULL,
+    PAID_AMOUNT  NUMERIC(12, 2) NOT NULL DEFAULT 0,
+    STATUS       VARCHAR(16)    NOT NULL DEFAULT 'issued' CHECK (STATUS IN ('issued', 'paid')),
+    CREATED_BY   INT REFERENCES USERS (ID),
+    CREATED_AT   TIMESTAMP      NOT NULL DEFAULT NOW()
+);
+
+-- Каждое проживание попадает ровно в один счёт организации
+CREATE TABLE IF NOT EXISTS COMPANY_INVOICE_LINES (
+    INVOICE_ID INT            NOT NULL REFERENCES COMPANY_INVOICES (ID),
+    BOOKING_ID INT            NOT NULL UNIQUE REFERENCES BOOKINGS (ID),
+    AMOUNT     NUMERIC(12, 2) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS COMPANY_INVOICE_PAYMENTS (
+    ID          SERIAL PRIMARY KEY,
+    INVOICE_ID  INT            NOT NULL REFERENCES COMPANY_INVOICES (ID),
+    AMOUNT      NUMERIC(12, 2) NOT NULL CHECK (AMOUNT > 0),
+    METHOD_CODE INT            NOT NULL REFERENCES PAYMENT_METHODS (CODE),
+    PAID_AT     TIMESTAMP      NOT NULL DEFAULT NOW(),
+    CREATED_BY  INT REFERENCES USERS (ID)
+);
+
+INSERT INTO ACCOUNTS (CODE, NAME, TYPE)
+VALUES ('company_receivable', 'Расчёты с организациями', 'asset')
+ON CONFLICT (CODE) DO NOTHING;
@@ -0,0 +1,44 @@
+INSERT INTO ACCOUNTS (CODE, NAME, TYPE)
+VALUES ('voucher_liability', 'Обязательства по сертификатам', 'liability')
+ON CONFLICT (CODE) DO NOTHING;
+
+-- Подарочные сертификаты: на сумму или на фиксированное число ночей.
+-- VALUE — сумма, уплаченная покупателем; BALANCE — её непогашенный остаток.
+CREATE TABLE IF NOT EXISTS VOUCHERS (
+    ID               SERIAL PRIMARY KEY,
+    CODE             VARCHAR(32)    NOT NULL UNIQUE,
+    KIND             VARCHAR(16)    NOT NULL CHECK (KIND IN ('value', 'nights')),
+    VALUE            NUMERIC(12, 2) NOT NULL CHECK (VALUE > 0),
+    BALANCE          NUMERIC(12, 2) NOT NULL CHECK (BALANCE >= 0),
+    NIGHTS           INT CHECK (NIGHTS > 0),
+    NIGHTS_REMAINING INT CHECK (NIGHTS_REMAINING >= 0),
+    SOLD_TO          VARCHAR(255),
+    ISSUED_AT        TIMESTAMP      NOT NULL DEFAULT NOW(),
+    EXPIRES_AT       DATE           NOT NULL,
+    CREATED_BY       INT REFERENCES USERS (ID),
+    CHECK ((KIND = 'nights') = (NIGHTS IS NOT NULL))
+);
+
+-- PAYMENT_ID без внешнего ключа: история погашения остаётся после удаления платежа
+CREATE TABLE IF NOT EXISTS VOUCHER_REDEMPTIONS (
+    ID          SERIAL PRIMARY KEY,
+    VOUCHER_ID  INT            NOT NULL REFERENCES VOUCHERS (ID),
+    PAYMENT_ID  INT            NOT NULL,
+    BOOKING_ID  INT            NOT NULL,
+    AMOUNT      NUMERIC(12, 2) NOT NULL,
+    VALUE       NUMERIC(12, 2) NOT NULL,
+    NIGHTS      INT            NOT NULL DEFAULT 0,
+    REDEEMED_AT TIMESTAMP      NOT NULL DEFAULT NOW(),
+    REVERSED_AT TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS VOUCHER_REDEMPTIONS_PAYMENT_ID_IDX ON VOUCHER_REDEMPTIONS (PAYMENT_ID);
+
+-- Сертификат принимается как способ оплаты
+ALTER TABLE PAYMENT_METHODS
+    ADD COLUMN IF NOT EXISTS IS_VOUCHER BOOLEAN NOT NULL DEFAULT FALSE;
+
+INSERT INTO PAYMENT_METHODS (CODE, NAME, LEDGER_ACCOUNT, IS_VOUCHER)
+SELECT COALESCE(MAX(CODE), 0) + 1, 'Подарочный сертификат', 'voucher_liability', TRUE
+FROM PAYMENT_METHODS
+HAVING NOT BOOL_OR(IS_VOUCHER) OR COUNT(*) = 0;
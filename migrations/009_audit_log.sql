@@ -0,0 +1,13 @@
+-- Журнал действий над данными: кто, когда и что изменил.
+-- DETAILS хранит снимок затронутых данных, чтобы действие можно было разобрать вручную.
+CREATE TABLE IF NOT EXISTS AUDIT_LOG (
+    ID         SERIAL PRIMARY KEY,
+    ENTITY     VARCHAR(32) NOT NULL,
+    ENTITY_ID  INT         NOT NULL,
+    ACTION     VARCHAR(32) NOT NULL,
+    DETAILS    JSONB       NOT NULL DEFAULT '{}',
+    USER_ID    INT REFERENCES USERS (ID),
+    CREATED_AT TIMESTAMP   NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS AUDIT_LOG_ENTITY_IDX ON AUDIT_LOG (ENTITY, ENTITY_ID);
@@ -0,0 +1,38 @@
+-- Тип документа и гражданство гостя
+ALTER TABLE GUESTS
+    ADD COLUMN IF NOT EXISTS DOCUMENT_TYPE VARCHAR(16) NOT NULL DEFAULT 'ru_passport',
+    ADD COLUMN IF NOT EXISTS NATIONALITY   CHAR(2);
+
+-- Номера документов храним без пробелов и разделителей, буквы в верхнем регистре
+UPDATE GUESTS
+SET PASSPORT_NO = UPPER(REGEXP_REPLACE(PASSPORT_NO, '[^[:alnum:]]', '', 'g'))
+WHERE PASSPORT_NO ~ '[^[:alnum:]]' OR PASSPORT_NO <> UPPER(PASSPORT_NO);
+
+-- Тип документа определяем по формату номера; у прочих документов гражданство неизвестно
+UPDATE GUESTS
+SET DOCUMENT_TYPE = CASE
+        WHEN PASSPORT_NO ~ '^\d{10}$' THEN 'ru_passport'
+        WHEN PASSPORT_NO ~ '^\d{9}$' THEN 'foreign_passport'
+        ELSE 'other'
+    END;
+
+UPDATE GUESTS
+SET NATIONALITY = 'RU'
+WHERE NATIONALITY IS NULL AND DOCUMENT_TYPE IN ('ru_passport', 'foreign_passport');
+
+ALTER TABLE GUESTS DROP CONSTRAINT IF EXISTS GUESTS_DOCUMENT_TYPE_CHECK;
+ALTER TABLE GUESTS
+    ADD CONSTRAINT GUESTS_DOCUMENT_TYPE_CHECK CHECK (DOCUMENT_TYPE IN ('ru_passport', 'foreign_passport', 'other'));
+
+-- Телефоны в формате E.164. Российские номера без кода страны дополняются +7,
+-- номера, которые нельзя однозначно разобрать, остаются как есть.
+UPDATE GUESTS
+SET PHONE_NUMBER = CASE
+        WHEN PHONE_NUMBER ~ '^\s*\+' THEN '+' || REGEXP_REPLACE(PHONE_NUMBER, '\D', '', 'g')
+        WHEN REGEXP_REPLACE(PHONE_NUMBER, '\D', '', 'g') ~ '^[78]\d{10}$'
+            THEN '+7' || SUBSTRING(REGEXP_REPLACE(PHONE_NUMBER, '\D', '', 'g') FROM 2)
+        WHEN REGEXP_REPLACE(PHONE_NUMBER, '\D', '', 'g') ~ '^9\d{9}$'
+            THEN '+7' || REGEXP_REPLACE(PHONE_NUMBER, '\D', '', 'g')
+        ELSE PHONE_NUMBER
+    END
+WHERE PHONE_NUMBER IS NOT NULL AND PHONE_NUMBER <> '';
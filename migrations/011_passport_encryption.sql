@@ -0,0 +1,24 @@
+-- Номера паспортов шифруются приложением. PASSPORT_ENC — шифротекст с идентификатором ключа,
+-- PASSPORT_HASH — HMAC нормализованного номера для поиска по точному совпадению.
+-- Существующие номера шифрует команда cmd/passportkeys, она же очищает PASSPORT_NO.
+ALTER TABLE GUESTS
+    ADD COLUMN IF NOT EXISTS PASSPORT_ENC  TEXT,
+    ADD COLUMN IF NOT EXISTS PASSPORT_HASH CHAR(64);
+
+ALTER TABLE GUESTS ALTER COLUMN PASSPORT_NO DROP NOT NULL;
+
+CREATE INDEX IF NOT EXISTS GUESTS_PASSPORT_HASH_IDX ON GUESTS (PASSPORT_HASH);
+
+-- Роли пользователей. Существующие пользователи сохраняют полный доступ.
+ALTER TABLE USERS
+    ADD COLUMN IF NOT EXISTS ROLE VARCHAR(16);
+
+UPDATE USERS SET ROLE = 'admin' WHERE ROLE IS NULL;
+
+ALTER TABLE USERS
+    ALTER COLUMN ROLE SET DEFAULT 'receptionist',
+    ALTER COLUMN ROLE SET NOT NULL;
+
+ALTER TABLE USERS DROP CONSTRAINT IF EXISTS USERS_ROLE_CHECK;
+ALTER TABLE USERS
+    ADD CONSTRAINT USERS_ROLE_CHECK CHECK (ROLE IN ('admin', 'manager', 'receptionist', 'housekeeper'));
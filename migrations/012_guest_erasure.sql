@@ -0,0 +1,4 @@
+-- Время обезличивания гостя по его запросу. Профиль остаётся, чтобы бронирования
+-- и платежи сохранили связь для бухгалтерской отчётности.
+ALTER TABLE GUESTS
+    ADD COLUMN IF NOT EXISTS ERASED_AT TIMESTAMP;
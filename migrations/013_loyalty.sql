@@ -0,0 +1,48 @@
+INSERT INTO ACCOUNTS (CODE, NAME, TYPE)
+VALUES ('loyalty_liability', 'Обязательства по баллам лояльности', 'liability'),
+       ('loyalty_expense', 'Расходы на программу лояльности', 'expense')
+ON CONFLICT (CODE) DO NOTHING;
+
+-- Уровни программы лояльности: порог — баллы, заработанные за последние 12 месяцев
+CREATE TABLE IF NOT EXISTS LOYALTY_TIERS (
+    CODE             VARCHAR(16)   PRIMARY KEY,
+    NAME             VARCHAR(64)   NOT NULL,
+    MIN_POINTS       INT           NOT NULL UNIQUE CHECK (MIN_POINTS >= 0),
+    DISCOUNT_PERCENT NUMERIC(5, 2) NOT NULL DEFAULT 0 CHECK (DISCOUNT_PERCENT >= 0 AND DISCOUNT_PERCENT < 100)
+);
+
+INSERT INTO LOYALTY_TIERS (CODE, NAME, MIN_POINTS, DISCOUNT_PERCENT)
+VALUES ('base', 'Базовый', 0, 0),
+       ('silver', 'Серебряный', 1000, 3),
+       ('gold', 'Золотой', 5000, 7)
+ON CONFLICT (CODE) DO NOTHING;
+
+-- Движение баллов. Начисления и возвраты — партии со сроком действия,
+-- REMAINING уменьшается при оплате баллами и при сгорании.
+CREATE TABLE IF NOT EXISTS LOYALTY_TRANSACTIONS (
+    ID          SERIAL PRIMARY KEY,
+    GUEST_ID    INT         NOT NULL REFERENCES GUESTS (ID),
+    KIND        VARCHAR(16) NOT NULL CHECK (KIND IN ('earn', 'redeem', 'restore', 'expire')),
+    POINTS      INT         NOT NULL,
+    REMAINING   INT         NOT NULL DEFAULT 0 CHECK (REMAINING >= 0),
+    BOOKING_ID  INT,
+    PAYMENT_ID  INT,
+    EXPIRES_AT  DATE,
+    CREATED_AT  TIMESTAMP   NOT NULL DEFAULT NOW(),
+    REVERSED_AT TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS LOYALTY_TRANSACTIONS_GUEST_ID_IDX ON LOYALTY_TRANSACTIONS (GUEST_ID);
+CREATE INDEX IF NOT EXISTS LOYALTY_TRANSACTIONS_PAYMENT_ID_IDX ON LOYALTY_TRANSACTIONS (PAYMENT_ID);
+-- за одно бронирование баллы начисляются один раз
+CREATE UNIQUE INDEX IF NOT EXISTS LOYALTY_TRANSACTIONS_EARN_BOOKING_IDX
+    ON LOYALTY_TRANSACTIONS (BOOKING_ID) WHERE KIND = 'earn';
+
+-- Баллы принимаются как способ оплаты
+ALTER TABLE PAYMENT_METHODS
+    ADD COLUMN IF NOT EXISTS IS_LOYALTY BOOLEAN NOT NULL DEFAULT FALSE;
+
+INSERT INTO PAYMENT_METHODS (CODE, NAME, LEDGER_ACCOUNT, IS_LOYALTY)
+SELECT COALESCE(MAX(CODE), 0) + 1, 'Баллы лояльности', 'loyalty_liability', TRUE
+FROM PAYMENT_METHODS
+HAVING NOT BOOL_OR(IS_LOYALTY) OR COUNT(*) = 0;
@@ -0,0 +1,15 @@
+-- Чёрный список гостей: block запрещает бронирование (снять запрет может только администратор
+-- с обоснованием), warn лишь предупреждает сотрудника при оформлении
+CREATE TABLE IF NOT EXISTS GUEST_BLACKLIST (
+    ID         SERIAL PRIMARY KEY,
+    GUEST_ID   INT        NOT NULL REFERENCES GUESTS (ID),
+    REASON     TEXT       NOT NULL,
+    SEVERITY   VARCHAR(8) NOT NULL CHECK (SEVERITY IN ('block', 'warn')),
+    CREATED_BY INT REFERENCES USERS (ID),
+    CREATED_AT TIMESTAMP  NOT NULL DEFAULT NOW(),
+    EXPIRES_AT DATE,
+    LIFTED_AT  TIMESTAMP,
+    LIFTED_BY  INT REFERENCES USERS (ID)
+);
+
+CREATE INDEX IF NOT EXISTS IDX_GUEST_BLACKLIST_GUEST ON GUEST_BLACKLIST (GUEST_ID);
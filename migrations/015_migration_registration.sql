@@ -0,0 +1,22 @@
+-- Миграционные документы иностранных гостей
+ALTER TABLE GUESTS
+    ADD COLUMN IF NOT EXISTS VISA_NUMBER               VARCHAR(32),
+    ADD COLUMN IF NOT EXISTS VISA_EXPIRES_AT           DATE,
+    ADD COLUMN IF NOT EXISTS MIGRATION_CARD_NO         VARCHAR(32),
+    ADD COLUMN IF NOT EXISTS ENTRY_DATE                DATE,
+    ADD COLUMN IF NOT EXISTS MIGRATION_CARD_EXPIRES_AT DATE;
+
+-- Уведомления о прибытии иностранного гостя. Строка появляется при выгрузке в программу
+-- постановки на миграционный учёт; FILED_AT — отметка, что уведомление подано.
+CREATE TABLE IF NOT EXISTS MIGRATION_NOTICES (
+    ID              SERIAL PRIMARY KEY,
+    BOOKING_ID      INT       NOT NULL REFERENCES BOOKINGS (ID),
+    GUEST_ID        INT       NOT NULL REFERENCES GUESTS (ID),
+    ARRIVAL_DATE    DATE      NOT NULL,
+    DEADLINE        DATE      NOT NULL,
+    EXPORTED_AT     TIMESTAMP,
+    FILED_AT        TIMESTAMP,
+    FILED_BY        INT REFERENCES USERS (ID),
+    CONFIRMATION_NO VARCHAR(64),
+    UNIQUE (BOOKING_ID, GUEST_ID)
+);
@@ -0,0 +1,51 @@
+-- Номер бронирования, который сообщают гостю; по внутреннему ID гость не входит,
+-- чтобы нельзя было перебрать чужие бронирования
+ALTER TABLE BOOKINGS
+    ADD COLUMN IF NOT EXISTS REFERENCE VARCHAR(12);
+
+-- Номера существующих бронирований заполняются тем же алфавитом и той же длиной, что и в
+-- GenerateBookingReference, из криптостойкого генератора; совпавшие номера генерируются заново
+CREATE EXTENSION IF NOT EXISTS PGCRYPTO;
+
+DO
+$$
+BEGIN
+    UPDATE BOOKINGS B
+    SET REFERENCE = (SELECT STRING_AGG(SUBSTR('ABCDEFGHJKLMNPQRSTUVWXYZ23456789', GET_BYTE(R.BYTES, I) % 32 + 1, 1), '' ORDER BY I)
+                     FROM (SELECT GEN_RANDOM_BYTES(8) AS BYTES, B.ID) R,
+                          GENERATE_SERIES(0, 7) I)
+    WHERE B.REFERENCE IS NULL;
+    LOOP
+        UPDATE BOOKINGS B
+        SET REFERENCE = (SELECT STRING_AGG(SUBSTR('ABCDEFGHJKLMNPQRSTUVWXYZ23456789', GET_BYTE(R.BYTES, I) % 32 + 1, 1), '' ORDER BY I)
+                         FROM (SELECT GEN_RANDOM_BYTES(8) AS BYTES, B.ID) R,
+                              GENERATE_SERIES(0, 7) I)
+        WHERE B.ID IN (SELECT D.ID
+                       FROM (SELECT ID, ROW_NUMBER() OVER (PARTITION BY REFERENCE ORDER BY ID) AS RN FROM BOOKINGS) D
+                       WHERE D.RN > 1);
+        EXIT WHEN NOT FOUND;
+    END LOOP;
+END;
+$$;
+
+ALTER TABLE BOOKINGS
+    ALTER COLUMN REFERENCE SET NOT NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS IDX_BOOKINGS_REFERENCE ON BOOKINGS (REFERENCE);
+
+-- Почта гостя для одноразовых кодов входа
+ALTER TABLE GUESTS
+    ADD COLUMN IF NOT EXISTS EMAIL VARCHAR(254);
+
+-- Одноразовые коды входа гостя; хранится только хеш кода
+CREATE TABLE IF NOT EXISTS GUEST_ACCESS_CODES (
+    ID         SERIAL PRIMARY KEY,
+    BOOKING_ID INT         NOT NULL REFERENCES BOOKINGS (ID),
+    CODE_HASH  VARCHAR(64) NOT NULL,
+    CREATED_AT TIMESTAMP   NOT NULL DEFAULT NOW(),
+    EXPIRES_AT TIMESTAMP   NOT NULL,
+    ATTEMPTS   INT         NOT NULL DEFAULT 0,
+    USED_AT    TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS IDX_GUEST_ACCESS_CODES_BOOKING ON GUEST_ACCESS_CODES (BOOKING_ID);
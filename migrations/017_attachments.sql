@@ -0,0 +1,19 @@
+-- Вложения гостей и бронирований: сканы паспортов, регистрационные карты и прочие документы.
+-- Сами файлы лежат в хранилище под STORAGE_KEY, здесь только метаданные.
+CREATE TABLE IF NOT EXISTS ATTACHMENTS (
+    ID           SERIAL PRIMARY KEY,
+    GUEST_ID     INT REFERENCES GUESTS (ID),
+    BOOKING_ID   INT REFERENCES BOOKINGS (ID),
+    KIND         VARCHAR(32)  NOT NULL CHECK (KIND IN ('passport_scan', 'registration_card', 'other')),
+    FILE_NAME    VARCHAR(255) NOT NULL,
+    CONTENT_TYPE VARCHAR(100) NOT NULL,
+    SIZE_BYTES   BIGINT       NOT NULL CHECK (SIZE_BYTES > 0),
+    SHA256       VARCHAR(64)  NOT NULL,
+    STORAGE_KEY  VARCHAR(64)  NOT NULL UNIQUE,
+    UPLOADED_BY  INT REFERENCES USERS (ID),
+    UPLOADED_AT  TIMESTAMP    NOT NULL DEFAULT NOW(),
+    CHECK (GUEST_ID IS NOT NULL OR BOOKING_ID IS NOT NULL)
+);
+
+CREATE INDEX IF NOT EXISTS IDX_ATTACHMENTS_GUEST ON ATTACHMENTS (GUEST_ID);
+CREATE INDEX IF NOT EXISTS IDX_ATTACHMENTS_BOOKING ON ATTACHMENTS (BOOKING_ID);
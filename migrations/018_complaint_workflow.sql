@@ -0,0 +1,38 @@
+-- Категории жалоб; SLA_HOURS — за сколько часов жалоба обычного приоритета должна быть решена
+CREATE TABLE IF NOT EXISTS COMPLAINT_CATEGORIES (
+    CODE      VARCHAR(16) PRIMARY KEY,
+    NAME      VARCHAR(64) NOT NULL,
+    SLA_HOURS INT         NOT NULL CHECK (SLA_HOURS > 0)
+);
+
+INSERT INTO COMPLAINT_CATEGORIES (CODE, NAME, SLA_HOURS)
+VALUES ('noise', 'Шум', 2),
+       ('cleanliness', 'Чистота', 4),
+       ('maintenance', 'Неисправности', 8),
+       ('staff', 'Персонал', 24),
+       ('billing', 'Счета и оплата', 48),
+       ('other', 'Прочее', 24)
+ON CONFLICT (CODE) DO NOTHING;
+
+-- Назначение, приоритет и срок решения жалобы.
+-- ESCALATED_AT — когда просроченная жалоба передана менеджерам.
+ALTER TABLE COMPLAINTS
+    ADD COLUMN IF NOT EXISTS CATEGORY_CODE VARCHAR(16) NOT NULL DEFAULT 'other' REFERENCES COMPLAINT_CATEGORIES (CODE),
+    ADD COLUMN IF NOT EXISTS PRIORITY      VARCHAR(8)  NOT NULL DEFAULT 'normal'
+        CHECK (PRIORITY IN ('low', 'normal', 'high', 'urgent')),
+    ADD COLUMN IF NOT EXISTS ASSIGNED_TO   INT REFERENCES USERS (ID),
+    ADD COLUMN IF NOT EXISTS SLA_DUE_AT    TIMESTAMP,
+    ADD COLUMN IF NOT EXISTS RESOLVED_AT   TIMESTAMP,
+    ADD COLUMN IF NOT EXISTS ESCALATED_AT  TIMESTAMP;
+
+-- у старых жалоб срок считается от даты подачи по категории «Прочее»
+UPDATE COMPLAINTS
+SET SLA_DUE_AT = ISSUE_DATE + INTERVAL '24 hours'
+WHERE SLA_DUE_AT IS NULL;
+
+-- время решения старых жалоб неизвестно, RESOLVED_AT у них остаётся пустым
+
+ALTER TABLE COMPLAINTS
+    ALTER COLUMN SLA_DUE_AT SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS IDX_COMPLAINTS_SLA ON COMPLAINTS (SLA_DUE_AT) WHERE RESOLVED_AT IS NULL;
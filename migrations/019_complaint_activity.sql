@@ -0,0 +1,16 @@
+-- Журнал работы с жалобой: комментарии сотрудников, смены статуса, назначения и правки.
+-- Записи только добавляются; CHANGES хранит изменённые поля в виде {"поле": {"from": ..., "to": ...}}.
+-- REDACTED_AT — когда текст записи удалён по запросу гостя на удаление данных.
+CREATE TABLE IF NOT EXISTS COMPLAINT_ACTIVITY (
+    ID           SERIAL PRIMARY KEY,
+    COMPLAINT_ID INT         NOT NULL REFERENCES COMPLAINTS (ID),
+    KIND         VARCHAR(16) NOT NULL
+        CHECK (KIND IN ('created', 'comment', 'status', 'assignment', 'edit', 'escalation')),
+    BODY         TEXT,
+    CHANGES      JSONB       NOT NULL DEFAULT '{}',
+    USER_ID      INT REFERENCES USERS (ID),
+    CREATED_AT   TIMESTAMP   NOT NULL DEFAULT NOW(),
+    REDACTED_AT  TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS IDX_COMPLAINT_ACTIVITY_COMPLAINT ON COMPLAINT_ACTIVITY (COMPLAINT_ID, CREATED_AT);
@@ -0,0 +1,10 @@
+-- Жалоба может относиться к бронированию, номеру, гостю или ни к чему
+-- (например, жалоба посетителя ресторана без проживания).
+-- У жалоб по бронированию номер и гость по-прежнему берутся из бронирования, если не указаны явно.
+ALTER TABLE COMPLAINTS
+    ALTER COLUMN BOOKING_ID DROP NOT NULL,
+    ADD COLUMN IF NOT EXISTS ROOM     INT REFERENCES ROOMS (NUMBER),
+    ADD COLUMN IF NOT EXISTS GUEST_ID INT REFERENCES GUESTS (ID);
+
+CREATE INDEX IF NOT EXISTS IDX_COMPLAINTS_CATEGORY ON COMPLAINTS (CATEGORY_CODE);
+CREATE INDEX IF NOT EXISTS IDX_COMPLAINTS_GUEST ON COMPLAINTS (GUEST_ID);
@@ -0,0 +1,27 @@
+-- Компенсации по жалобам: скидка на фолио, бесплатная ночь или возврат денег.
+-- Скидка и бесплатная ночь уменьшают долг по бронированию, возврат выплачивается способом оплаты METHOD_CODE.
+INSERT INTO ACCOUNTS (CODE, NAME, TYPE)
+VALUES ('complaint_compensation', 'Компенсации по жалобам', 'expense')
+ON CONFLICT (CODE) DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS COMPLAINT_COMPENSATIONS (
+    ID           SERIAL PRIMARY KEY,
+    COMPLAINT_ID INT            NOT NULL REFERENCES COMPLAINTS (ID),
+    BOOKING_ID   INT REFERENCES BOOKINGS (ID),
+    KIND         VARCHAR(16)    NOT NULL CHECK (KIND IN ('folio_credit', 'free_night', 'refund')),
+    AMOUNT       NUMERIC(12, 2) NOT NULL CHECK (AMOUNT > 0),
+    NIGHTS       INT CHECK (NIGHTS > 0),
+    METHOD_CODE  INT REFERENCES PAYMENT_METHODS (CODE),
+    COMMENTARY   TEXT,
+    CREATED_AT   TIMESTAMP      NOT NULL DEFAULT NOW(),
+    CREATED_BY   INT REFERENCES USERS (ID),
+    CHECK ((KIND = 'refund') = (METHOD_CODE IS NOT NULL))
+);
+
+CREATE INDEX IF NOT EXISTS IDX_COMPLAINT_COMPENSATIONS_COMPLAINT ON COMPLAINT_COMPENSATIONS (COMPLAINT_ID);
+CREATE INDEX IF NOT EXISTS IDX_COMPLAINT_COMPENSATIONS_BOOKING ON COMPLAINT_COMPENSATIONS (BOOKING_ID);
+
+ALTER TABLE COMPLAINT_ACTIVITY
+    DROP CONSTRAINT IF EXISTS COMPLAINT_ACTIVITY_KIND_CHECK,
+    ADD CONSTRAINT COMPLAINT_ACTIVITY_KIND_CHECK
+        CHECK (KIND IN ('created', 'comment', 'status', 'assignment', 'edit', 'escalation', 'compensation'));
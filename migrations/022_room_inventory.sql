@@ -0,0 +1,17 @@
+-- Номера и категории не удаляются, а выводятся из продажи: на них ссылаются бронирования и жалобы
+ALTER TABLE ROOMS
+    ADD COLUMN IF NOT EXISTS ACTIVE BOOLEAN NOT NULL DEFAULT TRUE;
+
+ALTER TABLE ROOM_CATEGORIES
+    ADD COLUMN IF NOT EXISTS ACTIVE BOOLEAN NOT NULL DEFAULT TRUE;
+
+-- коды новых категорий и тарифов выдаются последовательностями, начиная после уже занятых
+CREATE SEQUENCE IF NOT EXISTS ROOM_CATEGORIES_CODE_SEQ OWNED BY ROOM_CATEGORIES.CODE;
+SELECT SETVAL('ROOM_CATEGORIES_CODE_SEQ', COALESCE((SELECT MAX(CODE) FROM ROOM_CATEGORIES), 0) + 1, FALSE);
+ALTER TABLE ROOM_CATEGORIES
+    ALTER COLUMN CODE SET DEFAULT NEXTVAL('ROOM_CATEGORIES_CODE_SEQ');
+
+CREATE SEQUENCE IF NOT EXISTS TARIFFS_CODE_SEQ OWNED BY TARIFFS.CODE;
+SELECT SETVAL('TARIFFS_CODE_SEQ', COALESCE((SELECT MAX(CODE) FROM TARIFFS), 0) + 1, FALSE);
+ALTER TABLE TARIFFS
+    ALTER COLUMN CODE SET DEFAULT NEXTVAL('TARIFFS_CODE_SEQ');
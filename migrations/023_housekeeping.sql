@@ -0,0 +1,25 @@
+-- Статус уборки номера хранится отдельно от ROOM_STATES: занятость и ремонт не говорят, чист ли номер.
+-- dirty — требует уборки, cleaning — убирается, clean — убран, inspected — проверен старшей горничной
+ALTER TABLE ROOMS
+    ADD COLUMN IF NOT EXISTS HOUSEKEEPING_STATUS     VARCHAR(16) NOT NULL DEFAULT 'clean'
+        CHECK (HOUSEKEEPING_STATUS IN ('dirty', 'cleaning', 'clean', 'inspected')),
+    ADD COLUMN IF NOT EXISTS HOUSEKEEPING_UPDATED_AT TIMESTAMP,
+    ADD COLUMN IF NOT EXISTS HOUSEKEEPING_UPDATED_BY INT REFERENCES USERS (ID);
+
+-- Ежедневные задания на уборку: после выезда (departure) или в занятом номере (stayover).
+-- На номер в день создаётся одно задание, выезд важнее уборки в занятом номере
+CREATE TABLE IF NOT EXISTS HOUSEKEEPING_TASKS
+(
+    ID           SERIAL PRIMARY KEY,
+    TASK_DATE    DATE        NOT NULL,
+    ROOM         INT         NOT NULL REFERENCES ROOMS (NUMBER),
+    BOOKING_ID   INT REFERENCES BOOKINGS (ID),
+    KIND         VARCHAR(16) NOT NULL CHECK (KIND IN ('departure', 'stayover')),
+    ASSIGNED_TO  INT REFERENCES USERS (ID),
+    CREATED_AT   TIMESTAMP   NOT NULL DEFAULT NOW(),
+    COMPLETED_AT TIMESTAMP,
+    COMPLETED_BY INT REFERENCES USERS (ID),
+    UNIQUE (TASK_DATE, ROOM)
+);
+
+CREATE INDEX IF NOT EXISTS IDX_HOUSEKEEPING_TASKS_ASSIGNED ON HOUSEKEEPING_TASKS (ASSIGNED_TO, TASK_DATE);
@@ -0,0 +1,10 @@
+-- Жалобы не удаляются физически: журнал работы по жалобе неизменяем и остаётся вместе с ней.
+-- Удалённая жалоба скрыта из списков, отчётов и аналитики.
+ALTER TABLE COMPLAINTS
+    ADD COLUMN IF NOT EXISTS DELETED_AT TIMESTAMP,
+    ADD COLUMN IF NOT EXISTS DELETED_BY INT REFERENCES USERS (ID);
+
+ALTER TABLE COMPLAINT_ACTIVITY
+    DROP CONSTRAINT IF EXISTS COMPLAINT_ACTIVITY_KIND_CHECK,
+    ADD CONSTRAINT COMPLAINT_ACTIVITY_KIND_CHECK
+        CHECK (KIND IN ('created', 'comment', 'status', 'assignment', 'edit', 'escalation', 'compensation', 'deleted'));
@@ -0,0 +1,15 @@
+-- Базовая валюта, в которой записаны суммы в БД. До мультивалютности все суммы — бронирований,
+-- тарифов и платежей — велись в рублях, а миграция 003 заполнила исходные суммы старых платежей
+-- как рубли, поэтому база с любыми из этих данных закрепляется за RUB; в пустой базе
+-- валюту закрепляет первый запуск приложения по HOTEL_BASE_CURRENCY.
+CREATE TABLE IF NOT EXISTS BASE_CURRENCY (
+    ID       BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (ID),
+    CURRENCY CHAR(3) NOT NULL
+);
+
+INSERT INTO BASE_CURRENCY (CURRENCY)
+SELECT 'RUB'
+WHERE EXISTS (SELECT 1 FROM BOOKINGS)
+   OR EXISTS (SELECT 1 FROM TARIFFS)
+   OR EXISTS (SELECT 1 FROM PAYMENTS)
+ON CONFLICT (ID) DO NOTHING;
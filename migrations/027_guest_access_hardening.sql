@@ -0,0 +1,10 @@
+-- Неудачные попытки входа гостя; по ним ограничивается перебор фамилий и номеров бронирований
+CREATE TABLE IF NOT EXISTS GUEST_LOGIN_ATTEMPTS (
+    ID         SERIAL PRIMARY KEY,
+    REFERENCE  TEXT      NOT NULL,
+    IP         TEXT      NOT NULL,
+    CREATED_AT TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS IDX_GUEST_LOGIN_ATTEMPTS_REFERENCE ON GUEST_LOGIN_ATTEMPTS (REFERENCE, CREATED_AT);
+CREATE INDEX IF NOT EXISTS IDX_GUEST_LOGIN_ATTEMPTS_IP ON GUEST_LOGIN_ATTEMPTS (IP, CREATED_AT);
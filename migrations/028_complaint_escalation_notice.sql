@@ -0,0 +1,7 @@
+-- Когда менеджерам ушло письмо об эскалации. Пока отметки нет, письмо отправляется повторно
+-- при каждой проверке SLA; уже эскалированные нерешённые жалобы попадут в ближайшее письмо.
+ALTER TABLE COMPLAINTS
+    ADD COLUMN IF NOT EXISTS ESCALATION_NOTIFIED_AT TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS IDX_COMPLAINTS_ESCALATION_PENDING ON COMPLAINTS (ID)
+    WHERE ESCALATED_AT IS NOT NULL AND ESCALATION_NOTIFIED_AT IS NULL;